@@ -3,15 +3,24 @@ package cmd_test
 import (
 	"bytes"
 	"encoding/json"
+	"net/http"
+	"net/http/httptest"
 	"os"
 	"os/exec"
+	"path/filepath"
+	"strings"
 	"testing"
 
 	"github.com/HMetcalfeW/cartographer/cmd"
+	analyze "github.com/HMetcalfeW/cartographer/cmd/analyze"
+	"github.com/HMetcalfeW/cartographer/internal/cmdtest"
 	"github.com/HMetcalfeW/cartographer/pkg/dependency"
 	"github.com/spf13/viper"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
+	"helm.sh/helm/v3/pkg/chart/loader"
+	"helm.sh/helm/v3/pkg/chartutil"
+	"helm.sh/helm/v3/pkg/repo"
 )
 
 // multiResourceYAML provides a realistic manifest with edges for integration tests.
@@ -57,19 +66,6 @@ spec:
     - port: 80
 `
 
-// writeTestInput creates a temp YAML file and returns its path. The caller
-// must defer removal.
-func writeTestInput(t *testing.T, content string) string {
-	t.Helper()
-	f, err := os.CreateTemp("", "analyze-test-*.yaml")
-	require.NoError(t, err)
-	_, err = f.Write([]byte(content))
-	require.NoError(t, err)
-	require.NoError(t, f.Close())
-	t.Cleanup(func() { _ = os.Remove(f.Name()) })
-	return f.Name()
-}
-
 // tempOutputPath returns a path for a temp output file. The caller should
 // defer removal.
 func tempOutputPath(t *testing.T, pattern string) string {
@@ -87,6 +83,7 @@ func graphvizAvailable() bool {
 }
 
 func TestAnalyzeCommand_NoInputOrChart(t *testing.T) {
+	cmdtest.ResetFlags(t, analyze.AnalyzeCmd)
 	root := cmd.RootCmd
 	root.SetArgs([]string{"analyze"})
 
@@ -100,7 +97,8 @@ func TestAnalyzeCommand_NoInputOrChart(t *testing.T) {
 }
 
 func TestAnalyzeCommand_WithInput(t *testing.T) {
-	inputPath := writeTestInput(t, `
+	cmdtest.ResetFlags(t, analyze.AnalyzeCmd)
+	inputPath := cmdtest.WriteInput(t, `
 apiVersion: v1
 kind: Pod
 metadata:
@@ -119,7 +117,8 @@ metadata:
 }
 
 func TestAnalyzeCommand_MermaidStdout(t *testing.T) {
-	inputPath := writeTestInput(t, multiResourceYAML)
+	cmdtest.ResetFlags(t, analyze.AnalyzeCmd)
+	inputPath := cmdtest.WriteInput(t, multiResourceYAML)
 
 	root := cmd.RootCmd
 	root.SetArgs([]string{"analyze", "--input", inputPath, "--output-format", "mermaid"})
@@ -137,7 +136,8 @@ func TestAnalyzeCommand_MermaidStdout(t *testing.T) {
 }
 
 func TestAnalyzeCommand_MermaidFile(t *testing.T) {
-	inputPath := writeTestInput(t, multiResourceYAML)
+	cmdtest.ResetFlags(t, analyze.AnalyzeCmd)
+	inputPath := cmdtest.WriteInput(t, multiResourceYAML)
 	outputPath := tempOutputPath(t, "mermaid-*.md")
 
 	root := cmd.RootCmd
@@ -157,10 +157,11 @@ func TestAnalyzeCommand_MermaidFile(t *testing.T) {
 }
 
 func TestAnalyzeCommand_JSONStdout(t *testing.T) {
-	inputPath := writeTestInput(t, multiResourceYAML)
+	cmdtest.ResetFlags(t, analyze.AnalyzeCmd)
+	inputPath := cmdtest.WriteInput(t, multiResourceYAML)
 
 	root := cmd.RootCmd
-	root.SetArgs([]string{"analyze", "--input", inputPath, "--output-format", "json", "--output-file", ""})
+	root.SetArgs([]string{"analyze", "--input", inputPath, "--output-format", "json"})
 
 	buf := new(bytes.Buffer)
 	root.SetOut(buf)
@@ -181,7 +182,8 @@ func TestAnalyzeCommand_JSONStdout(t *testing.T) {
 }
 
 func TestAnalyzeCommand_JSONFile(t *testing.T) {
-	inputPath := writeTestInput(t, multiResourceYAML)
+	cmdtest.ResetFlags(t, analyze.AnalyzeCmd)
+	inputPath := cmdtest.WriteInput(t, multiResourceYAML)
 	outputPath := tempOutputPath(t, "output-*.json")
 
 	root := cmd.RootCmd
@@ -203,10 +205,11 @@ func TestAnalyzeCommand_JSONFile(t *testing.T) {
 }
 
 func TestAnalyzeCommand_PNGFile(t *testing.T) {
+	cmdtest.ResetFlags(t, analyze.AnalyzeCmd)
 	if !graphvizAvailable() {
 		t.Skip("graphviz not installed, skipping PNG integration test")
 	}
-	inputPath := writeTestInput(t, multiResourceYAML)
+	inputPath := cmdtest.WriteInput(t, multiResourceYAML)
 	outputPath := tempOutputPath(t, "output-*.png")
 
 	root := cmd.RootCmd
@@ -230,10 +233,11 @@ func TestAnalyzeCommand_PNGFile(t *testing.T) {
 }
 
 func TestAnalyzeCommand_SVGFile(t *testing.T) {
+	cmdtest.ResetFlags(t, analyze.AnalyzeCmd)
 	if !graphvizAvailable() {
 		t.Skip("graphviz not installed, skipping SVG integration test")
 	}
-	inputPath := writeTestInput(t, multiResourceYAML)
+	inputPath := cmdtest.WriteInput(t, multiResourceYAML)
 	outputPath := tempOutputPath(t, "output-*.svg")
 
 	root := cmd.RootCmd
@@ -308,10 +312,11 @@ spec:
 `
 
 func TestAnalyzeCommand_MatchExpressions(t *testing.T) {
-	inputPath := writeTestInput(t, matchExpressionsYAML)
+	cmdtest.ResetFlags(t, analyze.AnalyzeCmd)
+	inputPath := cmdtest.WriteInput(t, matchExpressionsYAML)
 
 	root := cmd.RootCmd
-	root.SetArgs([]string{"analyze", "--input", inputPath, "--output-format", "json", "--output-file", ""})
+	root.SetArgs([]string{"analyze", "--input", inputPath, "--output-format", "json"})
 
 	buf := new(bytes.Buffer)
 	root.SetOut(buf)
@@ -345,7 +350,8 @@ func TestAnalyzeCommand_MatchExpressions(t *testing.T) {
 }
 
 func TestAnalyzeCommand_MatchExpressions_MermaidStdout(t *testing.T) {
-	inputPath := writeTestInput(t, matchExpressionsYAML)
+	cmdtest.ResetFlags(t, analyze.AnalyzeCmd)
+	inputPath := cmdtest.WriteInput(t, matchExpressionsYAML)
 
 	root := cmd.RootCmd
 	root.SetArgs([]string{"analyze", "--input", inputPath, "--output-format", "mermaid"})
@@ -367,7 +373,8 @@ func TestAnalyzeCommand_MatchExpressions_MermaidStdout(t *testing.T) {
 }
 
 func TestAnalyzeCommand_MatchExpressions_DOTStdout(t *testing.T) {
-	inputPath := writeTestInput(t, matchExpressionsYAML)
+	cmdtest.ResetFlags(t, analyze.AnalyzeCmd)
+	inputPath := cmdtest.WriteInput(t, matchExpressionsYAML)
 
 	root := cmd.RootCmd
 	root.SetArgs([]string{"analyze", "--input", inputPath, "--output-format", "dot"})
@@ -389,10 +396,11 @@ func TestAnalyzeCommand_MatchExpressions_DOTStdout(t *testing.T) {
 }
 
 func TestAnalyzeCommand_MatchExpressions_PNGFile(t *testing.T) {
+	cmdtest.ResetFlags(t, analyze.AnalyzeCmd)
 	if !graphvizAvailable() {
 		t.Skip("graphviz not installed, skipping PNG integration test")
 	}
-	inputPath := writeTestInput(t, matchExpressionsYAML)
+	inputPath := cmdtest.WriteInput(t, matchExpressionsYAML)
 	outputPath := tempOutputPath(t, "matchexpr-*.png")
 
 	root := cmd.RootCmd
@@ -416,10 +424,11 @@ func TestAnalyzeCommand_MatchExpressions_PNGFile(t *testing.T) {
 }
 
 func TestAnalyzeCommand_MatchExpressions_SVGFile(t *testing.T) {
+	cmdtest.ResetFlags(t, analyze.AnalyzeCmd)
 	if !graphvizAvailable() {
 		t.Skip("graphviz not installed, skipping SVG integration test")
 	}
-	inputPath := writeTestInput(t, matchExpressionsYAML)
+	inputPath := cmdtest.WriteInput(t, matchExpressionsYAML)
 	outputPath := tempOutputPath(t, "matchexpr-*.svg")
 
 	root := cmd.RootCmd
@@ -439,6 +448,7 @@ func TestAnalyzeCommand_MatchExpressions_SVGFile(t *testing.T) {
 }
 
 func TestAnalyzeCommand_BadInputFile(t *testing.T) {
+	cmdtest.ResetFlags(t, analyze.AnalyzeCmd)
 	root := cmd.RootCmd
 	root.SetArgs([]string{"analyze", "--input", "/tmp/nonexistent-file-xyz.yaml"})
 
@@ -452,7 +462,8 @@ func TestAnalyzeCommand_BadInputFile(t *testing.T) {
 }
 
 func TestAnalyzeCommand_UnknownFormat(t *testing.T) {
-	inputPath := writeTestInput(t, multiResourceYAML)
+	cmdtest.ResetFlags(t, analyze.AnalyzeCmd)
+	inputPath := cmdtest.WriteInput(t, multiResourceYAML)
 
 	root := cmd.RootCmd
 	root.SetArgs([]string{"analyze", "--input", inputPath, "--output-format", "invalid-format"})
@@ -467,10 +478,11 @@ func TestAnalyzeCommand_UnknownFormat(t *testing.T) {
 }
 
 func TestAnalyzeCommand_DOTStdout(t *testing.T) {
-	inputPath := writeTestInput(t, multiResourceYAML)
+	cmdtest.ResetFlags(t, analyze.AnalyzeCmd)
+	inputPath := cmdtest.WriteInput(t, multiResourceYAML)
 
 	root := cmd.RootCmd
-	root.SetArgs([]string{"analyze", "--input", inputPath, "--output-format", "dot", "--output-file", ""})
+	root.SetArgs([]string{"analyze", "--input", inputPath, "--output-format", "dot"})
 
 	buf := new(bytes.Buffer)
 	root.SetOut(buf)
@@ -485,7 +497,8 @@ func TestAnalyzeCommand_DOTStdout(t *testing.T) {
 }
 
 func TestAnalyzeCommand_DOTFile(t *testing.T) {
-	inputPath := writeTestInput(t, multiResourceYAML)
+	cmdtest.ResetFlags(t, analyze.AnalyzeCmd)
+	inputPath := cmdtest.WriteInput(t, multiResourceYAML)
 	outputPath := tempOutputPath(t, "dot-*.dot")
 
 	root := cmd.RootCmd
@@ -504,10 +517,11 @@ func TestAnalyzeCommand_DOTFile(t *testing.T) {
 }
 
 func TestAnalyzeCommand_SVGRequiresOutputFile(t *testing.T) {
-	inputPath := writeTestInput(t, multiResourceYAML)
+	cmdtest.ResetFlags(t, analyze.AnalyzeCmd)
+	inputPath := cmdtest.WriteInput(t, multiResourceYAML)
 
 	root := cmd.RootCmd
-	root.SetArgs([]string{"analyze", "--input", inputPath, "--output-format", "svg", "--output-file", ""})
+	root.SetArgs([]string{"analyze", "--input", inputPath, "--output-format", "svg"})
 
 	buf := new(bytes.Buffer)
 	root.SetOut(buf)
@@ -519,10 +533,11 @@ func TestAnalyzeCommand_SVGRequiresOutputFile(t *testing.T) {
 }
 
 func TestAnalyzeCommand_PNGRequiresOutputFile(t *testing.T) {
-	inputPath := writeTestInput(t, multiResourceYAML)
+	cmdtest.ResetFlags(t, analyze.AnalyzeCmd)
+	inputPath := cmdtest.WriteInput(t, multiResourceYAML)
 
 	root := cmd.RootCmd
-	root.SetArgs([]string{"analyze", "--input", inputPath, "--output-format", "png", "--output-file", ""})
+	root.SetArgs([]string{"analyze", "--input", inputPath, "--output-format", "png"})
 
 	buf := new(bytes.Buffer)
 	root.SetOut(buf)
@@ -584,10 +599,11 @@ subjects:
 `
 
 func TestAnalyzeCommand_RBAC_JSON(t *testing.T) {
-	inputPath := writeTestInput(t, rbacYAML)
+	cmdtest.ResetFlags(t, analyze.AnalyzeCmd)
+	inputPath := cmdtest.WriteInput(t, rbacYAML)
 
 	root := cmd.RootCmd
-	root.SetArgs([]string{"analyze", "--input", inputPath, "--output-format", "json", "--output-file", ""})
+	root.SetArgs([]string{"analyze", "--input", inputPath, "--output-format", "json"})
 
 	buf := new(bytes.Buffer)
 	root.SetOut(buf)
@@ -611,10 +627,11 @@ func TestAnalyzeCommand_RBAC_JSON(t *testing.T) {
 }
 
 func TestAnalyzeCommand_RBAC_DOT(t *testing.T) {
-	inputPath := writeTestInput(t, rbacYAML)
+	cmdtest.ResetFlags(t, analyze.AnalyzeCmd)
+	inputPath := cmdtest.WriteInput(t, rbacYAML)
 
 	root := cmd.RootCmd
-	root.SetArgs([]string{"analyze", "--input", inputPath, "--output-format", "dot", "--output-file", ""})
+	root.SetArgs([]string{"analyze", "--input", inputPath, "--output-format", "dot"})
 
 	buf := new(bytes.Buffer)
 	root.SetOut(buf)
@@ -630,10 +647,11 @@ func TestAnalyzeCommand_RBAC_DOT(t *testing.T) {
 }
 
 func TestAnalyzeCommand_RBAC_Mermaid(t *testing.T) {
-	inputPath := writeTestInput(t, rbacYAML)
+	cmdtest.ResetFlags(t, analyze.AnalyzeCmd)
+	inputPath := cmdtest.WriteInput(t, rbacYAML)
 
 	root := cmd.RootCmd
-	root.SetArgs([]string{"analyze", "--input", inputPath, "--output-format", "mermaid", "--output-file", ""})
+	root.SetArgs([]string{"analyze", "--input", inputPath, "--output-format", "mermaid"})
 
 	buf := new(bytes.Buffer)
 	root.SetOut(buf)
@@ -650,10 +668,11 @@ func TestAnalyzeCommand_RBAC_Mermaid(t *testing.T) {
 }
 
 func TestAnalyzeCommand_RBAC_PNG(t *testing.T) {
+	cmdtest.ResetFlags(t, analyze.AnalyzeCmd)
 	if !graphvizAvailable() {
 		t.Skip("graphviz not installed")
 	}
-	inputPath := writeTestInput(t, rbacYAML)
+	inputPath := cmdtest.WriteInput(t, rbacYAML)
 	outputPath := tempOutputPath(t, "rbac-*.png")
 
 	root := cmd.RootCmd
@@ -751,10 +770,11 @@ spec:
 `
 
 func TestAnalyzeCommand_Clustering_JSON(t *testing.T) {
-	inputPath := writeTestInput(t, clusteringYAML)
+	cmdtest.ResetFlags(t, analyze.AnalyzeCmd)
+	inputPath := cmdtest.WriteInput(t, clusteringYAML)
 
 	root := cmd.RootCmd
-	root.SetArgs([]string{"analyze", "--input", inputPath, "--output-format", "json", "--output-file", ""})
+	root.SetArgs([]string{"analyze", "--input", inputPath, "--output-format", "json"})
 
 	buf := new(bytes.Buffer)
 	root.SetOut(buf)
@@ -781,10 +801,11 @@ func TestAnalyzeCommand_Clustering_JSON(t *testing.T) {
 }
 
 func TestAnalyzeCommand_Clustering_DOT(t *testing.T) {
-	inputPath := writeTestInput(t, clusteringYAML)
+	cmdtest.ResetFlags(t, analyze.AnalyzeCmd)
+	inputPath := cmdtest.WriteInput(t, clusteringYAML)
 
 	root := cmd.RootCmd
-	root.SetArgs([]string{"analyze", "--input", inputPath, "--output-format", "dot", "--output-file", ""})
+	root.SetArgs([]string{"analyze", "--input", inputPath, "--output-format", "dot"})
 
 	buf := new(bytes.Buffer)
 	root.SetOut(buf)
@@ -802,10 +823,11 @@ func TestAnalyzeCommand_Clustering_DOT(t *testing.T) {
 }
 
 func TestAnalyzeCommand_Clustering_Mermaid(t *testing.T) {
-	inputPath := writeTestInput(t, clusteringYAML)
+	cmdtest.ResetFlags(t, analyze.AnalyzeCmd)
+	inputPath := cmdtest.WriteInput(t, clusteringYAML)
 
 	root := cmd.RootCmd
-	root.SetArgs([]string{"analyze", "--input", inputPath, "--output-format", "mermaid", "--output-file", ""})
+	root.SetArgs([]string{"analyze", "--input", inputPath, "--output-format", "mermaid"})
 
 	buf := new(bytes.Buffer)
 	root.SetOut(buf)
@@ -824,10 +846,11 @@ func TestAnalyzeCommand_Clustering_Mermaid(t *testing.T) {
 }
 
 func TestAnalyzeCommand_RBAC_SVG(t *testing.T) {
+	cmdtest.ResetFlags(t, analyze.AnalyzeCmd)
 	if !graphvizAvailable() {
 		t.Skip("graphviz not installed")
 	}
-	inputPath := writeTestInput(t, rbacYAML)
+	inputPath := cmdtest.WriteInput(t, rbacYAML)
 	outputPath := tempOutputPath(t, "rbac-*.svg")
 
 	root := cmd.RootCmd
@@ -846,7 +869,8 @@ func TestAnalyzeCommand_RBAC_SVG(t *testing.T) {
 }
 
 func TestAnalyzeCommand_MutualExclusivity(t *testing.T) {
-	inputPath := writeTestInput(t, multiResourceYAML)
+	cmdtest.ResetFlags(t, analyze.AnalyzeCmd)
+	inputPath := cmdtest.WriteInput(t, multiResourceYAML)
 
 	root := cmd.RootCmd
 	root.SetArgs([]string{"analyze", "--input", inputPath, "--cluster"})
@@ -860,11 +884,38 @@ func TestAnalyzeCommand_MutualExclusivity(t *testing.T) {
 	assert.Contains(t, err.Error(), "mutually exclusive")
 }
 
+// TestAnalyzeCommand_ReleaseFromCluster verifies that --release-from-cluster
+// is a source of its own and reads the cluster configured in the config.
+func TestAnalyzeCommand_ReleaseFromCluster(t *testing.T) {
+	cmdtest.ResetFlags(t, analyze.AnalyzeCmd)
+	inputPath := cmdtest.WriteInput(t, multiResourceYAML)
+	t.Cleanup(func() { viper.Set("cluster.kubeconfig", "") })
+
+	root := cmd.RootCmd
+	root.SetArgs([]string{"analyze", "--input", inputPath, "--release-from-cluster", "web"})
+	buf := new(bytes.Buffer)
+	root.SetOut(buf)
+	root.SetErr(buf)
+
+	err := root.Execute()
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "--release-from-cluster, and --cluster are mutually exclusive")
+
+	cmdtest.ResetFlags(t, analyze.AnalyzeCmd)
+	viper.Set("cluster.kubeconfig", filepath.Join(t.TempDir(), "missing-kubeconfig"))
+	root.SetArgs([]string{"analyze", "--release-from-cluster", "web"})
+
+	err = root.Execute()
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "failed to create cluster client")
+}
+
 func TestAnalyzeCommand_AllNamespacesWithoutCluster(t *testing.T) {
-	inputPath := writeTestInput(t, multiResourceYAML)
+	cmdtest.ResetFlags(t, analyze.AnalyzeCmd)
+	inputPath := cmdtest.WriteInput(t, multiResourceYAML)
 
 	root := cmd.RootCmd
-	root.SetArgs([]string{"analyze", "--input", inputPath, "--cluster=false", "-A"})
+	root.SetArgs([]string{"analyze", "--input", inputPath, "-A"})
 
 	buf := new(bytes.Buffer)
 	root.SetOut(buf)
@@ -876,13 +927,14 @@ func TestAnalyzeCommand_AllNamespacesWithoutCluster(t *testing.T) {
 }
 
 func TestAnalyzeCommand_FilterExcludesKinds(t *testing.T) {
-	inputPath := writeTestInput(t, multiResourceYAML)
+	cmdtest.ResetFlags(t, analyze.AnalyzeCmd)
+	inputPath := cmdtest.WriteInput(t, multiResourceYAML)
 
 	viper.Set("exclude.kinds", []string{"Service"})
 	t.Cleanup(func() { viper.Set("exclude.kinds", []string{}) })
 
 	root := cmd.RootCmd
-	root.SetArgs([]string{"analyze", "--input", inputPath, "--cluster=false", "--all-namespaces=false", "--output-format", "json", "--output-file", ""})
+	root.SetArgs([]string{"analyze", "--input", inputPath, "--output-format", "json"})
 
 	buf := new(bytes.Buffer)
 	root.SetOut(buf)
@@ -900,13 +952,14 @@ func TestAnalyzeCommand_FilterExcludesKinds(t *testing.T) {
 }
 
 func TestAnalyzeCommand_FilterExcludesNames(t *testing.T) {
-	inputPath := writeTestInput(t, multiResourceYAML)
+	cmdtest.ResetFlags(t, analyze.AnalyzeCmd)
+	inputPath := cmdtest.WriteInput(t, multiResourceYAML)
 
 	viper.Set("exclude.names", []string{"web-svc"})
 	t.Cleanup(func() { viper.Set("exclude.names", []string{}) })
 
 	root := cmd.RootCmd
-	root.SetArgs([]string{"analyze", "--input", inputPath, "--cluster=false", "--all-namespaces=false", "--output-format", "json", "--output-file", ""})
+	root.SetArgs([]string{"analyze", "--input", inputPath, "--output-format", "json"})
 
 	buf := new(bytes.Buffer)
 	root.SetOut(buf)
@@ -922,3 +975,731 @@ func TestAnalyzeCommand_FilterExcludesNames(t *testing.T) {
 		assert.NotEqual(t, "Service/web-svc", node.ID, "web-svc should be excluded by name filter")
 	}
 }
+
+// certificateYAML is a cert-manager Certificate with an issuer reference.
+const certificateYAML = `
+apiVersion: cert-manager.io/v1
+kind: Certificate
+metadata:
+  name: web-tls
+  namespace: prod
+spec:
+  secretName: web-tls
+  issuerRef:
+    name: letsencrypt
+    kind: ClusterIssuer
+    group: cert-manager.io
+`
+
+func TestAnalyzeCommand_ReferenceRules(t *testing.T) {
+	cmdtest.ResetFlags(t, analyze.AnalyzeCmd)
+	inputPath := cmdtest.WriteInput(t, certificateYAML)
+
+	viper.Set("references", []interface{}{
+		map[string]interface{}{
+			"group":              "cert-manager.io",
+			"kind":               "Certificate",
+			"path":               "spec.issuerRef",
+			"targetKindField":    "kind",
+			"targetGroupField":   "group",
+			"clusterScopedKinds": []interface{}{"ClusterIssuer"},
+			"reason":             "issuerRef",
+		},
+	})
+	t.Cleanup(func() { viper.Set("references", []interface{}{}) })
+
+	root := cmd.RootCmd
+	root.SetArgs([]string{"analyze", "--input", inputPath, "--output-format", "json"})
+
+	buf := new(bytes.Buffer)
+	root.SetOut(buf)
+	root.SetErr(buf)
+
+	err := root.Execute()
+	require.NoError(t, err)
+
+	var graph dependency.JSONGraph
+	require.NoError(t, json.Unmarshal(buf.Bytes(), &graph))
+
+	require.Len(t, graph.Edges, 1)
+	assert.Equal(t, "Certificate.cert-manager.io/prod/web-tls", graph.Edges[0].From)
+	assert.Equal(t, "ClusterIssuer.cert-manager.io/letsencrypt", graph.Edges[0].To)
+	assert.Equal(t, "issuerRef", graph.Edges[0].Reason)
+}
+
+func TestAnalyzeCommand_InvalidReferenceRule(t *testing.T) {
+	cmdtest.ResetFlags(t, analyze.AnalyzeCmd)
+	inputPath := cmdtest.WriteInput(t, certificateYAML)
+
+	viper.Set("references", []interface{}{
+		map[string]interface{}{"kind": "Certificate", "path": "spec.issuerRef"},
+	})
+	t.Cleanup(func() { viper.Set("references", []interface{}{}) })
+
+	root := cmd.RootCmd
+	root.SetArgs([]string{"analyze", "--input", inputPath, "--output-format", "json"})
+
+	buf := new(bytes.Buffer)
+	root.SetOut(buf)
+	root.SetErr(buf)
+
+	err := root.Execute()
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "invalid reference rules in config")
+}
+
+func TestAnalyzeCommand_Focus(t *testing.T) {
+	cmdtest.ResetFlags(t, analyze.AnalyzeCmd)
+	inputPath := cmdtest.WriteInput(t, multiResourceYAML+`
+---
+apiVersion: v1
+kind: ConfigMap
+metadata:
+  name: other
+---
+apiVersion: apps/v1
+kind: Deployment
+metadata:
+  name: other
+spec:
+  template:
+    spec:
+      containers:
+        - name: other
+          image: nginx
+          envFrom:
+            - configMapRef:
+                name: other
+`)
+
+	root := cmd.RootCmd
+	root.SetArgs([]string{"analyze", "--input", inputPath, "--output-format", "mermaid",
+		"--focus", "Deployment/web", "--depth", "1", "--direction", "down"})
+
+	buf := new(bytes.Buffer)
+	root.SetOut(buf)
+	root.SetErr(buf)
+
+	require.NoError(t, root.Execute())
+
+	output := buf.String()
+	assert.Contains(t, output, "Deployment_2fweb --> |secretRef| Secret_2fdb_2dcreds")
+	assert.NotContains(t, output, "web-svc", "--direction down leaves out dependents")
+	assert.NotContains(t, output, "other", "unrelated resources are pruned")
+	assert.Contains(t, output, "class Deployment_2fweb highlight")
+}
+
+func TestAnalyzeCommand_FocusUnknownResource(t *testing.T) {
+	cmdtest.ResetFlags(t, analyze.AnalyzeCmd)
+	inputPath := cmdtest.WriteInput(t, multiResourceYAML)
+
+	root := cmd.RootCmd
+	root.SetArgs([]string{"analyze", "--input", inputPath, "--output-format", "dot", "--focus", "Deployment/missing"})
+
+	buf := new(bytes.Buffer)
+	root.SetOut(buf)
+	root.SetErr(buf)
+
+	err := root.Execute()
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), `invalid --focus: resource "Deployment/missing" not found in the input`)
+}
+
+func TestAnalyzeCommand_InvalidDirection(t *testing.T) {
+	cmdtest.ResetFlags(t, analyze.AnalyzeCmd)
+	inputPath := cmdtest.WriteInput(t, multiResourceYAML)
+
+	root := cmd.RootCmd
+	root.SetArgs([]string{"analyze", "--input", inputPath, "--output-format", "dot",
+		"--focus", "Deployment/web", "--direction", "sideways"})
+
+	buf := new(bytes.Buffer)
+	root.SetOut(buf)
+	root.SetErr(buf)
+
+	err := root.Execute()
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "invalid --direction")
+}
+
+// ownerLoopYAML has two ConfigMaps that own each other.
+const ownerLoopYAML = `
+apiVersion: v1
+kind: ConfigMap
+metadata:
+  name: a
+  ownerReferences:
+    - apiVersion: v1
+      kind: ConfigMap
+      name: b
+      uid: "2"
+---
+apiVersion: v1
+kind: ConfigMap
+metadata:
+  name: b
+  ownerReferences:
+    - apiVersion: v1
+      kind: ConfigMap
+      name: a
+      uid: "1"
+`
+
+func TestAnalyzeCommand_HighlightCycles(t *testing.T) {
+	cmdtest.ResetFlags(t, analyze.AnalyzeCmd)
+	inputPath := cmdtest.WriteInput(t, ownerLoopYAML)
+
+	root := cmd.RootCmd
+	root.SetArgs([]string{"analyze", "--input", inputPath, "--output-format", "dot", "--highlight-cycles"})
+
+	buf := new(bytes.Buffer)
+	root.SetOut(buf)
+	root.SetErr(buf)
+
+	require.NoError(t, root.Execute())
+	assert.Contains(t, buf.String(), `"ConfigMap/a" -> "ConfigMap/b" [label="ownerRef", tooltip="metadata.ownerReferences[0]", color="#1F3A93", fontcolor="#1F3A93", penwidth=3];`)
+}
+
+func TestAnalyzeCommand_JSONCycles(t *testing.T) {
+	cmdtest.ResetFlags(t, analyze.AnalyzeCmd)
+	inputPath := cmdtest.WriteInput(t, ownerLoopYAML)
+
+	root := cmd.RootCmd
+	root.SetArgs([]string{"analyze", "--input", inputPath, "--output-format", "json"})
+
+	buf := new(bytes.Buffer)
+	root.SetOut(buf)
+	root.SetErr(buf)
+
+	require.NoError(t, root.Execute())
+
+	var graph dependency.JSONGraph
+	require.NoError(t, json.Unmarshal(buf.Bytes(), &graph))
+	assert.Equal(t, [][]string{{"ConfigMap/a", "ConfigMap/b"}}, graph.Cycles)
+}
+
+// kubectlDumpYAML mimics `kubectl get deploy,svc -o yaml`: a List whose items
+// carry status and managedFields.
+const kubectlDumpYAML = `
+apiVersion: v1
+kind: List
+items:
+  - apiVersion: apps/v1
+    kind: Deployment
+    metadata:
+      name: web
+      labels:
+        app: web
+      managedFields:
+        - manager: kubectl
+          operation: Apply
+    spec:
+      template:
+        spec:
+          serviceAccountName: web
+          containers:
+            - name: web
+              image: nginx
+    status:
+      readyReplicas: 1
+  - apiVersion: v1
+    kind: Service
+    metadata:
+      name: web
+    spec:
+      selector:
+        app: web
+    status:
+      loadBalancer: {}
+`
+
+func TestAnalyzeCommand_ListStripFields(t *testing.T) {
+	cmdtest.ResetFlags(t, analyze.AnalyzeCmd)
+	inputPath := cmdtest.WriteInput(t, kubectlDumpYAML)
+
+	root := cmd.RootCmd
+	root.SetArgs([]string{"analyze", "--input", inputPath, "--output-format", "json", "--strip-fields"})
+
+	buf := new(bytes.Buffer)
+	root.SetOut(buf)
+	root.SetErr(buf)
+
+	require.NoError(t, root.Execute())
+
+	var graph dependency.JSONGraph
+	require.NoError(t, json.Unmarshal(buf.Bytes(), &graph))
+	var edges []string
+	for _, e := range graph.Edges {
+		edges = append(edges, e.From+" -> "+e.To)
+	}
+	assert.ElementsMatch(t, []string{"Deployment/web -> ServiceAccount/web", "Service/web -> Deployment/web"}, edges)
+}
+
+// malformedYAML has a valid Secret followed by a document with a syntax
+// error on line 9 and a Deployment missing its name.
+const malformedYAML = `apiVersion: v1
+kind: Secret
+metadata:
+  name: db
+---
+apiVersion: v1
+kind: ConfigMap
+metadata:
+  name: [oops
+---
+apiVersion: apps/v1
+kind: Deployment
+metadata: {}
+`
+
+func TestAnalyzeCommand_LenientParsing(t *testing.T) {
+	cmdtest.ResetFlags(t, analyze.AnalyzeCmd)
+	inputPath := cmdtest.WriteInput(t, malformedYAML)
+
+	root := cmd.RootCmd
+	root.SetArgs([]string{"analyze", "--input", inputPath, "--output-format", "json"})
+
+	buf := new(bytes.Buffer)
+	root.SetOut(buf)
+	root.SetErr(buf)
+
+	require.NoError(t, root.Execute())
+
+	var graph dependency.JSONGraph
+	require.NoError(t, json.Unmarshal(buf.Bytes(), &graph))
+	require.Len(t, graph.Nodes, 1)
+	assert.Equal(t, "Secret/db", graph.Nodes[0].ID)
+}
+
+func TestAnalyzeCommand_StrictParsing(t *testing.T) {
+	cmdtest.ResetFlags(t, analyze.AnalyzeCmd)
+	inputPath := cmdtest.WriteInput(t, malformedYAML)
+
+	root := cmd.RootCmd
+	root.SetArgs([]string{"analyze", "--input", inputPath, "--output-format", "json", "--strict"})
+
+	buf := new(bytes.Buffer)
+	root.SetOut(buf)
+	root.SetErr(buf)
+
+	err := root.Execute()
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), inputPath+": document 2, line 9: ")
+}
+
+// TestAnalyzeCommand_DirectoryAndStdin verifies that a directory, a second
+// --input and stdin are merged into one graph, with edges between resources
+// from different files.
+func TestAnalyzeCommand_DirectoryAndStdin(t *testing.T) {
+	cmdtest.ResetFlags(t, analyze.AnalyzeCmd)
+	dir := t.TempDir()
+	require.NoError(t, os.MkdirAll(filepath.Join(dir, "app", "vendor"), 0o755))
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "app", "deploy.yaml"), []byte(`apiVersion: apps/v1
+kind: Deployment
+metadata:
+  name: web
+spec:
+  template:
+    spec:
+      containers:
+        - name: web
+          image: nginx
+          envFrom:
+            - secretRef:
+                name: db
+            - configMapRef:
+                name: web-config
+`), 0o644))
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "app", "vendor", "ignored.yaml"), []byte(`apiVersion: v1
+kind: ConfigMap
+metadata:
+  name: ignored
+`), 0o644))
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "app", "README.md"), []byte("# not a manifest\n"), 0o644))
+	secretPath := filepath.Join(dir, "secret.yaml")
+	require.NoError(t, os.WriteFile(secretPath, []byte(`apiVersion: v1
+kind: Secret
+metadata:
+  name: db
+`), 0o644))
+
+	root := cmd.RootCmd
+	root.SetArgs([]string{"analyze", "--input", filepath.Join(dir, "app"), "-i", secretPath, "-i", "-",
+		"--exclude", "vendor", "--output-format", "json"})
+	root.SetIn(strings.NewReader(`apiVersion: v1
+kind: ConfigMap
+metadata:
+  name: web-config
+`))
+	t.Cleanup(func() { root.SetIn(nil) })
+
+	buf := new(bytes.Buffer)
+	root.SetOut(buf)
+	root.SetErr(buf)
+
+	require.NoError(t, root.Execute())
+
+	var graph dependency.JSONGraph
+	require.NoError(t, json.Unmarshal(buf.Bytes(), &graph))
+	sources := make(map[string]string)
+	for _, n := range graph.Nodes {
+		assert.False(t, n.Missing, n.ID)
+		if n.Source != nil {
+			sources[n.ID] = n.Source.File
+		}
+	}
+	assert.Equal(t, map[string]string{
+		"Deployment/web":       filepath.Join(dir, "app", "deploy.yaml"),
+		"Secret/db":            secretPath,
+		"ConfigMap/web-config": "stdin",
+	}, sources)
+	assert.Len(t, graph.Edges, 2)
+}
+
+func TestAnalyzeCommand_EmptyInputDirectory(t *testing.T) {
+	cmdtest.ResetFlags(t, analyze.AnalyzeCmd)
+	dir := t.TempDir()
+
+	root := cmd.RootCmd
+	root.SetArgs([]string{"analyze", "--input", dir})
+
+	buf := new(bytes.Buffer)
+	root.SetOut(buf)
+	root.SetErr(buf)
+
+	err := root.Execute()
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "no manifest files found in "+dir)
+}
+
+// TestAnalyzeCommand_Kustomize verifies that a kustomization is built and a
+// reference to a generated ConfigMap resolves to its hash-suffixed name.
+func TestAnalyzeCommand_Kustomize(t *testing.T) {
+	cmdtest.ResetFlags(t, analyze.AnalyzeCmd)
+	dir := t.TempDir()
+	base := filepath.Join(dir, "base")
+	overlay := filepath.Join(dir, "overlays", "prod")
+	require.NoError(t, os.MkdirAll(base, 0o755))
+	require.NoError(t, os.MkdirAll(overlay, 0o755))
+	require.NoError(t, os.WriteFile(filepath.Join(base, "kustomization.yaml"), []byte(`resources:
+  - deployment.yaml
+configMapGenerator:
+  - name: web-config
+    literals:
+      - LOG_LEVEL=info
+`), 0o644))
+	require.NoError(t, os.WriteFile(filepath.Join(base, "deployment.yaml"), []byte(`apiVersion: apps/v1
+kind: Deployment
+metadata:
+  name: web
+spec:
+  template:
+    spec:
+      containers:
+        - name: web
+          image: nginx
+          envFrom:
+            - configMapRef:
+                name: web-config
+`), 0o644))
+	require.NoError(t, os.WriteFile(filepath.Join(overlay, "kustomization.yaml"), []byte(`namespace: prod
+resources:
+  - ../../base
+`), 0o644))
+
+	root := cmd.RootCmd
+	root.SetArgs([]string{"analyze", "--kustomize", overlay, "--output-format", "json"})
+
+	buf := new(bytes.Buffer)
+	root.SetOut(buf)
+	root.SetErr(buf)
+
+	require.NoError(t, root.Execute())
+
+	var graph dependency.JSONGraph
+	require.NoError(t, json.Unmarshal(buf.Bytes(), &graph))
+	require.Len(t, graph.Edges, 1)
+	assert.Equal(t, "Deployment/prod/web", graph.Edges[0].From)
+	assert.True(t, strings.HasPrefix(graph.Edges[0].To, "ConfigMap/prod/web-config-"), graph.Edges[0].To)
+	for _, n := range graph.Nodes {
+		assert.False(t, n.Missing, n.ID)
+	}
+}
+
+// TestAnalyzeCommand_KustomizeLoadRestrictor verifies that --load-restrictor
+// is validated and that remote bases fail instead of being fetched.
+func TestAnalyzeCommand_KustomizeLoadRestrictor(t *testing.T) {
+	cmdtest.ResetFlags(t, analyze.AnalyzeCmd)
+	dir := t.TempDir()
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "kustomization.yaml"),
+		[]byte("resources:\n  - https://github.com/org/repo//deploy?ref=v1\n"), 0o644))
+
+	run := func(args ...string) error {
+		root := cmd.RootCmd
+		root.SetArgs(append([]string{"analyze", "--kustomize", dir}, args...))
+		buf := new(bytes.Buffer)
+		root.SetOut(buf)
+		root.SetErr(buf)
+		return root.Execute()
+	}
+
+	err := run("--load-restrictor", "none")
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "invalid --load-restrictor")
+
+	err = run("--load-restrictor", "LoadRestrictionsNone")
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), `references remote "https://github.com/org/repo//deploy?ref=v1"`)
+}
+
+// TestAnalyzeCommand_ChartValues verifies that repeated --values files and
+// --set overrides reach the chart, with --set taking precedence.
+func TestAnalyzeCommand_ChartValues(t *testing.T) {
+	cmdtest.ResetFlags(t, analyze.AnalyzeCmd)
+	chartDir := t.TempDir()
+	require.NoError(t, os.WriteFile(filepath.Join(chartDir, "Chart.yaml"), []byte("apiVersion: v2\nname: app\nversion: 0.1.0\n"), 0o644))
+	require.NoError(t, os.Mkdir(filepath.Join(chartDir, "templates"), 0o755))
+	require.NoError(t, os.WriteFile(filepath.Join(chartDir, "templates", "deploy.yaml"), []byte(`apiVersion: apps/v1
+kind: Deployment
+metadata:
+  name: {{ .Values.name }}
+spec:
+  template:
+    spec:
+      containers:
+        - name: app
+          image: nginx
+          envFrom:
+            - secretRef:
+                name: {{ .Values.secret }}
+`), 0o644))
+	valuesDir := t.TempDir()
+	base := filepath.Join(valuesDir, "base.yaml")
+	prod := filepath.Join(valuesDir, "prod.yaml")
+	require.NoError(t, os.WriteFile(base, []byte("name: web\nsecret: base-secret\n"), 0o644))
+	require.NoError(t, os.WriteFile(prod, []byte("secret: prod-secret\n"), 0o644))
+
+	root := cmd.RootCmd
+	root.SetArgs([]string{"analyze", "--chart", chartDir, "-v", base, "-v", prod, "--set", "name=api",
+		"--output-format", "json"})
+
+	buf := new(bytes.Buffer)
+	root.SetOut(buf)
+	root.SetErr(buf)
+
+	require.NoError(t, root.Execute())
+
+	var graph dependency.JSONGraph
+	require.NoError(t, json.Unmarshal(buf.Bytes(), &graph))
+	require.Len(t, graph.Edges, 1)
+	assert.Equal(t, "Deployment/api", graph.Edges[0].From)
+	assert.Equal(t, "Secret/prod-secret", graph.Edges[0].To)
+}
+
+// TestAnalyzeCommand_ChartHooks verifies that chart hooks become hook nodes in
+// JSON output and that --skip-tests drops test hooks.
+func TestAnalyzeCommand_ChartHooks(t *testing.T) {
+	cmdtest.ResetFlags(t, analyze.AnalyzeCmd)
+	chartDir := t.TempDir()
+	require.NoError(t, os.WriteFile(filepath.Join(chartDir, "Chart.yaml"), []byte("apiVersion: v2\nname: app\nversion: 0.1.0\n"), 0o644))
+	require.NoError(t, os.Mkdir(filepath.Join(chartDir, "templates"), 0o755))
+	require.NoError(t, os.WriteFile(filepath.Join(chartDir, "templates", "hooks.yaml"), []byte(`apiVersion: batch/v1
+kind: Job
+metadata:
+  name: migrate
+  annotations:
+    helm.sh/hook: pre-install
+    helm.sh/hook-weight: "-5"
+spec:
+  template:
+    spec:
+      containers:
+        - name: migrate
+          image: migrate
+          envFrom:
+            - secretRef:
+                name: db
+---
+apiVersion: batch/v1
+kind: Job
+metadata:
+  name: test-db
+  annotations:
+    helm.sh/hook: test
+spec:
+  template:
+    spec:
+      containers:
+        - name: test
+          image: busybox
+          envFrom:
+            - secretRef:
+                name: db
+`), 0o644))
+
+	root := cmd.RootCmd
+	root.SetArgs([]string{"analyze", "--chart", chartDir, "--skip-tests",
+		"--output-format", "json"})
+
+	buf := new(bytes.Buffer)
+	root.SetOut(buf)
+	root.SetErr(buf)
+
+	require.NoError(t, root.Execute())
+
+	var graph dependency.JSONGraph
+	require.NoError(t, json.Unmarshal(buf.Bytes(), &graph))
+	hooks := make(map[string]*dependency.Hook)
+	for _, n := range graph.Nodes {
+		hooks[n.ID] = n.Hook
+	}
+	assert.NotContains(t, hooks, "Job/test-db")
+	require.Contains(t, hooks, "Job/migrate")
+	require.NotNil(t, hooks["Job/migrate"])
+	assert.Equal(t, dependency.Hook{Events: []string{"pre-install"}, Weight: -5}, *hooks["Job/migrate"])
+}
+
+// TestAnalyzeCommand_ChartGrouping verifies that --group-by-chart boxes the
+// resources of a chart and of its subchart separately in DOT output.
+func TestAnalyzeCommand_ChartGrouping(t *testing.T) {
+	cmdtest.ResetFlags(t, analyze.AnalyzeCmd)
+	chartDir := t.TempDir()
+	subDir := filepath.Join(chartDir, "charts", "postgresql")
+	require.NoError(t, os.MkdirAll(filepath.Join(chartDir, "templates"), 0o755))
+	require.NoError(t, os.MkdirAll(filepath.Join(subDir, "templates"), 0o755))
+	require.NoError(t, os.WriteFile(filepath.Join(chartDir, "Chart.yaml"), []byte("apiVersion: v2\nname: platform\nversion: 0.1.0\n"), 0o644))
+	require.NoError(t, os.WriteFile(filepath.Join(subDir, "Chart.yaml"), []byte("apiVersion: v2\nname: postgresql\nversion: 1.0.0\n"), 0o644))
+	require.NoError(t, os.WriteFile(filepath.Join(chartDir, "templates", "deployment.yaml"), []byte(`apiVersion: apps/v1
+kind: Deployment
+metadata:
+  name: web
+spec:
+  template:
+    spec:
+      containers:
+        - name: web
+          image: web
+          envFrom:
+            - secretRef:
+                name: db
+`), 0o644))
+	require.NoError(t, os.WriteFile(filepath.Join(subDir, "templates", "secret.yaml"), []byte(`apiVersion: v1
+kind: Secret
+metadata:
+  name: db
+`), 0o644))
+
+	root := cmd.RootCmd
+	root.SetArgs([]string{"analyze", "--chart", chartDir, "--group-by-chart",
+		"--output-format", "dot"})
+
+	buf := new(bytes.Buffer)
+	root.SetOut(buf)
+	root.SetErr(buf)
+
+	require.NoError(t, root.Execute())
+
+	dot := buf.String()
+	assert.Contains(t, dot, "subgraph \"cluster_platform\" {\n    label=\"platform\";\n    style=\"rounded,dashed\";\n    \"Deployment/web\" [")
+	assert.Contains(t, dot, "subgraph \"cluster_platform/postgresql\" {\n    label=\"platform/postgresql\";\n    style=\"rounded,dashed\";\n    \"Secret/db\" [")
+	assert.Contains(t, dot, "\"Deployment/web\" -> \"Secret/db\"")
+}
+
+// TestAnalyzeCommand_ChartCapabilities verifies that --kube-version and
+// --api-versions reach the chart's templates through .Capabilities.
+func TestAnalyzeCommand_ChartCapabilities(t *testing.T) {
+	cmdtest.ResetFlags(t, analyze.AnalyzeCmd)
+	chartDir := t.TempDir()
+	require.NoError(t, os.WriteFile(filepath.Join(chartDir, "Chart.yaml"), []byte("apiVersion: v2\nname: app\nversion: 0.1.0\n"), 0o644))
+	require.NoError(t, os.Mkdir(filepath.Join(chartDir, "templates"), 0o755))
+	require.NoError(t, os.WriteFile(filepath.Join(chartDir, "templates", "deployment.yaml"), []byte(`apiVersion: apps/v1
+kind: Deployment
+metadata:
+  name: web
+spec:
+  template:
+    spec:
+      containers:
+        - name: web
+          image: web
+          envFrom:
+            - secretRef:
+                name: db-{{ .Capabilities.KubeVersion.Minor }}
+{{- if .Capabilities.APIVersions.Has "secrets-store.csi.x-k8s.io/v1" }}
+            - secretRef:
+                name: csi-synced
+{{- end }}
+`), 0o644))
+
+	root := cmd.RootCmd
+	root.SetArgs([]string{"analyze", "--chart", chartDir, "--kube-version", "1.31.2",
+		"--api-versions", "secrets-store.csi.x-k8s.io/v1",
+		"--output-format", "dot"})
+
+	buf := new(bytes.Buffer)
+	root.SetOut(buf)
+	root.SetErr(buf)
+
+	require.NoError(t, root.Execute())
+	assert.Contains(t, buf.String(), `"Deployment/web" -> "Secret/db-31"`)
+	assert.Contains(t, buf.String(), `"Deployment/web" -> "Secret/csi-synced"`)
+
+	root.SetArgs([]string{"analyze", "--chart", chartDir, "--kube-version", "latest",
+		"--output-format", "dot"})
+	err := root.Execute()
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "invalid --kube-version")
+}
+
+// TestAnalyzeCommand_ChartRepo verifies that --repo finds --chart by name in
+// a chart repository served over HTTP, without adding the repository.
+func TestAnalyzeCommand_ChartRepo(t *testing.T) {
+	cmdtest.ResetFlags(t, analyze.AnalyzeCmd)
+	home := t.TempDir()
+	t.Setenv("HELM_CONFIG_HOME", filepath.Join(home, "config"))
+	t.Setenv("HELM_CACHE_HOME", filepath.Join(home, "cache"))
+
+	chartDir := t.TempDir()
+	require.NoError(t, os.WriteFile(filepath.Join(chartDir, "Chart.yaml"), []byte("apiVersion: v2\nname: app\nversion: 1.2.0\n"), 0o644))
+	require.NoError(t, os.Mkdir(filepath.Join(chartDir, "templates"), 0o755))
+	require.NoError(t, os.WriteFile(filepath.Join(chartDir, "templates", "deployment.yaml"), []byte(`apiVersion: apps/v1
+kind: Deployment
+metadata:
+  name: web
+spec:
+  template:
+    spec:
+      serviceAccountName: web
+      containers:
+        - name: web
+          image: web
+`), 0o644))
+	ch, err := loader.LoadDir(chartDir)
+	require.NoError(t, err)
+	repoDir := t.TempDir()
+	_, err = chartutil.Save(ch, repoDir)
+	require.NoError(t, err)
+	srv := httptest.NewServer(http.FileServer(http.Dir(repoDir)))
+	t.Cleanup(srv.Close)
+	index, err := repo.IndexDirectory(repoDir, srv.URL)
+	require.NoError(t, err)
+	require.NoError(t, index.WriteFile(filepath.Join(repoDir, "index.yaml"), 0o644))
+
+	root := cmd.RootCmd
+	root.SetArgs([]string{"analyze", "--chart", "app", "--repo", srv.URL,
+		"--output-format", "dot"})
+
+	buf := new(bytes.Buffer)
+	root.SetOut(buf)
+	root.SetErr(buf)
+
+	require.NoError(t, root.Execute())
+	assert.Contains(t, buf.String(), `"Deployment/web" -> "ServiceAccount/web"`)
+
+	inputPath := cmdtest.WriteInput(t, multiResourceYAML)
+	require.NoError(t, analyze.AnalyzeCmd.Flags().Set("chart", ""))
+	root.SetArgs([]string{"analyze", "--input", inputPath, "--repo", srv.URL})
+	err = root.Execute()
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "--repo can only be used with --chart")
+}
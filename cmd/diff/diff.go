@@ -0,0 +1,69 @@
+package cmd
+
+import (
+	log "github.com/sirupsen/logrus"
+	"github.com/spf13/cobra"
+
+	"github.com/HMetcalfeW/cartographer/cmd/input"
+	"github.com/HMetcalfeW/cartographer/cmd/output"
+	"github.com/HMetcalfeW/cartographer/pkg/dependency"
+)
+
+// DiffCmd represents the diff subcommand.
+var DiffCmd = &cobra.Command{
+	Use:   "diff",
+	Short: "Compare the dependency graphs of two inputs",
+	Long: `Diff builds a dependency graph from each of two inputs and reports the nodes
+and edges that were added or removed, and the edges whose reason changed.
+
+Each side takes the same flags as analyze, prefixed with --from- or --to-:
+a file (--from-input), a chart with values (--from-chart, --from-values, ...),
+a kustomization (--from-kustomize), an installed Helm release
+(--from-release-from-cluster) or a live cluster (--from-cluster,
+--from-namespace, ...). For example:
+
+  cartographer diff --from-chart ./chart --to-chart ./chart --to-values prod.yaml
+
+dot, mermaid, png and svg output colors added resources green, removed ones
+red and unchanged ones grey; json lists only the changes.`,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		outputFormat, _ := cmd.Flags().GetString("output-format")
+		outputFile, _ := cmd.Flags().GetString("output-file")
+
+		registry, err := input.Registry()
+		if err != nil {
+			return err
+		}
+
+		fromObjs, fromOrigins, err := input.LoadSource(cmd, "from-")
+		if err != nil {
+			return err
+		}
+		toObjs, toOrigins, err := input.LoadSource(cmd, "to-")
+		if err != nil {
+			return err
+		}
+
+		from := dependency.BuildDependenciesWith(fromObjs, registry)
+		to := dependency.BuildDependenciesWith(toObjs, registry)
+		from.SetOrigins(fromOrigins)
+		to.SetOrigins(toOrigins)
+		diff := dependency.Diff(from, to)
+
+		log.WithFields(log.Fields{
+			"func":    "diff",
+			"from":    from.Len(),
+			"to":      to.Len(),
+			"changed": diff.Changed(),
+		}).Info("Graph diff complete")
+
+		return output.WriteDiff(cmd, diff, outputFormat, outputFile)
+	},
+}
+
+func init() {
+	input.AddSourceFlags(DiffCmd, "from-")
+	input.AddSourceFlags(DiffCmd, "to-")
+	DiffCmd.Flags().String("output-format", "dot", "Output format: dot, mermaid, json, png, svg (default: dot)")
+	DiffCmd.Flags().String("output-file", "", "Output file path (required for png/svg formats)")
+}
@@ -0,0 +1,96 @@
+package cmd_test
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/HMetcalfeW/cartographer/internal/cmdtest"
+	"github.com/HMetcalfeW/cartographer/pkg/dependency"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+const beforeYAML = `
+apiVersion: apps/v1
+kind: Deployment
+metadata:
+  name: web
+spec:
+  template:
+    spec:
+      containers:
+        - name: web
+          image: nginx
+          envFrom:
+            - configMapRef:
+                name: web-config
+---
+apiVersion: v1
+kind: ConfigMap
+metadata:
+  name: web-config
+`
+
+const afterYAML = `
+apiVersion: apps/v1
+kind: Deployment
+metadata:
+  name: web
+spec:
+  template:
+    spec:
+      containers:
+        - name: web
+          image: nginx
+          envFrom:
+            - secretRef:
+                name: web-secrets
+---
+apiVersion: v1
+kind: Secret
+metadata:
+  name: web-secrets
+`
+
+func TestDiffCommand_JSON(t *testing.T) {
+	from := cmdtest.WriteInput(t, beforeYAML)
+	to := cmdtest.WriteInput(t, afterYAML)
+
+	out, err := cmdtest.Run(t, "diff", "--from-input", from, "--to-input", to, "--output-format", "json")
+	require.NoError(t, err)
+
+	var changes dependency.JSONDiff
+	require.NoError(t, json.Unmarshal([]byte(out), &changes))
+	assert.Equal(t, []string{"Secret/web-secrets"}, changes.AddedNodes)
+	assert.Equal(t, []string{"ConfigMap/web-config"}, changes.RemovedNodes)
+	assert.Equal(t, []dependency.JSONEdge{{From: "Deployment/web", To: "Secret/web-secrets", Reason: "secretRef"}}, changes.AddedEdges)
+	assert.Equal(t, []dependency.JSONEdge{{From: "Deployment/web", To: "ConfigMap/web-config", Reason: "configMapRef"}}, changes.RemovedEdges)
+}
+
+func TestDiffCommand_Mermaid(t *testing.T) {
+	from := cmdtest.WriteInput(t, beforeYAML)
+	to := cmdtest.WriteInput(t, afterYAML)
+
+	out, err := cmdtest.Run(t, "diff", "--from-input", from, "--to-input", to, "--output-format", "mermaid")
+	require.NoError(t, err)
+	assert.Contains(t, out, "class Secret_2fweb_2dsecrets added")
+	assert.Contains(t, out, "class ConfigMap_2fweb_2dconfig removed")
+	assert.Contains(t, out, "class Deployment_2fweb unchanged")
+}
+
+func TestDiffCommand_MissingSource(t *testing.T) {
+	from := cmdtest.WriteInput(t, beforeYAML)
+
+	_, err := cmdtest.Run(t, "diff", "--from-input", from, "--output-format", "json")
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "no input source provided; specify --to-input, --to-chart, --to-kustomize, --to-release-from-cluster, or --to-cluster")
+}
+
+func TestDiffCommand_SVGRequiresOutputFile(t *testing.T) {
+	from := cmdtest.WriteInput(t, beforeYAML)
+	to := cmdtest.WriteInput(t, afterYAML)
+
+	_, err := cmdtest.Run(t, "diff", "--from-input", from, "--to-input", to, "--output-format", "svg")
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "--output-file is required for svg format")
+}
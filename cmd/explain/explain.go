@@ -0,0 +1,246 @@
+package cmd
+
+import (
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strings"
+
+	log "github.com/sirupsen/logrus"
+	"github.com/spf13/cobra"
+
+	"github.com/HMetcalfeW/cartographer/cmd/input"
+	"github.com/HMetcalfeW/cartographer/cmd/output"
+	"github.com/HMetcalfeW/cartographer/pkg/dependency"
+	"github.com/HMetcalfeW/cartographer/pkg/parser"
+)
+
+// ExplainCmd represents the explain subcommand.
+var ExplainCmd = &cobra.Command{
+	Use:   "explain <Kind/name>",
+	Short: "Explain every edge of a single resource",
+	Long: `Explain loads resources the same way as analyze (--input, --chart, --kustomize,
+--release-from-cluster or --cluster) and prints everything the dependency graph
+knows about one resource:
+
+  - its outgoing and incoming edges, grouped by reason, with the field that
+    produced each edge
+  - the resources its selector matches and the selectors that match it, with
+    the label keys that caused each match
+  - the references it makes to resources missing from the input
+
+The target is a node ID such as Deployment/web, Deployment/prod/web or
+Certificate.cert-manager.io/prod/web-tls. The namespace may be omitted when
+only one namespace has a matching resource.`,
+	Args: cobra.ExactArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		outputFormat, _ := cmd.Flags().GetString("output-format")
+		outputFile, _ := cmd.Flags().GetString("output-file")
+		if outputFormat != "text" && outputFormat != "json" {
+			return fmt.Errorf("unsupported output format %q; use text or json", outputFormat)
+		}
+
+		registry, err := input.Registry()
+		if err != nil {
+			return err
+		}
+
+		objs, origins, err := input.Load(cmd)
+		if err != nil {
+			return err
+		}
+
+		graph := dependency.BuildDependenciesWith(objs, registry)
+		graph.SetOrigins(origins)
+		target, err := graph.ResolveOne(args[0])
+		if err != nil {
+			return err
+		}
+		ex, _ := dependency.Explain(graph, target)
+
+		log.WithFields(log.Fields{
+			"func":       "explain",
+			"target":     target,
+			"unresolved": len(ex.Unresolved),
+		}).Info("Explain complete")
+
+		if outputFormat == "json" {
+			return writeJSON(cmd, ex, outputFile)
+		}
+		return output.WriteText(cmd, formatText(ex), outputFile, "text")
+	},
+}
+
+// formatText renders the explanation as indented sections. Empty sections
+// print "none" so the absence of an edge is explicit.
+func formatText(ex dependency.Explanation) string {
+	var b strings.Builder
+	n := ex.Node
+	fmt.Fprintf(&b, "%s\n", n.ID)
+	if n.Missing() {
+		b.WriteString("  not present in the input; only known from references to it\n")
+	} else {
+		fmt.Fprintf(&b, "  apiVersion: %s\n", n.APIVersion())
+		if !n.Source.IsZero() {
+			fmt.Fprintf(&b, "  source: %s\n", n.Source)
+		}
+		if len(n.Labels) > 0 {
+			fmt.Fprintf(&b, "  labels: %s\n", formatLabels(n.Labels))
+		}
+	}
+
+	writeGroups(&b, "Outgoing edges", ex.Outgoing, func(e dependency.Edge) string { return "-> " + e.ChildID })
+	writeGroups(&b, "Incoming edges", ex.Incoming, func(e dependency.Edge) string { return "<- " + e.ParentID })
+	writeMatches(&b, "Selects", ex.Selects, func(e dependency.Edge) string { return e.ChildID })
+	writeMatches(&b, "Selected by", ex.SelectedBy, func(e dependency.Edge) string { return e.ParentID })
+
+	b.WriteString("\nUnresolved references:\n")
+	if len(ex.Unresolved) == 0 {
+		b.WriteString("  none\n")
+	}
+	for _, e := range ex.Unresolved {
+		fmt.Fprintf(&b, "  %s -> %s%s\n", e.Reason, e.ChildID, formatField(e.Field))
+	}
+	return strings.TrimSuffix(b.String(), "\n")
+}
+
+func writeGroups(b *strings.Builder, title string, groups []dependency.EdgeGroup, other func(dependency.Edge) string) {
+	count := 0
+	for _, g := range groups {
+		count += len(g.Edges)
+	}
+	fmt.Fprintf(b, "\n%s (%d):\n", title, count)
+	if count == 0 {
+		b.WriteString("  none\n")
+	}
+	for _, g := range groups {
+		fmt.Fprintf(b, "  %s:\n", g.Reason)
+		for _, e := range g.Edges {
+			fmt.Fprintf(b, "    %s%s\n", other(e), formatField(e.Field))
+		}
+	}
+}
+
+func writeMatches(b *strings.Builder, title string, matches []dependency.SelectorMatch, other func(dependency.Edge) string) {
+	fmt.Fprintf(b, "\n%s (%d):\n", title, len(matches))
+	if len(matches) == 0 {
+		b.WriteString("  none\n")
+	}
+	for _, m := range matches {
+		fmt.Fprintf(b, "  %s via %s, matched on %s:\n", other(m.Edge), m.Edge.Reason, strings.Join(m.Keys(), ", "))
+		for _, t := range m.Terms {
+			fmt.Fprintf(b, "    %s\n", t)
+		}
+	}
+}
+
+func formatField(field string) string {
+	if field == "" {
+		return ""
+	}
+	return " (" + field + ")"
+}
+
+func formatLabels(labels map[string]string) string {
+	pairs := make([]string, 0, len(labels))
+	for k, v := range labels {
+		pairs = append(pairs, k+"="+v)
+	}
+	sort.Strings(pairs)
+	return strings.Join(pairs, ", ")
+}
+
+// jsonEdge is the JSON form of an edge in the explanation.
+type jsonEdge struct {
+	From   string `json:"from"`
+	To     string `json:"to"`
+	Reason string `json:"reason"`
+	Field  string `json:"field,omitempty"`
+}
+
+// jsonTerm is the JSON form of a selector term; Value is omitted when the
+// selected resource does not carry the label.
+type jsonTerm struct {
+	Key         string  `json:"key"`
+	Requirement string  `json:"requirement"`
+	Value       *string `json:"value,omitempty"`
+}
+
+// jsonMatch is the JSON form of a selector match.
+type jsonMatch struct {
+	jsonEdge
+	Keys  []string   `json:"keys"`
+	Terms []jsonTerm `json:"terms"`
+}
+
+// writeJSON prints the explanation as an indented JSON object. Edge groups
+// become objects keyed by reason.
+func writeJSON(cmd *cobra.Command, ex dependency.Explanation, outputFile string) error {
+	report := struct {
+		ID         string                `json:"id"`
+		Missing    bool                  `json:"missing,omitempty"`
+		Source     *parser.Source        `json:"source,omitempty"`
+		Outgoing   map[string][]jsonEdge `json:"outgoing"`
+		Incoming   map[string][]jsonEdge `json:"incoming"`
+		Selects    []jsonMatch           `json:"selects"`
+		SelectedBy []jsonMatch           `json:"selectedBy"`
+		Unresolved []jsonEdge            `json:"unresolved"`
+	}{
+		ID:         ex.Node.ID,
+		Missing:    ex.Node.Missing(),
+		Outgoing:   jsonGroups(ex.Outgoing),
+		Incoming:   jsonGroups(ex.Incoming),
+		Selects:    jsonMatches(ex.Selects),
+		SelectedBy: jsonMatches(ex.SelectedBy),
+		Unresolved: []jsonEdge{},
+	}
+	if !ex.Node.Source.IsZero() {
+		report.Source = &ex.Node.Source
+	}
+	for _, e := range ex.Unresolved {
+		report.Unresolved = append(report.Unresolved, toJSONEdge(e))
+	}
+
+	data, err := json.MarshalIndent(report, "", "  ")
+	if err != nil {
+		return fmt.Errorf("failed to encode explanation: %w", err)
+	}
+	return output.WriteText(cmd, string(data), outputFile, "JSON")
+}
+
+func toJSONEdge(e dependency.Edge) jsonEdge {
+	return jsonEdge{From: e.ParentID, To: e.ChildID, Reason: e.Reason, Field: e.Field}
+}
+
+func jsonGroups(groups []dependency.EdgeGroup) map[string][]jsonEdge {
+	out := make(map[string][]jsonEdge, len(groups))
+	for _, g := range groups {
+		for _, e := range g.Edges {
+			out[g.Reason] = append(out[g.Reason], toJSONEdge(e))
+		}
+	}
+	return out
+}
+
+func jsonMatches(matches []dependency.SelectorMatch) []jsonMatch {
+	out := []jsonMatch{}
+	for _, m := range matches {
+		jm := jsonMatch{jsonEdge: toJSONEdge(m.Edge), Keys: m.Keys(), Terms: []jsonTerm{}}
+		for _, t := range m.Terms {
+			term := jsonTerm{Key: t.Key, Requirement: t.Requirement}
+			if t.Present {
+				value := t.Value
+				term.Value = &value
+			}
+			jm.Terms = append(jm.Terms, term)
+		}
+		out = append(out, jm)
+	}
+	return out
+}
+
+func init() {
+	input.AddFlags(ExplainCmd)
+	ExplainCmd.Flags().String("output-format", "text", "Output format: text, json")
+	ExplainCmd.Flags().String("output-file", "", "Output file path (default: stdout)")
+}
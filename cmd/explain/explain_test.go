@@ -0,0 +1,131 @@
+package cmd_test
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/HMetcalfeW/cartographer/internal/cmdtest"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// explainYAML has a Deployment selected by a Service and a NetworkPolicy,
+// reading a Secret that is missing from the input.
+const explainYAML = `
+apiVersion: apps/v1
+kind: Deployment
+metadata:
+  name: web
+  labels:
+    app: web
+    tier: frontend
+spec:
+  template:
+    spec:
+      containers:
+        - name: web
+          image: nginx
+          envFrom:
+            - secretRef:
+                name: db-creds
+---
+apiVersion: v1
+kind: Service
+metadata:
+  name: web
+spec:
+  selector:
+    app: web
+---
+apiVersion: networking.k8s.io/v1
+kind: NetworkPolicy
+metadata:
+  name: frontend
+spec:
+  podSelector:
+    matchExpressions:
+      - key: tier
+        operator: In
+        values: [frontend]
+`
+
+func TestExplainCommand_Text(t *testing.T) {
+	inputPath := cmdtest.WriteInput(t, explainYAML)
+
+	out, err := cmdtest.Run(t, "explain", "Deployment/web", "--input", inputPath, "--output-format", "text")
+	require.NoError(t, err)
+	assert.Contains(t, out, "Deployment/web\n  apiVersion: apps/v1\n  source: "+inputPath+":2\n  labels: app=web, tier=frontend")
+	assert.Contains(t, out, "Outgoing edges (1):\n  secretRef:\n    -> Secret/db-creds (spec.template.spec.containers[0].envFrom[0].secretRef)")
+	assert.Contains(t, out, "Incoming edges (2):\n  podSelector:\n    <- NetworkPolicy/frontend (spec.podSelector)\n  selector:\n    <- Service/web (spec.selector)")
+	assert.Contains(t, out, "Selects (0):\n  none")
+	assert.Contains(t, out, "NetworkPolicy/frontend via podSelector, matched on tier:\n    tier in (frontend): tier=frontend")
+	assert.Contains(t, out, "Service/web via selector, matched on app:\n    app=web: app=web")
+	assert.Contains(t, out, "Unresolved references:\n  secretRef -> Secret/db-creds")
+}
+
+func TestExplainCommand_JSON(t *testing.T) {
+	inputPath := cmdtest.WriteInput(t, explainYAML)
+
+	out, err := cmdtest.Run(t, "explain", "Service/web", "--input", inputPath, "--output-format", "json")
+	require.NoError(t, err)
+
+	var result struct {
+		ID     string `json:"id"`
+		Source struct {
+			File string `json:"file"`
+			Line int    `json:"line"`
+		} `json:"source"`
+		Outgoing map[string][]struct {
+			To    string `json:"to"`
+			Field string `json:"field"`
+		} `json:"outgoing"`
+		Selects []struct {
+			To    string   `json:"to"`
+			Keys  []string `json:"keys"`
+			Terms []struct {
+				Requirement string  `json:"requirement"`
+				Value       *string `json:"value"`
+			} `json:"terms"`
+		} `json:"selects"`
+		Unresolved []any `json:"unresolved"`
+	}
+	require.NoError(t, json.Unmarshal([]byte(out), &result))
+	assert.Equal(t, "Service/web", result.ID)
+	assert.Equal(t, inputPath, result.Source.File)
+	assert.Equal(t, 19, result.Source.Line)
+	require.Len(t, result.Outgoing["selector"], 1)
+	assert.Equal(t, "spec.selector", result.Outgoing["selector"][0].Field)
+	require.Len(t, result.Selects, 1)
+	assert.Equal(t, "Deployment/web", result.Selects[0].To)
+	assert.Equal(t, []string{"app"}, result.Selects[0].Keys)
+	require.Len(t, result.Selects[0].Terms, 1)
+	require.NotNil(t, result.Selects[0].Terms[0].Value)
+	assert.Equal(t, "web", *result.Selects[0].Terms[0].Value)
+	assert.NotNil(t, result.Unresolved)
+	assert.Empty(t, result.Unresolved)
+}
+
+func TestExplainCommand_MissingTarget(t *testing.T) {
+	inputPath := cmdtest.WriteInput(t, explainYAML)
+
+	out, err := cmdtest.Run(t, "explain", "Secret/db-creds", "--input", inputPath, "--output-format", "text")
+	require.NoError(t, err)
+	assert.Contains(t, out, "not present in the input")
+	assert.Contains(t, out, "<- Deployment/web")
+}
+
+func TestExplainCommand_UnknownTarget(t *testing.T) {
+	inputPath := cmdtest.WriteInput(t, explainYAML)
+
+	_, err := cmdtest.Run(t, "explain", "ConfigMap/nope", "--input", inputPath, "--output-format", "text")
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), `resource "ConfigMap/nope" not found in the input`)
+}
+
+func TestExplainCommand_BadFormat(t *testing.T) {
+	inputPath := cmdtest.WriteInput(t, explainYAML)
+
+	_, err := cmdtest.Run(t, "explain", "Deployment/web", "--input", inputPath, "--output-format", "dot")
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), `unsupported output format "dot"`)
+}
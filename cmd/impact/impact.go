@@ -0,0 +1,141 @@
+package cmd
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+
+	log "github.com/sirupsen/logrus"
+	"github.com/spf13/cobra"
+
+	"github.com/HMetcalfeW/cartographer/cmd/input"
+	"github.com/HMetcalfeW/cartographer/cmd/output"
+	"github.com/HMetcalfeW/cartographer/pkg/dependency"
+)
+
+// ImpactCmd represents the impact subcommand.
+var ImpactCmd = &cobra.Command{
+	Use:   "impact <Kind/name>",
+	Short: "List every resource that depends on a given resource",
+	Long: `Impact loads resources the same way as analyze (--input, --chart, --kustomize,
+--release-from-cluster or --cluster) and walks the dependency graph backwards
+from the target, listing every resource that directly or transitively depends
+on it with its depth and the chain of references leading to the target. Use it
+before rotating a Secret or deleting a ConfigMap to see what will break.
+
+The target is a node ID such as Secret/db-creds, Secret/prod/db-creds or
+Certificate.cert-manager.io/prod/web-tls. The namespace may be omitted when
+only one namespace has a matching resource.
+
+The text and json formats list the impacted resources; dot, mermaid, png and
+svg render the impacted subgraph with the target highlighted.`,
+	Args: cobra.ExactArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		outputFormat, _ := cmd.Flags().GetString("output-format")
+		outputFile, _ := cmd.Flags().GetString("output-file")
+		maxDepth, _ := cmd.Flags().GetInt("max-depth")
+		if maxDepth < 0 {
+			return fmt.Errorf("--max-depth must not be negative, got %d", maxDepth)
+		}
+
+		registry, err := input.Registry()
+		if err != nil {
+			return err
+		}
+
+		objs, origins, err := input.Load(cmd)
+		if err != nil {
+			return err
+		}
+
+		graph := dependency.BuildDependenciesWith(objs, registry)
+		graph.SetOrigins(origins)
+		target, err := graph.ResolveOne(args[0])
+		if err != nil {
+			return err
+		}
+		impacted := dependency.Impact(graph, target, maxDepth)
+
+		log.WithFields(log.Fields{
+			"func":     "impact",
+			"target":   target,
+			"impacted": len(impacted),
+		}).Info("Impact analysis complete")
+
+		switch outputFormat {
+		case "text":
+			return output.WriteText(cmd, formatText(target, impacted), outputFile, "text")
+		case "json":
+			return writeJSON(cmd, target, impacted, outputFile)
+		default:
+			return output.Write(cmd, dependency.ImpactGraph(graph, target, impacted), outputFormat, outputFile)
+		}
+	},
+}
+
+// formatText renders one line per impacted resource with its depth and the
+// chain of references down to the target, followed by a count.
+func formatText(target string, impacted []dependency.ImpactedResource) string {
+	var b strings.Builder
+	fmt.Fprintf(&b, "Resources depending on %s:\n", target)
+	for _, r := range impacted {
+		fmt.Fprintf(&b, "  [%d] %s: %s\n", r.Depth, r.ID, formatChain(r.Chain))
+	}
+	fmt.Fprintf(&b, "%d impacted resource(s)", len(impacted))
+	return b.String()
+}
+
+// formatChain renders a chain as "A -[reason]-> B -[reason]-> target".
+func formatChain(chain []dependency.Edge) string {
+	var b strings.Builder
+	for i, e := range chain {
+		if i == 0 {
+			b.WriteString(e.ParentID)
+		}
+		fmt.Fprintf(&b, " -[%s]-> %s", e.Reason, e.ChildID)
+	}
+	return b.String()
+}
+
+// jsonImpacted is the JSON form of an impacted resource. Path lists the node
+// IDs from the resource to the target; Reasons has one entry per hop.
+type jsonImpacted struct {
+	ID      string   `json:"id"`
+	Depth   int      `json:"depth"`
+	Path    []string `json:"path"`
+	Reasons []string `json:"reasons"`
+}
+
+// writeJSON prints the report as an indented JSON object.
+func writeJSON(cmd *cobra.Command, target string, impacted []dependency.ImpactedResource, outputFile string) error {
+	report := struct {
+		Target   string         `json:"target"`
+		Impacted []jsonImpacted `json:"impacted"`
+	}{Target: target, Impacted: []jsonImpacted{}}
+
+	for _, r := range impacted {
+		path := []string{r.ID}
+		for _, e := range r.Chain {
+			path = append(path, e.ChildID)
+		}
+		report.Impacted = append(report.Impacted, jsonImpacted{
+			ID:      r.ID,
+			Depth:   r.Depth,
+			Path:    path,
+			Reasons: r.Reasons(),
+		})
+	}
+
+	data, err := json.MarshalIndent(report, "", "  ")
+	if err != nil {
+		return fmt.Errorf("failed to encode report: %w", err)
+	}
+	return output.WriteText(cmd, string(data), outputFile, "JSON")
+}
+
+func init() {
+	input.AddFlags(ImpactCmd)
+	ImpactCmd.Flags().Int("max-depth", 0, "Maximum number of hops to follow from the target (0 = unlimited)")
+	ImpactCmd.Flags().String("output-format", "text", "Output format: text, json, dot, mermaid, png, svg")
+	ImpactCmd.Flags().String("output-file", "", "Output file path (required for png/svg formats)")
+}
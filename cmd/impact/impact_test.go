@@ -0,0 +1,154 @@
+package cmd_test
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/HMetcalfeW/cartographer/internal/cmdtest"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// impactYAML has a Service selecting a Deployment that reads Secret db-creds,
+// plus a CronJob that reads the same Secret.
+const impactYAML = `
+apiVersion: apps/v1
+kind: Deployment
+metadata:
+  name: web
+  labels:
+    app: web
+spec:
+  selector:
+    matchLabels:
+      app: web
+  template:
+    metadata:
+      labels:
+        app: web
+    spec:
+      containers:
+        - name: web
+          image: nginx
+          envFrom:
+            - secretRef:
+                name: db-creds
+---
+apiVersion: v1
+kind: Service
+metadata:
+  name: web
+spec:
+  selector:
+    app: web
+---
+apiVersion: batch/v1
+kind: CronJob
+metadata:
+  name: backup
+spec:
+  schedule: "@daily"
+  jobTemplate:
+    spec:
+      template:
+        spec:
+          containers:
+            - name: backup
+              image: backup
+              envFrom:
+                - secretRef:
+                    name: db-creds
+---
+apiVersion: v1
+kind: Secret
+metadata:
+  name: db-creds
+`
+
+func TestImpactCommand_Text(t *testing.T) {
+	inputPath := cmdtest.WriteInput(t, impactYAML)
+
+	out, err := cmdtest.Run(t, "impact", "Secret/db-creds", "--input", inputPath, "--output-format", "text")
+	require.NoError(t, err)
+	assert.Contains(t, out, "Resources depending on Secret/db-creds:")
+	assert.Contains(t, out, "[1] CronJob/backup: CronJob/backup -[secretRef]-> Secret/db-creds")
+	assert.Contains(t, out, "[2] Service/web: Service/web -[selector]-> Deployment/web -[secretRef]-> Secret/db-creds")
+	assert.Contains(t, out, "3 impacted resource(s)")
+}
+
+func TestImpactCommand_MaxDepth(t *testing.T) {
+	inputPath := cmdtest.WriteInput(t, impactYAML)
+
+	out, err := cmdtest.Run(t, "impact", "Secret/db-creds", "--input", inputPath, "--output-format", "text", "--max-depth", "1")
+	require.NoError(t, err)
+	assert.NotContains(t, out, "Service/web")
+	assert.Contains(t, out, "2 impacted resource(s)")
+}
+
+func TestImpactCommand_JSON(t *testing.T) {
+	inputPath := cmdtest.WriteInput(t, impactYAML)
+
+	out, err := cmdtest.Run(t, "impact", "Secret/db-creds", "--input", inputPath, "--output-format", "json")
+	require.NoError(t, err)
+
+	var result struct {
+		Target   string `json:"target"`
+		Impacted []struct {
+			ID      string   `json:"id"`
+			Depth   int      `json:"depth"`
+			Path    []string `json:"path"`
+			Reasons []string `json:"reasons"`
+		} `json:"impacted"`
+	}
+	require.NoError(t, json.Unmarshal([]byte(out), &result))
+	assert.Equal(t, "Secret/db-creds", result.Target)
+	require.Len(t, result.Impacted, 3)
+	last := result.Impacted[2]
+	assert.Equal(t, "Service/web", last.ID)
+	assert.Equal(t, 2, last.Depth)
+	assert.Equal(t, []string{"Service/web", "Deployment/web", "Secret/db-creds"}, last.Path)
+	assert.Equal(t, []string{"selector", "secretRef"}, last.Reasons)
+}
+
+func TestImpactCommand_DOTFile(t *testing.T) {
+	inputPath := cmdtest.WriteInput(t, impactYAML)
+	outPath := filepath.Join(t.TempDir(), "impact.dot")
+
+	_, err := cmdtest.Run(t, "impact", "Secret/db-creds", "--input", inputPath, "--output-format", "dot", "--output-file", outPath)
+	require.NoError(t, err)
+
+	data, err := os.ReadFile(outPath)
+	require.NoError(t, err)
+	assert.Contains(t, string(data), `"Service/web" -> "Deployment/web"`)
+	assert.Contains(t, string(data), "penwidth=3")
+}
+
+func TestImpactCommand_UnknownTarget(t *testing.T) {
+	inputPath := cmdtest.WriteInput(t, impactYAML)
+
+	_, err := cmdtest.Run(t, "impact", "Secret/nope", "--input", inputPath, "--output-format", "text")
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), `resource "Secret/nope" not found in the input`)
+}
+
+func TestImpactCommand_AmbiguousTarget(t *testing.T) {
+	inputPath := cmdtest.WriteInput(t, `
+apiVersion: v1
+kind: Secret
+metadata:
+  name: db
+  namespace: prod
+---
+apiVersion: v1
+kind: Secret
+metadata:
+  name: db
+  namespace: staging
+`)
+
+	_, err := cmdtest.Run(t, "impact", "Secret/db", "--input", inputPath, "--output-format", "text")
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "specify one of: Secret/prod/db, Secret/staging/db")
+}
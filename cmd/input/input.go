@@ -0,0 +1,432 @@
+// Package input holds the input pipeline shared by every subcommand that
+// reads Kubernetes resources: the --input/--chart/--kustomize/
+// --release-from-cluster/--cluster flags, loading and parsing, config-driven
+// exclusion filters, and the handler registry extended with config-driven
+// reference rules.
+package input
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+
+	log "github.com/sirupsen/logrus"
+	"github.com/spf13/cobra"
+	"github.com/spf13/viper"
+	"helm.sh/helm/v3/pkg/chartutil"
+	"helm.sh/helm/v3/pkg/cli/values"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"sigs.k8s.io/kustomize/api/types"
+
+	"github.com/HMetcalfeW/cartographer/pkg/cluster"
+	"github.com/HMetcalfeW/cartographer/pkg/dependency"
+	"github.com/HMetcalfeW/cartographer/pkg/filter"
+	"github.com/HMetcalfeW/cartographer/pkg/helm"
+	"github.com/HMetcalfeW/cartographer/pkg/kustomize"
+	"github.com/HMetcalfeW/cartographer/pkg/manifests"
+	"github.com/HMetcalfeW/cartographer/pkg/parser"
+)
+
+// DefaultNamespace is used for Helm rendering and cluster scope when
+// --namespace is not given.
+const DefaultNamespace = "default"
+
+// AddFlags registers the input flags on cmd.
+func AddFlags(cmd *cobra.Command) {
+	AddSourceFlags(cmd, "")
+}
+
+// AddSourceFlags registers the input flags on cmd with every name prefixed,
+// e.g. "from-" gives --from-input, --from-chart, --from-cluster and so on, so
+// one command can read several sources. Only unprefixed flags get shorthands.
+func AddSourceFlags(cmd *cobra.Command, prefix string) {
+	short := func(s string) string {
+		if prefix != "" {
+			return ""
+		}
+		return s
+	}
+	cmd.Flags().StringSliceP(prefix+"input", short("i"), nil, "Kubernetes YAML file, directory, glob, or - for stdin (repeatable)")
+	cmd.Flags().StringSlice(prefix+"include", nil, "File name globs read from --"+prefix+"input directories (default *.yaml, *.yml, *.json)")
+	cmd.Flags().StringSlice(prefix+"exclude", nil, "File or directory name globs skipped in --"+prefix+"input directories, e.g. vendor or 'tests/*'")
+	cmd.Flags().StringP(prefix+"chart", short("c"), "", "Chart reference, local path, file:// archive or chart URL of a Helm chart (e.g. bitnami/postgres)")
+	cmd.Flags().String(prefix+"repo", "", "Chart repository URL to find --"+prefix+"chart in by name, like helm template --repo")
+	cmd.Flags().StringP(prefix+"kustomize", short("k"), "", "Directory containing a kustomization to build, e.g. overlays/prod")
+	cmd.Flags().String(prefix+"load-restrictor", types.LoadRestrictionsRootOnly.String(), "Which files --"+prefix+"kustomize may load: LoadRestrictionsRootOnly (only under the kustomization directory) or LoadRestrictionsNone (anywhere)")
+	cmd.Flags().Bool(prefix+"cluster", false, "Analyze resources from a live Kubernetes cluster")
+	cmd.Flags().String(prefix+"release-from-cluster", "", "Name of an installed Helm release whose stored manifest to read from the cluster, in --"+prefix+"namespace")
+	cmd.Flags().BoolP(prefix+"all-namespaces", short("A"), false, "Fetch resources from all namespaces (requires --"+prefix+"cluster)")
+	cmd.Flags().StringSliceP(prefix+"values", short("v"), nil, "Values file for the Helm chart (repeatable; later files take precedence)")
+	cmd.Flags().StringArray(prefix+"set", nil, "Set a chart value, e.g. image.tag=v2 (repeatable)")
+	cmd.Flags().StringArray(prefix+"set-string", nil, "Set a chart value as a string, e.g. version=1.10 (repeatable)")
+	cmd.Flags().StringArray(prefix+"set-file", nil, "Set a chart value from the contents of a file, e.g. config=app.conf (repeatable)")
+	cmd.Flags().StringArray(prefix+"set-json", nil, `Set a chart value to JSON, e.g. 'resources={"limits":{"cpu":"1"}}' (repeatable)`)
+	cmd.Flags().StringArray(prefix+"set-literal", nil, "Set a chart value to a literal string without parsing commas or escapes (repeatable)")
+	cmd.Flags().String(prefix+"hooks", string(helm.HooksTag), "How to render Helm hooks: tag (keep and mark as hooks), include (keep as ordinary resources) or exclude")
+	cmd.Flags().Bool(prefix+"skip-tests", false, "Drop Helm test hooks, like helm template --skip-tests")
+	cmd.Flags().Bool(prefix+"include-crds", false, "Include the CRDs in the chart's crds/ directories, like helm template --include-crds")
+	cmd.Flags().String(prefix+"kube-version", "", "Kubernetes version for .Capabilities.KubeVersion when rendering the Helm chart, e.g. 1.31")
+	cmd.Flags().StringSlice(prefix+"api-versions", nil, "Extra API versions for .Capabilities.APIVersions when rendering the Helm chart, e.g. monitoring.coreos.com/v1 (repeatable)")
+	cmd.Flags().Bool(prefix+"cluster-capabilities", false, "Read the Kubernetes and API versions for rendering the Helm chart from the configured cluster")
+	cmd.Flags().StringP(prefix+"release", short("l"), "cartographer-release", "Release name for the Helm chart")
+	cmd.Flags().String(prefix+"version", "", "Chart version to pull (optional if remote charts specify a version)")
+	cmd.Flags().String(prefix+"namespace", "", "Namespace to inject into the Helm rendered release or cluster scope")
+	cmd.Flags().Bool(prefix+"strict", false, "Fail on malformed documents or resources missing kind, apiVersion or metadata.name instead of skipping them with a warning")
+	cmd.Flags().Bool(prefix+"strip-fields", false, "Drop status and metadata.managedFields from each resource as it is loaded to reduce memory use")
+}
+
+// LoadOptions adjusts how LoadWith filters the loaded resources.
+type LoadOptions struct {
+	// KeepExcludedKinds ignores exclude.kinds, for commands whose results
+	// would be wrong without every object, such as the unused report, which
+	// must see the bare Pods that use a ConfigMap. exclude.names still
+	// applies.
+	KeepExcludedKinds bool
+}
+
+// Load validates the input flags registered by AddFlags, loads resources from
+// the selected source, and applies the config-driven exclusion filters. It
+// also returns where each resource was read from, for Graph.SetOrigins.
+func Load(cmd *cobra.Command) ([]*unstructured.Unstructured, dependency.Origins, error) {
+	return LoadSource(cmd, "")
+}
+
+// LoadWith is Load with the filtering adjusted by lo.
+func LoadWith(cmd *cobra.Command, lo LoadOptions) ([]*unstructured.Unstructured, dependency.Origins, error) {
+	return loadSource(cmd, "", lo)
+}
+
+// LoadSource is Load for the flags registered by AddSourceFlags with the same
+// prefix.
+func LoadSource(cmd *cobra.Command, prefix string) ([]*unstructured.Unstructured, dependency.Origins, error) {
+	return loadSource(cmd, prefix, LoadOptions{})
+}
+
+func loadSource(cmd *cobra.Command, prefix string, lo LoadOptions) ([]*unstructured.Unstructured, dependency.Origins, error) {
+	inputPaths, _ := cmd.Flags().GetStringSlice(prefix + "input")
+	include, _ := cmd.Flags().GetStringSlice(prefix + "include")
+	excludeFiles, _ := cmd.Flags().GetStringSlice(prefix + "exclude")
+	chartPath, _ := cmd.Flags().GetString(prefix + "chart")
+	repoURL, _ := cmd.Flags().GetString(prefix + "repo")
+	kustomizeDir, _ := cmd.Flags().GetString(prefix + "kustomize")
+	loadRestrictor, _ := cmd.Flags().GetString(prefix + "load-restrictor")
+	clusterMode, _ := cmd.Flags().GetBool(prefix + "cluster")
+	installedRelease, _ := cmd.Flags().GetString(prefix + "release-from-cluster")
+	allNamespaces, _ := cmd.Flags().GetBool(prefix + "all-namespaces")
+	valueFiles, _ := cmd.Flags().GetStringSlice(prefix + "values")
+	setValues, _ := cmd.Flags().GetStringArray(prefix + "set")
+	setStrings, _ := cmd.Flags().GetStringArray(prefix + "set-string")
+	setFiles, _ := cmd.Flags().GetStringArray(prefix + "set-file")
+	setJSON, _ := cmd.Flags().GetStringArray(prefix + "set-json")
+	setLiterals, _ := cmd.Flags().GetStringArray(prefix + "set-literal")
+	hooks, _ := cmd.Flags().GetString(prefix + "hooks")
+	skipTests, _ := cmd.Flags().GetBool(prefix + "skip-tests")
+	includeCRDs, _ := cmd.Flags().GetBool(prefix + "include-crds")
+	kubeVersion, _ := cmd.Flags().GetString(prefix + "kube-version")
+	apiVersions, _ := cmd.Flags().GetStringSlice(prefix + "api-versions")
+	clusterCapabilities, _ := cmd.Flags().GetBool(prefix + "cluster-capabilities")
+	version, _ := cmd.Flags().GetString(prefix + "version")
+	namespace, _ := cmd.Flags().GetString(prefix + "namespace")
+	releaseName, _ := cmd.Flags().GetString(prefix + "release")
+	stripFields, _ := cmd.Flags().GetBool(prefix + "strip-fields")
+	strict, _ := cmd.Flags().GetBool(prefix + "strict")
+
+	// Validate mutual exclusivity of input sources.
+	sources := 0
+	if len(inputPaths) > 0 {
+		sources++
+	}
+	if chartPath != "" {
+		sources++
+	}
+	if kustomizeDir != "" {
+		sources++
+	}
+	if installedRelease != "" {
+		sources++
+	}
+	if clusterMode {
+		sources++
+	}
+	if sources == 0 {
+		return nil, nil, fmt.Errorf("no input source provided; specify --%[1]sinput, --%[1]schart, --%[1]skustomize, --%[1]srelease-from-cluster, or --%[1]scluster", prefix)
+	}
+	if sources > 1 {
+		return nil, nil, fmt.Errorf("--%[1]sinput, --%[1]schart, --%[1]skustomize, --%[1]srelease-from-cluster, and --%[1]scluster are mutually exclusive", prefix)
+	}
+
+	hookMode, err := helm.ParseHookMode(hooks)
+	if err != nil {
+		return nil, nil, fmt.Errorf("invalid --%shooks: %w", prefix, err)
+	}
+	loadRestrictions, err := kustomize.ParseLoadRestrictor(loadRestrictor)
+	if err != nil {
+		return nil, nil, fmt.Errorf("invalid --%sload-restrictor: %w", prefix, err)
+	}
+
+	// -A only valid with --cluster.
+	if allNamespaces && !clusterMode {
+		return nil, nil, fmt.Errorf("--%sall-namespaces can only be used with --%scluster", prefix, prefix)
+	}
+	if repoURL != "" && chartPath == "" {
+		return nil, nil, fmt.Errorf("--%srepo can only be used with --%schart", prefix, prefix)
+	}
+
+	if namespace == "" {
+		namespace = DefaultNamespace
+	}
+
+	// Determine input source label for logging.
+	source := "file"
+	if chartPath != "" {
+		source = "chart"
+	} else if kustomizeDir != "" {
+		source = "kustomize"
+	} else if installedRelease != "" {
+		source = "release"
+	} else if clusterMode {
+		source = "cluster"
+	}
+	logger := log.WithFields(log.Fields{
+		"func":      "input.Load",
+		"prefix":    prefix,
+		"source":    source,
+		"namespace": namespace,
+	})
+
+	// Objects are filtered as they are decoded, so excluded kinds (Pods and
+	// ReplicaSets by default) are never held in memory.
+	excludeKinds := viper.GetStringSlice("exclude.kinds")
+	if lo.KeepExcludedKinds {
+		excludeKinds = nil
+	}
+	exclude := filter.New(excludeKinds, viper.GetStringSlice("exclude.names"))
+	opts := parser.Options{StripFields: stripFields, Strict: strict}
+	loaded := 0
+	var objs []*unstructured.Unstructured
+	origins := dependency.Origins{}
+	record := func(obj *unstructured.Unstructured, origin dependency.Origin) error {
+		loaded++
+		if !exclude.Excludes(obj) {
+			objs = append(objs, obj)
+			if !origin.IsZero() {
+				origins[obj] = origin
+			}
+		}
+		return nil
+	}
+	keep := func(obj *unstructured.Unstructured, src parser.Source) error {
+		return record(obj, dependency.Origin{Source: src})
+	}
+	// keepRendered is keep for output rendered in memory, by kustomize or
+	// Helm, whose line numbers point into no file.
+	keepRendered := func(obj *unstructured.Unstructured, src parser.Source) error {
+		src.Line = 0
+		return keep(obj, src)
+	}
+	// keepHooks is keepRendered for Helm output, marking the tagged hooks.
+	keepHooks := func(hooks helm.Hooks) func(*unstructured.Unstructured, parser.Source) error {
+		return func(obj *unstructured.Unstructured, src parser.Source) error {
+			src.Line = 0
+			origin := dependency.Origin{Source: src}
+			if h, ok := hooks.Lookup(src.Template, obj); ok {
+				origin.Hook = &dependency.Hook{Events: h.Events, Weight: h.Weight}
+			}
+			return record(obj, origin)
+		}
+	}
+
+	switch {
+	case clusterMode:
+		kubeconfigPath := viper.GetString("cluster.kubeconfig")
+		contextName := viper.GetString("cluster.context")
+
+		client, err := cluster.NewClient(kubeconfigPath, contextName)
+		if err != nil {
+			return nil, nil, fmt.Errorf("failed to create cluster client: %w", err)
+		}
+
+		fetched, unreadable, err := cluster.FetchResources(context.Background(), client, namespace, allNamespaces)
+		if err != nil {
+			return nil, nil, fmt.Errorf("failed to fetch cluster resources: %w", err)
+		}
+		for _, obj := range fetched {
+			if opts.StripFields {
+				parser.StripFields(obj)
+			}
+			var origin dependency.Origin
+			if role, ok := unreadable[obj]; ok {
+				origin.Unreadable = []string{dependency.RefID("rbac.authorization.k8s.io", "ClusterRole", "", role)}
+			}
+			_ = record(obj, origin)
+		}
+
+	case chartPath != "":
+		log.WithFields(log.Fields{
+			"func":  "input.Load",
+			"chart": chartPath,
+		}).Debug("Rendering Helm chart")
+		caps, err := capabilities(prefix, kubeVersion, apiVersions, clusterCapabilities)
+		if err != nil {
+			return nil, nil, err
+		}
+		rendered, hooks, err := helm.RenderChart(chartPath, helm.RenderOptions{
+			ReleaseName: releaseName,
+			Namespace:   namespace,
+			Version:     version,
+			RepoURL:     repoURL,
+			Values: values.Options{
+				ValueFiles:    valueFiles,
+				Values:        setValues,
+				StringValues:  setStrings,
+				FileValues:    setFiles,
+				JSONValues:    setJSON,
+				LiteralValues: setLiterals,
+			},
+			Hooks:        hookMode,
+			SkipTests:    skipTests,
+			IncludeCRDs:  includeCRDs,
+			Capabilities: caps,
+		})
+		if err != nil {
+			return nil, nil, err
+		}
+		opts.Source = "chart " + chartPath
+		if err := parser.Decode(strings.NewReader(rendered), opts, keepHooks(hooks)); err != nil {
+			return nil, nil, fmt.Errorf("failed to parse YAML content: %w", err)
+		}
+
+	case installedRelease != "":
+		client, err := cluster.NewClientset(viper.GetString("cluster.kubeconfig"), viper.GetString("cluster.context"))
+		if err != nil {
+			return nil, nil, fmt.Errorf("failed to create cluster client: %w", err)
+		}
+		manifest, hooks, err := helm.FetchRelease(client, namespace, installedRelease, helm.ReleaseOptions{
+			Hooks:     hookMode,
+			SkipTests: skipTests,
+		})
+		if err != nil {
+			return nil, nil, err
+		}
+		opts.Source = "release " + installedRelease
+		if err := parser.Decode(strings.NewReader(manifest), opts, keepHooks(hooks)); err != nil {
+			return nil, nil, fmt.Errorf("failed to parse YAML content: %w", err)
+		}
+
+	case kustomizeDir != "":
+		built, err := kustomize.Build(kustomizeDir, kustomize.Options{LoadRestrictions: loadRestrictions})
+		if err != nil {
+			return nil, nil, err
+		}
+		opts.Source = "kustomize " + kustomizeDir
+		if err := parser.Decode(strings.NewReader(built), opts, keepRendered); err != nil {
+			return nil, nil, fmt.Errorf("failed to parse YAML content: %w", err)
+		}
+
+	default:
+		files, err := manifests.Find(inputPaths, include, excludeFiles)
+		if err != nil {
+			return nil, nil, fmt.Errorf("failed to find input files: %w", err)
+		}
+		if len(files) == 0 {
+			return nil, nil, fmt.Errorf("no manifest files found in %s", strings.Join(inputPaths, ", "))
+		}
+		for _, path := range files {
+			if err := decodeFile(cmd, path, opts, keep); err != nil {
+				return nil, nil, err
+			}
+		}
+		logger = logger.WithField("files", len(files))
+	}
+
+	logger.WithField("count", loaded).Info("Loaded resources")
+
+	if excluded := loaded - len(objs); excluded > 0 {
+		logger.WithFields(log.Fields{
+			"before":   loaded,
+			"after":    len(objs),
+			"excluded": excluded,
+		}).Info("Applied exclusion filters")
+	}
+
+	return objs, origins, nil
+}
+
+// Registry returns the default handler registry extended with the reference
+// rules from the "references" config key. The default registry itself is left
+// untouched.
+func Registry() (*dependency.Registry, error) {
+	var rules []dependency.ReferenceRule
+	if err := viper.UnmarshalKey("references", &rules); err != nil {
+		return nil, fmt.Errorf("failed to read reference rules from config: %w", err)
+	}
+
+	registry := dependency.DefaultRegistry().Clone()
+	if err := dependency.RegisterRules(registry, rules); err != nil {
+		return nil, fmt.Errorf("invalid reference rules in config: %w", err)
+	}
+	if len(rules) > 0 {
+		log.WithFields(log.Fields{
+			"func":  "input.Registry",
+			"rules": len(rules),
+		}).Info("Loaded reference rules from config")
+	}
+	return registry, nil
+}
+
+// decodeFile streams the objects of one input file, or of standard input for
+// manifests.Stdin, into keep.
+func decodeFile(cmd *cobra.Command, path string, opts parser.Options, keep func(*unstructured.Unstructured, parser.Source) error) error {
+	log.WithFields(log.Fields{
+		"func": "decodeFile",
+		"path": path,
+	}).Debug("Reading YAML file")
+
+	var r io.Reader
+	opts.Source = path
+	if path == manifests.Stdin {
+		r = cmd.InOrStdin()
+		opts.Source = "stdin"
+	} else {
+		f, err := os.Open(path)
+		if err != nil {
+			return fmt.Errorf("failed to read input file: %w", err)
+		}
+		defer func() { _ = f.Close() }()
+		r = f
+	}
+
+	if err := parser.Decode(r, opts, keep); err != nil {
+		return fmt.Errorf("failed to parse YAML content: %w", err)
+	}
+	return nil
+}
+
+// capabilities returns the Helm .Capabilities for the --kube-version,
+// --api-versions and --cluster-capabilities flags, or nil for Helm's
+// defaults when none is set. Flags given explicitly override what the
+// cluster reports.
+func capabilities(prefix, kubeVersion string, apiVersions []string, fromCluster bool) (*chartutil.Capabilities, error) {
+	if kubeVersion == "" && len(apiVersions) == 0 && !fromCluster {
+		return nil, nil
+	}
+
+	var base *chartutil.Capabilities
+	if fromCluster {
+		client, err := cluster.NewDiscoveryClient(viper.GetString("cluster.kubeconfig"), viper.GetString("cluster.context"))
+		if err != nil {
+			return nil, fmt.Errorf("failed to create cluster client: %w", err)
+		}
+		if base, err = helm.DiscoverCapabilities(client); err != nil {
+			return nil, err
+		}
+	}
+
+	caps, err := helm.Capabilities(base, kubeVersion, apiVersions)
+	if err != nil {
+		return nil, fmt.Errorf("invalid --%skube-version: %w", prefix, err)
+	}
+	return caps, nil
+}
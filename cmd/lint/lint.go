@@ -0,0 +1,142 @@
+package cmd
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"strings"
+
+	log "github.com/sirupsen/logrus"
+	"github.com/spf13/cobra"
+	"github.com/spf13/viper"
+
+	"github.com/HMetcalfeW/cartographer/cmd/input"
+	"github.com/HMetcalfeW/cartographer/pkg/dependency"
+	"github.com/HMetcalfeW/cartographer/pkg/lint"
+)
+
+// LintCmd represents the lint subcommand.
+var LintCmd = &cobra.Command{
+	Use:   "lint",
+	Short: "Report broken references and selectors that match nothing",
+	Long: `Lint loads resources the same way as analyze (--input, --chart, --kustomize,
+--release-from-cluster or --cluster) and reports problems instead of drawing a
+graph: dangling references, selectors that match no workload, HPAs targeting
+missing workloads and bindings to absent roles. Rule severities are configured under lint.rules in .cartographer.yaml.
+The command exits non-zero when any finding is at or above --fail-on.`,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		outputFormat, _ := cmd.Flags().GetString("output-format")
+		failOnName, _ := cmd.Flags().GetString("fail-on")
+		if failOnName == "" {
+			failOnName = viper.GetString("lint.failOn")
+		}
+		failOn, err := lint.ParseSeverity(failOnName)
+		if err != nil {
+			return fmt.Errorf("invalid --fail-on: %w", err)
+		}
+		if outputFormat != "text" && outputFormat != "json" {
+			return fmt.Errorf("unknown output format: %s", outputFormat)
+		}
+
+		severities, err := ruleSeverities()
+		if err != nil {
+			return err
+		}
+
+		registry, err := input.Registry()
+		if err != nil {
+			return err
+		}
+
+		objs, origins, err := input.Load(cmd)
+		if err != nil {
+			return err
+		}
+
+		graph := dependency.BuildDependenciesWith(objs, registry)
+		graph.SetOrigins(origins)
+		findings, err := lint.Run(graph, lint.DefaultRules(), severities)
+		if err != nil {
+			return fmt.Errorf("invalid lint config: %w", err)
+		}
+
+		if outputFormat == "json" {
+			err = writeJSON(cmd.OutOrStdout(), findings)
+		} else {
+			err = writeText(cmd.OutOrStdout(), findings)
+		}
+		if err != nil {
+			return err
+		}
+
+		log.WithFields(log.Fields{
+			"func":     "lint",
+			"findings": len(findings),
+			"failOn":   failOn,
+		}).Info("Lint finished")
+
+		if failOn == lint.SeverityOff {
+			return nil
+		}
+		if n := lint.CountAtLeast(findings, failOn); n > 0 {
+			// The findings have been reported; usage text would only bury them.
+			cmd.SilenceUsage = true
+			return fmt.Errorf("lint failed: %d finding(s) at or above %s", n, failOn)
+		}
+		return nil
+	},
+}
+
+// ruleSeverities reads per-rule severity overrides from lint.rules.
+func ruleSeverities() (map[string]lint.Severity, error) {
+	severities := make(map[string]lint.Severity)
+	for name, value := range viper.GetStringMapString("lint.rules") {
+		sev, err := lint.ParseSeverity(value)
+		if err != nil {
+			return nil, fmt.Errorf("invalid severity for lint rule %q: %w", name, err)
+		}
+		severities[name] = sev
+	}
+	return severities, nil
+}
+
+// writeText prints one line per finding followed by a summary. Findings for
+// resources with a known location start with it, so editors and CI logs can
+// link straight to the manifest.
+func writeText(w io.Writer, findings []lint.Finding) error {
+	counts := make(map[lint.Severity]int)
+	for _, f := range findings {
+		counts[f.Severity]++
+		location := ""
+		if f.Source != "" {
+			location = f.Source + ": "
+		}
+		if _, err := fmt.Fprintf(w, "%-7s %s%s: %s [%s]\n", strings.ToUpper(f.Severity.String()), location, f.Resource, f.Message, f.Rule); err != nil {
+			return err
+		}
+	}
+	_, err := fmt.Fprintf(w, "%d problem(s): %d error(s), %d warning(s), %d info\n",
+		len(findings), counts[lint.SeverityError], counts[lint.SeverityWarning], counts[lint.SeverityInfo])
+	return err
+}
+
+// writeJSON prints the findings as an indented JSON object.
+func writeJSON(w io.Writer, findings []lint.Finding) error {
+	if findings == nil {
+		findings = []lint.Finding{}
+	}
+	data, err := json.MarshalIndent(struct {
+		Findings []lint.Finding `json:"findings"`
+	}{findings}, "", "  ")
+	if err != nil {
+		return fmt.Errorf("failed to encode findings: %w", err)
+	}
+	_, err = fmt.Fprintln(w, string(data))
+	return err
+}
+
+func init() {
+	input.AddFlags(LintCmd)
+	LintCmd.Flags().String("output-format", "text", "Output format: text, json")
+	LintCmd.Flags().String("fail-on", "", "Exit non-zero if any finding is at or above this severity: info, warning, error, off (default: lint.failOn from config, or error)")
+}
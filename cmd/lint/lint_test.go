@@ -0,0 +1,160 @@
+package cmd_test
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/HMetcalfeW/cartographer/internal/cmdtest"
+	"github.com/HMetcalfeW/cartographer/pkg/lint"
+	"github.com/spf13/viper"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// lintYAML has one dangling reference (error) and one Service whose selector
+// matches nothing (warning).
+const lintYAML = `
+apiVersion: apps/v1
+kind: Deployment
+metadata:
+  name: web
+  labels:
+    app: web
+spec:
+  template:
+    spec:
+      containers:
+        - name: web
+          image: nginx
+          envFrom:
+            - configMapRef:
+                name: web-config
+---
+apiVersion: v1
+kind: Service
+metadata:
+  name: typo
+spec:
+  selector:
+    app: wbe
+`
+
+func TestLintCommand_FailsOnError(t *testing.T) {
+	inputPath := cmdtest.WriteInput(t, lintYAML)
+
+	out, err := cmdtest.Run(t, "lint", "--input", inputPath, "--output-format", "text", "--fail-on", "error")
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "1 finding(s) at or above error")
+	assert.Contains(t, out, "ERROR   "+inputPath+":2: Deployment/web: references ConfigMap/web-config (configMapRef), which is not in the input [dangling-reference]")
+	assert.Contains(t, out, "WARNING "+inputPath+":18: Service/typo: spec.selector matches no workload [service-selector-no-match]")
+	assert.Contains(t, out, "2 problem(s): 1 error(s), 1 warning(s), 0 info")
+}
+
+func TestLintCommand_FailOnThreshold(t *testing.T) {
+	inputPath := cmdtest.WriteInput(t, lintYAML)
+
+	_, err := cmdtest.Run(t, "lint", "--input", inputPath, "--output-format", "text", "--fail-on", "warning")
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "2 finding(s) at or above warning")
+
+	_, err = cmdtest.Run(t, "lint", "--input", inputPath, "--output-format", "text", "--fail-on", "off")
+	require.NoError(t, err)
+}
+
+func TestLintCommand_ConfigSeverities(t *testing.T) {
+	inputPath := cmdtest.WriteInput(t, lintYAML)
+
+	viper.Set("lint.rules", map[string]string{"dangling-reference": "warning"})
+	t.Cleanup(func() { viper.Set("lint.rules", map[string]string{}) })
+
+	_, err := cmdtest.Run(t, "lint", "--input", inputPath, "--output-format", "text", "--fail-on", "error")
+	require.NoError(t, err, "no finding should remain at error severity")
+}
+
+func TestLintCommand_InvalidConfigSeverity(t *testing.T) {
+	inputPath := cmdtest.WriteInput(t, lintYAML)
+
+	viper.Set("lint.rules", map[string]string{"dangling-reference": "fatal"})
+	t.Cleanup(func() { viper.Set("lint.rules", map[string]string{}) })
+
+	_, err := cmdtest.Run(t, "lint", "--input", inputPath, "--output-format", "text", "--fail-on", "error")
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), `invalid severity for lint rule "dangling-reference"`)
+}
+
+func TestLintCommand_JSON(t *testing.T) {
+	inputPath := cmdtest.WriteInput(t, lintYAML)
+
+	out, err := cmdtest.Run(t, "lint", "--input", inputPath, "--output-format", "json", "--fail-on", "off")
+	require.NoError(t, err)
+
+	var result struct {
+		Findings []struct {
+			Rule     string `json:"rule"`
+			Severity string `json:"severity"`
+			Resource string `json:"resource"`
+			Source   string `json:"source"`
+			Message  string `json:"message"`
+		} `json:"findings"`
+	}
+	require.NoError(t, json.Unmarshal([]byte(out), &result))
+	require.Len(t, result.Findings, 2)
+	assert.Equal(t, "dangling-reference", result.Findings[0].Rule)
+	assert.Equal(t, lint.SeverityError.String(), result.Findings[0].Severity)
+	assert.Equal(t, "Deployment/web", result.Findings[0].Resource)
+	assert.Equal(t, inputPath+":2", result.Findings[0].Source)
+	assert.Equal(t, "Service/typo", result.Findings[1].Resource)
+}
+
+// TestLintCommand_HelmTemplateOutput verifies that findings in a saved
+// `helm template` file point at the file and line, with the template only
+// alongside.
+func TestLintCommand_HelmTemplateOutput(t *testing.T) {
+	inputPath := cmdtest.WriteInput(t, `---
+# Source: my-app/templates/deployment.yaml
+apiVersion: apps/v1
+kind: Deployment
+metadata:
+  name: web
+spec:
+  template:
+    spec:
+      containers:
+        - name: web
+          image: nginx
+          envFrom:
+            - secretRef:
+                name: web-secrets
+`)
+
+	out, err := cmdtest.Run(t, "lint", "--input", inputPath, "--output-format", "text", "--fail-on", "off")
+	require.NoError(t, err)
+	assert.Contains(t, out, inputPath+":3 (my-app/templates/deployment.yaml): Deployment/web")
+}
+
+func TestLintCommand_Clean(t *testing.T) {
+	inputPath := cmdtest.WriteInput(t, `
+apiVersion: v1
+kind: ConfigMap
+metadata:
+  name: standalone
+`)
+
+	out, err := cmdtest.Run(t, "lint", "--input", inputPath, "--output-format", "text", "--fail-on", "info")
+	require.NoError(t, err)
+	assert.Contains(t, out, "0 problem(s)")
+}
+
+func TestLintCommand_NoInput(t *testing.T) {
+	_, err := cmdtest.Run(t, "lint", "--fail-on", "error")
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "no input source provided")
+}
+
+func TestLintCommand_UnknownFormat(t *testing.T) {
+	inputPath := cmdtest.WriteInput(t, lintYAML)
+
+	_, err := cmdtest.Run(t, "lint", "--input", inputPath, "--output-format", "yaml", "--fail-on", "error")
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "unknown output format: yaml")
+}
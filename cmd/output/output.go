@@ -0,0 +1,103 @@
+// Package output writes rendered graphs and reports for the subcommands,
+// either to stdout or to a file.
+package output
+
+import (
+	"fmt"
+	"os"
+
+	log "github.com/sirupsen/logrus"
+	"github.com/spf13/cobra"
+
+	"github.com/HMetcalfeW/cartographer/pkg/dependency"
+)
+
+// Write renders the graph in the given format (dot, mermaid, json, png or
+// svg) to outputFile, or to the command's stdout when outputFile is empty.
+// Image formats require outputFile.
+func Write(cmd *cobra.Command, graph *dependency.Graph, format, outputFile string) error {
+	log.WithFields(log.Fields{
+		"func":   "output.Write",
+		"format": format,
+	}).Debug("Generating output")
+
+	switch format {
+	case "dot":
+		return WriteText(cmd, dependency.GenerateDOT(graph), outputFile, "DOT")
+	case "mermaid":
+		return WriteText(cmd, dependency.GenerateMermaid(graph), outputFile, "Mermaid")
+	case "json":
+		return WriteText(cmd, dependency.GenerateJSON(graph), outputFile, "JSON")
+	case "png", "svg":
+		return writeImage(format, outputFile, func() ([]byte, error) {
+			return dependency.RenderImage(graph, format)
+		})
+	default:
+		return fmt.Errorf("unknown output format: %s", format)
+	}
+}
+
+// WriteDiff renders a graph diff in the given format (dot, mermaid, json, png
+// or svg), like Write.
+func WriteDiff(cmd *cobra.Command, diff *dependency.GraphDiff, format, outputFile string) error {
+	log.WithFields(log.Fields{
+		"func":   "output.WriteDiff",
+		"format": format,
+	}).Debug("Generating diff output")
+
+	switch format {
+	case "dot":
+		return WriteText(cmd, dependency.GenerateDiffDOT(diff), outputFile, "DOT")
+	case "mermaid":
+		return WriteText(cmd, dependency.GenerateDiffMermaid(diff), outputFile, "Mermaid")
+	case "json":
+		return WriteText(cmd, dependency.GenerateDiffJSON(diff), outputFile, "JSON")
+	case "png", "svg":
+		return writeImage(format, outputFile, func() ([]byte, error) {
+			return dependency.RenderDiffImage(diff, format)
+		})
+	default:
+		return fmt.Errorf("unknown output format: %s", format)
+	}
+}
+
+// writeImage renders an image with render and saves it to outputFile, which
+// is required since binary data cannot go to stdout.
+func writeImage(format, outputFile string, render func() ([]byte, error)) error {
+	if outputFile == "" {
+		return fmt.Errorf("--output-file is required for %s format (binary data cannot be printed to stdout)", format)
+	}
+	imageData, err := render()
+	if err != nil {
+		return fmt.Errorf("failed to render %s: %w", format, err)
+	}
+	if err := os.WriteFile(outputFile, imageData, 0644); err != nil {
+		return fmt.Errorf("failed to write %s output: %w", format, err)
+	}
+	log.WithFields(log.Fields{
+		"func":   "output.writeImage",
+		"format": format,
+		"path":   outputFile,
+		"bytes":  len(imageData),
+	}).Debug("Image file saved")
+	return nil
+}
+
+// WriteText writes text content to the command's stdout, or to outputFile
+// when it is set. label names the content in errors and logs.
+func WriteText(cmd *cobra.Command, content, outputFile, label string) error {
+	if outputFile == "" {
+		log.WithField("func", "output.WriteText").Debug("Writing to stdout")
+		_, err := fmt.Fprintln(cmd.OutOrStdout(), content)
+		return err
+	}
+	if err := os.WriteFile(outputFile, []byte(content), 0644); err != nil {
+		return fmt.Errorf("failed to write %s output: %w", label, err)
+	}
+	log.WithFields(log.Fields{
+		"func": "output.WriteText",
+		"path": outputFile,
+		"type": label,
+	}).Debug("File saved")
+	return nil
+}
@@ -6,6 +6,11 @@ import (
 	"github.com/spf13/viper"
 
 	analyze "github.com/HMetcalfeW/cartographer/cmd/analyze"
+	diffCmd "github.com/HMetcalfeW/cartographer/cmd/diff"
+	explainCmd "github.com/HMetcalfeW/cartographer/cmd/explain"
+	impactCmd "github.com/HMetcalfeW/cartographer/cmd/impact"
+	lintCmd "github.com/HMetcalfeW/cartographer/cmd/lint"
+	unusedCmd "github.com/HMetcalfeW/cartographer/cmd/unused"
 	versionCmd "github.com/HMetcalfeW/cartographer/cmd/version"
 )
 
@@ -44,6 +49,11 @@ func init() {
 
 	// Register subcommands.
 	RootCmd.AddCommand(analyze.AnalyzeCmd)
+	RootCmd.AddCommand(diffCmd.DiffCmd)
+	RootCmd.AddCommand(explainCmd.ExplainCmd)
+	RootCmd.AddCommand(impactCmd.ImpactCmd)
+	RootCmd.AddCommand(lintCmd.LintCmd)
+	RootCmd.AddCommand(unusedCmd.UnusedCmd)
 	RootCmd.AddCommand(versionCmd.VersionCmd)
 
 	log.WithField("func", "root.init").Debug("root initialization complete")
@@ -62,11 +72,14 @@ func initConfig() {
 	}
 	viper.AutomaticEnv() // read in environment variables that match
 
-	// Defaults for cluster and exclusion config.
+	// Defaults for cluster, exclusion, reference rule and lint config.
 	viper.SetDefault("cluster.kubeconfig", "")
 	viper.SetDefault("cluster.context", "")
 	viper.SetDefault("exclude.kinds", []string{"ReplicaSet", "Pod"})
 	viper.SetDefault("exclude.names", []string{})
+	viper.SetDefault("references", []interface{}{})
+	viper.SetDefault("lint.failOn", "error")
+	viper.SetDefault("lint.rules", map[string]string{})
 
 	if err := viper.ReadInConfig(); err == nil {
 		logger.Info("Using config file:", viper.ConfigFileUsed())
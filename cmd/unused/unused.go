@@ -0,0 +1,96 @@
+package cmd
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+
+	log "github.com/sirupsen/logrus"
+	"github.com/spf13/cobra"
+
+	"github.com/HMetcalfeW/cartographer/cmd/input"
+	"github.com/HMetcalfeW/cartographer/pkg/dependency"
+)
+
+// UnusedCmd represents the unused subcommand.
+var UnusedCmd = &cobra.Command{
+	Use:   "unused",
+	Short: "List ConfigMaps, Secrets, PVCs, ServiceAccounts and Roles nothing references",
+	Long: `Unused loads resources the same way as analyze (--input, --chart, --kustomize,
+--release-from-cluster or --cluster) and lists the ConfigMaps, Secrets,
+PersistentVolumeClaims, ServiceAccounts, Roles and ClusterRoles that no
+workload, Ingress, binding or other resource references, explaining why each
+was considered unused. The exclude.kinds config is ignored so that bare Pods
+count as users. With --cluster but without --all-namespaces, ClusterRoleBindings
+are not read, and every reported ServiceAccount says so.`,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		outputFormat, _ := cmd.Flags().GetString("output-format")
+		if outputFormat != "text" && outputFormat != "json" {
+			return fmt.Errorf("unknown output format: %s", outputFormat)
+		}
+
+		registry, err := input.Registry()
+		if err != nil {
+			return err
+		}
+
+		// Kind exclusions would hide the Pods and ReplicaSets that use a
+		// resource and make it look unused.
+		objs, origins, err := input.LoadWith(cmd, input.LoadOptions{KeepExcludedKinds: true})
+		if err != nil {
+			return err
+		}
+
+		clusterMode, _ := cmd.Flags().GetBool("cluster")
+		allNamespaces, _ := cmd.Flags().GetBool("all-namespaces")
+		opts := dependency.UnusedOptions{NamespaceScoped: clusterMode && !allNamespaces}
+		if opts.NamespaceScoped {
+			log.WithField("func", "unused").Warn("ClusterRoleBindings are not read without --all-namespaces; ServiceAccounts bound only by one are reported as unused")
+		}
+
+		graph := dependency.BuildDependenciesWith(objs, registry)
+		graph.SetOrigins(origins)
+		unused := dependency.FindUnusedWith(graph, opts)
+
+		log.WithFields(log.Fields{
+			"func":   "unused",
+			"unused": len(unused),
+		}).Info("Unused resource report complete")
+
+		if outputFormat == "json" {
+			return writeJSON(cmd.OutOrStdout(), unused)
+		}
+		return writeText(cmd.OutOrStdout(), unused)
+	},
+}
+
+// writeText prints one line per unused resource followed by a count.
+func writeText(w io.Writer, unused []dependency.UnusedResource) error {
+	for _, u := range unused {
+		if _, err := fmt.Fprintf(w, "%s: %s\n", u.ID, u.Reason); err != nil {
+			return err
+		}
+	}
+	_, err := fmt.Fprintf(w, "%d unused resource(s)\n", len(unused))
+	return err
+}
+
+// writeJSON prints the report as an indented JSON object.
+func writeJSON(w io.Writer, unused []dependency.UnusedResource) error {
+	if unused == nil {
+		unused = []dependency.UnusedResource{}
+	}
+	data, err := json.MarshalIndent(struct {
+		Unused []dependency.UnusedResource `json:"unused"`
+	}{unused}, "", "  ")
+	if err != nil {
+		return fmt.Errorf("failed to encode report: %w", err)
+	}
+	_, err = fmt.Fprintln(w, string(data))
+	return err
+}
+
+func init() {
+	input.AddFlags(UnusedCmd)
+	UnusedCmd.Flags().String("output-format", "text", "Output format: text, json")
+}
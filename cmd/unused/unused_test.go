@@ -0,0 +1,114 @@
+package cmd_test
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/HMetcalfeW/cartographer/internal/cmdtest"
+	"github.com/HMetcalfeW/cartographer/pkg/dependency"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+const unusedYAML = `
+apiVersion: apps/v1
+kind: Deployment
+metadata:
+  name: web
+spec:
+  template:
+    spec:
+      containers:
+        - name: web
+          image: nginx
+          envFrom:
+            - configMapRef:
+                name: web-config
+---
+apiVersion: v1
+kind: ConfigMap
+metadata:
+  name: web-config
+---
+apiVersion: v1
+kind: ConfigMap
+metadata:
+  name: stale-config
+`
+
+func TestUnusedCommand_Text(t *testing.T) {
+	inputPath := cmdtest.WriteInput(t, unusedYAML)
+
+	out, err := cmdtest.Run(t, "unused", "--input", inputPath, "--output-format", "text")
+	require.NoError(t, err)
+	assert.Contains(t, out, "ConfigMap/stale-config: not referenced:")
+	assert.NotContains(t, out, "ConfigMap/web-config:")
+	assert.Contains(t, out, "1 unused resource(s)")
+}
+
+func TestUnusedCommand_JSON(t *testing.T) {
+	inputPath := cmdtest.WriteInput(t, unusedYAML)
+
+	out, err := cmdtest.Run(t, "unused", "--input", inputPath, "--output-format", "json")
+	require.NoError(t, err)
+
+	var report struct {
+		Unused []dependency.UnusedResource `json:"unused"`
+	}
+	require.NoError(t, json.Unmarshal([]byte(out), &report))
+	require.Len(t, report.Unused, 1)
+	assert.Equal(t, "ConfigMap/stale-config", report.Unused[0].ID)
+	assert.Equal(t, "ConfigMap", report.Unused[0].Kind)
+	assert.NotEmpty(t, report.Unused[0].Reason)
+}
+
+// TestUnusedCommand_BarePod verifies that kind exclusions, which drop Pods by
+// default, do not apply, so resources used only by a bare Pod are not listed.
+func TestUnusedCommand_BarePod(t *testing.T) {
+	inputPath := cmdtest.WriteInput(t, `
+apiVersion: v1
+kind: Pod
+metadata:
+  name: debug
+spec:
+  volumes:
+    - name: data
+      persistentVolumeClaim:
+        claimName: scratch
+  containers:
+    - name: debug
+      image: busybox
+      envFrom:
+        - configMapRef:
+            name: debug-config
+        - secretRef:
+            name: debug-secret
+---
+apiVersion: v1
+kind: ConfigMap
+metadata:
+  name: debug-config
+---
+apiVersion: v1
+kind: Secret
+metadata:
+  name: debug-secret
+---
+apiVersion: v1
+kind: PersistentVolumeClaim
+metadata:
+  name: scratch
+`)
+
+	out, err := cmdtest.Run(t, "unused", "--input", inputPath)
+	require.NoError(t, err)
+	assert.Contains(t, out, "0 unused resource(s)")
+}
+
+func TestUnusedCommand_UnknownFormat(t *testing.T) {
+	inputPath := cmdtest.WriteInput(t, unusedYAML)
+
+	_, err := cmdtest.Run(t, "unused", "--input", inputPath, "--output-format", "dot")
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "unknown output format: dot")
+}
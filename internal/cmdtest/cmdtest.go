@@ -0,0 +1,74 @@
+// Package cmdtest holds helpers for tests that execute subcommands through
+// the shared cmd.RootCmd. The subcommands are package-level values, so pflag
+// keeps every flag value (and appends to repeatable flags) from one execution
+// to the next; ResetFlags and Run give each test a clean command.
+package cmdtest
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/spf13/cobra"
+	"github.com/spf13/pflag"
+	"github.com/stretchr/testify/require"
+
+	"github.com/HMetcalfeW/cartographer/cmd"
+)
+
+// WriteInput writes content to a YAML file in the test's temporary directory
+// and returns its path.
+func WriteInput(t *testing.T, content string) string {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), "input.yaml")
+	require.NoError(t, os.WriteFile(path, []byte(content), 0o644))
+	return path
+}
+
+// ResetFlags restores every flag of c, including those it inherits, to its
+// default now and again when the test ends.
+func ResetFlags(t *testing.T, c *cobra.Command) {
+	t.Helper()
+	reset := func() {
+		for cur := c; cur != nil; cur = cur.Parent() {
+			cur.Flags().VisitAll(func(f *pflag.Flag) {
+				require.NoError(t, resetFlag(f), f.Name)
+			})
+		}
+	}
+	reset()
+	t.Cleanup(reset)
+}
+
+// resetFlag sets f back to its default value.
+func resetFlag(f *pflag.Flag) error {
+	f.Changed = false
+	if slice, ok := f.Value.(pflag.SliceValue); ok {
+		var def []string
+		if trimmed := strings.Trim(f.DefValue, "[]"); trimmed != "" {
+			def = strings.Split(trimmed, ",")
+		}
+		return slice.Replace(def)
+	}
+	return f.Value.Set(f.DefValue)
+}
+
+// Run executes cmd.RootCmd with args, such as "lint", "--input", path, with
+// the flags of the subcommand reset first, and returns everything it printed.
+func Run(t *testing.T, args ...string) (string, error) {
+	t.Helper()
+	root := cmd.RootCmd
+	sub, _, err := root.Find(args)
+	require.NoError(t, err)
+	ResetFlags(t, sub)
+	root.SetArgs(args)
+
+	buf := new(bytes.Buffer)
+	root.SetOut(buf)
+	root.SetErr(buf)
+
+	err = root.Execute()
+	return buf.String(), err
+}
@@ -3,13 +3,17 @@ package cluster
 import (
 	"context"
 	"fmt"
+	"sort"
 
 	log "github.com/sirupsen/logrus"
 	apierrors "k8s.io/apimachinery/pkg/api/errors"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
 	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/client-go/discovery"
 	"k8s.io/client-go/dynamic"
+	"k8s.io/client-go/kubernetes"
+	"k8s.io/client-go/rest"
 	"k8s.io/client-go/tools/clientcmd"
 )
 
@@ -56,6 +60,52 @@ var clusterScopedGVRs = map[schema.GroupVersionResource]bool{
 // context name. Empty strings use defaults (standard kubeconfig resolution
 // and current-context, respectively).
 func NewClient(kubeconfigPath, contextName string) (dynamic.Interface, error) {
+	config, err := restConfig(kubeconfigPath, contextName)
+	if err != nil {
+		return nil, err
+	}
+
+	client, err := dynamic.NewForConfig(config)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create dynamic client: %w", err)
+	}
+	return client, nil
+}
+
+// NewClientset builds a typed kubernetes.Interface, used to read objects such
+// as Helm's release storage, from the same kubeconfig settings as NewClient.
+func NewClientset(kubeconfigPath, contextName string) (kubernetes.Interface, error) {
+	config, err := restConfig(kubeconfigPath, contextName)
+	if err != nil {
+		return nil, err
+	}
+
+	client, err := kubernetes.NewForConfig(config)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create Kubernetes client: %w", err)
+	}
+	return client, nil
+}
+
+// NewDiscoveryClient builds a discovery client, which reports the server
+// version and served API versions, from the same kubeconfig settings as
+// NewClient.
+func NewDiscoveryClient(kubeconfigPath, contextName string) (discovery.DiscoveryInterface, error) {
+	config, err := restConfig(kubeconfigPath, contextName)
+	if err != nil {
+		return nil, err
+	}
+
+	client, err := discovery.NewDiscoveryClientForConfig(config)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create discovery client: %w", err)
+	}
+	return client, nil
+}
+
+// restConfig resolves the kubeconfig path and context name into a client
+// configuration.
+func restConfig(kubeconfigPath, contextName string) (*rest.Config, error) {
 	rules := clientcmd.NewDefaultClientConfigLoadingRules()
 	if kubeconfigPath != "" {
 		rules.ExplicitPath = kubeconfigPath
@@ -72,51 +122,77 @@ func NewClient(kubeconfigPath, contextName string) (dynamic.Interface, error) {
 	if err != nil {
 		return nil, fmt.Errorf("failed to build kubeconfig: %w", err)
 	}
-
-	client, err := dynamic.NewForConfig(config)
-	if err != nil {
-		return nil, fmt.Errorf("failed to create dynamic client: %w", err)
-	}
-	return client, nil
+	return config, nil
 }
 
+// UnreadableRoles maps each fetched RoleBinding or ClusterRoleBinding whose
+// roleRef names a ClusterRole the cluster refused to return (403) to that
+// ClusterRole's name. Such a ClusterRole may well exist; the credentials in
+// use just cannot read it, so it must not be mistaken for an absent one.
+type UnreadableRoles map[*unstructured.Unstructured]string
+
 // FetchResources lists all supported Kubernetes resource types from the cluster.
 // If allNamespaces is true, resources are listed across all namespaces and
 // cluster-scoped resources (ClusterRole, ClusterRoleBinding) are included.
-// When a specific namespace is given, cluster-scoped resources are skipped to
-// avoid pulling every system ClusterRole/ClusterRoleBinding into the graph;
-// any that are referenced (e.g. via roleRef) still appear as edge targets.
-// Missing GVRs (404) and permission errors (403) are logged and skipped.
+// When a specific namespace is given, cluster-scoped resources are not listed
+// to avoid pulling every system ClusterRole/ClusterRoleBinding into the graph;
+// only the ClusterRoles named by the namespace's RoleBindings are fetched, so
+// a binding to an existing ClusterRole is not mistaken for a dangling one.
+// Missing GVRs (404) and permission errors (403) are logged and skipped;
+// bindings to ClusterRoles that could not be read are returned as well.
 func FetchResources(
 	ctx context.Context,
 	client dynamic.Interface,
 	namespace string,
 	allNamespaces bool,
-) ([]*unstructured.Unstructured, error) {
+) ([]*unstructured.Unstructured, UnreadableRoles, error) {
 	var result []*unstructured.Unstructured
+	allRolesForbidden := false
 
 	for _, gvr := range supportedGVRs {
-		items, err := fetchGVR(ctx, client, gvr, namespace, allNamespaces)
+		items, forbidden, err := fetchGVR(ctx, client, gvr, namespace, allNamespaces)
 		if err != nil {
-			return nil, err
+			return nil, nil, err
+		}
+		if forbidden && gvr == clusterRolesGVR {
+			allRolesForbidden = true
 		}
 		result = append(result, items...)
 	}
 
+	forbiddenRoles := make(map[string]bool)
+	if !allNamespaces {
+		roles, forbidden, err := fetchReferencedClusterRoles(ctx, client, result)
+		if err != nil {
+			return nil, nil, err
+		}
+		result = append(result, roles...)
+		forbiddenRoles = forbidden
+	}
+
+	unreadable := make(UnreadableRoles)
+	for _, obj := range result {
+		if name, ok := clusterRoleRef(obj); ok && (allRolesForbidden || forbiddenRoles[name]) {
+			unreadable[obj] = name
+		}
+	}
+
 	log.WithField("func", "FetchResources").Infof("Fetched %d resources from cluster", len(result))
-	return result, nil
+	return result, unreadable, nil
 }
 
+// fetchGVR lists gvr in namespace, or in all namespaces. It also reports
+// whether listing was forbidden, in which case no items are returned.
 func fetchGVR(
 	ctx context.Context,
 	client dynamic.Interface,
 	gvr schema.GroupVersionResource,
 	namespace string,
 	allNamespaces bool,
-) ([]*unstructured.Unstructured, error) {
+) ([]*unstructured.Unstructured, bool, error) {
 	// Skip cluster-scoped resources when a specific namespace is requested.
 	if clusterScopedGVRs[gvr] && !allNamespaces {
-		return nil, nil
+		return nil, false, nil
 	}
 
 	var ri dynamic.ResourceInterface
@@ -128,19 +204,86 @@ func fetchGVR(
 
 	list, err := ri.List(ctx, metav1.ListOptions{})
 	if err != nil {
-		if apierrors.IsNotFound(err) || apierrors.IsForbidden(err) {
-			log.WithFields(log.Fields{
-				"func": "fetchGVR",
-				"gvr":  gvr.String(),
-			}).Debug("Skipping unavailable or forbidden resource")
-			return nil, nil
+		logger := log.WithFields(log.Fields{
+			"func": "fetchGVR",
+			"gvr":  gvr.String(),
+		})
+		if apierrors.IsNotFound(err) {
+			logger.Debug("Skipping unavailable resource")
+			return nil, false, nil
+		}
+		if apierrors.IsForbidden(err) {
+			logger.Warn("Skipping resource the cluster user may not list")
+			return nil, true, nil
 		}
-		return nil, fmt.Errorf("failed to list %s: %w", gvr.Resource, err)
+		return nil, false, fmt.Errorf("failed to list %s: %w", gvr.Resource, err)
 	}
 
 	result := make([]*unstructured.Unstructured, len(list.Items))
 	for i := range list.Items {
 		result[i] = &list.Items[i]
 	}
-	return result, nil
+	return result, false, nil
+}
+
+// clusterRolesGVR is the resource fetched by fetchReferencedClusterRoles.
+var clusterRolesGVR = schema.GroupVersionResource{Group: "rbac.authorization.k8s.io", Version: "v1", Resource: "clusterroles"}
+
+// fetchReferencedClusterRoles gets each ClusterRole named in the roleRef of a
+// RoleBinding in objs, once and in name order. ClusterRoles that do not exist
+// are skipped and stay missing in the graph; those that may not be read are
+// skipped too and returned by name, so they can be told apart.
+func fetchReferencedClusterRoles(
+	ctx context.Context,
+	client dynamic.Interface,
+	objs []*unstructured.Unstructured,
+) ([]*unstructured.Unstructured, map[string]bool, error) {
+	names := make(map[string]bool)
+	for _, obj := range objs {
+		if name, ok := clusterRoleRef(obj); ok {
+			names[name] = true
+		}
+	}
+
+	sorted := make([]string, 0, len(names))
+	for name := range names {
+		sorted = append(sorted, name)
+	}
+	sort.Strings(sorted)
+
+	var result []*unstructured.Unstructured
+	forbidden := make(map[string]bool)
+	for _, name := range sorted {
+		role, err := client.Resource(clusterRolesGVR).Get(ctx, name, metav1.GetOptions{})
+		if err != nil {
+			logger := log.WithFields(log.Fields{
+				"func": "fetchReferencedClusterRoles",
+				"name": name,
+			})
+			if apierrors.IsNotFound(err) {
+				logger.Debug("Skipping missing ClusterRole")
+				continue
+			}
+			if apierrors.IsForbidden(err) {
+				logger.Warn("Skipping ClusterRole the cluster user may not read")
+				forbidden[name] = true
+				continue
+			}
+			return nil, nil, fmt.Errorf("failed to get clusterrole %s: %w", name, err)
+		}
+		result = append(result, role)
+	}
+	return result, forbidden, nil
+}
+
+// clusterRoleRef returns the ClusterRole named by the roleRef of a
+// RoleBinding or ClusterRoleBinding.
+func clusterRoleRef(obj *unstructured.Unstructured) (string, bool) {
+	if obj.GroupVersionKind().Group != clusterRolesGVR.Group ||
+		(obj.GetKind() != "RoleBinding" && obj.GetKind() != "ClusterRoleBinding") {
+		return "", false
+	}
+	kind, _, _ := unstructured.NestedString(obj.Object, "roleRef", "kind")
+	name, _, _ := unstructured.NestedString(obj.Object, "roleRef", "name")
+	return name, kind == "ClusterRole" && name != ""
 }
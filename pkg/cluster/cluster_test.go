@@ -7,6 +7,7 @@ import (
 
 	"github.com/HMetcalfeW/cartographer/pkg/cluster"
 	"github.com/HMetcalfeW/cartographer/pkg/dependency"
+	"github.com/HMetcalfeW/cartographer/pkg/lint"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
 	apierrors "k8s.io/apimachinery/pkg/api/errors"
@@ -62,7 +63,7 @@ func TestFetchResources_Basic(t *testing.T) {
 	}
 	client := dynamicfake.NewSimpleDynamicClientWithCustomListKinds(runtime.NewScheme(), gvrMap, objs...)
 
-	result, err := cluster.FetchResources(context.Background(), client, "default", false)
+	result, _, err := cluster.FetchResources(context.Background(), client, "default", false)
 	require.NoError(t, err)
 	assert.Len(t, result, 3)
 
@@ -83,7 +84,7 @@ func TestFetchResources_AllNamespaces(t *testing.T) {
 	}
 	client := dynamicfake.NewSimpleDynamicClientWithCustomListKinds(runtime.NewScheme(), gvrMap, objs...)
 
-	result, err := cluster.FetchResources(context.Background(), client, "", true)
+	result, _, err := cluster.FetchResources(context.Background(), client, "", true)
 	require.NoError(t, err)
 	assert.Len(t, result, 3)
 }
@@ -95,7 +96,7 @@ func TestFetchResources_NamespaceScoped(t *testing.T) {
 	}
 	client := dynamicfake.NewSimpleDynamicClientWithCustomListKinds(runtime.NewScheme(), gvrMap, objs...)
 
-	result, err := cluster.FetchResources(context.Background(), client, "ns1", false)
+	result, _, err := cluster.FetchResources(context.Background(), client, "ns1", false)
 	require.NoError(t, err)
 	assert.Len(t, result, 1)
 	assert.Equal(t, "app1", result[0].GetName())
@@ -110,13 +111,13 @@ func TestFetchResources_ClusterScopedSkippedInNamespaceMode(t *testing.T) {
 	client := dynamicfake.NewSimpleDynamicClientWithCustomListKinds(runtime.NewScheme(), gvrMap, objs...)
 
 	// Namespace-scoped: should skip ClusterRoles and ClusterRoleBindings.
-	result, err := cluster.FetchResources(context.Background(), client, "default", false)
+	result, _, err := cluster.FetchResources(context.Background(), client, "default", false)
 	require.NoError(t, err)
 	assert.Len(t, result, 1)
 	assert.Equal(t, "Deployment", result[0].GetKind())
 
 	// All-namespaces: should include cluster-scoped resources.
-	resultAll, err := cluster.FetchResources(context.Background(), client, "", true)
+	resultAll, _, err := cluster.FetchResources(context.Background(), client, "", true)
 	require.NoError(t, err)
 	kinds := make(map[string]bool)
 	for _, obj := range resultAll {
@@ -141,7 +142,7 @@ func TestFetchResources_NamespaceModeProducesCleanGraph(t *testing.T) {
 	}
 	client := dynamicfake.NewSimpleDynamicClientWithCustomListKinds(runtime.NewScheme(), gvrMap, objs...)
 
-	result, err := cluster.FetchResources(context.Background(), client, "myns", false)
+	result, _, err := cluster.FetchResources(context.Background(), client, "myns", false)
 	require.NoError(t, err)
 
 	// Only namespace-scoped resources should be returned.
@@ -157,8 +158,141 @@ func TestFetchResources_NamespaceModeProducesCleanGraph(t *testing.T) {
 	jsonOut := dependency.GenerateJSON(deps)
 	assert.NotContains(t, jsonOut, "ClusterRole/", "ClusterRoles should not appear in JSON")
 	assert.NotContains(t, jsonOut, "ClusterRoleBinding/", "ClusterRoleBindings should not appear in JSON")
-	assert.Contains(t, jsonOut, "Deployment/web")
-	assert.Contains(t, jsonOut, "Service/web-svc")
+	assert.Contains(t, jsonOut, "Deployment/myns/web")
+	assert.Contains(t, jsonOut, "Service/myns/web-svc")
+}
+
+// TestFetchResources_NamespaceModeReferencedClusterRoles verifies that a
+// namespace-scoped fetch includes the ClusterRoles its RoleBindings name, so
+// lint only reports bindings to ClusterRoles that really do not exist.
+func TestFetchResources_NamespaceModeReferencedClusterRoles(t *testing.T) {
+	binding := func(name, role string) *unstructured.Unstructured {
+		rb := makeObj("rbac.authorization.k8s.io/v1", "RoleBinding", "myns", name)
+		rb.Object["roleRef"] = map[string]interface{}{
+			"apiGroup": "rbac.authorization.k8s.io",
+			"kind":     "ClusterRole",
+			"name":     role,
+		}
+		return rb
+	}
+	objs := []runtime.Object{
+		binding("reader", "app-reader"),
+		binding("reader-again", "app-reader"),
+		binding("broken", "gone"),
+		makeObj("rbac.authorization.k8s.io/v1", "ClusterRole", "", "app-reader"),
+		makeObj("rbac.authorization.k8s.io/v1", "ClusterRole", "", "unrelated"),
+	}
+	client := dynamicfake.NewSimpleDynamicClientWithCustomListKinds(runtime.NewScheme(), gvrMap, objs...)
+
+	result, _, err := cluster.FetchResources(context.Background(), client, "myns", false)
+	require.NoError(t, err)
+
+	var roles []string
+	for _, obj := range result {
+		if obj.GetKind() == "ClusterRole" {
+			roles = append(roles, obj.GetName())
+		}
+	}
+	assert.Equal(t, []string{"app-reader"}, roles, "only referenced ClusterRoles are fetched, once each")
+
+	findings, err := lint.Run(dependency.BuildDependencies(result), lint.DefaultRules(), nil)
+	require.NoError(t, err)
+	require.Len(t, findings, 1)
+	assert.Equal(t, "rolebinding-role-missing", findings[0].Rule)
+	assert.Equal(t, "RoleBinding/myns/broken", findings[0].Resource)
+}
+
+// TestFetchResources_ForbiddenClusterRoles verifies that a ClusterRole the
+// cluster refuses to return is reported as unreadable rather than missing,
+// whether it is fetched by name or the whole kind may not be listed, so lint
+// warns about the binding instead of reporting it as broken.
+func TestFetchResources_ForbiddenClusterRoles(t *testing.T) {
+	binding := func(kind, namespace, name, role string) *unstructured.Unstructured {
+		rb := makeObj("rbac.authorization.k8s.io/v1", kind, namespace, name)
+		rb.Object["roleRef"] = map[string]interface{}{
+			"apiGroup": "rbac.authorization.k8s.io",
+			"kind":     "ClusterRole",
+			"name":     role,
+		}
+		return rb
+	}
+	forbidden := func(_ k8stesting.Action) (bool, runtime.Object, error) {
+		return true, nil, apierrors.NewForbidden(
+			schema.GroupResource{Group: "rbac.authorization.k8s.io", Resource: "clusterroles"}, "", fmt.Errorf("forbidden"),
+		)
+	}
+
+	tests := []struct {
+		name          string
+		allNamespaces bool
+		objs          []runtime.Object
+		react         func(client *dynamicfake.FakeDynamicClient)
+		wantFindings  []string
+	}{
+		{
+			name: "get forbidden",
+			objs: []runtime.Object{
+				binding("RoleBinding", "myns", "hidden", "secret-role"),
+				binding("RoleBinding", "myns", "broken", "gone"),
+			},
+			react: func(client *dynamicfake.FakeDynamicClient) {
+				client.PrependReactor("get", "clusterroles", func(a k8stesting.Action) (bool, runtime.Object, error) {
+					if a.(k8stesting.GetAction).GetName() != "secret-role" {
+						return false, nil, nil
+					}
+					return forbidden(a)
+				})
+			},
+			wantFindings: []string{
+				"rolebinding-role-missing RoleBinding/myns/broken",
+				"unreadable-reference RoleBinding/myns/hidden",
+			},
+		},
+		{
+			name:          "list forbidden",
+			allNamespaces: true,
+			objs: []runtime.Object{
+				binding("RoleBinding", "myns", "hidden", "secret-role"),
+				binding("ClusterRoleBinding", "", "hidden-cluster", "secret-role"),
+			},
+			react: func(client *dynamicfake.FakeDynamicClient) {
+				client.PrependReactor("list", "clusterroles", forbidden)
+			},
+			wantFindings: []string{
+				"unreadable-reference ClusterRoleBinding/hidden-cluster",
+				"unreadable-reference RoleBinding/myns/hidden",
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			client := dynamicfake.NewSimpleDynamicClientWithCustomListKinds(runtime.NewScheme(), gvrMap, tt.objs...)
+			tt.react(client)
+
+			namespace := "myns"
+			if tt.allNamespaces {
+				namespace = ""
+			}
+			result, unreadable, err := cluster.FetchResources(context.Background(), client, namespace, tt.allNamespaces)
+			require.NoError(t, err, "403 errors should be skipped, not returned")
+
+			origins := dependency.Origins{}
+			for obj, role := range unreadable {
+				assert.Equal(t, "secret-role", role)
+				origins[obj] = dependency.Origin{Unreadable: []string{dependency.RefID("rbac.authorization.k8s.io", "ClusterRole", "", role)}}
+			}
+			g := dependency.BuildDependencies(result)
+			g.SetOrigins(origins)
+
+			findings, err := lint.Run(g, lint.DefaultRules(), nil)
+			require.NoError(t, err)
+			var got []string
+			for _, f := range findings {
+				got = append(got, f.Rule+" "+f.Resource)
+			}
+			assert.ElementsMatch(t, tt.wantFindings, got)
+		})
+	}
 }
 
 func TestFetchResources_MissingGVRSkippedGracefully(t *testing.T) {
@@ -181,7 +315,7 @@ func TestFetchResources_MissingGVRSkippedGracefully(t *testing.T) {
 		)
 	})
 
-	result, err := cluster.FetchResources(context.Background(), client, "default", false)
+	result, _, err := cluster.FetchResources(context.Background(), client, "default", false)
 	require.NoError(t, err, "404 and 403 errors should be skipped, not returned")
 	assert.NotEmpty(t, result)
 
@@ -196,7 +330,7 @@ func TestFetchResources_MissingGVRSkippedGracefully(t *testing.T) {
 func TestFetchResources_EmptyCluster(t *testing.T) {
 	client := dynamicfake.NewSimpleDynamicClientWithCustomListKinds(runtime.NewScheme(), gvrMap)
 
-	result, err := cluster.FetchResources(context.Background(), client, "default", false)
+	result, _, err := cluster.FetchResources(context.Background(), client, "default", false)
 	require.NoError(t, err)
 	assert.Empty(t, result)
 }
@@ -263,7 +397,7 @@ func TestFetchResources_WithBuildDependencies(t *testing.T) {
 	objs := []runtime.Object{deploy, secret, svc}
 	client := dynamicfake.NewSimpleDynamicClientWithCustomListKinds(runtime.NewScheme(), gvrMap, objs...)
 
-	result, err := cluster.FetchResources(context.Background(), client, "default", false)
+	result, _, err := cluster.FetchResources(context.Background(), client, "default", false)
 	require.NoError(t, err)
 	assert.Len(t, result, 3)
 
@@ -271,20 +405,20 @@ func TestFetchResources_WithBuildDependencies(t *testing.T) {
 	deps := dependency.BuildDependencies(result)
 
 	// Deployment → Secret (secretRef)
-	deployEdges := deps["Deployment/web"]
+	deployEdges := deps.Edges("Deployment/default/web")
 	hasSecretRef := false
 	for _, e := range deployEdges {
-		if e.ChildID == "Secret/db-creds" && e.Reason == "secretRef" {
+		if e.ChildID == "Secret/default/db-creds" && e.Reason == "secretRef" {
 			hasSecretRef = true
 		}
 	}
 	assert.True(t, hasSecretRef, "expected Deployment/web → Secret/db-creds secretRef edge")
 
 	// Service → Deployment (selector)
-	svcEdges := deps["Service/web-svc"]
+	svcEdges := deps.Edges("Service/default/web-svc")
 	hasSelectorEdge := false
 	for _, e := range svcEdges {
-		if e.ChildID == "Deployment/web" && e.Reason == "selector" {
+		if e.ChildID == "Deployment/default/web" && e.Reason == "selector" {
 			hasSelectorEdge = true
 		}
 	}
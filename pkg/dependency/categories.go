@@ -1,7 +1,6 @@
 package dependency
 
 import (
-	"sort"
 	"strings"
 )
 
@@ -94,10 +93,12 @@ func init() {
 	}
 }
 
-// CategoryForNode returns the category key for a node ID ("Kind/Name").
+// CategoryForNode returns the category key for a node ID (see ResourceKey).
+// Custom resources whose kind shadows a built-in (e.g. a Knative Service,
+// "Service.serving.knative.dev/...") fall into "other".
 func CategoryForNode(nodeID string) string {
 	kind, _, ok := strings.Cut(nodeID, "/")
-	if !ok {
+	if !ok || strings.Contains(kind, ".") {
 		return "other"
 	}
 	if cat, found := kindToCategory[kind]; found {
@@ -106,26 +107,13 @@ func CategoryForNode(nodeID string) string {
 	return "other"
 }
 
-// GroupNodesByCategory collects all unique node IDs from the dependency map
-// (both parents and children) and groups them by category. Each group's
-// node list is sorted for deterministic output.
-func GroupNodesByCategory(deps map[string][]Edge) map[string][]string {
-	nodeSet := make(map[string]struct{})
-	for parent, edges := range deps {
-		nodeSet[parent] = struct{}{}
-		for _, e := range edges {
-			nodeSet[e.ChildID] = struct{}{}
-		}
-	}
-
+// GroupNodesByCategory groups every node ID in the graph by category. Each
+// group's node list is sorted for deterministic output.
+func GroupNodesByCategory(g *Graph) map[string][]string {
 	groups := make(map[string][]string)
-	for nodeID := range nodeSet {
-		cat := CategoryForNode(nodeID)
-		groups[cat] = append(groups[cat], nodeID)
-	}
-
-	for cat := range groups {
-		sort.Strings(groups[cat])
+	for _, n := range g.Nodes() {
+		cat := CategoryForNode(n.ID)
+		groups[cat] = append(groups[cat], n.ID)
 	}
 	return groups
 }
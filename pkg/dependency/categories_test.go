@@ -29,6 +29,8 @@ func TestCategoryForNode(t *testing.T) {
 		{"HPA is autoscaling", "HorizontalPodAutoscaler/web-hpa", "autoscaling"},
 		{"PDB is autoscaling", "PodDisruptionBudget/web-pdb", "autoscaling"},
 		{"Unknown kind is other", "CustomResource/foo", "other"},
+		{"Namespaced ID keeps category", "Secret/prod/db-creds", "config"},
+		{"Custom group shadowing builtin is other", "Service.serving.knative.dev/prod/hello", "other"},
 		{"No slash falls back to other", "orphan", "other"},
 	}
 
@@ -57,7 +59,15 @@ func TestGroupNodesByCategory(t *testing.T) {
 		},
 	}
 
-	groups := GroupNodesByCategory(deps)
+	g := NewGraph()
+	for parent, edges := range deps {
+		for _, e := range edges {
+			e.ParentID = parent
+			g.AddEdge(e)
+		}
+	}
+
+	groups := GroupNodesByCategory(g)
 
 	assert.Equal(t, []string{"Deployment/web"}, groups["workloads"])
 	assert.Equal(t, []string{"Service/frontend"}, groups["networking"])
@@ -0,0 +1,105 @@
+package dependency
+
+import (
+	"sort"
+)
+
+// Cycles returns the strongly connected components of the graph that contain
+// a cycle: components with more than one node, or a single node with an edge
+// to itself. Each component is sorted by ID and components are sorted by
+// their first ID. Ownership loops and mutual references from config-driven
+// rules show up here.
+func Cycles(g *Graph) [][]string {
+	t := &tarjan{
+		g:       g,
+		index:   make(map[string]int),
+		lowlink: make(map[string]int),
+		onStack: make(map[string]bool),
+	}
+	for _, n := range g.Nodes() {
+		if _, visited := t.index[n.ID]; !visited {
+			t.connect(n.ID)
+		}
+	}
+
+	var cycles [][]string
+	for _, scc := range t.components {
+		if len(scc) == 1 && !g.hasSelfLoop(scc[0]) {
+			continue
+		}
+		sort.Strings(scc)
+		cycles = append(cycles, scc)
+	}
+	sort.Slice(cycles, func(i, j int) bool { return cycles[i][0] < cycles[j][0] })
+	return cycles
+}
+
+// CycleEdges returns the edges that run between nodes of the same cycle, in
+// AllEdges order.
+func CycleEdges(g *Graph, cycles [][]string) []Edge {
+	component := make(map[string]int)
+	for i, scc := range cycles {
+		for _, id := range scc {
+			component[id] = i + 1
+		}
+	}
+	var edges []Edge
+	for _, e := range g.AllEdges() {
+		if c := component[e.ParentID]; c != 0 && c == component[e.ChildID] {
+			edges = append(edges, e)
+		}
+	}
+	return edges
+}
+
+func (g *Graph) hasSelfLoop(id string) bool {
+	for _, e := range g.out[id] {
+		if e.ChildID == id {
+			return true
+		}
+	}
+	return false
+}
+
+// tarjan holds the state of Tarjan's strongly connected components algorithm.
+type tarjan struct {
+	g          *Graph
+	next       int
+	index      map[string]int
+	lowlink    map[string]int
+	stack      []string
+	onStack    map[string]bool
+	components [][]string
+}
+
+func (t *tarjan) connect(id string) {
+	t.index[id] = t.next
+	t.lowlink[id] = t.next
+	t.next++
+	t.stack = append(t.stack, id)
+	t.onStack[id] = true
+
+	for _, child := range t.g.Neighbors(id) {
+		if _, visited := t.index[child]; !visited {
+			t.connect(child)
+			t.lowlink[id] = min(t.lowlink[id], t.lowlink[child])
+		} else if t.onStack[child] {
+			t.lowlink[id] = min(t.lowlink[id], t.index[child])
+		}
+	}
+
+	if t.lowlink[id] != t.index[id] {
+		return
+	}
+	var scc []string
+	for {
+		top := t.stack[len(t.stack)-1]
+		t.stack = t.stack[:len(t.stack)-1]
+		t.onStack[top] = false
+		scc = append(scc, top)
+		if top == id {
+			break
+		}
+	}
+	t.components = append(t.components, scc)
+}
@@ -0,0 +1,78 @@
+package dependency_test
+
+import (
+	"encoding/json"
+	"strings"
+	"testing"
+
+	"github.com/HMetcalfeW/cartographer/pkg/dependency"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// cyclicGraph has a three-node cycle, a self-reference, and an acyclic tail
+// hanging off the cycle.
+func cyclicGraph() *dependency.Graph {
+	return graphFromEdges(map[string][]dependency.Edge{
+		"Foo.example.com/a": {{ChildID: "Foo.example.com/b", Reason: "custom"}},
+		"Foo.example.com/b": {{ChildID: "Foo.example.com/c", Reason: "custom"}, {ChildID: "Secret/tail", Reason: "secretRef"}},
+		"Foo.example.com/c": {{ChildID: "Foo.example.com/a", Reason: "ownerRef"}},
+		"Bar.example.com/x": {{ChildID: "Bar.example.com/x", Reason: "custom"}},
+		"Deployment/web":    {{ChildID: "Secret/tail", Reason: "secretRef"}},
+	})
+}
+
+func TestCycles(t *testing.T) {
+	assert.Equal(t, [][]string{
+		{"Bar.example.com/x"},
+		{"Foo.example.com/a", "Foo.example.com/b", "Foo.example.com/c"},
+	}, dependency.Cycles(cyclicGraph()))
+
+	assert.Empty(t, dependency.Cycles(impactGraph()))
+}
+
+func TestCycleEdges(t *testing.T) {
+	g := cyclicGraph()
+	edges := dependency.CycleEdges(g, dependency.Cycles(g))
+
+	var got []string
+	for _, e := range edges {
+		got = append(got, e.ParentID+" -> "+e.ChildID)
+	}
+	assert.Equal(t, []string{
+		"Bar.example.com/x -> Bar.example.com/x",
+		"Foo.example.com/a -> Foo.example.com/b",
+		"Foo.example.com/b -> Foo.example.com/c",
+		"Foo.example.com/c -> Foo.example.com/a",
+	}, got)
+}
+
+func TestHighlightedEdgesRendering(t *testing.T) {
+	g := cyclicGraph()
+	g.HighlightEdges(dependency.CycleEdges(g, dependency.Cycles(g))...)
+
+	dot := dependency.GenerateDOT(g)
+	assert.Contains(t, dot, `"Foo.example.com/c" -> "Foo.example.com/a" [label="ownerRef", color="#1F3A93", fontcolor="#1F3A93", penwidth=3];`)
+	assert.Contains(t, dot, `"Foo.example.com/b" -> "Secret/tail" [label="secretRef"];`)
+
+	// Edges are sorted by parent: Bar/x, Deployment/web, Foo/a, Foo/b (c),
+	// Foo/b (tail), Foo/c.
+	mermaid := dependency.GenerateMermaid(g)
+	assert.Contains(t, mermaid, "linkStyle 0,2,3,5 stroke:#1F3A93,stroke-width:4px")
+
+	sub := g.Subgraph([]string{"Foo.example.com/a", "Foo.example.com/b"})
+	require.Len(t, sub.AllEdges(), 1)
+	assert.True(t, sub.IsEdgeHighlighted(sub.AllEdges()[0]))
+	assert.False(t, strings.Contains(dependency.GenerateDOT(impactGraph()), "fontcolor="))
+}
+
+func TestGenerateJSONCycles(t *testing.T) {
+	var graph dependency.JSONGraph
+	require.NoError(t, json.Unmarshal([]byte(dependency.GenerateJSON(cyclicGraph())), &graph))
+	assert.Equal(t, [][]string{
+		{"Bar.example.com/x"},
+		{"Foo.example.com/a", "Foo.example.com/b", "Foo.example.com/c"},
+	}, graph.Cycles)
+
+	assert.NotContains(t, dependency.GenerateJSON(impactGraph()), `"cycles"`)
+}
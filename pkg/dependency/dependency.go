@@ -2,100 +2,210 @@ package dependency
 
 import (
 	"fmt"
+	"runtime"
+	"strings"
+	"sync"
+	"sync/atomic"
 
 	log "github.com/sirupsen/logrus"
 	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
 )
 
 // BuildDependencies analyzes a slice of unstructured Kubernetes objects and
-// identifies their interdependencies. It returns a map where each key is a
-// "parent" resource identifier ("Kind/Name"), and each value is a slice of
-// Edge structures describing the child resource and the reason for the link.
-func BuildDependencies(objs []*unstructured.Unstructured) map[string][]Edge {
+// identifies their interdependencies using the handlers in the default
+// registry. It returns a Graph holding a node for every object (plus any
+// resource that is only referenced) and an Edge for every reference,
+// annotated with the reason for the link. References are resolved in the
+// referring object's namespace unless the reference names a namespace
+// explicitly. Duplicate edges are collapsed.
+func BuildDependencies(objs []*unstructured.Unstructured) *Graph {
+	return BuildDependenciesWith(objs, defaultRegistry)
+}
+
+// BuildDependenciesWith is BuildDependencies using the handlers and Pod spec
+// paths in reg instead of the default registry. It uses one worker per
+// available CPU (see BuildDependenciesConcurrent).
+func BuildDependenciesWith(objs []*unstructured.Unstructured, reg *Registry) *Graph {
+	return BuildDependenciesConcurrent(objs, reg, runtime.GOMAXPROCS(0))
+}
+
+// BuildDependenciesConcurrent is BuildDependenciesWith running the handlers on
+// up to workers goroutines. Each worker claims batches of objects and records
+// their edges in per-object buffers, which are then merged into the graph in
+// input order, so the result is identical to a sequential build regardless of
+// the number of workers or how they were scheduled. Handlers must therefore be
+// safe for concurrent use. A workers value below 1 is treated as 1.
+func BuildDependenciesConcurrent(objs []*unstructured.Unstructured, reg *Registry, workers int) *Graph {
 	mainLogger := log.WithFields(log.Fields{
-		"func":  "BuildDependencies",
-		"count": len(objs),
+		"func":    "BuildDependencies",
+		"count":   len(objs),
+		"workers": workers,
 	})
-	mainLogger.Info("Starting dependency analysis")
+	mainLogger.Debug("Starting dependency analysis")
 
-	deps := make(map[string][]Edge)
+	g := NewGraph()
 
-	// Ensure every resource appears in the map, even if it has no edges.
+	// Ensure every resource appears in the graph, even if it has no edges.
 	for _, obj := range objs {
-		deps[ResourceID(obj)] = []Edge{}
+		g.AddObject(obj)
 	}
 
-	// Process ownerReferences (Owner -> Child).
-	for _, obj := range objs {
-		childID := ResourceID(obj)
-		for _, owner := range obj.GetOwnerReferences() {
-			ownerID := fmt.Sprintf("%s/%s", owner.Kind, owner.Name)
-			deps[ownerID] = append(deps[ownerID], Edge{ChildID: childID, Reason: "ownerRef"})
-		}
+	// Build a label index for O(n) selector lookups and a claim index for
+	// StatefulSet volume claims, then discover every object's edges
+	// concurrently.
+	ctx := &HandlerContext{
+		Objects: objs,
+		Labels:  BuildLabelIndex(objs, reg),
+		claims:  buildClaimIndex(objs),
 	}
+	found := discoverEdges(objs, reg, ctx, workers)
 
-	// Build a label index for O(n) selector lookups, then process all
-	// resource-specific handlers in a single pass.
-	labelIdx := BuildLabelIndex(objs)
-	for _, obj := range objs {
-		switch obj.GetKind() {
-		case "Service":
-			handleServiceLabelSelector(obj, labelIdx, deps)
-		case "NetworkPolicy":
-			handleNetworkPolicy(obj, labelIdx, deps)
-		case "PodDisruptionBudget":
-			handlePodDisruptionBudget(obj, labelIdx, deps)
-		case "Ingress":
-			handleIngressReferences(obj, deps)
-		case "HorizontalPodAutoscaler":
-			handleHPAReferences(obj, deps)
-		case "RoleBinding", "ClusterRoleBinding":
-			handleRoleBinding(obj, deps)
+	// Merge in input order: ownerReferences of every object first, then the
+	// handler and Pod spec references, matching the order a single pass over
+	// the objects would add them.
+	for _, f := range found {
+		for _, e := range f.owners {
+			g.AddEdge(e)
 		}
-
-		// Pod spec references (Secrets, ConfigMaps, PVCs, ServiceAccounts).
-		if IsPodOrController(obj) {
-			gatherPodSpecEdges(obj, deps)
+	}
+	for _, f := range found {
+		for _, e := range f.refs {
+			g.AddEdge(e)
 		}
 	}
 
-	// Deduplicate edges for each parent.
-	for parent, edges := range deps {
-		deps[parent] = deduplicateEdges(edges)
+	warnMissing(g)
+
+	mainLogger.WithField("nodes", g.Len()).Debug("Finished building dependencies")
+	return g
+}
+
+// discoverBatchSize is how many consecutive objects a worker claims at a time.
+// Batches keep workers balanced when expensive objects (e.g. NetworkPolicies
+// with expression-only selectors) are clustered together in the input.
+const discoverBatchSize = 64
+
+// objectEdges buffers the edges discovered for a single object.
+type objectEdges struct {
+	owners []Edge
+	refs   []Edge
+}
+
+// discoverEdges runs ownerReference, handler and Pod spec discovery for every
+// object on up to workers goroutines. found[i] holds the edges of objs[i];
+// each slot is written by exactly one worker, so no locking is needed.
+func discoverEdges(objs []*unstructured.Unstructured, reg *Registry, ctx *HandlerContext, workers int) []objectEdges {
+	found := make([]objectEdges, len(objs))
+	batches := (len(objs) + discoverBatchSize - 1) / discoverBatchSize
+	workers = max(1, min(workers, batches))
+
+	var next atomic.Int64
+	var wg sync.WaitGroup
+	for range workers {
+		wg.Go(func() {
+			for {
+				start := int(next.Add(1)-1) * discoverBatchSize
+				if start >= len(objs) {
+					return
+				}
+				for i := start; i < min(start+discoverBatchSize, len(objs)); i++ {
+					found[i] = discoverObjectEdges(objs[i], reg, ctx)
+				}
+			}
+		})
+	}
+	wg.Wait()
+	return found
+}
+
+// discoverObjectEdges returns the edges of a single object. Owners live in the
+// object's namespace unless they are cluster-scoped. Handlers are keyed by
+// GroupKind, so custom resources that share a built-in kind name (e.g. a
+// Knative Service) do not reach the built-in handlers.
+func discoverObjectEdges(obj *unstructured.Unstructured, reg *Registry, ctx *HandlerContext) objectEdges {
+	var f objectEdges
+
+	// ownerReferences (Owner -> Child).
+	childID := ResourceID(obj)
+	for i, owner := range obj.GetOwnerReferences() {
+		ownerID := RefID(groupOf(owner.APIVersion), owner.Kind, obj.GetNamespace(), owner.Name)
+		f.owners = append(f.owners, Edge{
+			ParentID: ownerID,
+			ChildID:  childID,
+			Reason:   "ownerRef",
+			Field:    fmt.Sprintf("metadata.ownerReferences[%d]", i),
+		})
+	}
+
+	gk := obj.GroupVersionKind().GroupKind()
+	for _, h := range reg.Handlers(gk) {
+		f.refs = append(f.refs, h.Handle(obj, ctx)...)
+	}
+
+	// Pod spec references (Secrets, ConfigMaps, PVCs, ServiceAccounts).
+	if path, ok := reg.PodSpecPath(gk); ok {
+		f.refs = append(f.refs, gatherPodSpecEdges(obj, path)...)
 	}
+	return f
+}
+
+// maxMissingLogged caps how many missing IDs are listed in the warning.
+const maxMissingLogged = 10
 
-	mainLogger.WithField("dependencies_count", len(deps)).Info("Finished building dependencies")
-	return deps
+// warnMissing logs a summary of references whose target is not in the input.
+func warnMissing(g *Graph) {
+	missing := g.MissingNodes()
+	if len(missing) == 0 {
+		return
+	}
+	ids := make([]string, 0, maxMissingLogged)
+	for i, n := range missing {
+		if i == maxMissingLogged {
+			ids = append(ids, fmt.Sprintf("... and %d more", len(missing)-maxMissingLogged))
+			break
+		}
+		ids = append(ids, n.ID)
+	}
+	log.WithFields(log.Fields{
+		"func":    "BuildDependencies",
+		"missing": len(missing),
+		"ids":     strings.Join(ids, ", "),
+	}).Warn("Found references to resources that are not in the input")
 }
 
-// gatherPodSpecEdges extracts pod spec references from a pod or controller
-// and appends them as edges to the dependency map.
-func gatherPodSpecEdges(obj *unstructured.Unstructured, deps map[string][]Edge) {
-	podSpec, found, err := GetPodSpec(obj)
+// gatherPodSpecEdges extracts pod spec references from the Pod spec found at
+// path within obj and returns them as edges.
+func gatherPodSpecEdges(obj *unstructured.Unstructured, path []string) []Edge {
+	podSpec, found, err := unstructured.NestedMap(obj.Object, path...)
 	if err != nil {
 		log.WithFields(log.Fields{
 			"func": "gatherPodSpecEdges",
 			"kind": obj.GetKind(),
 			"name": obj.GetName(),
 		}).WithError(err).Warn("Error retrieving podSpec")
-		return
+		return nil
 	}
 	if !found || podSpec == nil {
-		return
+		return nil
 	}
 
 	parentID := ResourceID(obj)
-	secrets, configMaps, pvcs, serviceAccounts := GatherPodSpecReferences(podSpec)
+	prefix := strings.Join(path, ".")
+	secrets, configMaps, pvcs, serviceAccounts := GatherPodSpecReferences(podSpec, obj.GetNamespace())
 
-	appendEdges(deps, parentID, secrets, "secretRef")
-	appendEdges(deps, parentID, configMaps, "configMapRef")
-	appendEdges(deps, parentID, pvcs, "pvcRef")
-	appendEdges(deps, parentID, serviceAccounts, "serviceAccountName")
+	var edges []Edge
+	edges = appendEdges(edges, parentID, prefix, secrets, "secretRef")
+	edges = appendEdges(edges, parentID, prefix, configMaps, "configMapRef")
+	edges = appendEdges(edges, parentID, prefix, pvcs, "pvcRef")
+	edges = appendEdges(edges, parentID, prefix, serviceAccounts, "serviceAccountName")
+	return edges
 }
 
-// appendEdges adds an edge from parentID to each child with the given reason.
-func appendEdges(deps map[string][]Edge, parentID string, children []string, reason string) {
+// appendEdges appends an edge from parentID to each referenced child with the
+// given reason, prefixing each reference's field path with the Pod spec path.
+func appendEdges(edges []Edge, parentID, prefix string, children []Reference, reason string) []Edge {
 	for _, child := range children {
-		deps[parentID] = append(deps[parentID], Edge{ChildID: child, Reason: reason})
+		edges = append(edges, Edge{ParentID: parentID, ChildID: child.ID, Reason: reason, Field: joinField(prefix, child.Field), Optional: child.Optional})
 	}
+	return edges
 }
@@ -0,0 +1,129 @@
+package dependency_test
+
+import (
+	"fmt"
+	"runtime"
+	"testing"
+
+	"github.com/HMetcalfeW/cartographer/pkg/dependency"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+)
+
+// syntheticApp returns the objects of one application in a namespace: a
+// Deployment with its ReplicaSet, a Service, ConfigMap, Secret, PDB, HPA,
+// Ingress and an expression-only NetworkPolicy, roughly the mix found in a
+// large cluster.
+func syntheticApp(ns string, i int) []*unstructured.Unstructured {
+	name := fmt.Sprintf("app-%d", i)
+	tier := []string{"frontend", "backend", "worker"}[i%3]
+	labels := map[string]interface{}{"app": name, "tier": tier}
+	meta := func(extra map[string]interface{}) map[string]interface{} {
+		m := map[string]interface{}{"name": name, "namespace": ns}
+		for k, v := range extra {
+			m[k] = v
+		}
+		return m
+	}
+	podSpec := map[string]interface{}{
+		"serviceAccountName": name,
+		"containers": []interface{}{map[string]interface{}{
+			"name":  name,
+			"image": "nginx",
+			"envFrom": []interface{}{
+				map[string]interface{}{"configMapRef": map[string]interface{}{"name": name}},
+				map[string]interface{}{"secretRef": map[string]interface{}{"name": name}},
+			},
+		}},
+	}
+
+	objs := []map[string]interface{}{
+		{"apiVersion": "apps/v1", "kind": "Deployment", "metadata": meta(map[string]interface{}{"labels": labels}),
+			"spec": map[string]interface{}{"template": map[string]interface{}{"spec": podSpec}}},
+		{"apiVersion": "apps/v1", "kind": "ReplicaSet", "metadata": meta(map[string]interface{}{
+			"labels": labels,
+			"ownerReferences": []interface{}{map[string]interface{}{
+				"apiVersion": "apps/v1", "kind": "Deployment", "name": name, "uid": name,
+			}},
+		}), "spec": map[string]interface{}{"template": map[string]interface{}{"spec": podSpec}}},
+		{"apiVersion": "v1", "kind": "Service", "metadata": meta(nil),
+			"spec": map[string]interface{}{"selector": map[string]interface{}{"app": name}}},
+		{"apiVersion": "v1", "kind": "ConfigMap", "metadata": meta(nil)},
+		{"apiVersion": "v1", "kind": "Secret", "metadata": meta(nil)},
+		{"apiVersion": "policy/v1", "kind": "PodDisruptionBudget", "metadata": meta(nil),
+			"spec": map[string]interface{}{"selector": map[string]interface{}{"matchLabels": map[string]interface{}{"app": name}}}},
+		{"apiVersion": "autoscaling/v2", "kind": "HorizontalPodAutoscaler", "metadata": meta(nil),
+			"spec": map[string]interface{}{"scaleTargetRef": map[string]interface{}{"kind": "Deployment", "name": name}}},
+		{"apiVersion": "networking.k8s.io/v1", "kind": "Ingress", "metadata": meta(nil),
+			"spec": map[string]interface{}{"rules": []interface{}{map[string]interface{}{
+				"http": map[string]interface{}{"paths": []interface{}{map[string]interface{}{
+					"path": "/", "backend": map[string]interface{}{"service": map[string]interface{}{"name": name}},
+				}}},
+			}}}},
+		{"apiVersion": "networking.k8s.io/v1", "kind": "NetworkPolicy", "metadata": meta(nil),
+			"spec": map[string]interface{}{"podSelector": map[string]interface{}{"matchExpressions": []interface{}{
+				map[string]interface{}{"key": "app", "operator": "In", "values": []interface{}{name}},
+				map[string]interface{}{"key": "tier", "operator": "Exists"},
+			}}}},
+	}
+
+	result := make([]*unstructured.Unstructured, len(objs))
+	for j, o := range objs {
+		result[j] = &unstructured.Unstructured{Object: o}
+	}
+	return result
+}
+
+// syntheticObjects returns about n objects spread over ten namespaces.
+func syntheticObjects(n int) []*unstructured.Unstructured {
+	var objs []*unstructured.Unstructured
+	for i := 0; len(objs) < n; i++ {
+		objs = append(objs, syntheticApp(fmt.Sprintf("ns-%d", i%10), i)...)
+	}
+	return objs
+}
+
+// BenchmarkBuildDependencies compares a sequential build with one using a
+// worker per CPU, e.g.:
+//
+//	go test ./pkg/dependency -run '^$' -bench BuildDependencies -cpu 8
+func BenchmarkBuildDependencies(b *testing.B) {
+	reg := dependency.DefaultRegistry()
+	workerCounts := []int{1}
+	if procs := runtime.GOMAXPROCS(0); procs > 1 {
+		workerCounts = append(workerCounts, procs)
+	}
+	for _, n := range []int{1000, 10000, 40000} {
+		objs := syntheticObjects(n)
+		for _, workers := range workerCounts {
+			b.Run(fmt.Sprintf("objects=%d/workers=%d", n, workers), func(b *testing.B) {
+				for b.Loop() {
+					dependency.BuildDependenciesConcurrent(objs, reg, workers)
+				}
+			})
+		}
+	}
+}
+
+// BenchmarkLabelIndexMatchSelector measures selector lookups against a large
+// index, including expression-only selectors that cannot use the label keys.
+func BenchmarkLabelIndexMatchSelector(b *testing.B) {
+	idx := dependency.BuildLabelIndex(syntheticObjects(40000), dependency.DefaultRegistry())
+	in := []dependency.LabelSelectorRequirement{{Key: "app", Operator: "In", Values: []string{"app-13", "app-23"}}}
+	notIn := []dependency.LabelSelectorRequirement{{Key: "tier", Operator: "NotIn", Values: []string{"backend"}}}
+
+	b.Run("matchLabels", func(b *testing.B) {
+		for b.Loop() {
+			idx.MatchSelector("ns-3", map[string]string{"app": "app-13"}, nil)
+		}
+	})
+	b.Run("matchExpressionsIn", func(b *testing.B) {
+		for b.Loop() {
+			idx.MatchSelector("ns-3", nil, in)
+		}
+	})
+	b.Run("matchExpressionsNotIn", func(b *testing.B) {
+		for b.Loop() {
+			idx.MatchSelector("ns-3", nil, notIn)
+		}
+	})
+}
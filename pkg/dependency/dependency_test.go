@@ -1,6 +1,8 @@
 package dependency_test
 
 import (
+	"os"
+	"path/filepath"
 	"testing"
 
 	log "github.com/sirupsen/logrus"
@@ -28,8 +30,9 @@ func TestBuildDependencies(t *testing.T) {
 				"name": "my-deploy",
 				"ownerReferences": []interface{}{
 					map[string]interface{}{
-						"kind": "HelmRelease",
-						"name": "my-release",
+						"apiVersion": "helm.example.com/v1",
+						"kind":       "HelmRelease",
+						"name":       "my-release",
 					},
 				},
 			},
@@ -206,13 +209,13 @@ func TestBuildDependencies(t *testing.T) {
 	deps := dependency.BuildDependencies(objs)
 
 	// Confirm the HelmRelease -> Deployment
-	hrEdges := deps["HelmRelease/my-release"]
+	hrEdges := deps.Edges("HelmRelease.helm.example.com/my-release")
 	require.Len(t, hrEdges, 1)
 	assert.Equal(t, "Deployment/my-deploy", hrEdges[0].ChildID)
 	assert.Equal(t, "ownerRef", hrEdges[0].Reason)
 
 	// Confirm the Deployment -> Secret, ConfigMap, ServiceAccount
-	depEdges := deps["Deployment/my-deploy"]
+	depEdges := deps.Edges("Deployment/my-deploy")
 	require.Len(t, depEdges, 3, "expected 3 references from Deployment/my-deploy")
 
 	var secretRef, cmRef, saRef bool
@@ -232,13 +235,13 @@ func TestBuildDependencies(t *testing.T) {
 	assert.True(t, saRef, "Expected serviceAccountName to my-sa")
 
 	// Confirm the Service -> Pod (label selector)
-	svcEdges := deps["Service/my-service"]
+	svcEdges := deps.Edges("Service/my-service")
 	require.Len(t, svcEdges, 1)
 	assert.Equal(t, "Pod/my-pod", svcEdges[0].ChildID)
 	assert.Equal(t, "selector", svcEdges[0].Reason)
 
 	// Confirm the Ingress references
-	ingEdges := deps["Ingress/my-ing"]
+	ingEdges := deps.Edges("Ingress/my-ing")
 	require.Len(t, ingEdges, 2, "expected 2 edges from Ingress: service, secret")
 	var svcFound, tlsFound bool
 	for _, e := range ingEdges {
@@ -253,7 +256,7 @@ func TestBuildDependencies(t *testing.T) {
 	assert.True(t, tlsFound, "Expected tlsSecret to Secret/tls-secret")
 
 	// Confirm the HPA
-	hpaEdges := deps["HorizontalPodAutoscaler/my-hpa"]
+	hpaEdges := deps.Edges("HorizontalPodAutoscaler/my-hpa")
 	require.Len(t, hpaEdges, 1)
 	assert.Equal(t, "Deployment/my-deploy", hpaEdges[0].ChildID)
 	assert.Equal(t, "scaleTargetRef", hpaEdges[0].Reason)
@@ -400,7 +403,7 @@ spec:
 	deps := dependency.BuildDependencies(objs)
 
 	// Deployment → Secret (volume), Secret (env secretKeyRef), ConfigMap (envFrom), ServiceAccount
-	deployEdges := deps["Deployment/web"]
+	deployEdges := deps.Edges("Deployment/web")
 	edgeSet := make(map[string]string)
 	for _, e := range deployEdges {
 		edgeSet[e.ChildID] = e.Reason
@@ -411,13 +414,13 @@ spec:
 	assert.Equal(t, "serviceAccountName", edgeSet["ServiceAccount/web-sa"], "Deployment should ref web-sa")
 
 	// Service → Deployment (selector)
-	svcEdges := deps["Service/web-svc"]
+	svcEdges := deps.Edges("Service/web-svc")
 	require.Len(t, svcEdges, 1)
 	assert.Equal(t, "Deployment/web", svcEdges[0].ChildID)
 	assert.Equal(t, "selector", svcEdges[0].Reason)
 
 	// Ingress → Service + TLS Secret
-	ingEdges := deps["Ingress/web-ingress"]
+	ingEdges := deps.Edges("Ingress/web-ingress")
 	require.Len(t, ingEdges, 2)
 	ingEdgeSet := make(map[string]string)
 	for _, e := range ingEdges {
@@ -427,19 +430,19 @@ spec:
 	assert.Equal(t, "tlsSecret", ingEdgeSet["Secret/tls-cert"])
 
 	// HPA → Deployment
-	hpaEdges := deps["HorizontalPodAutoscaler/web-hpa"]
+	hpaEdges := deps.Edges("HorizontalPodAutoscaler/web-hpa")
 	require.Len(t, hpaEdges, 1)
 	assert.Equal(t, "Deployment/web", hpaEdges[0].ChildID)
 	assert.Equal(t, "scaleTargetRef", hpaEdges[0].Reason)
 
 	// PDB → Deployment (pdbSelector)
-	pdbEdges := deps["PodDisruptionBudget/web-pdb"]
+	pdbEdges := deps.Edges("PodDisruptionBudget/web-pdb")
 	require.Len(t, pdbEdges, 1)
 	assert.Equal(t, "Deployment/web", pdbEdges[0].ChildID)
 	assert.Equal(t, "pdbSelector", pdbEdges[0].Reason)
 
 	// NetworkPolicy → Deployment (podSelector)
-	npEdges := deps["NetworkPolicy/web-netpol"]
+	npEdges := deps.Edges("NetworkPolicy/web-netpol")
 	require.Len(t, npEdges, 1)
 	assert.Equal(t, "Deployment/web", npEdges[0].ChildID)
 	assert.Equal(t, "podSelector", npEdges[0].Reason)
@@ -525,7 +528,7 @@ spec:
 	deps := dependency.BuildDependencies(objs)
 
 	// NetworkPolicy/prod-only → web + api (env In [prod])
-	npProdEdges := deps["NetworkPolicy/prod-only"]
+	npProdEdges := deps.Edges("NetworkPolicy/prod-only")
 	require.Len(t, npProdEdges, 2, "prod-only should match web and api")
 	npProdTargets := map[string]bool{}
 	for _, e := range npProdEdges {
@@ -537,13 +540,13 @@ spec:
 	assert.False(t, npProdTargets["Deployment/worker"])
 
 	// PDB/backend-pdb → api only (tier=backend AND env NotIn [staging])
-	pdbEdges := deps["PodDisruptionBudget/backend-pdb"]
+	pdbEdges := deps.Edges("PodDisruptionBudget/backend-pdb")
 	require.Len(t, pdbEdges, 1, "backend-pdb: tier=backend AND env NotIn [staging] → api only")
 	assert.Equal(t, "Deployment/api", pdbEdges[0].ChildID)
 	assert.Equal(t, "pdbSelector", pdbEdges[0].Reason)
 
 	// NetworkPolicy/all-with-app → web + api + worker (app Exists)
-	npAllEdges := deps["NetworkPolicy/all-with-app"]
+	npAllEdges := deps.Edges("NetworkPolicy/all-with-app")
 	require.Len(t, npAllEdges, 3, "all-with-app should match all three deployments")
 }
 
@@ -619,12 +622,12 @@ spec:
 	deps := dependency.BuildDependencies(objs)
 
 	// redis-master-only → only redis-master (name=redis AND component In [master])
-	npMasterEdges := deps["NetworkPolicy/redis-master-only"]
+	npMasterEdges := deps.Edges("NetworkPolicy/redis-master-only")
 	require.Len(t, npMasterEdges, 1)
 	assert.Equal(t, "Deployment/redis-master", npMasterEdges[0].ChildID)
 
 	// redis-all PDB → both redis deployments
-	pdbEdges := deps["PodDisruptionBudget/redis-all"]
+	pdbEdges := deps.Edges("PodDisruptionBudget/redis-all")
 	require.Len(t, pdbEdges, 2, "redis-all should match redis-master and redis-replicas")
 	pdbTargets := map[string]bool{}
 	for _, e := range pdbEdges {
@@ -635,7 +638,7 @@ spec:
 	assert.False(t, pdbTargets["Deployment/postgres"])
 
 	// not-postgres → redis-master + redis-replicas (NotIn [postgres])
-	npNotPgEdges := deps["NetworkPolicy/not-postgres"]
+	npNotPgEdges := deps.Edges("NetworkPolicy/not-postgres")
 	require.Len(t, npNotPgEdges, 2)
 	npNotPgTargets := map[string]bool{}
 	for _, e := range npNotPgEdges {
@@ -805,16 +808,17 @@ spec:
 	deps := dependency.BuildDependencies(objs)
 
 	// RoleBinding → Role (roleRef)
-	rbEdges := deps["RoleBinding/app-binding"]
+	rbEdges := deps.Edges("RoleBinding/app-binding")
 	edgeSet := map[string]string{}
 	for _, e := range rbEdges {
 		edgeSet[e.ChildID] = e.Reason
 	}
 	assert.Equal(t, "roleRef", edgeSet["Role/app-role"])
-	assert.Equal(t, "subject", edgeSet["ServiceAccount/app-sa"])
+	// The subject names its namespace explicitly, so it resolves there.
+	assert.Equal(t, "subject", edgeSet["ServiceAccount/default/app-sa"])
 
 	// Deployment → ServiceAccount
-	deployEdges := deps["Deployment/web"]
+	deployEdges := deps.Edges("Deployment/web")
 	deployEdgeSet := map[string]string{}
 	for _, e := range deployEdges {
 		deployEdgeSet[e.ChildID] = e.Reason
@@ -824,7 +828,7 @@ spec:
 	// Verify DOT output includes RBAC edges
 	dot := dependency.GenerateDOT(deps)
 	assert.Contains(t, dot, `"RoleBinding/app-binding" -> "Role/app-role"`)
-	assert.Contains(t, dot, `"RoleBinding/app-binding" -> "ServiceAccount/app-sa"`)
+	assert.Contains(t, dot, `"RoleBinding/app-binding" -> "ServiceAccount/default/app-sa"`)
 }
 
 // TestBuildDependencies_RBAC_HelmStyle tests RBAC with Helm-style labels and
@@ -880,7 +884,7 @@ subjects:
 	deps := dependency.BuildDependencies(objs)
 
 	// ClusterRoleBinding → ClusterRole + 2 ServiceAccounts (Group skipped)
-	crbEdges := deps["ClusterRoleBinding/monitoring-binding"]
+	crbEdges := deps.Edges("ClusterRoleBinding/monitoring-binding")
 	require.Len(t, crbEdges, 3)
 
 	edgeSet := map[string]string{}
@@ -888,8 +892,8 @@ subjects:
 		edgeSet[e.ChildID] = e.Reason
 	}
 	assert.Equal(t, "roleRef", edgeSet["ClusterRole/monitoring-reader"])
-	assert.Equal(t, "subject", edgeSet["ServiceAccount/prometheus"])
-	assert.Equal(t, "subject", edgeSet["ServiceAccount/grafana"])
+	assert.Equal(t, "subject", edgeSet["ServiceAccount/monitoring/prometheus"])
+	assert.Equal(t, "subject", edgeSet["ServiceAccount/monitoring/grafana"])
 
 	// Verify JSON output includes RBAC edges
 	jsonOut := dependency.GenerateJSON(deps)
@@ -898,3 +902,248 @@ subjects:
 	assert.Contains(t, jsonOut, "roleRef")
 	assert.Contains(t, jsonOut, "subject")
 }
+
+// TestBuildDependencies_MultiNamespace verifies that same-named objects in
+// different namespaces stay distinct, references resolve in the referring
+// object's namespace, and selectors never cross namespaces.
+func TestBuildDependencies_MultiNamespace(t *testing.T) {
+	manifest := `
+apiVersion: v1
+kind: Secret
+metadata:
+  name: db
+  namespace: prod
+---
+apiVersion: v1
+kind: Secret
+metadata:
+  name: db
+  namespace: staging
+---
+apiVersion: apps/v1
+kind: Deployment
+metadata:
+  name: api
+  namespace: prod
+  labels:
+    app: api
+spec:
+  template:
+    spec:
+      containers:
+        - name: api
+          envFrom:
+            - secretRef:
+                name: db
+---
+apiVersion: apps/v1
+kind: Deployment
+metadata:
+  name: api
+  namespace: staging
+  labels:
+    app: api
+spec:
+  template:
+    spec:
+      containers:
+        - name: api
+          envFrom:
+            - secretRef:
+                name: db
+---
+apiVersion: v1
+kind: Service
+metadata:
+  name: api
+  namespace: prod
+spec:
+  selector:
+    app: api
+---
+apiVersion: serving.knative.dev/v1
+kind: Service
+metadata:
+  name: api
+  namespace: prod
+spec:
+  template:
+    spec:
+      containers:
+        - image: example/api
+`
+
+	objs, err := parser.ParseYAML([]byte(manifest))
+	require.NoError(t, err)
+	require.Len(t, objs, 6)
+
+	deps := dependency.BuildDependencies(objs)
+
+	// Every object gets its own node.
+	for _, id := range []string{
+		"Secret/prod/db", "Secret/staging/db",
+		"Deployment/prod/api", "Deployment/staging/api",
+		"Service/prod/api", "Service.serving.knative.dev/prod/api",
+	} {
+		_, ok := deps.Node(id)
+		assert.True(t, ok, "expected node %s", id)
+	}
+
+	// References resolve in the referring object's namespace.
+	require.Len(t, deps.Edges("Deployment/prod/api"), 1)
+	assert.Equal(t, "Secret/prod/db", deps.Edges("Deployment/prod/api")[0].ChildID)
+	require.Len(t, deps.Edges("Deployment/staging/api"), 1)
+	assert.Equal(t, "Secret/staging/db", deps.Edges("Deployment/staging/api")[0].ChildID)
+
+	// The core Service only selects the Deployment in its own namespace.
+	require.Len(t, deps.Edges("Service/prod/api"), 1)
+	assert.Equal(t, "Deployment/prod/api", deps.Edges("Service/prod/api")[0].ChildID)
+
+	// The Knative Service is not treated as a core Service.
+	assert.Empty(t, deps.Edges("Service.serving.knative.dev/prod/api"))
+}
+
+// TestBuildDependencies_EdgeFields verifies every edge records the field path
+// that produced it, and that duplicate edges collect all their paths.
+func TestBuildDependencies_EdgeFields(t *testing.T) {
+	objs, err := parser.ParseYAML([]byte(`
+apiVersion: apps/v1
+kind: Deployment
+metadata:
+  name: web
+  labels:
+    app: web
+spec:
+  template:
+    spec:
+      serviceAccountName: web
+      volumes:
+        - name: data
+          persistentVolumeClaim:
+            claimName: data
+      containers:
+        - name: web
+          image: nginx
+          envFrom:
+            - configMapRef:
+                name: web
+        - name: sidecar
+          image: sidecar
+          env:
+            - name: A
+              value: a
+            - name: B
+              value: b
+            - name: C
+              value: c
+            - name: PASSWORD
+              valueFrom:
+                secretKeyRef:
+                  name: db
+                  key: password
+      initContainers:
+        - name: migrate
+          image: migrate
+          env:
+            - name: PASSWORD
+              valueFrom:
+                secretKeyRef:
+                  name: db
+                  key: password
+---
+apiVersion: v1
+kind: Service
+metadata:
+  name: web
+spec:
+  selector:
+    app: web
+---
+apiVersion: networking.k8s.io/v1
+kind: Ingress
+metadata:
+  name: web
+spec:
+  tls:
+    - secretName: web-tls
+  rules:
+    - http:
+        paths:
+          - path: /
+            backend:
+              service:
+                name: web
+---
+apiVersion: rbac.authorization.k8s.io/v1
+kind: RoleBinding
+metadata:
+  name: web
+roleRef:
+  apiGroup: rbac.authorization.k8s.io
+  kind: Role
+  name: web
+subjects:
+  - kind: User
+    name: alice
+  - kind: ServiceAccount
+    name: web
+`))
+	require.NoError(t, err)
+	g := dependency.BuildDependencies(objs)
+
+	fields := make(map[string]string)
+	for _, e := range g.AllEdges() {
+		fields[e.ParentID+" -> "+e.ChildID] = e.Field
+	}
+	assert.Equal(t, map[string]string{
+		"Deployment/web -> ServiceAccount/web":         "spec.template.spec.serviceAccountName",
+		"Deployment/web -> PersistentVolumeClaim/data": "spec.template.spec.volumes[0].persistentVolumeClaim",
+		"Deployment/web -> ConfigMap/web":              "spec.template.spec.containers[0].envFrom[0].configMapRef",
+		"Deployment/web -> Secret/db":                  "spec.template.spec.containers[1].env[3].valueFrom.secretKeyRef, spec.template.spec.initContainers[0].env[0].valueFrom.secretKeyRef",
+		"Service/web -> Deployment/web":                "spec.selector",
+		"Ingress/web -> Service/web":                   "spec.rules[0].http.paths[0].backend.service",
+		"Ingress/web -> Secret/web-tls":                "spec.tls[0].secretName",
+		"RoleBinding/web -> Role/web":                  "roleRef",
+		"RoleBinding/web -> ServiceAccount/web":        "subjects[1]",
+	}, fields)
+
+	// The in-edge view carries the merged paths too.
+	for _, e := range g.InEdges("Secret/db") {
+		assert.Contains(t, e.Field, "initContainers[0]")
+	}
+}
+
+// TestBuildDependenciesConcurrent_Deterministic verifies that every exporter
+// produces byte-identical output whatever the number of workers.
+func TestBuildDependenciesConcurrent_Deterministic(t *testing.T) {
+	objs := syntheticObjects(2000)
+	reg := dependency.DefaultRegistry()
+
+	want := dependency.BuildDependenciesConcurrent(objs, reg, 1)
+	for _, workers := range []int{0, 2, 4, 16} {
+		got := dependency.BuildDependenciesConcurrent(objs, reg, workers)
+		assert.Equal(t, dependency.GenerateDOT(want), dependency.GenerateDOT(got), "workers=%d", workers)
+		assert.Equal(t, dependency.GenerateJSON(want), dependency.GenerateJSON(got), "workers=%d", workers)
+		assert.Equal(t, dependency.GenerateMermaid(want), dependency.GenerateMermaid(got), "workers=%d", workers)
+		assert.Equal(t, want.AllEdges(), got.AllEdges(), "workers=%d", workers)
+	}
+}
+
+// TestBuildDependenciesConcurrent_Golden compares concurrent builds with the
+// output of the sequential implementation that preceded them. The golden
+// files under testdata were generated from syntheticObjects(150) by the
+// single-pass BuildDependencies before concurrent building was introduced,
+// and must not be regenerated from the current code.
+func TestBuildDependenciesConcurrent_Golden(t *testing.T) {
+	objs := syntheticObjects(150)
+	wantDOT, err := os.ReadFile(filepath.Join("testdata", "synthetic-150.golden.dot"))
+	require.NoError(t, err)
+	wantJSON, err := os.ReadFile(filepath.Join("testdata", "synthetic-150.golden.json"))
+	require.NoError(t, err)
+
+	for _, workers := range []int{1, 2, 4, 16} {
+		g := dependency.BuildDependenciesConcurrent(objs, dependency.DefaultRegistry(), workers)
+		assert.Equal(t, string(wantDOT), dependency.GenerateDOT(g), "workers=%d", workers)
+		assert.Equal(t, string(wantJSON), dependency.GenerateJSON(g), "workers=%d", workers)
+	}
+}
@@ -0,0 +1,396 @@
+package dependency
+
+import (
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strings"
+
+	log "github.com/sirupsen/logrus"
+)
+
+// Change classifies a node or edge in a GraphDiff.
+type Change string
+
+const (
+	ChangeUnchanged Change = "unchanged"
+	ChangeAdded     Change = "added"
+	ChangeRemoved   Change = "removed"
+	// ChangeReason marks an edge present in both graphs whose reason differs.
+	ChangeReason Change = "reasonChanged"
+)
+
+// Colors used for diff output: green added, red removed, grey unchanged and
+// amber for edges whose reason changed.
+const (
+	diffAddedColor     = "#2CA02C"
+	diffAddedFill      = "#D9F2D9"
+	diffRemovedColor   = missingColor
+	diffRemovedFill    = "#F8D7D7"
+	diffUnchangedColor = "#999999"
+	diffUnchangedFill  = "#EEEEEE"
+	diffChangedColor   = "#FF7F0E"
+)
+
+// DiffNode is a node of either graph with its change status. A missing node
+// (a reference target with no object) counts as less present than a real
+// one, so a dangling reference whose object is added in the "to" graph is
+// ChangeAdded, and an object deleted while still referenced is ChangeRemoved.
+type DiffNode struct {
+	ID     string `json:"id"`
+	Change Change `json:"change"`
+}
+
+// DiffEdge is a parent/child pair of either graph with its change status.
+// Reason is the reason in the "to" graph (or the "from" graph for removed
+// edges); OldReason is set only for ChangeReason. When several edges join
+// the same pair, their reasons are compared as one sorted, comma-separated
+// list.
+type DiffEdge struct {
+	ParentID  string `json:"from"`
+	ChildID   string `json:"to"`
+	Reason    string `json:"reason"`
+	OldReason string `json:"oldReason,omitempty"`
+	Change    Change `json:"change"`
+}
+
+// GraphDiff is the difference between two dependency graphs. Nodes and Edges
+// cover both graphs, unchanged entries included, sorted by ID.
+type GraphDiff struct {
+	Nodes []DiffNode
+	Edges []DiffEdge
+}
+
+// Diff compares two dependency graphs by node ID and by parent/child pair.
+func Diff(from, to *Graph) *GraphDiff {
+	d := &GraphDiff{}
+
+	ids := make(map[string]bool)
+	for _, n := range from.Nodes() {
+		ids[n.ID] = true
+	}
+	for _, n := range to.Nodes() {
+		ids[n.ID] = true
+	}
+	for _, id := range sortedKeys(ids) {
+		d.Nodes = append(d.Nodes, DiffNode{ID: id, Change: nodeChange(nodePresence(from, id), nodePresence(to, id))})
+	}
+
+	fromReasons := edgeReasons(from)
+	toReasons := edgeReasons(to)
+	pairs := make(map[[2]string]bool)
+	for p := range fromReasons {
+		pairs[p] = true
+	}
+	for p := range toReasons {
+		pairs[p] = true
+	}
+	for _, p := range sortedPairs(pairs) {
+		oldReason, inFrom := fromReasons[p]
+		newReason, inTo := toReasons[p]
+		e := DiffEdge{ParentID: p[0], ChildID: p[1], Reason: newReason, Change: presenceChange(inFrom, inTo)}
+		switch {
+		case !inTo:
+			e.Reason = oldReason
+		case inFrom && oldReason != newReason:
+			e.OldReason = oldReason
+			e.Change = ChangeReason
+		}
+		d.Edges = append(d.Edges, e)
+	}
+
+	log.WithFields(log.Fields{
+		"func":  "Diff",
+		"nodes": len(d.Nodes),
+		"edges": len(d.Edges),
+	}).Debug("Computed graph diff")
+
+	return d
+}
+
+// Changed reports whether the graphs differ at all.
+func (d *GraphDiff) Changed() bool {
+	for _, n := range d.Nodes {
+		if n.Change != ChangeUnchanged {
+			return true
+		}
+	}
+	for _, e := range d.Edges {
+		if e.Change != ChangeUnchanged {
+			return true
+		}
+	}
+	return false
+}
+
+// presenceChange classifies an entry by the graphs it appears in.
+func presenceChange(inFrom, inTo bool) Change {
+	switch {
+	case inFrom && inTo:
+		return ChangeUnchanged
+	case inTo:
+		return ChangeAdded
+	default:
+		return ChangeRemoved
+	}
+}
+
+// Presence levels of a node ID in one graph, in increasing order.
+const (
+	nodeAbsent = iota
+	nodeMissing
+	nodePresent
+)
+
+// nodePresence reports whether id is absent from g, only a missing
+// reference target, or backed by an object.
+func nodePresence(g *Graph, id string) int {
+	n, ok := g.Node(id)
+	switch {
+	case !ok:
+		return nodeAbsent
+	case n.Missing():
+		return nodeMissing
+	default:
+		return nodePresent
+	}
+}
+
+// nodeChange classifies a node by how its presence changed between the
+// graphs: more present is an addition, less present a removal.
+func nodeChange(from, to int) Change {
+	switch {
+	case to > from:
+		return ChangeAdded
+	case to < from:
+		return ChangeRemoved
+	default:
+		return ChangeUnchanged
+	}
+}
+
+// edgeReasons maps each parent/child pair of g to its sorted, comma-separated
+// edge reasons.
+func edgeReasons(g *Graph) map[[2]string]string {
+	reasons := make(map[[2]string][]string)
+	for _, e := range g.AllEdges() {
+		p := [2]string{e.ParentID, e.ChildID}
+		reasons[p] = append(reasons[p], e.Reason)
+	}
+	joined := make(map[[2]string]string, len(reasons))
+	for p, rs := range reasons {
+		sort.Strings(rs)
+		joined[p] = strings.Join(rs, ", ")
+	}
+	return joined
+}
+
+func sortedKeys(m map[string]bool) []string {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+func sortedPairs(m map[[2]string]bool) [][2]string {
+	pairs := make([][2]string, 0, len(m))
+	for p := range m {
+		pairs = append(pairs, p)
+	}
+	sort.Slice(pairs, func(i, j int) bool {
+		if pairs[i][0] != pairs[j][0] {
+			return pairs[i][0] < pairs[j][0]
+		}
+		return pairs[i][1] < pairs[j][1]
+	})
+	return pairs
+}
+
+// diffNodes returns the nodes worth drawing: every changed node plus
+// unchanged nodes that take part in an edge, mirroring the orphan filtering
+// of GenerateDOT.
+func (d *GraphDiff) diffNodes() []DiffNode {
+	connected := make(map[string]bool)
+	for _, e := range d.Edges {
+		connected[e.ParentID] = true
+		connected[e.ChildID] = true
+	}
+	var nodes []DiffNode
+	for _, n := range d.Nodes {
+		if n.Change != ChangeUnchanged || connected[n.ID] {
+			nodes = append(nodes, n)
+		}
+	}
+	return nodes
+}
+
+// label renders the reason, or "old → new" when it changed.
+func (e DiffEdge) label() string {
+	if e.Change == ChangeReason {
+		return e.OldReason + " → " + e.Reason
+	}
+	return e.Reason
+}
+
+// GenerateDiffDOT produces a DOT graph of the diff: added nodes and edges in
+// green, removed ones in red (dashed), unchanged ones in grey, and edges whose
+// reason changed in amber labelled "old → new".
+func GenerateDiffDOT(d *GraphDiff) string {
+	var sb strings.Builder
+	sb.WriteString("digraph G {\n")
+	sb.WriteString("  rankdir=\"LR\";\n")
+	sb.WriteString("  node [shape=box, style=filled];\n\n")
+
+	nodes := d.diffNodes()
+	for _, n := range nodes {
+		var attrs string
+		switch n.Change {
+		case ChangeAdded:
+			attrs = fmt.Sprintf("fillcolor=\"%s\", color=\"%s\", penwidth=2", diffAddedFill, diffAddedColor)
+		case ChangeRemoved:
+			attrs = fmt.Sprintf("fillcolor=\"%s\", color=\"%s\", style=\"filled,dashed\", penwidth=2", diffRemovedFill, diffRemovedColor)
+		default:
+			attrs = fmt.Sprintf("fillcolor=\"%s\", color=\"%s\", fontcolor=\"%s\"", diffUnchangedFill, diffUnchangedColor, diffUnchangedColor)
+		}
+		sb.WriteString(fmt.Sprintf("  \"%s\" [%s];\n", n.ID, attrs))
+	}
+	sb.WriteString("\n")
+
+	for _, e := range d.Edges {
+		var attrs string
+		switch e.Change {
+		case ChangeAdded:
+			attrs = fmt.Sprintf("color=\"%s\", fontcolor=\"%s\", penwidth=2", diffAddedColor, diffAddedColor)
+		case ChangeRemoved:
+			attrs = fmt.Sprintf("color=\"%s\", fontcolor=\"%s\", style=dashed, penwidth=2", diffRemovedColor, diffRemovedColor)
+		case ChangeReason:
+			attrs = fmt.Sprintf("color=\"%s\", fontcolor=\"%s\", penwidth=2", diffChangedColor, diffChangedColor)
+		default:
+			attrs = fmt.Sprintf("color=\"%s\", fontcolor=\"%s\"", diffUnchangedColor, diffUnchangedColor)
+		}
+		sb.WriteString(fmt.Sprintf("  \"%s\" -> \"%s\" [label=\"%s\", %s];\n", e.ParentID, e.ChildID, e.label(), attrs))
+	}
+
+	sb.WriteString("\n")
+	sb.WriteString("  \"legend\" [shape=plaintext, label=<\n")
+	sb.WriteString("    <TABLE BORDER=\"0\" CELLBORDER=\"1\" CELLSPACING=\"0\" CELLPADDING=\"6\">\n")
+	sb.WriteString("    <TR><TD COLSPAN=\"2\"><B>Legend</B></TD></TR>\n")
+	sb.WriteString(fmt.Sprintf("    <TR><TD BGCOLOR=\"%s\">    </TD><TD>Added</TD></TR>\n", diffAddedFill))
+	sb.WriteString(fmt.Sprintf("    <TR><TD BGCOLOR=\"%s\">    </TD><TD>Removed</TD></TR>\n", diffRemovedFill))
+	sb.WriteString(fmt.Sprintf("    <TR><TD BGCOLOR=\"%s\">    </TD><TD>Reason changed</TD></TR>\n", diffChangedColor))
+	sb.WriteString(fmt.Sprintf("    <TR><TD BGCOLOR=\"%s\">    </TD><TD>Unchanged</TD></TR>\n", diffUnchangedFill))
+	sb.WriteString("    </TABLE>\n")
+	sb.WriteString("  >];\n")
+	sb.WriteString("  { rank=sink; \"legend\"; }\n")
+	sb.WriteString("}\n")
+
+	log.WithFields(log.Fields{
+		"func":  "GenerateDiffDOT",
+		"nodes": len(nodes),
+		"edges": len(d.Edges),
+	}).Debug("Generated diff DOT graph")
+
+	return sb.String()
+}
+
+// GenerateDiffMermaid produces a Mermaid flowchart of the diff with the same
+// color scheme as GenerateDiffDOT, using classDef for nodes and linkStyle for
+// edges.
+func GenerateDiffMermaid(d *GraphDiff) string {
+	var sb strings.Builder
+	sb.WriteString("graph LR\n")
+
+	nodes := d.diffNodes()
+	for _, n := range nodes {
+		sb.WriteString(fmt.Sprintf("    %s[\"%s\"]\n", sanitizeMermaidID(n.ID), n.ID))
+	}
+
+	// linkStyle addresses edges by their position in the output.
+	edgesByChange := make(map[Change][]string)
+	for i, e := range d.Edges {
+		sb.WriteString(fmt.Sprintf("    %s --> |%s| %s\n", sanitizeMermaidID(e.ParentID), e.label(), sanitizeMermaidID(e.ChildID)))
+		edgesByChange[e.Change] = append(edgesByChange[e.Change], fmt.Sprint(i))
+	}
+
+	sb.WriteString("\n")
+	sb.WriteString(fmt.Sprintf("    classDef added fill:%s,stroke:%s,stroke-width:2px\n", diffAddedFill, diffAddedColor))
+	sb.WriteString(fmt.Sprintf("    classDef removed fill:%s,stroke:%s,stroke-width:2px,stroke-dasharray:5 5\n", diffRemovedFill, diffRemovedColor))
+	sb.WriteString(fmt.Sprintf("    classDef unchanged fill:%s,stroke:%s,color:%s\n", diffUnchangedFill, diffUnchangedColor, diffUnchangedColor))
+
+	nodesByChange := make(map[Change][]string)
+	for _, n := range nodes {
+		nodesByChange[n.Change] = append(nodesByChange[n.Change], sanitizeMermaidID(n.ID))
+	}
+	for _, c := range []Change{ChangeAdded, ChangeRemoved, ChangeUnchanged} {
+		if ids := nodesByChange[c]; len(ids) > 0 {
+			sb.WriteString(fmt.Sprintf("    class %s %s\n", strings.Join(ids, ","), c))
+		}
+	}
+
+	edgeStyles := map[Change]string{
+		ChangeAdded:     fmt.Sprintf("stroke:%s,stroke-width:2px", diffAddedColor),
+		ChangeRemoved:   fmt.Sprintf("stroke:%s,stroke-width:2px,stroke-dasharray:5 5", diffRemovedColor),
+		ChangeReason:    fmt.Sprintf("stroke:%s,stroke-width:2px", diffChangedColor),
+		ChangeUnchanged: fmt.Sprintf("stroke:%s", diffUnchangedColor),
+	}
+	for _, c := range []Change{ChangeAdded, ChangeRemoved, ChangeReason, ChangeUnchanged} {
+		if idx := edgesByChange[c]; len(idx) > 0 {
+			sb.WriteString(fmt.Sprintf("    linkStyle %s %s\n", strings.Join(idx, ","), edgeStyles[c]))
+		}
+	}
+
+	log.WithFields(log.Fields{
+		"func":  "GenerateDiffMermaid",
+		"nodes": len(nodes),
+		"edges": len(d.Edges),
+	}).Debug("Generated diff Mermaid graph")
+
+	return sb.String()
+}
+
+// JSONDiff is the structure emitted by GenerateDiffJSON. Only changes are
+// listed; unchanged nodes and edges are left out. It summarizes the changes
+// and is not an RFC 6902 JSON Patch.
+type JSONDiff struct {
+	AddedNodes   []string   `json:"addedNodes"`
+	RemovedNodes []string   `json:"removedNodes"`
+	AddedEdges   []JSONEdge `json:"addedEdges"`
+	RemovedEdges []JSONEdge `json:"removedEdges"`
+	ChangedEdges []DiffEdge `json:"changedEdges"`
+}
+
+// GenerateDiffJSON produces an indented JSON document listing the added and
+// removed nodes and edges and the edges whose reason changed. Every list is
+// present, possibly empty, so consumers need no null checks.
+func GenerateDiffJSON(d *GraphDiff) string {
+	out := JSONDiff{
+		AddedNodes:   []string{},
+		RemovedNodes: []string{},
+		AddedEdges:   []JSONEdge{},
+		RemovedEdges: []JSONEdge{},
+		ChangedEdges: []DiffEdge{},
+	}
+	for _, n := range d.Nodes {
+		switch n.Change {
+		case ChangeAdded:
+			out.AddedNodes = append(out.AddedNodes, n.ID)
+		case ChangeRemoved:
+			out.RemovedNodes = append(out.RemovedNodes, n.ID)
+		}
+	}
+	for _, e := range d.Edges {
+		switch e.Change {
+		case ChangeAdded:
+			out.AddedEdges = append(out.AddedEdges, JSONEdge{From: e.ParentID, To: e.ChildID, Reason: e.Reason})
+		case ChangeRemoved:
+			out.RemovedEdges = append(out.RemovedEdges, JSONEdge{From: e.ParentID, To: e.ChildID, Reason: e.Reason})
+		case ChangeReason:
+			out.ChangedEdges = append(out.ChangedEdges, e)
+		}
+	}
+
+	data, _ := json.MarshalIndent(out, "", "  ")
+	return string(data)
+}
@@ -0,0 +1,154 @@
+package dependency_test
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/HMetcalfeW/cartographer/pkg/dependency"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// diffGraphs returns a "before" and "after" graph: the Deployment swaps its
+// ConfigMap for a Secret, the Service now reaches it via a different reason,
+// and an Ingress is added.
+func diffGraphs() (*dependency.Graph, *dependency.Graph) {
+	from := graphFromEdges(map[string][]dependency.Edge{
+		"Service/web":    {{ChildID: "Deployment/web", Reason: "selector"}},
+		"Deployment/web": {{ChildID: "ConfigMap/web", Reason: "configMapRef"}, {ChildID: "ServiceAccount/web", Reason: "serviceAccountName"}},
+	})
+	to := graphFromEdges(map[string][]dependency.Edge{
+		"Service/web":    {{ChildID: "Deployment/web", Reason: "custom"}},
+		"Deployment/web": {{ChildID: "Secret/web", Reason: "secretRef"}, {ChildID: "ServiceAccount/web", Reason: "serviceAccountName"}},
+		"Ingress/web":    {{ChildID: "Service/web", Reason: "ingressBackend"}},
+	})
+	return from, to
+}
+
+func TestDiff(t *testing.T) {
+	d := dependency.Diff(diffGraphs())
+	require.True(t, d.Changed())
+
+	nodes := make(map[string]dependency.Change)
+	for _, n := range d.Nodes {
+		nodes[n.ID] = n.Change
+	}
+	assert.Equal(t, map[string]dependency.Change{
+		"ConfigMap/web":      dependency.ChangeRemoved,
+		"Deployment/web":     dependency.ChangeUnchanged,
+		"Ingress/web":        dependency.ChangeAdded,
+		"Secret/web":         dependency.ChangeAdded,
+		"Service/web":        dependency.ChangeUnchanged,
+		"ServiceAccount/web": dependency.ChangeUnchanged,
+	}, nodes)
+
+	assert.Equal(t, []dependency.DiffEdge{
+		{ParentID: "Deployment/web", ChildID: "ConfigMap/web", Reason: "configMapRef", Change: dependency.ChangeRemoved},
+		{ParentID: "Deployment/web", ChildID: "Secret/web", Reason: "secretRef", Change: dependency.ChangeAdded},
+		{ParentID: "Deployment/web", ChildID: "ServiceAccount/web", Reason: "serviceAccountName", Change: dependency.ChangeUnchanged},
+		{ParentID: "Ingress/web", ChildID: "Service/web", Reason: "ingressBackend", Change: dependency.ChangeAdded},
+		{ParentID: "Service/web", ChildID: "Deployment/web", Reason: "custom", OldReason: "selector", Change: dependency.ChangeReason},
+	}, d.Edges)
+}
+
+func TestDiffIdenticalGraphs(t *testing.T) {
+	from, _ := diffGraphs()
+	d := dependency.Diff(from, from)
+	assert.False(t, d.Changed())
+
+	var changes dependency.JSONDiff
+	require.NoError(t, json.Unmarshal([]byte(dependency.GenerateDiffJSON(d)), &changes))
+	assert.Empty(t, changes.AddedNodes)
+	assert.Empty(t, changes.ChangedEdges)
+}
+
+func TestGenerateDiffDOT(t *testing.T) {
+	dot := dependency.GenerateDiffDOT(dependency.Diff(diffGraphs()))
+
+	assert.Contains(t, dot, `"Ingress/web" [fillcolor="#D9F2D9", color="#2CA02C", penwidth=2];`)
+	assert.Contains(t, dot, `"ConfigMap/web" [fillcolor="#F8D7D7", color="#D62728", style="filled,dashed", penwidth=2];`)
+	assert.Contains(t, dot, `"Deployment/web" [fillcolor="#EEEEEE", color="#999999", fontcolor="#999999"];`)
+	assert.Contains(t, dot, `"Service/web" -> "Deployment/web" [label="selector → custom", color="#FF7F0E"`)
+	assert.Contains(t, dot, `"Deployment/web" -> "ConfigMap/web" [label="configMapRef", color="#D62728", fontcolor="#D62728", style=dashed`)
+	assert.Contains(t, dot, "Reason changed")
+}
+
+func TestGenerateDiffMermaid(t *testing.T) {
+	mermaid := dependency.GenerateDiffMermaid(dependency.Diff(diffGraphs()))
+
+	assert.Contains(t, mermaid, "Service_2fweb --> |selector → custom| Deployment_2fweb")
+	assert.Contains(t, mermaid, "class Ingress_2fweb,Secret_2fweb added")
+	assert.Contains(t, mermaid, "class ConfigMap_2fweb removed")
+	assert.Contains(t, mermaid, "class Deployment_2fweb,Service_2fweb,ServiceAccount_2fweb unchanged")
+	// Edges are numbered in output order: 0 removed, 1 added, 2 unchanged,
+	// 3 added, 4 reason changed.
+	assert.Contains(t, mermaid, "linkStyle 1,3 stroke:#2CA02C,stroke-width:2px")
+	assert.Contains(t, mermaid, "linkStyle 0 stroke:#D62728")
+	assert.Contains(t, mermaid, "linkStyle 4 stroke:#FF7F0E")
+	assert.Contains(t, mermaid, "linkStyle 2 stroke:#999999")
+}
+
+// TestGenerateDiffMermaid_DistinctIDs is a regression test: namespaced IDs
+// that differ only in separators must stay separate Mermaid nodes.
+func TestGenerateDiffMermaid_DistinctIDs(t *testing.T) {
+	from := graphFromEdges(map[string][]dependency.Edge{
+		"Deployment/a-b/c": {{ChildID: "Secret/a-b/db", Reason: "secretRef"}},
+	})
+	to := graphFromEdges(map[string][]dependency.Edge{
+		"Deployment/a/b-c": {{ChildID: "Secret/a/db", Reason: "secretRef"}},
+	})
+	mermaid := dependency.GenerateDiffMermaid(dependency.Diff(from, to))
+	assert.Contains(t, mermaid, "class Deployment_2fa_2fb_2dc,Secret_2fa_2fdb added")
+	assert.Contains(t, mermaid, "class Deployment_2fa_2db_2fc,Secret_2fa_2db_2fdb removed")
+}
+
+func TestGenerateDiffJSON(t *testing.T) {
+	var changes dependency.JSONDiff
+	require.NoError(t, json.Unmarshal([]byte(dependency.GenerateDiffJSON(dependency.Diff(diffGraphs()))), &changes))
+
+	assert.Equal(t, []string{"Ingress/web", "Secret/web"}, changes.AddedNodes)
+	assert.Equal(t, []string{"ConfigMap/web"}, changes.RemovedNodes)
+	assert.Equal(t, []dependency.JSONEdge{
+		{From: "Deployment/web", To: "Secret/web", Reason: "secretRef"},
+		{From: "Ingress/web", To: "Service/web", Reason: "ingressBackend"},
+	}, changes.AddedEdges)
+	assert.Equal(t, []dependency.JSONEdge{{From: "Deployment/web", To: "ConfigMap/web", Reason: "configMapRef"}}, changes.RemovedEdges)
+	require.Len(t, changes.ChangedEdges, 1)
+	assert.Equal(t, "selector", changes.ChangedEdges[0].OldReason)
+	assert.Equal(t, "custom", changes.ChangedEdges[0].Reason)
+}
+
+// TestDiffMissingTransitions verifies a dangling reference target that gains
+// an object is reported as added, and an object deleted while still
+// referenced as removed.
+func TestDiffMissingTransitions(t *testing.T) {
+	// Both graphs reference the Secret and the ConfigMap; only "from" has the
+	// ConfigMap and only "to" has the Secret.
+	build := func(present string) *dependency.Graph {
+		g := dependency.NewGraph()
+		g.AddObject(objectForID("Deployment/web"))
+		g.AddObject(objectForID(present))
+		g.AddEdge(dependency.Edge{ParentID: "Deployment/web", ChildID: "Secret/web", Reason: "secretRef"})
+		g.AddEdge(dependency.Edge{ParentID: "Deployment/web", ChildID: "ConfigMap/web", Reason: "configMapRef"})
+		return g
+	}
+	from, to := build("ConfigMap/web"), build("Secret/web")
+
+	d := dependency.Diff(from, to)
+	assert.True(t, d.Changed())
+
+	nodes := make(map[string]dependency.Change)
+	for _, n := range d.Nodes {
+		nodes[n.ID] = n.Change
+	}
+	assert.Equal(t, map[string]dependency.Change{
+		"ConfigMap/web":  dependency.ChangeRemoved,
+		"Deployment/web": dependency.ChangeUnchanged,
+		"Secret/web":     dependency.ChangeAdded,
+	}, nodes)
+
+	var changes dependency.JSONDiff
+	require.NoError(t, json.Unmarshal([]byte(dependency.GenerateDiffJSON(d)), &changes))
+	assert.Equal(t, []string{"Secret/web"}, changes.AddedNodes)
+	assert.Equal(t, []string{"ConfigMap/web"}, changes.RemovedNodes)
+}
@@ -2,71 +2,110 @@ package dependency
 
 import (
 	"fmt"
-	"sort"
 	"strings"
 
 	log "github.com/sirupsen/logrus"
 )
 
+// missingColor outlines nodes for resources referenced but not present in the
+// input, in both DOT and Mermaid output.
+const missingColor = "#D62728"
+
+// highlightColor outlines highlighted nodes and draws highlighted edges (see
+// Graph.Highlight and Graph.HighlightEdges).
+const highlightColor = "#1F3A93"
+
 // GenerateDOT produces a DOT graph with resources color-coded by category
 // (Workloads, Networking, Config & Storage, etc.). Nodes are colored with
 // fill colors instead of grouped into subgraph clusters, allowing GraphViz
 // to freely optimize node placement for minimal edge crossings.
-// Only nodes that participate in at least one edge are emitted.
-func GenerateDOT(deps map[string][]Edge) string {
+// Only nodes that participate in at least one edge (or are highlighted) are
+// emitted. Missing (dangling-reference) nodes get a dashed red outline,
+// Helm hooks a grey note shape, highlighted nodes a thick bold outline and
+// highlighted edges a thick colored line. Nodes carry the file and line they
+// were read from (and their hook events), and edges their source field path,
+// as tooltips, which SVG viewers show on hover. When the graph groups by chart
+// (see Graph.GroupByChart), nodes rendered from a Helm chart or subchart are
+// drawn inside a dashed "cluster_<chart>" box labelled with the chart path.
+func GenerateDOT(g *Graph) string {
 	var sb strings.Builder
 	sb.WriteString("digraph G {\n")
 	sb.WriteString("  rankdir=\"LR\";\n")
 	sb.WriteString("  node [shape=box, style=filled];\n\n")
 
-	// Collect only nodes that participate in edges.
-	connected := make(map[string]struct{})
-	for parent, edges := range deps {
-		if len(edges) > 0 {
-			connected[parent] = struct{}{}
+	// Emit node declarations with category fill colors, only for nodes that
+	// participate in edges.
+	connected := g.connectedIDs()
+	anyMissing, anyHook := false, false
+	charts, chartOf := g.chartGroups(connected)
+	clusters := make(map[string][]string, len(charts))
+	for _, node := range connected {
+		cat := Categories[CategoryForNode(node)]
+		attrs := []string{fmt.Sprintf("fillcolor=\"%s\"", cat.Color)}
+		n, _ := g.Node(node)
+		highlighted := g.IsHighlighted(node)
+		switch {
+		case n.Missing() && highlighted:
+			attrs = append(attrs, "style=\"filled,dashed,bold\"", fmt.Sprintf("color=\"%s\"", missingColor), "penwidth=3")
+		case n.Missing():
+			attrs = append(attrs, "style=\"filled,dashed\"", fmt.Sprintf("color=\"%s\"", missingColor), "penwidth=2")
+		case highlighted:
+			attrs = append(attrs, "style=\"filled,bold\"", fmt.Sprintf("color=\"%s\"", highlightColor), "penwidth=3")
 		}
-		for _, e := range edges {
-			connected[e.ChildID] = struct{}{}
+		if n.Hook != nil {
+			attrs = append(attrs, "shape=note")
+			if !highlighted {
+				attrs = append(attrs, fmt.Sprintf("color=\"%s\"", hookColor))
+			}
 		}
+		if tooltip := nodeTooltip(n); tooltip != "" {
+			attrs = append(attrs, fmt.Sprintf("tooltip=\"%s\"", tooltip))
+		}
+		anyMissing = anyMissing || n.Missing()
+		anyHook = anyHook || n.Hook != nil
+		decl := fmt.Sprintf("\"%s\" [%s];\n", node, strings.Join(attrs, ", "))
+		if chart, ok := chartOf[node]; ok {
+			clusters[chart] = append(clusters[chart], decl)
+			continue
+		}
+		sb.WriteString("  " + decl)
 	}
-
-	// Emit node declarations with category fill colors.
-	nodeIDs := make([]string, 0, len(connected))
-	for id := range connected {
-		nodeIDs = append(nodeIDs, id)
-	}
-	sort.Strings(nodeIDs)
-
-	for _, node := range nodeIDs {
-		cat := Categories[CategoryForNode(node)]
-		sb.WriteString(fmt.Sprintf("  \"%s\" [fillcolor=\"%s\"];\n", node, cat.Color))
+	for i, chart := range charts {
+		if i > 0 || len(chartOf) < len(connected) {
+			sb.WriteString("\n")
+		}
+		sb.WriteString(fmt.Sprintf("  subgraph \"cluster_%s\" {\n", dotEscape(chart)))
+		sb.WriteString(fmt.Sprintf("    label=\"%s\";\n", dotEscape(chart)))
+		sb.WriteString("    style=\"rounded,dashed\";\n")
+		for _, decl := range clusters[chart] {
+			sb.WriteString("    " + decl)
+		}
+		sb.WriteString("  }\n")
 	}
 	sb.WriteString("\n")
 
-	// Edges.
-	parents := make([]string, 0, len(deps))
-	for p := range deps {
-		parents = append(parents, p)
-	}
-	sort.Strings(parents)
-
-	edgeCount := 0
-	for _, parent := range parents {
-		for _, edge := range deps[parent] {
-			sb.WriteString(fmt.Sprintf("  \"%s\" -> \"%s\" [label=\"%s\"];\n", parent, edge.ChildID, edge.Reason))
-			edgeCount++
+	// Edges, grouped by sorted parent.
+	edges := g.AllEdges()
+	for _, edge := range edges {
+		attrs := fmt.Sprintf("label=\"%s\"", dotEscape(edge.Reason))
+		if edge.Field != "" {
+			attrs += fmt.Sprintf(", tooltip=\"%s\"", dotEscape(edge.Field))
+		}
+		if g.IsEdgeHighlighted(edge) {
+			attrs += fmt.Sprintf(", color=\"%s\", fontcolor=\"%s\", penwidth=3", highlightColor, highlightColor)
 		}
+		sb.WriteString(fmt.Sprintf("  \"%s\" -> \"%s\" [%s];\n", edge.ParentID, edge.ChildID, attrs))
 	}
 
 	log.WithFields(log.Fields{
 		"func":  "GenerateDOT",
 		"nodes": len(connected),
-		"edges": edgeCount,
+		"edges": len(edges),
 	}).Debug("Generated DOT graph")
 
 	// Determine which categories are present.
 	activeCats := make(map[string]bool)
-	for id := range connected {
+	for _, id := range connected {
 		activeCats[CategoryForNode(id)] = true
 	}
 
@@ -83,6 +122,12 @@ func GenerateDOT(deps map[string][]Edge) string {
 		htmlLabel := strings.ReplaceAll(cat.Label, "&", "&amp;")
 		sb.WriteString(fmt.Sprintf("    <TR><TD BGCOLOR=\"%s\">    </TD><TD>%s</TD></TR>\n", cat.Color, htmlLabel))
 	}
+	if anyMissing {
+		sb.WriteString(fmt.Sprintf("    <TR><TD COLOR=\"%s\" STYLE=\"dashed\">    </TD><TD>Missing (not in input)</TD></TR>\n", missingColor))
+	}
+	if anyHook {
+		sb.WriteString(fmt.Sprintf("    <TR><TD COLOR=\"%s\">    </TD><TD>Helm hook</TD></TR>\n", hookColor))
+	}
 	sb.WriteString("    </TABLE>\n")
 	sb.WriteString("  >];\n")
 	sb.WriteString("  { rank=sink; \"legend\"; }\n")
@@ -90,3 +135,22 @@ func GenerateDOT(deps map[string][]Edge) string {
 	sb.WriteString("}\n")
 	return sb.String()
 }
+
+// nodeTooltip describes where a node was read from and, for Helm hooks, when
+// it runs, e.g. "chart/templates/job.yaml\nhook: pre-install (weight 0)".
+func nodeTooltip(n *Node) string {
+	var parts []string
+	if !n.Source.IsZero() {
+		parts = append(parts, dotEscape(n.Source.String()))
+	}
+	if n.Hook != nil {
+		parts = append(parts, dotEscape("hook: "+n.Hook.String()))
+	}
+	return strings.Join(parts, "\\n")
+}
+
+// dotEscape escapes the double quotes in s, which would otherwise end the
+// quoted DOT string it is written into.
+func dotEscape(s string) string {
+	return strings.ReplaceAll(s, `"`, `\"`)
+}
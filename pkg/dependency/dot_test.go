@@ -1,9 +1,11 @@
 package dependency_test
 
 import (
+	"strings"
 	"testing"
 
 	"github.com/HMetcalfeW/cartographer/pkg/dependency"
+	"github.com/HMetcalfeW/cartographer/pkg/parser"
 	"github.com/stretchr/testify/assert"
 )
 
@@ -15,7 +17,7 @@ func TestGenerateDOT(t *testing.T) {
 			{ChildID: "ServiceAccount/my-sa", Reason: "serviceAccountName"},
 		},
 	}
-	dot := dependency.GenerateDOT(deps)
+	dot := dependency.GenerateDOT(graphFromEdges(deps))
 	t.Log(dot)
 	assert.Contains(t, dot, "[label=\"secretRef\"]")
 	assert.Contains(t, dot, "[label=\"serviceAccountName\"]")
@@ -23,7 +25,7 @@ func TestGenerateDOT(t *testing.T) {
 
 // TestGenerateDOT_EmptyDeps verifies DOT output for an empty dependency map.
 func TestGenerateDOT_EmptyDeps(t *testing.T) {
-	dot := dependency.GenerateDOT(map[string][]dependency.Edge{})
+	dot := dependency.GenerateDOT(graphFromEdges(map[string][]dependency.Edge{}))
 	assert.Contains(t, dot, "digraph G {")
 	assert.Contains(t, dot, "}")
 	// No edges should be present
@@ -39,7 +41,7 @@ func TestGenerateDOT_OrphansOmitted(t *testing.T) {
 			{ChildID: "Secret/db-pass", Reason: "secretRef"},
 		},
 	}
-	dot := dependency.GenerateDOT(deps)
+	dot := dependency.GenerateDOT(graphFromEdges(deps))
 	assert.Contains(t, dot, `"Deployment/web" -> "Secret/db-pass"`)
 	assert.NotContains(t, dot, "ConfigMap/standalone")
 }
@@ -57,7 +59,7 @@ func TestGenerateDOT_ColorCoded(t *testing.T) {
 			{ChildID: "Role/reader", Reason: "roleRef"},
 		},
 	}
-	dot := dependency.GenerateDOT(deps)
+	dot := dependency.GenerateDOT(graphFromEdges(deps))
 
 	// Nodes should have fillcolor attributes
 	assert.Contains(t, dot, `"Deployment/web" [fillcolor=`)
@@ -82,7 +84,7 @@ func TestGenerateDOT_StructureValid(t *testing.T) {
 			{ChildID: "Deployment/web", Reason: "selector"},
 		},
 	}
-	dot := dependency.GenerateDOT(deps)
+	dot := dependency.GenerateDOT(graphFromEdges(deps))
 	assert.True(t, len(dot) > 0)
 	// Must start with digraph and end with closing brace
 	assert.Contains(t, dot, "digraph G {")
@@ -109,7 +111,125 @@ func TestGenerateDOT_DeterministicOrder(t *testing.T) {
 		"Service/web":    {{ChildID: "Deployment/web", Reason: "selector"}},
 		"Deployment/web": {{ChildID: "Secret/db-pass", Reason: "secretRef"}},
 	}
-	first := dependency.GenerateDOT(deps)
-	second := dependency.GenerateDOT(deps)
+	first := dependency.GenerateDOT(graphFromEdges(deps))
+	second := dependency.GenerateDOT(graphFromEdges(deps))
 	assert.Equal(t, first, second, "DOT output should be deterministic")
 }
+
+// TestGenerateDOT_MissingNodes verifies dangling-reference targets are drawn
+// dashed red and listed in the legend, while present resources are not.
+func TestGenerateDOT_MissingNodes(t *testing.T) {
+	g := graphFromEdges(map[string][]dependency.Edge{
+		"Deployment/web": {{ChildID: "ConfigMap/cfg", Reason: "configMapRef"}},
+	})
+	g.AddEdge(dependency.Edge{ParentID: "Deployment/web", ChildID: "Secret/gone", Reason: "secretRef"})
+
+	dot := dependency.GenerateDOT(g)
+	assert.Contains(t, dot, `"Secret/gone" [fillcolor="#FFF2CC", style="filled,dashed", color="#D62728", penwidth=2];`)
+	assert.Contains(t, dot, `"ConfigMap/cfg" [fillcolor="#FFF2CC"];`)
+	assert.Contains(t, dot, "Missing (not in input)")
+
+	dot = dependency.GenerateDOT(graphFromEdges(map[string][]dependency.Edge{
+		"Deployment/web": {{ChildID: "ConfigMap/cfg", Reason: "configMapRef"}},
+	}))
+	assert.NotContains(t, dot, "dashed")
+	assert.NotContains(t, dot, "Missing (not in input)")
+}
+
+// TestGenerateDOT_FieldTooltip verifies an edge's field path becomes its
+// tooltip, which SVG viewers show on hover.
+func TestGenerateDOT_FieldTooltip(t *testing.T) {
+	dot := dependency.GenerateDOT(graphFromEdges(map[string][]dependency.Edge{
+		"Deployment/web": {
+			{ChildID: "Secret/db", Reason: "secretRef", Field: "spec.template.spec.volumes[2].secret"},
+			{ChildID: "ConfigMap/cfg", Reason: "configMapRef"},
+		},
+	}))
+	assert.Contains(t, dot, `[label="secretRef", tooltip="spec.template.spec.volumes[2].secret"]`)
+	assert.Contains(t, dot, `[label="configMapRef"]`)
+}
+
+// TestGenerateDOT_SourceTooltip verifies nodes with a known source location
+// get it as their tooltip.
+func TestGenerateDOT_SourceTooltip(t *testing.T) {
+	g := graphFromEdges(map[string][]dependency.Edge{
+		"Deployment/web": {{ChildID: "Secret/db", Reason: "secretRef"}},
+	})
+	web := objectForID("Deployment/web")
+	g.AddObject(web)
+	g.SetOrigins(dependency.Origins{web: {Source: parser.Source{File: "k8s/web.yaml", Line: 12}}})
+
+	dot := dependency.GenerateDOT(g)
+	assert.Contains(t, dot, `tooltip="k8s/web.yaml:12"`)
+	assert.Equal(t, 1, strings.Count(dot, "tooltip="))
+}
+
+// TestGenerateDOT_EscapedTooltips verifies quotes in tooltips, edge labels and
+// chart names are escaped so they cannot end the DOT string early.
+func TestGenerateDOT_EscapedTooltips(t *testing.T) {
+	g := graphFromEdges(map[string][]dependency.Edge{
+		"Deployment/web": {{ChildID: "Secret/db", Reason: `my"ref`, Field: `spec.refs["db"]`}},
+	})
+	web := objectForID("Deployment/web")
+	g.AddObject(web)
+	g.SetOrigins(dependency.Origins{web: {Source: parser.Source{File: `k8s/"quoted".yaml`, Line: 3, Chart: `my"chart`}}})
+	g.GroupByChart(true)
+
+	dot := dependency.GenerateDOT(g)
+	assert.Contains(t, dot, `tooltip="k8s/\"quoted\".yaml:3"`)
+	assert.Contains(t, dot, `label="my\"ref", tooltip="spec.refs[\"db\"]"`)
+	assert.Contains(t, dot, `subgraph "cluster_my\"chart" {`)
+	assert.Contains(t, dot, `label="my\"chart";`)
+}
+
+// TestGenerateDOT_HookNodes verifies Helm hooks are drawn as grey notes with
+// their events in the tooltip and a legend entry.
+func TestGenerateDOT_HookNodes(t *testing.T) {
+	g := graphFromEdges(map[string][]dependency.Edge{
+		"Job/migrate": {{ChildID: "Secret/db", Reason: "secretRef"}},
+	})
+	job := objectForID("Job/migrate")
+	g.AddObject(job)
+	g.SetOrigins(dependency.Origins{job: {Hook: &dependency.Hook{Events: []string{"pre-install"}, Weight: 1}}})
+
+	dot := dependency.GenerateDOT(g)
+	assert.Contains(t, dot, `"Job/migrate" [fillcolor=`)
+	assert.Contains(t, dot, `shape=note, color="#7F7F7F", tooltip="hook: pre-install (weight 1)"`)
+	assert.Contains(t, dot, "Helm hook")
+	assert.Equal(t, 1, strings.Count(dot, "shape=note"))
+}
+
+// chartGraph returns a graph with a Deployment rendered from the platform
+// chart, a StatefulSet from its postgresql subchart and a Secret from no chart.
+func chartGraph() *dependency.Graph {
+	g := graphFromEdges(map[string][]dependency.Edge{
+		"Deployment/web": {{ChildID: "Secret/db", Reason: "secretRef"}},
+		"StatefulSet/db": {{ChildID: "Secret/db", Reason: "secretRef"}},
+	})
+	origins := dependency.Origins{}
+	for id, chart := range map[string]string{"Deployment/web": "platform", "StatefulSet/db": "platform/postgresql"} {
+		obj := objectForID(id)
+		g.AddObject(obj)
+		origins[obj] = dependency.Origin{Source: parser.Source{Chart: chart}}
+	}
+	g.SetOrigins(origins)
+	return g
+}
+
+// TestGenerateDOT_ChartClusters verifies nodes are boxed by chart only when
+// chart grouping is enabled, and that subgraphs keep it.
+func TestGenerateDOT_ChartClusters(t *testing.T) {
+	g := chartGraph()
+	assert.NotContains(t, dependency.GenerateDOT(g), "subgraph")
+
+	g.GroupByChart(true)
+	dot := dependency.GenerateDOT(g)
+	assert.Contains(t, dot, "  \"Secret/db\" [fillcolor=")
+	assert.Contains(t, dot, "  subgraph \"cluster_platform\" {\n    label=\"platform\";\n    style=\"rounded,dashed\";\n    \"Deployment/web\" [")
+	assert.Contains(t, dot, "  subgraph \"cluster_platform/postgresql\" {\n    label=\"platform/postgresql\";\n    style=\"rounded,dashed\";\n    \"StatefulSet/db\" [")
+	assert.Less(t, strings.Index(dot, "cluster_platform\""), strings.Index(dot, "cluster_platform/postgresql"))
+
+	sub := g.Subgraph([]string{"StatefulSet/db", "Secret/db"})
+	assert.True(t, sub.GroupsByChart())
+	assert.Equal(t, 1, strings.Count(dependency.GenerateDOT(sub), "subgraph"))
+}
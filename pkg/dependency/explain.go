@@ -0,0 +1,206 @@
+package dependency
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	log "github.com/sirupsen/logrus"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+)
+
+// EdgeGroup is a set of edges of one resource that share a reason.
+type EdgeGroup struct {
+	Reason string
+	Edges  []Edge
+}
+
+// SelectorTerm is one term of a label selector together with the label of
+// the selected resource it was evaluated against.
+type SelectorTerm struct {
+	// Key is the label key the term tests.
+	Key string
+
+	// Requirement is the term in kubectl selector syntax, e.g. "app=web",
+	// "tier in (api, web)", "canary" or "!canary".
+	Requirement string
+
+	// Value is the selected resource's value for Key, and Present whether it
+	// carries the label at all.
+	Value   string
+	Present bool
+}
+
+// String renders the term with the label that satisfied it, e.g.
+// "tier in (api, web): tier=api" or "!canary: canary not set".
+func (t SelectorTerm) String() string {
+	if !t.Present {
+		return fmt.Sprintf("%s: %s not set", t.Requirement, t.Key)
+	}
+	return fmt.Sprintf("%s: %s=%s", t.Requirement, t.Key, t.Value)
+}
+
+// SelectorMatch explains a selector edge: which terms of the parent's
+// selector matched which labels of the child.
+type SelectorMatch struct {
+	Edge  Edge
+	Terms []SelectorTerm
+}
+
+// Keys returns the label keys that caused the match, in term order.
+func (m SelectorMatch) Keys() []string {
+	keys := make([]string, len(m.Terms))
+	for i, t := range m.Terms {
+		keys[i] = t.Key
+	}
+	return keys
+}
+
+// Explanation is everything the graph knows about a single resource.
+type Explanation struct {
+	Node *Node
+
+	// Outgoing and Incoming group the resource's edges by reason, sorted by
+	// reason and then by the ID at the other end.
+	Outgoing []EdgeGroup
+	Incoming []EdgeGroup
+
+	// Selects lists the resources this resource's selector matches, and
+	// SelectedBy the selectors that match this resource.
+	Selects    []SelectorMatch
+	SelectedBy []SelectorMatch
+
+	// Unresolved lists the outgoing references whose target is not present
+	// in the input.
+	Unresolved []Edge
+}
+
+// selectorPaths maps the reason of each selector-based edge to the location
+// of the selector in the parent. Service selectors are a plain label map;
+// the others are full LabelSelectors with matchLabels and matchExpressions.
+var selectorPaths = map[string][]string{
+	"selector":    {"spec", "selector"},
+	"podSelector": {"spec", "podSelector"},
+	"pdbSelector": {"spec", "selector"},
+}
+
+// Explain collects the edges, selector matches and unresolved references of
+// the node with the given ID. It returns false if the graph has no such node.
+func Explain(g *Graph, id string) (Explanation, bool) {
+	n, ok := g.Node(id)
+	if !ok {
+		return Explanation{}, false
+	}
+
+	ex := Explanation{
+		Node:     n,
+		Outgoing: groupEdges(g.Edges(id), func(e Edge) string { return e.ChildID }),
+		Incoming: groupEdges(g.InEdges(id), func(e Edge) string { return e.ParentID }),
+	}
+
+	for _, group := range ex.Outgoing {
+		for _, e := range group.Edges {
+			if child, _ := g.Node(e.ChildID); child.Missing() {
+				ex.Unresolved = append(ex.Unresolved, e)
+			}
+			if m, ok := explainSelector(g, e); ok {
+				ex.Selects = append(ex.Selects, m)
+			}
+		}
+	}
+	for _, group := range ex.Incoming {
+		for _, e := range group.Edges {
+			if m, ok := explainSelector(g, e); ok {
+				ex.SelectedBy = append(ex.SelectedBy, m)
+			}
+		}
+	}
+
+	log.WithFields(log.Fields{
+		"func":     "Explain",
+		"id":       id,
+		"outgoing": len(g.Edges(id)),
+		"incoming": len(g.InEdges(id)),
+	}).Debug("Explained resource")
+
+	return ex, true
+}
+
+// groupEdges groups edges by reason, sorting groups by reason and edges
+// within a group by the ID returned by other.
+func groupEdges(edges []Edge, other func(Edge) string) []EdgeGroup {
+	byReason := make(map[string][]Edge)
+	for _, e := range edges {
+		byReason[e.Reason] = append(byReason[e.Reason], e)
+	}
+
+	groups := make([]EdgeGroup, 0, len(byReason))
+	for reason, es := range byReason {
+		sort.SliceStable(es, func(i, j int) bool { return other(es[i]) < other(es[j]) })
+		groups = append(groups, EdgeGroup{Reason: reason, Edges: es})
+	}
+	sort.Slice(groups, func(i, j int) bool { return groups[i].Reason < groups[j].Reason })
+	return groups
+}
+
+// explainSelector re-evaluates the parent's selector against the child's
+// labels for a selector-based edge. It returns false for other edges or when
+// either end has no object.
+func explainSelector(g *Graph, e Edge) (SelectorMatch, bool) {
+	path, ok := selectorPaths[e.Reason]
+	if !ok {
+		return SelectorMatch{}, false
+	}
+	parent, _ := g.Node(e.ParentID)
+	child, _ := g.Node(e.ChildID)
+	if parent.Missing() || child.Missing() {
+		return SelectorMatch{}, false
+	}
+
+	var matchLabels map[string]string
+	var exprs []LabelSelectorRequirement
+	if e.Reason == "selector" {
+		sel, _, _ := unstructured.NestedFieldCopy(parent.Object.Object, path...)
+		matchLabels = MapInterfaceToStringMap(sel)
+	} else {
+		sel, _, _ := unstructured.NestedMap(parent.Object.Object, path...)
+		ml, _, _ := unstructured.NestedMap(sel, "matchLabels")
+		matchLabels = MapInterfaceToStringMap(ml)
+		exprs = ExtractMatchExpressions(sel)
+	}
+
+	m := SelectorMatch{Edge: e}
+	keys := make([]string, 0, len(matchLabels))
+	for k := range matchLabels {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	for _, k := range keys {
+		m.Terms = append(m.Terms, selectorTerm(k, k+"="+matchLabels[k], child.Labels))
+	}
+	for _, expr := range exprs {
+		m.Terms = append(m.Terms, selectorTerm(expr.Key, requirementString(expr), child.Labels))
+	}
+	return m, true
+}
+
+func selectorTerm(key, requirement string, labels map[string]string) SelectorTerm {
+	value, present := labels[key]
+	return SelectorTerm{Key: key, Requirement: requirement, Value: value, Present: present}
+}
+
+// requirementString renders a matchExpressions entry in kubectl selector
+// syntax.
+func requirementString(expr LabelSelectorRequirement) string {
+	switch expr.Operator {
+	case "In":
+		return fmt.Sprintf("%s in (%s)", expr.Key, strings.Join(expr.Values, ", "))
+	case "NotIn":
+		return fmt.Sprintf("%s notin (%s)", expr.Key, strings.Join(expr.Values, ", "))
+	case "Exists":
+		return expr.Key
+	case "DoesNotExist":
+		return "!" + expr.Key
+	}
+	return fmt.Sprintf("%s %s (%s)", expr.Key, expr.Operator, strings.Join(expr.Values, ", "))
+}
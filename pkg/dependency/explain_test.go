@@ -0,0 +1,130 @@
+package dependency_test
+
+import (
+	"testing"
+
+	"github.com/HMetcalfeW/cartographer/pkg/dependency"
+	"github.com/HMetcalfeW/cartographer/pkg/parser"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// explainYAML has a Deployment selected by a Service and by a NetworkPolicy
+// using matchExpressions, reading one present and one missing Secret.
+const explainYAML = `
+apiVersion: apps/v1
+kind: Deployment
+metadata:
+  name: web
+  labels:
+    app: web
+    tier: frontend
+spec:
+  template:
+    spec:
+      containers:
+        - name: web
+          image: nginx
+          envFrom:
+            - secretRef:
+                name: db
+            - secretRef:
+                name: gone
+---
+apiVersion: v1
+kind: Secret
+metadata:
+  name: db
+---
+apiVersion: v1
+kind: Service
+metadata:
+  name: web
+spec:
+  selector:
+    app: web
+---
+apiVersion: networking.k8s.io/v1
+kind: NetworkPolicy
+metadata:
+  name: deny-canary
+spec:
+  podSelector:
+    matchLabels:
+      app: web
+    matchExpressions:
+      - key: tier
+        operator: In
+        values: [frontend, api]
+      - key: canary
+        operator: DoesNotExist
+`
+
+func TestExplain(t *testing.T) {
+	objs, err := parser.ParseYAML([]byte(explainYAML))
+	require.NoError(t, err)
+	g := dependency.BuildDependencies(objs)
+
+	ex, ok := dependency.Explain(g, "Deployment/web")
+	require.True(t, ok)
+	assert.Equal(t, "Deployment/web", ex.Node.ID)
+
+	require.Len(t, ex.Outgoing, 1)
+	assert.Equal(t, "secretRef", ex.Outgoing[0].Reason)
+	require.Len(t, ex.Outgoing[0].Edges, 2)
+	assert.Equal(t, "Secret/db", ex.Outgoing[0].Edges[0].ChildID)
+
+	var reasons []string
+	for _, group := range ex.Incoming {
+		reasons = append(reasons, group.Reason)
+	}
+	assert.Equal(t, []string{"podSelector", "selector"}, reasons)
+
+	require.Len(t, ex.Unresolved, 1)
+	assert.Equal(t, "Secret/gone", ex.Unresolved[0].ChildID)
+	assert.Equal(t, "spec.template.spec.containers[0].envFrom[1].secretRef", ex.Unresolved[0].Field)
+
+	assert.Empty(t, ex.Selects)
+	require.Len(t, ex.SelectedBy, 2)
+	np := ex.SelectedBy[0]
+	assert.Equal(t, "NetworkPolicy/deny-canary", np.Edge.ParentID)
+	assert.Equal(t, []string{"app", "tier", "canary"}, np.Keys())
+	var terms []string
+	for _, term := range np.Terms {
+		terms = append(terms, term.String())
+	}
+	assert.Equal(t, []string{
+		"app=web: app=web",
+		"tier in (frontend, api): tier=frontend",
+		"!canary: canary not set",
+	}, terms)
+	assert.Equal(t, []string{"app"}, ex.SelectedBy[1].Keys())
+}
+
+func TestExplain_Selector(t *testing.T) {
+	objs, err := parser.ParseYAML([]byte(explainYAML))
+	require.NoError(t, err)
+	g := dependency.BuildDependencies(objs)
+
+	ex, ok := dependency.Explain(g, "Service/web")
+	require.True(t, ok)
+	require.Len(t, ex.Selects, 1)
+	assert.Equal(t, "Deployment/web", ex.Selects[0].Edge.ChildID)
+	assert.Empty(t, ex.SelectedBy)
+	assert.Empty(t, ex.Unresolved)
+}
+
+func TestExplain_MissingNode(t *testing.T) {
+	objs, err := parser.ParseYAML([]byte(explainYAML))
+	require.NoError(t, err)
+	g := dependency.BuildDependencies(objs)
+
+	ex, ok := dependency.Explain(g, "Secret/gone")
+	require.True(t, ok)
+	assert.True(t, ex.Node.Missing())
+	assert.Empty(t, ex.Outgoing)
+	require.Len(t, ex.Incoming, 1)
+
+	_, ok = dependency.Explain(g, "Secret/nope")
+	assert.False(t, ok)
+}
@@ -0,0 +1,102 @@
+package dependency
+
+import (
+	"fmt"
+	"strings"
+
+	log "github.com/sirupsen/logrus"
+)
+
+// Direction selects which edges Focus follows away from the focus nodes.
+// Edges point from the referencing resource to the referenced one, so "down"
+// reaches what a resource depends on and "up" reaches what depends on it.
+type Direction int
+
+const (
+	DirectionBoth Direction = iota
+	DirectionDown
+	DirectionUp
+)
+
+// String returns the lower-case name of the direction.
+func (d Direction) String() string {
+	switch d {
+	case DirectionBoth:
+		return "both"
+	case DirectionDown:
+		return "down"
+	case DirectionUp:
+		return "up"
+	default:
+		return fmt.Sprintf("direction(%d)", int(d))
+	}
+}
+
+// ParseDirection parses a direction name (case-insensitive).
+func ParseDirection(name string) (Direction, error) {
+	switch strings.ToLower(strings.TrimSpace(name)) {
+	case "both":
+		return DirectionBoth, nil
+	case "down":
+		return DirectionDown, nil
+	case "up":
+		return DirectionUp, nil
+	default:
+		return DirectionBoth, fmt.Errorf("unknown direction %q (want up, down or both)", name)
+	}
+}
+
+// Focus returns the subgraph of nodes within depth hops of the focus nodes,
+// with the focus nodes highlighted. depth 0 means unlimited. With
+// DirectionBoth the upward and downward walks are made separately and
+// combined, so siblings that merely share a dependency with a focus node are
+// not pulled in.
+func Focus(g *Graph, ids []string, depth int, dir Direction) *Graph {
+	keep := append([]string(nil), ids...)
+	if dir != DirectionUp {
+		keep = append(keep, walk(ids, depth, g.Neighbors)...)
+	}
+	if dir != DirectionDown {
+		keep = append(keep, walk(ids, depth, g.ReverseNeighbors)...)
+	}
+
+	sub := g.Subgraph(sortedUnique(keep))
+	sub.Highlight(ids...)
+
+	log.WithFields(log.Fields{
+		"func":      "Focus",
+		"focus":     ids,
+		"depth":     depth,
+		"direction": dir,
+		"nodes":     sub.Len(),
+	}).Debug("Focused graph")
+
+	return sub
+}
+
+// walk returns the nodes reachable from start within depth hops (0 means
+// unlimited) using next to list each node's neighbors. The start nodes
+// themselves are not returned.
+func walk(start []string, depth int, next func(string) []string) []string {
+	seen := make(map[string]bool, len(start))
+	for _, id := range start {
+		seen[id] = true
+	}
+	var found []string
+	frontier := start
+	for hop := 1; len(frontier) > 0 && (depth == 0 || hop <= depth); hop++ {
+		var nextFrontier []string
+		for _, id := range frontier {
+			for _, n := range next(id) {
+				if seen[n] {
+					continue
+				}
+				seen[n] = true
+				found = append(found, n)
+				nextFrontier = append(nextFrontier, n)
+			}
+		}
+		frontier = nextFrontier
+	}
+	return found
+}
@@ -0,0 +1,84 @@
+package dependency_test
+
+import (
+	"testing"
+
+	"github.com/HMetcalfeW/cartographer/pkg/dependency"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// nodeIDs returns the sorted IDs of every node in the graph.
+func nodeIDs(g *dependency.Graph) []string {
+	var ids []string
+	for _, n := range g.Nodes() {
+		ids = append(ids, n.ID)
+	}
+	return ids
+}
+
+func TestFocus(t *testing.T) {
+	g := impactGraph()
+
+	tests := []struct {
+		name     string
+		depth    int
+		dir      dependency.Direction
+		expected []string
+	}{
+		{
+			name:     "down one hop",
+			depth:    1,
+			dir:      dependency.DirectionDown,
+			expected: []string{"ConfigMap/web", "Deployment/web", "Secret/db-creds"},
+		},
+		{
+			name:     "up unlimited",
+			depth:    0,
+			dir:      dependency.DirectionUp,
+			expected: []string{"Deployment/web", "Ingress/web", "Service/web"},
+		},
+		{
+			// Siblings sharing the Secret or ConfigMap (CronJob/backup,
+			// Deployment/worker) are not pulled in.
+			name:     "both one hop",
+			depth:    1,
+			dir:      dependency.DirectionBoth,
+			expected: []string{"ConfigMap/web", "Deployment/web", "Secret/db-creds", "Service/web"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			sub := dependency.Focus(g, []string{"Deployment/web"}, tt.depth, tt.dir)
+			assert.Equal(t, tt.expected, nodeIDs(sub))
+			assert.True(t, sub.IsHighlighted("Deployment/web"))
+		})
+	}
+}
+
+func TestFocusMultipleNodes(t *testing.T) {
+	sub := dependency.Focus(impactGraph(), []string{"CronJob/backup", "Deployment/worker"}, 1, dependency.DirectionDown)
+	assert.Equal(t, []string{"ConfigMap/web", "CronJob/backup", "Deployment/worker", "Secret/db-creds"}, nodeIDs(sub))
+	assert.True(t, sub.IsHighlighted("CronJob/backup"))
+	assert.True(t, sub.IsHighlighted("Deployment/worker"))
+	assert.Len(t, sub.AllEdges(), 2)
+}
+
+func TestParseDirection(t *testing.T) {
+	tests := []struct {
+		in       string
+		expected dependency.Direction
+	}{
+		{"up", dependency.DirectionUp},
+		{"Down", dependency.DirectionDown},
+		{"both", dependency.DirectionBoth},
+	}
+	for _, tt := range tests {
+		dir, err := dependency.ParseDirection(tt.in)
+		require.NoError(t, err, tt.in)
+		assert.Equal(t, tt.expected, dir, tt.in)
+	}
+
+	_, err := dependency.ParseDirection("sideways")
+	assert.Error(t, err)
+}
@@ -0,0 +1,457 @@
+package dependency
+
+import (
+	"fmt"
+	"slices"
+	"sort"
+	"strings"
+
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+
+	"github.com/HMetcalfeW/cartographer/pkg/parser"
+)
+
+// lastAppliedAnnotation is dropped from node metadata; it duplicates the
+// whole manifest and would swamp every exporter.
+const lastAppliedAnnotation = "kubectl.kubernetes.io/last-applied-configuration"
+
+// Node is a single Kubernetes resource in the dependency graph, carrying the
+// identity and metadata of the object it was built from.
+type Node struct {
+	// ID is the node identifier (see ResourceKey).
+	ID string
+
+	Group     string
+	Version   string
+	Kind      string
+	Namespace string
+	Name      string
+
+	Labels      map[string]string
+	Annotations map[string]string
+
+	// Source is where the object was read from, when it came from a file or
+	// chart and was recorded with SetOrigins; zero otherwise.
+	Source parser.Source
+
+	// Hook is set for Helm hook resources rendered with hooks tagged and
+	// recorded with SetOrigins.
+	Hook *Hook
+
+	// Unreadable is set on a missing node whose object the cluster refused
+	// to return, recorded with SetOrigins. The object may well exist.
+	Unreadable bool
+
+	// Object is the manifest backing this node, or nil when the node is
+	// only known as the target of a reference.
+	Object *unstructured.Unstructured
+}
+
+// APIVersion returns the node's "group/version" (or bare version for the
+// core group). It is empty for nodes only known from a reference.
+func (n *Node) APIVersion() string {
+	if n.Version == "" {
+		return ""
+	}
+	if n.Group == "" {
+		return n.Version
+	}
+	return n.Group + "/" + n.Version
+}
+
+// Missing reports whether the node is only known as the target (or owner) of
+// a reference, with no object in the input backing it — a dangling reference.
+func (n *Node) Missing() bool {
+	return n.Object == nil
+}
+
+// Key returns the node's ResourceKey.
+func (n *Node) Key() ResourceKey {
+	return ResourceKey{Group: n.Group, Kind: n.Kind, Namespace: n.Namespace, Name: n.Name}
+}
+
+// Graph is a directed dependency graph of Kubernetes resources. Edges point
+// from the referring resource (parent) to the referenced one (child).
+// Edge order per parent is insertion order; node listings are sorted by ID.
+type Graph struct {
+	nodes            map[string]*Node
+	out              map[string][]Edge
+	in               map[string][]Edge
+	edgeSeen         map[string]struct{}
+	highlighted      map[string]bool
+	highlightedEdges map[string]bool
+	groupByChart     bool
+}
+
+// NewGraph returns an empty Graph.
+func NewGraph() *Graph {
+	return &Graph{
+		nodes:            make(map[string]*Node),
+		out:              make(map[string][]Edge),
+		in:               make(map[string][]Edge),
+		edgeSeen:         make(map[string]struct{}),
+		highlighted:      make(map[string]bool),
+		highlightedEdges: make(map[string]bool),
+	}
+}
+
+// AddObject adds (or fills in) the node for obj and returns it. A node
+// previously created only from a reference is upgraded in place.
+func (g *Graph) AddObject(obj *unstructured.Unstructured) *Node {
+	gvk := obj.GroupVersionKind()
+	annotations := obj.GetAnnotations()
+	delete(annotations, lastAppliedAnnotation)
+
+	id := ResourceID(obj)
+	n := g.ensureNode(id)
+	n.Group = gvk.Group
+	n.Version = gvk.Version
+	n.Kind = obj.GetKind()
+	n.Namespace = obj.GetNamespace()
+	n.Name = obj.GetName()
+	n.Labels = obj.GetLabels()
+	n.Annotations = annotations
+	n.Object = obj
+	return n
+}
+
+// Origin is what is known about an object beyond its manifest.
+type Origin struct {
+	// Source is where the object was read from (see parser.Decode).
+	Source parser.Source
+
+	// Hook is set for Helm hook resources (see helm.Hooks).
+	Hook *Hook
+
+	// Unreadable lists the IDs of resources the object references that the
+	// cluster refused to return (see cluster.UnreadableRoles).
+	Unreadable []string
+}
+
+// IsZero reports whether o records nothing.
+func (o Origin) IsZero() bool {
+	return o.Source.IsZero() && o.Hook == nil && len(o.Unreadable) == 0
+}
+
+// Origins maps objects, by identity, to their Origin.
+type Origins map[*unstructured.Unstructured]Origin
+
+// SetOrigins records the origin of every object in origins on the node it
+// backs, and marks the missing nodes it lists as unreadable. Objects that back
+// no node, e.g. because a later object with the same ID replaced them, are
+// ignored.
+func (g *Graph) SetOrigins(origins Origins) {
+	for obj, origin := range origins {
+		n, ok := g.nodes[ResourceID(obj)]
+		if !ok || n.Object != obj {
+			continue
+		}
+		n.Source = origin.Source
+		n.Hook = origin.Hook
+		for _, id := range origin.Unreadable {
+			if target, ok := g.nodes[id]; ok && target.Missing() {
+				target.Unreadable = true
+			}
+		}
+	}
+}
+
+// AddEdge records an edge, creating placeholder nodes for either endpoint if
+// needed. Duplicate edges (same parent, child and reason) are collapsed into
+// the first one, whose Field gains the duplicate's field path.
+func (g *Graph) AddEdge(e Edge) {
+	key := edgeKey(e)
+	if _, dup := g.edgeSeen[key]; dup {
+		g.mergeField(e)
+		return
+	}
+	g.edgeSeen[key] = struct{}{}
+	g.ensureNode(e.ParentID)
+	g.ensureNode(e.ChildID)
+	g.out[e.ParentID] = append(g.out[e.ParentID], e)
+	g.in[e.ChildID] = append(g.in[e.ChildID], e)
+}
+
+// mergeField appends the field path of a duplicate edge to the recorded edge.
+// The edge stays optional only if the duplicate is optional too.
+func (g *Graph) mergeField(e Edge) {
+	merge := func(edges []Edge) {
+		for i := range edges {
+			if edges[i].ParentID != e.ParentID || edges[i].ChildID != e.ChildID || edges[i].Reason != e.Reason {
+				continue
+			}
+			edges[i].Optional = edges[i].Optional && e.Optional
+			if e.Field == "" {
+				return
+			}
+			if edges[i].Field == "" {
+				edges[i].Field = e.Field
+			} else if !slices.Contains(strings.Split(edges[i].Field, ", "), e.Field) {
+				edges[i].Field += ", " + e.Field
+			}
+			return
+		}
+	}
+	merge(g.out[e.ParentID])
+	merge(g.in[e.ChildID])
+}
+
+// edgeKey identifies an edge for de-duplication and highlighting.
+func edgeKey(e Edge) string {
+	return e.ParentID + "|" + e.ChildID + "|" + e.Reason
+}
+
+// ensureNode returns the node for id, creating a reference-only node from
+// the parsed ID if it does not exist yet.
+func (g *Graph) ensureNode(id string) *Node {
+	if n, ok := g.nodes[id]; ok {
+		return n
+	}
+	key := ParseResourceID(id)
+	n := &Node{
+		ID:        id,
+		Group:     key.Group,
+		Kind:      key.Kind,
+		Namespace: key.Namespace,
+		Name:      key.Name,
+	}
+	g.nodes[id] = n
+	return n
+}
+
+// Node looks up a node by ID.
+func (g *Graph) Node(id string) (*Node, bool) {
+	n, ok := g.nodes[id]
+	return n, ok
+}
+
+// Nodes returns every node sorted by ID.
+func (g *Graph) Nodes() []*Node {
+	nodes := make([]*Node, 0, len(g.nodes))
+	for _, n := range g.nodes {
+		nodes = append(nodes, n)
+	}
+	sort.Slice(nodes, func(i, j int) bool { return nodes[i].ID < nodes[j].ID })
+	return nodes
+}
+
+// MissingNodes returns the nodes with no backing object, sorted by ID.
+func (g *Graph) MissingNodes() []*Node {
+	var missing []*Node
+	for _, n := range g.Nodes() {
+		if n.Missing() {
+			missing = append(missing, n)
+		}
+	}
+	return missing
+}
+
+// Len returns the number of nodes in the graph.
+func (g *Graph) Len() int {
+	return len(g.nodes)
+}
+
+// Edges returns the outgoing edges of the node, in insertion order.
+func (g *Graph) Edges(id string) []Edge {
+	return g.out[id]
+}
+
+// InEdges returns the incoming edges of the node, in insertion order.
+func (g *Graph) InEdges(id string) []Edge {
+	return g.in[id]
+}
+
+// AllEdges returns every edge, grouped by parent in sorted parent order.
+func (g *Graph) AllEdges() []Edge {
+	parents := make([]string, 0, len(g.out))
+	for p := range g.out {
+		parents = append(parents, p)
+	}
+	sort.Strings(parents)
+
+	var edges []Edge
+	for _, p := range parents {
+		edges = append(edges, g.out[p]...)
+	}
+	return edges
+}
+
+// Neighbors returns the sorted, de-duplicated IDs the node points to.
+func (g *Graph) Neighbors(id string) []string {
+	ids := make([]string, 0, len(g.out[id]))
+	for _, e := range g.out[id] {
+		ids = append(ids, e.ChildID)
+	}
+	return sortedUnique(ids)
+}
+
+// ReverseNeighbors returns the sorted, de-duplicated IDs pointing at the node.
+func (g *Graph) ReverseNeighbors(id string) []string {
+	ids := make([]string, 0, len(g.in[id]))
+	for _, e := range g.in[id] {
+		ids = append(ids, e.ParentID)
+	}
+	return sortedUnique(ids)
+}
+
+// Resolve finds the nodes a user-supplied reference names. An exact node ID
+// matches itself; otherwise a "Kind[.group]/name" reference without a
+// namespace matches that resource in every namespace. The result is sorted
+// and is empty if nothing matches.
+func (g *Graph) Resolve(ref string) []string {
+	if _, ok := g.nodes[ref]; ok {
+		return []string{ref}
+	}
+	want := ParseResourceID(ref)
+	if want.Namespace != "" {
+		return nil
+	}
+	var ids []string
+	for id, n := range g.nodes {
+		if n.Kind == want.Kind && n.Name == want.Name && sameGroup(n, want.Group) {
+			ids = append(ids, id)
+		}
+	}
+	sort.Strings(ids)
+	return ids
+}
+
+// ResolveOne is Resolve for callers that need a single node: it returns an
+// error when ref matches nothing or matches resources in several namespaces.
+func (g *Graph) ResolveOne(ref string) (string, error) {
+	matches := g.Resolve(ref)
+	switch len(matches) {
+	case 0:
+		return "", fmt.Errorf("resource %q not found in the input", ref)
+	case 1:
+		return matches[0], nil
+	default:
+		return "", fmt.Errorf("resource %q is ambiguous, specify one of: %s", ref, strings.Join(matches, ", "))
+	}
+}
+
+// sameGroup reports whether the node belongs to group, treating the group
+// of a built-in kind as implied when the reference leaves it out.
+func sameGroup(n *Node, group string) bool {
+	if group == "" {
+		return IsBuiltinGroup(n.Kind, n.Group)
+	}
+	return n.Group == group
+}
+
+// Highlight marks nodes to be emphasized by the exporters. Unknown IDs are
+// ignored.
+func (g *Graph) Highlight(ids ...string) {
+	for _, id := range ids {
+		if _, ok := g.nodes[id]; ok {
+			g.highlighted[id] = true
+		}
+	}
+}
+
+// IsHighlighted reports whether the node was marked with Highlight.
+func (g *Graph) IsHighlighted(id string) bool {
+	return g.highlighted[id]
+}
+
+// HighlightEdges marks edges to be emphasized by the exporters. Edges not in
+// the graph are ignored.
+func (g *Graph) HighlightEdges(edges ...Edge) {
+	for _, e := range edges {
+		if _, ok := g.edgeSeen[edgeKey(e)]; ok {
+			g.highlightedEdges[edgeKey(e)] = true
+		}
+	}
+}
+
+// IsEdgeHighlighted reports whether the edge was marked with HighlightEdges.
+func (g *Graph) IsEdgeHighlighted(e Edge) bool {
+	return g.highlightedEdges[edgeKey(e)]
+}
+
+// GroupByChart makes the exporters draw the nodes rendered from each Helm
+// chart and subchart (see parser.Source.Chart) inside a box labelled with the
+// chart path.
+func (g *Graph) GroupByChart(enabled bool) {
+	g.groupByChart = enabled
+}
+
+// GroupsByChart reports whether chart grouping was enabled with GroupByChart.
+func (g *Graph) GroupsByChart() bool {
+	return g.groupByChart
+}
+
+// Subgraph returns a new graph holding the given nodes and every edge between
+// them. Nodes and edges keep their metadata and highlighting, and the graph
+// its chart grouping; unknown IDs are ignored.
+func (g *Graph) Subgraph(ids []string) *Graph {
+	keep := make(map[string]bool, len(ids))
+	sub := NewGraph()
+	sub.groupByChart = g.groupByChart
+	for _, id := range ids {
+		n, ok := g.nodes[id]
+		if !ok || keep[id] {
+			continue
+		}
+		keep[id] = true
+		copied := *n
+		sub.nodes[id] = &copied
+		if g.highlighted[id] {
+			sub.highlighted[id] = true
+		}
+	}
+	for _, e := range g.AllEdges() {
+		if keep[e.ParentID] && keep[e.ChildID] {
+			sub.AddEdge(e)
+			if g.IsEdgeHighlighted(e) {
+				sub.highlightedEdges[edgeKey(e)] = true
+			}
+		}
+	}
+	return sub
+}
+
+// chartGroups returns, when the graph groups by chart, the sorted charts the
+// given nodes were rendered from and the chart of each such node. Nodes not
+// rendered from a chart are left out of the map.
+func (g *Graph) chartGroups(ids []string) ([]string, map[string]string) {
+	chartOf := make(map[string]string)
+	if !g.groupByChart {
+		return nil, chartOf
+	}
+	var charts []string
+	for _, id := range ids {
+		n, ok := g.nodes[id]
+		if !ok || n.Source.Chart == "" {
+			continue
+		}
+		chartOf[id] = n.Source.Chart
+		charts = append(charts, n.Source.Chart)
+	}
+	return sortedUnique(charts), chartOf
+}
+
+// connectedIDs returns the sorted IDs of nodes that participate in at least
+// one edge, plus any highlighted node. Exporters use it to leave orphan nodes
+// out of diagrams.
+func (g *Graph) connectedIDs() []string {
+	ids := make([]string, 0, len(g.nodes))
+	for id := range g.nodes {
+		if len(g.out[id]) > 0 || len(g.in[id]) > 0 || g.highlighted[id] {
+			ids = append(ids, id)
+		}
+	}
+	sort.Strings(ids)
+	return ids
+}
+
+func sortedUnique(ids []string) []string {
+	sort.Strings(ids)
+	out := ids[:0]
+	for i, id := range ids {
+		if i == 0 || id != ids[i-1] {
+			out = append(out, id)
+		}
+	}
+	return out
+}
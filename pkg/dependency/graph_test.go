@@ -0,0 +1,301 @@
+package dependency_test
+
+import (
+	"testing"
+
+	"github.com/HMetcalfeW/cartographer/pkg/dependency"
+	"github.com/HMetcalfeW/cartographer/pkg/parser"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+)
+
+// graphFromEdges builds a Graph from a parent → edges map. Every node is
+// backed by a minimal object, so none are reported as missing. Parents
+// without edges become standalone nodes so orphan handling can be exercised.
+func graphFromEdges(deps map[string][]dependency.Edge) *dependency.Graph {
+	g := dependency.NewGraph()
+	for parent, edges := range deps {
+		g.AddObject(objectForID(parent))
+		for _, e := range edges {
+			e.ParentID = parent
+			g.AddEdge(e)
+		}
+	}
+	for _, n := range g.MissingNodes() {
+		g.AddObject(objectForID(n.ID))
+	}
+	return g
+}
+
+// objectForID returns a minimal object whose ResourceID is id.
+func objectForID(id string) *unstructured.Unstructured {
+	key := dependency.ParseResourceID(id)
+	obj := &unstructured.Unstructured{}
+	if key.Group != "" {
+		obj.SetAPIVersion(key.Group + "/v1")
+	}
+	obj.SetKind(key.Kind)
+	obj.SetNamespace(key.Namespace)
+	obj.SetName(key.Name)
+	return obj
+}
+
+// TestGraphAddObject verifies node metadata is captured from the manifest.
+func TestGraphAddObject(t *testing.T) {
+	obj := &unstructured.Unstructured{
+		Object: map[string]interface{}{
+			"apiVersion": "apps/v1",
+			"kind":       "Deployment",
+			"metadata": map[string]interface{}{
+				"name":      "web",
+				"namespace": "prod",
+				"labels":    map[string]interface{}{"app": "web"},
+				"annotations": map[string]interface{}{
+					"team": "platform",
+					"kubectl.kubernetes.io/last-applied-configuration": "{}",
+				},
+			},
+		},
+	}
+
+	g := dependency.NewGraph()
+	g.AddObject(obj)
+
+	n, ok := g.Node("Deployment/prod/web")
+	require.True(t, ok)
+	assert.Equal(t, "apps", n.Group)
+	assert.Equal(t, "v1", n.Version)
+	assert.Equal(t, "apps/v1", n.APIVersion())
+	assert.Equal(t, "Deployment", n.Kind)
+	assert.Equal(t, "prod", n.Namespace)
+	assert.Equal(t, "web", n.Name)
+	assert.Equal(t, map[string]string{"app": "web"}, n.Labels)
+	assert.Equal(t, map[string]string{"team": "platform"}, n.Annotations, "last-applied-configuration is dropped")
+	assert.Same(t, obj, n.Object)
+	assert.Equal(t, 1, g.Len())
+}
+
+// TestGraphSetOrigins verifies that origins recorded by the caller become
+// Node.Source, and that an object replaced by a later one with the same ID
+// does not overwrite the origin of the node.
+func TestGraphSetOrigins(t *testing.T) {
+	web := objectForID("Service/web")
+	web.SetAnnotations(map[string]string{"team": "platform"})
+	replaced := objectForID("ConfigMap/settings")
+	settings := objectForID("ConfigMap/settings")
+
+	g := dependency.NewGraph()
+	g.AddObject(web)
+	g.AddObject(replaced)
+	g.AddObject(settings)
+	g.SetOrigins(dependency.Origins{
+		web:      {Source: parser.Source{File: "k8s/prod/api.yaml", Document: 4, Line: 142}},
+		replaced: {Source: parser.Source{File: "k8s/old.yaml", Line: 1}},
+		settings: {Source: parser.Source{File: "k8s/settings.yaml", Line: 7}},
+	})
+
+	n, ok := g.Node("Service/web")
+	require.True(t, ok)
+	assert.Equal(t, parser.Source{File: "k8s/prod/api.yaml", Document: 4, Line: 142}, n.Source)
+	assert.Equal(t, "k8s/prod/api.yaml:142", n.Source.String())
+	assert.Equal(t, map[string]string{"team": "platform"}, n.Annotations)
+
+	n, _ = g.Node("ConfigMap/settings")
+	assert.Equal(t, "k8s/settings.yaml:7", n.Source.String())
+}
+
+// TestGraphSetOrigins_Hook verifies that a hook recorded by the caller
+// becomes Node.Hook and that the object's annotations are left alone.
+func TestGraphSetOrigins_Hook(t *testing.T) {
+	job := objectForID("Job/migrate")
+	job.SetAnnotations(map[string]string{"helm.sh/hook": "pre-install,pre-upgrade"})
+
+	g := dependency.NewGraph()
+	g.AddObject(job)
+	g.AddObject(objectForID("Job/plain"))
+	g.SetOrigins(dependency.Origins{
+		job: {Hook: &dependency.Hook{Events: []string{"pre-install", "pre-upgrade"}, Weight: -5}},
+	})
+
+	n, _ := g.Node("Job/migrate")
+	require.NotNil(t, n.Hook)
+	assert.Equal(t, []string{"pre-install", "pre-upgrade"}, n.Hook.Events)
+	assert.Equal(t, -5, n.Hook.Weight)
+	assert.Equal(t, "pre-install,pre-upgrade (weight -5)", n.Hook.String())
+	assert.Equal(t, map[string]string{"helm.sh/hook": "pre-install,pre-upgrade"}, n.Annotations)
+
+	n, _ = g.Node("Job/plain")
+	assert.Nil(t, n.Hook)
+}
+
+// TestGraphSetOrigins_Unreadable verifies that the references an origin lists
+// as unreadable mark their missing nodes, and leave nodes backed by an object
+// alone.
+func TestGraphSetOrigins_Unreadable(t *testing.T) {
+	binding := objectForID("RoleBinding.rbac.authorization.k8s.io/app/reader")
+
+	g := dependency.NewGraph()
+	g.AddObject(binding)
+	g.AddObject(objectForID("ConfigMap/app/settings"))
+	g.AddEdge(dependency.Edge{ParentID: dependency.ResourceID(binding), ChildID: "ClusterRole/secret-reader", Reason: "roleRef"})
+	g.SetOrigins(dependency.Origins{
+		binding: {Unreadable: []string{"ClusterRole/secret-reader", "ConfigMap/app/settings"}},
+	})
+
+	n, _ := g.Node("ClusterRole/secret-reader")
+	assert.True(t, n.Missing())
+	assert.True(t, n.Unreadable)
+
+	n, _ = g.Node("ConfigMap/app/settings")
+	assert.False(t, n.Unreadable)
+}
+
+// TestGraphPlaceholderNodes verifies that edge targets without a manifest
+// become reference-only nodes, and are filled in if the object appears later.
+func TestGraphPlaceholderNodes(t *testing.T) {
+	g := dependency.NewGraph()
+	g.AddEdge(dependency.Edge{ParentID: "Deployment/prod/web", ChildID: "Secret/prod/db", Reason: "secretRef"})
+
+	n, ok := g.Node("Secret/prod/db")
+	require.True(t, ok)
+	assert.Nil(t, n.Object)
+	assert.Equal(t, "Secret", n.Kind)
+	assert.Equal(t, "prod", n.Namespace)
+	assert.Equal(t, "db", n.Name)
+	assert.Empty(t, n.APIVersion())
+
+	secret := &unstructured.Unstructured{}
+	secret.SetAPIVersion("v1")
+	secret.SetKind("Secret")
+	secret.SetNamespace("prod")
+	secret.SetName("db")
+	g.AddObject(secret)
+
+	n, _ = g.Node("Secret/prod/db")
+	assert.Same(t, secret, n.Object)
+	assert.Equal(t, "v1", n.APIVersion())
+	assert.Equal(t, 2, g.Len())
+}
+
+// TestGraphNeighbors verifies forward and reverse adjacency lookups.
+func TestGraphNeighbors(t *testing.T) {
+	g := graphFromEdges(map[string][]dependency.Edge{
+		"Deployment/web": {
+			{ChildID: "Secret/db", Reason: "secretRef"},
+			{ChildID: "ConfigMap/cfg", Reason: "configMapRef"},
+		},
+		"Deployment/worker": {
+			{ChildID: "Secret/db", Reason: "secretRef"},
+		},
+		"Service/web": {
+			{ChildID: "Deployment/web", Reason: "selector"},
+		},
+	})
+
+	assert.Equal(t, []string{"ConfigMap/cfg", "Secret/db"}, g.Neighbors("Deployment/web"))
+	assert.Equal(t, []string{"Deployment/web", "Deployment/worker"}, g.ReverseNeighbors("Secret/db"))
+	assert.Equal(t, []string{"Service/web"}, g.ReverseNeighbors("Deployment/web"))
+	assert.Empty(t, g.Neighbors("Secret/db"))
+	assert.Empty(t, g.ReverseNeighbors("Service/web"))
+
+	in := g.InEdges("Secret/db")
+	require.Len(t, in, 2)
+	assert.Equal(t, "secretRef", in[0].Reason)
+
+	_, ok := g.Node("Secret/missing")
+	assert.False(t, ok)
+}
+
+// TestGraphAddEdgeDeduplicates verifies identical edges are stored once while
+// edges that differ only by reason are kept.
+func TestGraphAddEdgeDeduplicates(t *testing.T) {
+	g := dependency.NewGraph()
+	e := dependency.Edge{ParentID: "Deployment/web", ChildID: "Secret/db", Reason: "secretRef"}
+	g.AddEdge(e)
+	g.AddEdge(e)
+	g.AddEdge(dependency.Edge{ParentID: "Deployment/web", ChildID: "Secret/db", Reason: "volume"})
+
+	assert.Len(t, g.Edges("Deployment/web"), 2)
+	assert.Len(t, g.InEdges("Secret/db"), 2)
+	assert.Len(t, g.AllEdges(), 2)
+}
+
+// TestGraphAddEdgeOptional verifies a duplicate edge stays optional only
+// when every field producing it is optional.
+func TestGraphAddEdgeOptional(t *testing.T) {
+	g := dependency.NewGraph()
+	optional := dependency.Edge{ParentID: "Deployment/web", ChildID: "Secret/db", Reason: "secretRef", Field: "env[0]", Optional: true}
+	g.AddEdge(optional)
+	g.AddEdge(optional)
+	require.Len(t, g.AllEdges(), 1)
+	assert.True(t, g.AllEdges()[0].Optional)
+
+	g.AddEdge(dependency.Edge{ParentID: "Deployment/web", ChildID: "Secret/db", Reason: "secretRef", Field: "envFrom[0]"})
+	require.Len(t, g.AllEdges(), 1)
+	assert.False(t, g.AllEdges()[0].Optional)
+	assert.Equal(t, "env[0], envFrom[0]", g.AllEdges()[0].Field)
+	assert.False(t, g.InEdges("Secret/db")[0].Optional)
+}
+
+// TestGraphNodesSorted verifies Nodes returns a deterministic order.
+func TestGraphNodesSorted(t *testing.T) {
+	g := graphFromEdges(map[string][]dependency.Edge{
+		"Service/web":    {{ChildID: "Deployment/web", Reason: "selector"}},
+		"Deployment/web": {{ChildID: "Secret/db", Reason: "secretRef"}},
+	})
+
+	var ids []string
+	for _, n := range g.Nodes() {
+		ids = append(ids, n.ID)
+	}
+	assert.Equal(t, []string{"Deployment/web", "Secret/db", "Service/web"}, ids)
+}
+
+// TestGraphMissingNodes verifies reference targets without a backing object
+// are reported as missing.
+func TestGraphMissingNodes(t *testing.T) {
+	deploy := &unstructured.Unstructured{
+		Object: map[string]interface{}{
+			"apiVersion": "apps/v1",
+			"kind":       "Deployment",
+			"metadata":   map[string]interface{}{"name": "web", "namespace": "prod"},
+			"spec": map[string]interface{}{
+				"template": map[string]interface{}{
+					"spec": map[string]interface{}{
+						"serviceAccountName": "web-sa",
+						"containers": []interface{}{
+							map[string]interface{}{
+								"name": "web",
+								"env": []interface{}{
+									map[string]interface{}{
+										"name": "PASS",
+										"valueFrom": map[string]interface{}{
+											"secretKeyRef": map[string]interface{}{"name": "db", "key": "pass"},
+										},
+									},
+								},
+							},
+						},
+					},
+				},
+			},
+		},
+	}
+	sa := &unstructured.Unstructured{}
+	sa.SetAPIVersion("v1")
+	sa.SetKind("ServiceAccount")
+	sa.SetNamespace("prod")
+	sa.SetName("web-sa")
+
+	g := dependency.BuildDependencies([]*unstructured.Unstructured{deploy, sa})
+
+	missing := g.MissingNodes()
+	require.Len(t, missing, 1)
+	assert.Equal(t, "Secret/prod/db", missing[0].ID)
+
+	n, _ := g.Node("ServiceAccount/prod/web-sa")
+	assert.False(t, n.Missing())
+	n, _ = g.Node("Deployment/prod/web")
+	assert.False(t, n.Missing())
+}
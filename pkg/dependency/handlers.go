@@ -2,6 +2,7 @@ package dependency
 
 import (
 	"fmt"
+	"slices"
 
 	log "github.com/sirupsen/logrus"
 	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
@@ -11,33 +12,34 @@ import (
 // the Service's .spec.selector, and records each matching resource as a child with Reason="selector".
 func handleServiceLabelSelector(
 	svc *unstructured.Unstructured,
-	labelIdx LabelIndex,
-	deps map[string][]Edge,
-) {
+	ctx *HandlerContext,
+) []Edge {
+	var edges []Edge
 	localLogger := log.WithField("func", "handleServiceLabelSelector")
 	svcID := ResourceID(svc)
 	spec, found, err := unstructured.NestedMap(svc.Object, "spec")
 	if err != nil {
 		localLogger.WithError(err).Warn("Could not retrieve .spec from Service")
-		return
+		return nil
 	}
 	if !found {
-		return
+		return nil
 	}
 	selObj, selFound, _ := unstructured.NestedFieldCopy(spec, "selector")
 	if !selFound {
-		return
+		return nil
 	}
 	selectorMap := MapInterfaceToStringMap(selObj)
 
-	for _, target := range labelIdx.Match(selectorMap) {
+	for _, target := range ctx.Labels.Match(svc.GetNamespace(), selectorMap) {
 		tgtID := ResourceID(target)
-		deps[svcID] = append(deps[svcID], Edge{ChildID: tgtID, Reason: "selector"})
+		edges = append(edges, Edge{ParentID: svcID, ChildID: tgtID, Reason: "selector", Field: "spec.selector"})
 		localLogger.WithFields(log.Fields{
 			"serviceID": svcID,
 			"targetID":  tgtID,
 		}).Debug("Added service->target dependency")
 	}
+	return edges
 }
 
 // handleNetworkPolicy finds Pods or controllers whose labels match
@@ -45,24 +47,24 @@ func handleServiceLabelSelector(
 // each link as Reason="podSelector".
 func handleNetworkPolicy(
 	np *unstructured.Unstructured,
-	labelIdx LabelIndex,
-	deps map[string][]Edge,
-) {
+	ctx *HandlerContext,
+) []Edge {
+	var edges []Edge
 	localLogger := log.WithField("func", "handleNetworkPolicy")
 	npID := ResourceID(np)
 	spec, found, err := unstructured.NestedMap(np.Object, "spec")
 	if err != nil {
 		localLogger.WithError(err).Warn("Could not retrieve .spec from NetworkPolicy")
-		return
+		return nil
 	}
 	if !found {
-		return
+		return nil
 	}
 
 	// Extract the full podSelector map.
 	podSelector, psFound, _ := unstructured.NestedMap(spec, "podSelector")
 	if !psFound || len(podSelector) == 0 {
-		return
+		return nil
 	}
 
 	// matchLabels (may be empty/absent).
@@ -73,41 +75,42 @@ func handleNetworkPolicy(
 	matchExprs := ExtractMatchExpressions(podSelector)
 
 	if len(matchLabels) == 0 && len(matchExprs) == 0 {
-		return
+		return nil
 	}
 
-	for _, obj := range labelIdx.MatchSelector(matchLabels, matchExprs) {
+	for _, obj := range ctx.Labels.MatchSelector(np.GetNamespace(), matchLabels, matchExprs) {
 		tgtID := ResourceID(obj)
-		deps[npID] = append(deps[npID], Edge{ChildID: tgtID, Reason: "podSelector"})
+		edges = append(edges, Edge{ParentID: npID, ChildID: tgtID, Reason: "podSelector", Field: "spec.podSelector"})
 		localLogger.WithFields(log.Fields{
 			"networkPolicy": npID,
 			"targetID":      tgtID,
 		}).Debug("Added networkpolicy->pod dependency")
 	}
+	return edges
 }
 
 // handlePodDisruptionBudget processes .spec.selector (both matchLabels and
 // matchExpressions) to find target objects and creates edges with Reason="pdbSelector".
 func handlePodDisruptionBudget(
 	pdb *unstructured.Unstructured,
-	labelIdx LabelIndex,
-	deps map[string][]Edge,
-) {
+	ctx *HandlerContext,
+) []Edge {
+	var edges []Edge
 	localLogger := log.WithField("func", "handlePodDisruptionBudget")
 	pdbID := ResourceID(pdb)
 	spec, found, err := unstructured.NestedMap(pdb.Object, "spec")
 	if err != nil {
 		localLogger.WithError(err).Warn("Could not retrieve .spec from PDB")
-		return
+		return nil
 	}
 	if !found {
-		return
+		return nil
 	}
 
 	// Extract the full selector map.
 	selector, selFound, _ := unstructured.NestedMap(spec, "selector")
 	if !selFound || len(selector) == 0 {
-		return
+		return nil
 	}
 
 	// matchLabels (may be empty/absent).
@@ -118,28 +121,32 @@ func handlePodDisruptionBudget(
 	matchExprs := ExtractMatchExpressions(selector)
 
 	if len(matchLabels) == 0 && len(matchExprs) == 0 {
-		return
+		return nil
 	}
 
-	for _, obj := range labelIdx.MatchSelector(matchLabels, matchExprs) {
+	for _, obj := range ctx.Labels.MatchSelector(pdb.GetNamespace(), matchLabels, matchExprs) {
 		tgtID := ResourceID(obj)
-		deps[pdbID] = append(deps[pdbID], Edge{ChildID: tgtID, Reason: "pdbSelector"})
+		edges = append(edges, Edge{ParentID: pdbID, ChildID: tgtID, Reason: "pdbSelector", Field: "spec.selector"})
 		localLogger.WithFields(log.Fields{
 			"pdb":    pdbID,
 			"target": tgtID,
 		}).Debug("Added pdb->pod/controller dependency")
 	}
+	return edges
 }
 
 // handleIngressReferences inspects an Ingress's .spec.rules[].http.paths[].backend
 // (both newer and older styles) and .spec.tls[].secretName, creating edges with
-// Reason="ingressBackend" or Reason="tlsSecret", respectively.
+// Reason="ingressBackend" or Reason="tlsSecret", respectively. Backends and TLS
+// secrets always live in the Ingress's own namespace.
 func handleIngressReferences(
 	ingress *unstructured.Unstructured,
-	deps map[string][]Edge,
-) {
+	ctx *HandlerContext,
+) []Edge {
+	var edges []Edge
 	localLogger := log.WithField("func", "handleIngressReferences")
 	ingID := ResourceID(ingress)
+	ns := ingress.GetNamespace()
 
 	// 1. Ingress -> Services in .spec.rules[].http.paths[].backend
 	rules, foundRules, errRules := unstructured.NestedSlice(ingress.Object, "spec", "rules")
@@ -147,7 +154,7 @@ func handleIngressReferences(
 		localLogger.WithError(errRules).Warn("Error retrieving .spec.rules from Ingress")
 	}
 	if foundRules {
-		for _, rule := range rules {
+		for i, rule := range rules {
 			rMap, ok := rule.(map[string]interface{})
 			if !ok {
 				continue
@@ -160,24 +167,31 @@ func handleIngressReferences(
 			if !foundPaths {
 				continue
 			}
-			for _, p := range paths {
+			for j, p := range paths {
 				pathMap, ok := p.(map[string]interface{})
 				if !ok {
 					continue
 				}
+				backend := fmt.Sprintf("spec.rules[%d].http.paths[%d].backend", i, j)
 				// Newer style: .backend.service.name
 				backendSvc, foundB, _ := unstructured.NestedMap(pathMap, "backend", "service")
 				if foundB && backendSvc != nil {
 					if svcName, ok := backendSvc["name"].(string); ok && svcName != "" {
-						deps[ingID] = append(deps[ingID], Edge{
-							ChildID: "Service/" + svcName, Reason: "ingressBackend",
+						edges = append(edges, Edge{
+							ParentID: ingID,
+							ChildID:  RefID("", "Service", ns, svcName),
+							Reason:   "ingressBackend",
+							Field:    backend + ".service",
 						})
 					}
 				}
 				// Older style: .backend.serviceName
 				if oldSvcName, oldFound, _ := unstructured.NestedString(pathMap, "backend", "serviceName"); oldFound && oldSvcName != "" {
-					deps[ingID] = append(deps[ingID], Edge{
-						ChildID: "Service/" + oldSvcName, Reason: "ingressBackend",
+					edges = append(edges, Edge{
+						ParentID: ingID,
+						ChildID:  RefID("", "Service", ns, oldSvcName),
+						Reason:   "ingressBackend",
+						Field:    backend + ".serviceName",
 					})
 				}
 			}
@@ -190,38 +204,49 @@ func handleIngressReferences(
 		localLogger.WithError(errTls).Warn("Error retrieving .spec.tls from Ingress")
 	}
 	if foundTls {
-		for _, tVal := range tlsSlice {
+		for i, tVal := range tlsSlice {
 			tMap, ok := tVal.(map[string]interface{})
 			if !ok {
 				continue
 			}
 			if secName, ok := tMap["secretName"].(string); ok && secName != "" {
-				deps[ingID] = append(deps[ingID], Edge{
-					ChildID: "Secret/" + secName, Reason: "tlsSecret",
+				edges = append(edges, Edge{
+					ParentID: ingID,
+					ChildID:  RefID("", "Secret", ns, secName),
+					Reason:   "tlsSecret",
+					Field:    fmt.Sprintf("spec.tls[%d].secretName", i),
 				})
 			}
 		}
 	}
+	return edges
 }
 
 // handleRoleBinding processes RoleBinding and ClusterRoleBinding objects.
 // It creates edges to the referenced Role/ClusterRole (via .roleRef) with
 // Reason="roleRef", and to each ServiceAccount subject with Reason="subject".
+// A Role is resolved in the binding's namespace; subjects use their own
+// .namespace when set and fall back to the binding's namespace otherwise.
 func handleRoleBinding(
 	rb *unstructured.Unstructured,
-	deps map[string][]Edge,
-) {
+	ctx *HandlerContext,
+) []Edge {
+	var edges []Edge
 	rbID := ResourceID(rb)
+	ns := rb.GetNamespace()
 
 	// roleRef → Role or ClusterRole
 	roleRef, found, _ := unstructured.NestedMap(rb.Object, "roleRef")
 	if found && len(roleRef) > 0 {
 		kind, _ := roleRef["kind"].(string)
 		name, _ := roleRef["name"].(string)
+		group, _ := roleRef["apiGroup"].(string)
 		if kind != "" && name != "" {
-			deps[rbID] = append(deps[rbID], Edge{
-				ChildID: fmt.Sprintf("%s/%s", kind, name),
-				Reason:  "roleRef",
+			edges = append(edges, Edge{
+				ParentID: rbID,
+				ChildID:  RefID(group, kind, ns, name),
+				Reason:   "roleRef",
+				Field:    "roleRef",
 			})
 		}
 	}
@@ -229,9 +254,9 @@ func handleRoleBinding(
 	// subjects[] → ServiceAccounts
 	subjects, foundSubjects, _ := unstructured.NestedSlice(rb.Object, "subjects")
 	if !foundSubjects {
-		return
+		return edges
 	}
-	for _, s := range subjects {
+	for i, s := range subjects {
 		sMap, ok := s.(map[string]interface{})
 		if !ok {
 			continue
@@ -239,34 +264,172 @@ func handleRoleBinding(
 		kind, _ := sMap["kind"].(string)
 		name, _ := sMap["name"].(string)
 		if kind == "ServiceAccount" && name != "" {
-			deps[rbID] = append(deps[rbID], Edge{
-				ChildID: fmt.Sprintf("ServiceAccount/%s", name),
-				Reason:  "subject",
+			subjectNS, _ := sMap["namespace"].(string)
+			if subjectNS == "" {
+				subjectNS = ns
+			}
+			edges = append(edges, Edge{
+				ParentID: rbID,
+				ChildID:  RefID("", "ServiceAccount", subjectNS, name),
+				Reason:   "subject",
+				Field:    fmt.Sprintf("subjects[%d]", i),
 			})
 		}
 	}
+	return edges
 }
 
 // handleHPAReferences checks .spec.scaleTargetRef for HPA objects, creating an
-// edge with Reason="scaleTargetRef".
+// edge with Reason="scaleTargetRef". The target is resolved in the HPA's
+// namespace using the group from scaleTargetRef.apiVersion.
 func handleHPAReferences(
 	hpa *unstructured.Unstructured,
-	deps map[string][]Edge,
-) {
+	ctx *HandlerContext,
+) []Edge {
+	var edges []Edge
 	localLogger := log.WithField("func", "handleHPAReferences")
 	hpaID := ResourceID(hpa)
 	scaleTarget, found, err := unstructured.NestedMap(hpa.Object, "spec", "scaleTargetRef")
 	if err != nil {
 		localLogger.WithError(err).Warn("Could not retrieve .spec.scaleTargetRef from HPA")
-		return
+		return nil
 	}
 	if !found || len(scaleTarget) == 0 {
-		return
+		return nil
 	}
 	if kind, ok := scaleTarget["kind"].(string); ok && kind != "" {
 		if name, ok := scaleTarget["name"].(string); ok && name != "" {
-			targetID := fmt.Sprintf("%s/%s", kind, name)
-			deps[hpaID] = append(deps[hpaID], Edge{ChildID: targetID, Reason: "scaleTargetRef"})
+			apiVersion, _ := scaleTarget["apiVersion"].(string)
+			targetID := RefID(groupOf(apiVersion), kind, hpa.GetNamespace(), name)
+			edges = append(edges, Edge{ParentID: hpaID, ChildID: targetID, Reason: "scaleTargetRef", Field: "spec.scaleTargetRef"})
+		}
+	}
+	return edges
+}
+
+// handleStatefulSetClaims links a StatefulSet to the PersistentVolumeClaims
+// its .spec.volumeClaimTemplates create, named "<template>-<statefulset>-<ordinal>",
+// with Reason="volumeClaimTemplate". The number of claims depends on the
+// replica history, so only claims present in the input are linked.
+func handleStatefulSetClaims(
+	sts *unstructured.Unstructured,
+	ctx *HandlerContext,
+) []Edge {
+	templates, found, _ := unstructured.NestedSlice(sts.Object, "spec", "volumeClaimTemplates")
+	if !found {
+		return nil
+	}
+	prefixes := make(map[string]int, len(templates))
+	for i, tmpl := range templates {
+		name, _, _ := unstructured.NestedString(asMap(tmpl), "metadata", "name")
+		if name != "" {
+			prefixes[name+"-"+sts.GetName()+"-"] = i
+		}
+	}
+	if len(prefixes) == 0 {
+		return nil
+	}
+
+	claims := ctx.claims
+	if claims == nil {
+		claims = buildClaimIndex(ctx.Objects)
+	}
+	type match struct {
+		claimEntry
+		template int
+	}
+	var matched []match
+	for prefix, i := range prefixes {
+		for _, c := range claims[claimIndexKey(sts.GetNamespace(), prefix)] {
+			matched = append(matched, match{c, i})
+		}
+	}
+	// Link the claims in input order.
+	slices.SortFunc(matched, func(a, b match) int { return a.order - b.order })
+
+	var edges []Edge
+	stsID := ResourceID(sts)
+	for _, m := range matched {
+		edges = append(edges, Edge{
+			ParentID: stsID,
+			ChildID:  ResourceID(m.obj),
+			Reason:   "volumeClaimTemplate",
+			Field:    fmt.Sprintf("spec.volumeClaimTemplates[%d]", m.template),
+		})
+	}
+	return edges
+}
+
+// claimEntry is a PersistentVolumeClaim in a claim index with its input
+// position.
+type claimEntry struct {
+	obj   *unstructured.Unstructured
+	order int
+}
+
+// claimIndexKey builds the claim index key for the claims in namespace whose
+// name is prefix followed by an ordinal.
+func claimIndexKey(namespace, prefix string) string {
+	return namespace + "|" + prefix
+}
+
+// buildClaimIndex indexes the PersistentVolumeClaims in objs whose names end
+// in an ordinal by namespace and the name before the ordinal, so each
+// StatefulSet finds its claims without scanning every object.
+func buildClaimIndex(objs []*unstructured.Unstructured) map[string][]claimEntry {
+	idx := make(map[string][]claimEntry)
+	for i, obj := range objs {
+		if obj.GetKind() != "PersistentVolumeClaim" || !IsBuiltinGroup(obj.GetKind(), obj.GroupVersionKind().Group) {
+			continue
+		}
+		if prefix, ordinal := splitOrdinal(obj.GetName()); ordinal {
+			key := claimIndexKey(obj.GetNamespace(), prefix)
+			idx[key] = append(idx[key], claimEntry{obj: obj, order: i})
 		}
 	}
+	return idx
+}
+
+// splitOrdinal splits a name ending in a decimal ordinal into the part before
+// the ordinal and true, e.g. "data-db-12" gives "data-db-", true.
+func splitOrdinal(name string) (string, bool) {
+	i := len(name)
+	for i > 0 && name[i-1] >= '0' && name[i-1] <= '9' {
+		i--
+	}
+	return name[:i], i < len(name)
+}
+
+// handleServiceAccountSecrets links a ServiceAccount to the Secrets listed in
+// its .secrets and .imagePullSecrets with Reason="secretRef". Both are
+// resolved in the ServiceAccount's namespace.
+func handleServiceAccountSecrets(
+	sa *unstructured.Unstructured,
+	ctx *HandlerContext,
+) []Edge {
+	var edges []Edge
+	saID := ResourceID(sa)
+	for _, key := range []string{"secrets", "imagePullSecrets"} {
+		refs, found, _ := unstructured.NestedSlice(sa.Object, key)
+		if !found {
+			continue
+		}
+		for i, ref := range refs {
+			if name, ok := asMap(ref)["name"].(string); ok && name != "" {
+				edges = append(edges, Edge{
+					ParentID: saID,
+					ChildID:  RefID("", "Secret", sa.GetNamespace(), name),
+					Reason:   "secretRef",
+					Field:    fmt.Sprintf("%s[%d]", key, i),
+				})
+			}
+		}
+	}
+	return edges
+}
+
+// asMap returns v as a map, or nil if it is not one.
+func asMap(v interface{}) map[string]interface{} {
+	m, _ := v.(map[string]interface{})
+	return m
 }
@@ -57,7 +57,7 @@ func TestServiceSelectorOnlyMatchesPodControllers(t *testing.T) {
 	}
 
 	deps := dependency.BuildDependencies([]*unstructured.Unstructured{svc, deploy, sa, cm})
-	svcEdges := deps["Service/my-svc"]
+	svcEdges := deps.Edges("Service/my-svc")
 
 	require.Len(t, svcEdges, 1, "Service should only match the Deployment")
 	assert.Equal(t, "Deployment/my-deploy", svcEdges[0].ChildID)
@@ -102,7 +102,7 @@ func TestNetworkPolicyMatchesPodControllers(t *testing.T) {
 	}
 
 	deps := dependency.BuildDependencies([]*unstructured.Unstructured{np, sts, cm})
-	npEdges := deps["NetworkPolicy/deny-all"]
+	npEdges := deps.Edges("NetworkPolicy/deny-all")
 
 	require.Len(t, npEdges, 1, "NetworkPolicy should only match the StatefulSet")
 	assert.Equal(t, "StatefulSet/postgres", npEdges[0].ChildID)
@@ -134,7 +134,7 @@ func TestNetworkPolicyEmptySelector(t *testing.T) {
 	}
 
 	deps := dependency.BuildDependencies([]*unstructured.Unstructured{np, pod})
-	assert.Empty(t, deps["NetworkPolicy/allow-all"], "empty podSelector should match nothing")
+	assert.Empty(t, deps.Edges("NetworkPolicy/allow-all"), "empty podSelector should match nothing")
 }
 
 // TestPodDisruptionBudgetSelector verifies PDB selector matching.
@@ -174,7 +174,7 @@ func TestPodDisruptionBudgetSelector(t *testing.T) {
 	}
 
 	deps := dependency.BuildDependencies([]*unstructured.Unstructured{pdb, deploy, other})
-	pdbEdges := deps["PodDisruptionBudget/web-pdb"]
+	pdbEdges := deps.Edges("PodDisruptionBudget/web-pdb")
 
 	require.Len(t, pdbEdges, 1)
 	assert.Equal(t, "Deployment/web-deploy", pdbEdges[0].ChildID)
@@ -223,7 +223,7 @@ func TestIngressReferences(t *testing.T) {
 	}
 
 	deps := dependency.BuildDependencies([]*unstructured.Unstructured{ing})
-	ingEdges := deps["Ingress/my-ing"]
+	ingEdges := deps.Edges("Ingress/my-ing")
 
 	require.Len(t, ingEdges, 3, "expected 2 backend services + 1 TLS secret")
 	var apiFound, webFound, tlsFound bool
@@ -269,7 +269,7 @@ func TestIngressOlderBackendStyle(t *testing.T) {
 	}
 
 	deps := dependency.BuildDependencies([]*unstructured.Unstructured{ing})
-	ingEdges := deps["Ingress/legacy-ing"]
+	ingEdges := deps.Edges("Ingress/legacy-ing")
 
 	require.Len(t, ingEdges, 1)
 	assert.Equal(t, "Service/legacy-svc", ingEdges[0].ChildID)
@@ -294,7 +294,7 @@ func TestHPAReferences(t *testing.T) {
 	}
 
 	deps := dependency.BuildDependencies([]*unstructured.Unstructured{hpa})
-	hpaEdges := deps["HorizontalPodAutoscaler/web-hpa"]
+	hpaEdges := deps.Edges("HorizontalPodAutoscaler/web-hpa")
 
 	require.Len(t, hpaEdges, 1)
 	assert.Equal(t, "Deployment/web-deploy", hpaEdges[0].ChildID)
@@ -313,7 +313,7 @@ func TestHPAMissingScaleTarget(t *testing.T) {
 	}
 
 	deps := dependency.BuildDependencies([]*unstructured.Unstructured{hpa})
-	assert.Empty(t, deps["HorizontalPodAutoscaler/empty-hpa"])
+	assert.Empty(t, deps.Edges("HorizontalPodAutoscaler/empty-hpa"))
 }
 
 // TestNetworkPolicyMatchExpressions verifies NetworkPolicy with matchExpressions.
@@ -366,7 +366,7 @@ func TestNetworkPolicyMatchExpressions(t *testing.T) {
 	}
 
 	deps := dependency.BuildDependencies([]*unstructured.Unstructured{npIn, web, api, worker})
-	npEdges := deps["NetworkPolicy/prod-only"]
+	npEdges := deps.Edges("NetworkPolicy/prod-only")
 	require.Len(t, npEdges, 2, "should match web and api")
 	names := map[string]bool{}
 	for _, e := range npEdges {
@@ -394,7 +394,7 @@ func TestNetworkPolicyMatchExpressions(t *testing.T) {
 		},
 	}
 	deps = dependency.BuildDependencies([]*unstructured.Unstructured{npNotIn, web, api, worker})
-	npEdges = deps["NetworkPolicy/not-staging"]
+	npEdges = deps.Edges("NetworkPolicy/not-staging")
 	require.Len(t, npEdges, 2, "should match web and api, not worker")
 
 	// NetworkPolicy with mixed matchLabels + matchExpressions
@@ -416,7 +416,7 @@ func TestNetworkPolicyMatchExpressions(t *testing.T) {
 		},
 	}
 	deps = dependency.BuildDependencies([]*unstructured.Unstructured{npMixed, web, api, worker})
-	npEdges = deps["NetworkPolicy/prod-web"]
+	npEdges = deps.Edges("NetworkPolicy/prod-web")
 	require.Len(t, npEdges, 1, "only web matches env=prod AND app In [web]")
 	assert.Equal(t, "Deployment/web", npEdges[0].ChildID)
 
@@ -436,7 +436,7 @@ func TestNetworkPolicyMatchExpressions(t *testing.T) {
 		},
 	}
 	deps = dependency.BuildDependencies([]*unstructured.Unstructured{npExists, web, api, worker})
-	assert.Len(t, deps["NetworkPolicy/has-app"], 3, "all three have the app label")
+	assert.Len(t, deps.Edges("NetworkPolicy/has-app"), 3, "all three have the app label")
 }
 
 // TestPodDisruptionBudgetMatchExpressions verifies PDB with matchExpressions.
@@ -478,7 +478,7 @@ func TestPodDisruptionBudgetMatchExpressions(t *testing.T) {
 		},
 	}
 	deps := dependency.BuildDependencies([]*unstructured.Unstructured{pdb, master, replica})
-	pdbEdges := deps["PodDisruptionBudget/redis-pdb"]
+	pdbEdges := deps.Edges("PodDisruptionBudget/redis-pdb")
 	require.Len(t, pdbEdges, 2, "should match both redis deployments")
 
 	// PDB with combined matchLabels + matchExpressions
@@ -500,7 +500,7 @@ func TestPodDisruptionBudgetMatchExpressions(t *testing.T) {
 		},
 	}
 	deps = dependency.BuildDependencies([]*unstructured.Unstructured{pdbMixed, master, replica})
-	pdbEdges = deps["PodDisruptionBudget/redis-master-pdb"]
+	pdbEdges = deps.Edges("PodDisruptionBudget/redis-master-pdb")
 	require.Len(t, pdbEdges, 1, "only master matches app=redis AND component In [master]")
 	assert.Equal(t, "Deployment/redis-master", pdbEdges[0].ChildID)
 }
@@ -539,23 +539,23 @@ func TestLabelIndexMatch(t *testing.T) {
 		},
 	}
 
-	idx := dependency.BuildLabelIndex([]*unstructured.Unstructured{deploy, pod, sa})
+	idx := dependency.BuildLabelIndex([]*unstructured.Unstructured{deploy, pod, sa}, dependency.DefaultRegistry())
 
 	// Single label match
-	matches := idx.Match(map[string]string{"app": "web"})
+	matches := idx.Match("", map[string]string{"app": "web"})
 	assert.Len(t, matches, 2, "both deploy and pod have app=web")
 
 	// Multi-label match — only deploy has both app=web AND tier=frontend
-	matches = idx.Match(map[string]string{"app": "web", "tier": "frontend"})
+	matches = idx.Match("", map[string]string{"app": "web", "tier": "frontend"})
 	assert.Len(t, matches, 1)
 	assert.Equal(t, "web", matches[0].GetName())
 
 	// No match
-	matches = idx.Match(map[string]string{"app": "nonexistent"})
+	matches = idx.Match("", map[string]string{"app": "nonexistent"})
 	assert.Empty(t, matches)
 
 	// Empty selector
-	matches = idx.Match(map[string]string{})
+	matches = idx.Match("", map[string]string{})
 	assert.Empty(t, matches)
 }
 
@@ -579,7 +579,7 @@ func TestServiceMissingSpec(t *testing.T) {
 		},
 	}
 	deps := dependency.BuildDependencies([]*unstructured.Unstructured{svc, deploy})
-	assert.Empty(t, deps["Service/no-spec-svc"])
+	assert.Empty(t, deps.Edges("Service/no-spec-svc"))
 }
 
 // TestServiceMissingSelector verifies that a Service with spec but no selector produces no edges.
@@ -607,7 +607,7 @@ func TestServiceMissingSelector(t *testing.T) {
 		},
 	}
 	deps := dependency.BuildDependencies([]*unstructured.Unstructured{svc, deploy})
-	assert.Empty(t, deps["Service/no-sel-svc"])
+	assert.Empty(t, deps.Edges("Service/no-sel-svc"))
 }
 
 // TestNetworkPolicyMissingSpec verifies NP with no .spec produces no edges.
@@ -620,7 +620,7 @@ func TestNetworkPolicyMissingSpec(t *testing.T) {
 		},
 	}
 	deps := dependency.BuildDependencies([]*unstructured.Unstructured{np})
-	assert.Empty(t, deps["NetworkPolicy/no-spec"])
+	assert.Empty(t, deps.Edges("NetworkPolicy/no-spec"))
 }
 
 // TestPDBMissingSpec verifies PDB with no .spec produces no edges.
@@ -633,7 +633,7 @@ func TestPDBMissingSpec(t *testing.T) {
 		},
 	}
 	deps := dependency.BuildDependencies([]*unstructured.Unstructured{pdb})
-	assert.Empty(t, deps["PodDisruptionBudget/no-spec"])
+	assert.Empty(t, deps.Edges("PodDisruptionBudget/no-spec"))
 }
 
 // TestPDBEmptySelector verifies PDB with empty selector map produces no edges.
@@ -659,7 +659,7 @@ func TestPDBEmptySelector(t *testing.T) {
 		},
 	}
 	deps := dependency.BuildDependencies([]*unstructured.Unstructured{pdb, deploy})
-	assert.Empty(t, deps["PodDisruptionBudget/empty-sel"])
+	assert.Empty(t, deps.Edges("PodDisruptionBudget/empty-sel"))
 }
 
 // TestHPAMissingKindOrName verifies HPA with incomplete scaleTargetRef produces no edges.
@@ -678,7 +678,7 @@ func TestHPAMissingKindOrName(t *testing.T) {
 		},
 	}
 	deps := dependency.BuildDependencies([]*unstructured.Unstructured{hpaNoName})
-	assert.Empty(t, deps["HorizontalPodAutoscaler/hpa-no-name"])
+	assert.Empty(t, deps.Edges("HorizontalPodAutoscaler/hpa-no-name"))
 
 	// Has name but no kind
 	hpaNoKind := &unstructured.Unstructured{
@@ -694,7 +694,7 @@ func TestHPAMissingKindOrName(t *testing.T) {
 		},
 	}
 	deps = dependency.BuildDependencies([]*unstructured.Unstructured{hpaNoKind})
-	assert.Empty(t, deps["HorizontalPodAutoscaler/hpa-no-kind"])
+	assert.Empty(t, deps.Edges("HorizontalPodAutoscaler/hpa-no-kind"))
 }
 
 // TestIngressMissingRulesAndTLS verifies Ingress with no rules or TLS produces no edges.
@@ -708,7 +708,7 @@ func TestIngressMissingRulesAndTLS(t *testing.T) {
 		},
 	}
 	deps := dependency.BuildDependencies([]*unstructured.Unstructured{ing})
-	assert.Empty(t, deps["Ingress/empty-ing"])
+	assert.Empty(t, deps.Edges("Ingress/empty-ing"))
 }
 
 // TestIngressRuleWithoutHTTP verifies that a rule without an http block is skipped.
@@ -729,7 +729,7 @@ func TestIngressRuleWithoutHTTP(t *testing.T) {
 		},
 	}
 	deps := dependency.BuildDependencies([]*unstructured.Unstructured{ing})
-	assert.Empty(t, deps["Ingress/no-http-ing"])
+	assert.Empty(t, deps.Edges("Ingress/no-http-ing"))
 }
 
 // TestRoleBindingToRoleAndServiceAccount verifies RoleBinding creates edges
@@ -756,7 +756,7 @@ func TestRoleBindingToRoleAndServiceAccount(t *testing.T) {
 	}
 
 	deps := dependency.BuildDependencies([]*unstructured.Unstructured{rb})
-	edges := deps["RoleBinding/app-binding"]
+	edges := deps.Edges("RoleBinding/app-binding")
 
 	require.Len(t, edges, 2)
 	edgeSet := map[string]string{}
@@ -764,7 +764,7 @@ func TestRoleBindingToRoleAndServiceAccount(t *testing.T) {
 		edgeSet[e.ChildID] = e.Reason
 	}
 	assert.Equal(t, "roleRef", edgeSet["Role/app-role"])
-	assert.Equal(t, "subject", edgeSet["ServiceAccount/app-sa"])
+	assert.Equal(t, "subject", edgeSet["ServiceAccount/default/app-sa"])
 }
 
 // TestClusterRoleBindingToClusterRoleAndMultipleSubjects verifies ClusterRoleBinding
@@ -800,7 +800,7 @@ func TestClusterRoleBindingToClusterRoleAndMultipleSubjects(t *testing.T) {
 	}
 
 	deps := dependency.BuildDependencies([]*unstructured.Unstructured{crb})
-	edges := deps["ClusterRoleBinding/cluster-admin-binding"]
+	edges := deps.Edges("ClusterRoleBinding/cluster-admin-binding")
 
 	// Should have roleRef + 2 ServiceAccounts (Group subject is skipped)
 	require.Len(t, edges, 3)
@@ -809,8 +809,8 @@ func TestClusterRoleBindingToClusterRoleAndMultipleSubjects(t *testing.T) {
 		edgeSet[e.ChildID] = e.Reason
 	}
 	assert.Equal(t, "roleRef", edgeSet["ClusterRole/cluster-admin"])
-	assert.Equal(t, "subject", edgeSet["ServiceAccount/admin-sa"])
-	assert.Equal(t, "subject", edgeSet["ServiceAccount/monitoring-sa"])
+	assert.Equal(t, "subject", edgeSet["ServiceAccount/kube-system/admin-sa"])
+	assert.Equal(t, "subject", edgeSet["ServiceAccount/monitoring/monitoring-sa"])
 }
 
 // TestRoleBindingMissingRoleRef verifies RoleBinding with no roleRef still
@@ -831,7 +831,7 @@ func TestRoleBindingMissingRoleRef(t *testing.T) {
 	}
 
 	deps := dependency.BuildDependencies([]*unstructured.Unstructured{rb})
-	edges := deps["RoleBinding/no-roleref"]
+	edges := deps.Edges("RoleBinding/no-roleref")
 
 	require.Len(t, edges, 1)
 	assert.Equal(t, "ServiceAccount/some-sa", edges[0].ChildID)
@@ -855,7 +855,7 @@ func TestRoleBindingMissingSubjects(t *testing.T) {
 	}
 
 	deps := dependency.BuildDependencies([]*unstructured.Unstructured{rb})
-	edges := deps["RoleBinding/no-subjects"]
+	edges := deps.Edges("RoleBinding/no-subjects")
 
 	require.Len(t, edges, 1)
 	assert.Equal(t, "Role/app-role", edges[0].ChildID)
@@ -874,5 +874,5 @@ func TestRoleBindingEmpty(t *testing.T) {
 	}
 
 	deps := dependency.BuildDependencies([]*unstructured.Unstructured{rb})
-	assert.Empty(t, deps["RoleBinding/empty-rb"])
+	assert.Empty(t, deps.Edges("RoleBinding/empty-rb"))
 }
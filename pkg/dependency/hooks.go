@@ -0,0 +1,23 @@
+package dependency
+
+import (
+	"fmt"
+	"strings"
+)
+
+// hookColor outlines Helm hook nodes in DOT and Mermaid output.
+const hookColor = "#7F7F7F"
+
+// Hook describes a Helm hook resource.
+type Hook struct {
+	// Events are the hook events it runs on, e.g. "pre-install" or "test".
+	Events []string `json:"events"`
+
+	// Weight orders hooks of the same event; lower weights run first.
+	Weight int `json:"weight"`
+}
+
+// String renders the hook as e.g. "pre-install,pre-upgrade (weight -5)".
+func (h *Hook) String() string {
+	return fmt.Sprintf("%s (weight %d)", strings.Join(h.Events, ","), h.Weight)
+}
@@ -0,0 +1,145 @@
+package dependency
+
+import (
+	"strings"
+
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+)
+
+// builtinGroups maps each built-in Kind to the API groups it is served from.
+// A node ID omits the group for these kinds so that the common case stays
+// readable ("Deployment/web" rather than "Deployment.apps/web"). Any other
+// group (e.g. a Knative "Service") is spelled out in the ID.
+var builtinGroups = map[string]map[string]bool{
+	"Pod":                     {"": true},
+	"Service":                 {"": true},
+	"ConfigMap":               {"": true},
+	"Secret":                  {"": true},
+	"PersistentVolumeClaim":   {"": true},
+	"PersistentVolume":        {"": true},
+	"ServiceAccount":          {"": true},
+	"Namespace":               {"": true},
+	"Node":                    {"": true},
+	"Deployment":              {"apps": true, "extensions": true},
+	"DaemonSet":               {"apps": true, "extensions": true},
+	"StatefulSet":             {"apps": true},
+	"ReplicaSet":              {"apps": true, "extensions": true},
+	"Job":                     {"batch": true},
+	"CronJob":                 {"batch": true},
+	"Ingress":                 {"networking.k8s.io": true, "extensions": true},
+	"IngressClass":            {"networking.k8s.io": true},
+	"NetworkPolicy":           {"networking.k8s.io": true, "extensions": true},
+	"Role":                    {"rbac.authorization.k8s.io": true},
+	"ClusterRole":             {"rbac.authorization.k8s.io": true},
+	"RoleBinding":             {"rbac.authorization.k8s.io": true},
+	"ClusterRoleBinding":      {"rbac.authorization.k8s.io": true},
+	"HorizontalPodAutoscaler": {"autoscaling": true},
+	"PodDisruptionBudget":     {"policy": true},
+	"StorageClass":            {"storage.k8s.io": true},
+	"PriorityClass":           {"scheduling.k8s.io": true},
+}
+
+// clusterScopedKinds lists built-in kinds that never carry a namespace.
+var clusterScopedKinds = map[string]bool{
+	"ClusterRole":        true,
+	"ClusterRoleBinding": true,
+	"Namespace":          true,
+	"Node":               true,
+	"PersistentVolume":   true,
+	"StorageClass":       true,
+	"PriorityClass":      true,
+	"IngressClass":       true,
+}
+
+// ResourceKey identifies a Kubernetes object by API group, kind, namespace
+// and name. Its String form is the node ID used throughout the graph.
+type ResourceKey struct {
+	Group     string
+	Kind      string
+	Namespace string
+	Name      string
+}
+
+// String renders the key as a node ID of the form
+// "Kind[.group]/[namespace/]name". The group is omitted for built-in kinds
+// served from their usual group, and the namespace is omitted for
+// cluster-scoped kinds or when the object has none.
+func (k ResourceKey) String() string {
+	var sb strings.Builder
+	sb.WriteString(k.Kind)
+	if !IsBuiltinGroup(k.Kind, k.Group) {
+		sb.WriteString(".")
+		sb.WriteString(k.Group)
+	}
+	sb.WriteString("/")
+	if k.Namespace != "" && !IsClusterScoped(k.Kind, k.Group) {
+		sb.WriteString(k.Namespace)
+		sb.WriteString("/")
+	}
+	sb.WriteString(k.Name)
+	return sb.String()
+}
+
+// KeyFor builds the ResourceKey for an object from its apiVersion, kind,
+// metadata.namespace and metadata.name.
+func KeyFor(obj *unstructured.Unstructured) ResourceKey {
+	return ResourceKey{
+		Group:     obj.GroupVersionKind().Group,
+		Kind:      obj.GetKind(),
+		Namespace: obj.GetNamespace(),
+		Name:      obj.GetName(),
+	}
+}
+
+// RefID builds the node ID for a referenced object. Namespace should be the
+// referring object's namespace unless the reference names one explicitly.
+func RefID(group, kind, namespace, name string) string {
+	return ResourceKey{
+		Group:     group,
+		Kind:      kind,
+		Namespace: namespace,
+		Name:      name,
+	}.String()
+}
+
+// ParseResourceID splits a node ID produced by ResourceKey.String back into
+// its parts. IDs without a slash yield a key with only Name set.
+func ParseResourceID(id string) ResourceKey {
+	kindPart, rest, ok := strings.Cut(id, "/")
+	if !ok {
+		return ResourceKey{Name: id}
+	}
+	var key ResourceKey
+	key.Kind, key.Group, _ = strings.Cut(kindPart, ".")
+	if ns, name, hasNS := strings.Cut(rest, "/"); hasNS {
+		key.Namespace, key.Name = ns, name
+	} else {
+		key.Name = rest
+	}
+	return key
+}
+
+// IsBuiltinGroup reports whether group is empty or one of the groups the
+// built-in kind is normally served from.
+func IsBuiltinGroup(kind, group string) bool {
+	if group == "" {
+		return true
+	}
+	return builtinGroups[kind][group]
+}
+
+// IsClusterScoped reports whether the kind is a built-in cluster-scoped kind.
+func IsClusterScoped(kind, group string) bool {
+	return clusterScopedKinds[kind] && IsBuiltinGroup(kind, group)
+}
+
+// groupOf returns the API group from an apiVersion ("apps/v1" → "apps",
+// "v1" → "").
+func groupOf(apiVersion string) string {
+	gv, err := schema.ParseGroupVersion(apiVersion)
+	if err != nil {
+		return ""
+	}
+	return gv.Group
+}
@@ -0,0 +1,52 @@
+package dependency_test
+
+import (
+	"testing"
+
+	"github.com/HMetcalfeW/cartographer/pkg/dependency"
+	"github.com/stretchr/testify/assert"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+)
+
+// TestResourceKeyString covers group and namespace qualification rules.
+func TestResourceKeyString(t *testing.T) {
+	tests := []struct {
+		name     string
+		key      dependency.ResourceKey
+		expected string
+	}{
+		{"core kind without namespace", dependency.ResourceKey{Kind: "Secret", Name: "db"}, "Secret/db"},
+		{"core kind with namespace", dependency.ResourceKey{Kind: "Secret", Namespace: "prod", Name: "db"}, "Secret/prod/db"},
+		{"builtin group omitted", dependency.ResourceKey{Group: "apps", Kind: "Deployment", Namespace: "prod", Name: "web"}, "Deployment/prod/web"},
+		{"legacy builtin group omitted", dependency.ResourceKey{Group: "extensions", Kind: "Ingress", Name: "main"}, "Ingress/main"},
+		{"custom group kept", dependency.ResourceKey{Group: "serving.knative.dev", Kind: "Service", Namespace: "prod", Name: "hello"}, "Service.serving.knative.dev/prod/hello"},
+		{"cluster-scoped drops namespace", dependency.ResourceKey{Group: "rbac.authorization.k8s.io", Kind: "ClusterRole", Namespace: "prod", Name: "admin"}, "ClusterRole/admin"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.expected, tt.key.String())
+		})
+	}
+}
+
+// TestParseResourceID verifies IDs round-trip through ParseResourceID.
+func TestParseResourceID(t *testing.T) {
+	key := dependency.ParseResourceID("Service.serving.knative.dev/prod/hello")
+	assert.Equal(t, dependency.ResourceKey{Group: "serving.knative.dev", Kind: "Service", Namespace: "prod", Name: "hello"}, key)
+
+	key = dependency.ParseResourceID("ClusterRole/admin")
+	assert.Equal(t, dependency.ResourceKey{Kind: "ClusterRole", Name: "admin"}, key)
+
+	key = dependency.ParseResourceID("orphan")
+	assert.Equal(t, dependency.ResourceKey{Name: "orphan"}, key)
+}
+
+// TestResourceIDNamespaced ensures ResourceID includes namespace and group.
+func TestResourceIDNamespaced(t *testing.T) {
+	obj := &unstructured.Unstructured{}
+	obj.SetAPIVersion("serving.knative.dev/v1")
+	obj.SetKind("Service")
+	obj.SetNamespace("prod")
+	obj.SetName("hello")
+	assert.Equal(t, "Service.serving.knative.dev/prod/hello", dependency.ResourceID(obj))
+}
@@ -8,13 +8,23 @@ import (
 	log "github.com/sirupsen/logrus"
 )
 
-// RenderImage generates DOT from the dependency map and pipes it through
+// RenderImage generates DOT from the dependency graph and pipes it through
 // the GraphViz dot command to produce image output in the given format
 // ("png" or "svg").
 //
 // Returns the raw image bytes or an error if GraphViz is not installed
 // or the rendering fails.
-func RenderImage(deps map[string][]Edge, format string) ([]byte, error) {
+func RenderImage(g *Graph, format string) ([]byte, error) {
+	return renderDOT(GenerateDOT(g), format)
+}
+
+// RenderDiffImage is RenderImage for the output of GenerateDiffDOT.
+func RenderDiffImage(d *GraphDiff, format string) ([]byte, error) {
+	return renderDOT(GenerateDiffDOT(d), format)
+}
+
+// renderDOT pipes DOT source through the GraphViz dot command.
+func renderDOT(dotContent, format string) ([]byte, error) {
 	dotPath, err := exec.LookPath("dot")
 	if err != nil {
 		return nil, fmt.Errorf(
@@ -34,8 +44,6 @@ func RenderImage(deps map[string][]Edge, format string) ([]byte, error) {
 	})
 	logger.Debug("Invoking GraphViz")
 
-	dotContent := GenerateDOT(deps)
-
 	cmd := exec.Command(dotPath, "-T"+format)
 	cmd.Stdin = bytes.NewReader([]byte(dotContent))
 
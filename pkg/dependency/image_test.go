@@ -24,7 +24,7 @@ func TestRenderImage_PNG(t *testing.T) {
 			{ChildID: "Secret/db-pass", Reason: "secretRef"},
 		},
 	}
-	data, err := dependency.RenderImage(deps, "png")
+	data, err := dependency.RenderImage(graphFromEdges(deps), "png")
 	require.NoError(t, err)
 	assert.True(t, len(data) > 0, "PNG output should not be empty")
 	// PNG files start with the magic bytes: 0x89 0x50 0x4E 0x47
@@ -44,7 +44,7 @@ func TestRenderImage_SVG(t *testing.T) {
 			{ChildID: "Deployment/web", Reason: "selector"},
 		},
 	}
-	data, err := dependency.RenderImage(deps, "svg")
+	data, err := dependency.RenderImage(graphFromEdges(deps), "svg")
 	require.NoError(t, err)
 	svgStr := string(data)
 	assert.Contains(t, svgStr, "<svg")
@@ -56,7 +56,7 @@ func TestRenderImage_EmptyDeps(t *testing.T) {
 	if !graphvizAvailable() {
 		t.Skip("graphviz not installed, skipping image render test")
 	}
-	data, err := dependency.RenderImage(map[string][]dependency.Edge{}, "png")
+	data, err := dependency.RenderImage(graphFromEdges(map[string][]dependency.Edge{}), "png")
 	require.NoError(t, err)
 	assert.True(t, len(data) > 0, "even empty graph should produce valid PNG")
 }
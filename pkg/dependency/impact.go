@@ -0,0 +1,88 @@
+package dependency
+
+import (
+	"sort"
+
+	log "github.com/sirupsen/logrus"
+)
+
+// ImpactedResource is a resource that directly or transitively depends on
+// the target of an impact query.
+type ImpactedResource struct {
+	// ID is the dependent resource.
+	ID string
+
+	// Depth is the number of edges between the resource and the target;
+	// direct dependents have depth 1.
+	Depth int
+
+	// Chain is the shortest sequence of edges leading from the resource to
+	// the target. Chain[0].ParentID is ID and the last ChildID is the target.
+	Chain []Edge
+}
+
+// Reasons returns the edge reasons along the chain, from the resource to the
+// target.
+func (r ImpactedResource) Reasons() []string {
+	reasons := make([]string, len(r.Chain))
+	for i, e := range r.Chain {
+		reasons[i] = e.Reason
+	}
+	return reasons
+}
+
+// Impact walks the graph's reverse edges from target and returns every
+// resource that depends on it, breadth-first: sorted by depth, then ID.
+// maxDepth limits the walk (0 means unlimited). Each resource is reported
+// once, with the shortest chain to the target.
+func Impact(g *Graph, target string, maxDepth int) []ImpactedResource {
+	chains := map[string][]Edge{target: nil}
+	frontier := []string{target}
+	var impacted []ImpactedResource
+
+	for depth := 1; len(frontier) > 0 && (maxDepth == 0 || depth <= maxDepth); depth++ {
+		var next []string
+		for _, id := range frontier {
+			in := append([]Edge(nil), g.InEdges(id)...)
+			sort.SliceStable(in, func(i, j int) bool { return in[i].ParentID < in[j].ParentID })
+			for _, e := range in {
+				if _, seen := chains[e.ParentID]; seen {
+					continue
+				}
+				chain := append([]Edge{e}, chains[id]...)
+				chains[e.ParentID] = chain
+				next = append(next, e.ParentID)
+				impacted = append(impacted, ImpactedResource{ID: e.ParentID, Depth: depth, Chain: chain})
+			}
+		}
+		frontier = next
+	}
+
+	sort.SliceStable(impacted, func(i, j int) bool {
+		if impacted[i].Depth != impacted[j].Depth {
+			return impacted[i].Depth < impacted[j].Depth
+		}
+		return impacted[i].ID < impacted[j].ID
+	})
+
+	log.WithFields(log.Fields{
+		"func":     "Impact",
+		"target":   target,
+		"impacted": len(impacted),
+	}).Debug("Computed impact")
+
+	return impacted
+}
+
+// ImpactGraph returns the subgraph made of target and its impacted resources,
+// with target highlighted, ready for any of the exporters.
+func ImpactGraph(g *Graph, target string, impacted []ImpactedResource) *Graph {
+	ids := make([]string, 0, len(impacted)+1)
+	ids = append(ids, target)
+	for _, r := range impacted {
+		ids = append(ids, r.ID)
+	}
+	sub := g.Subgraph(ids)
+	sub.Highlight(target)
+	return sub
+}
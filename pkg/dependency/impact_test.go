@@ -0,0 +1,98 @@
+package dependency_test
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/HMetcalfeW/cartographer/pkg/dependency"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// impactGraph models an Ingress -> Service -> Deployment -> Secret chain, with
+// a CronJob also using the Secret and an unrelated ConfigMap.
+func impactGraph() *dependency.Graph {
+	return graphFromEdges(map[string][]dependency.Edge{
+		"Ingress/web":       {{ChildID: "Service/web", Reason: "ingressBackend"}},
+		"Service/web":       {{ChildID: "Deployment/web", Reason: "selector"}},
+		"Deployment/web":    {{ChildID: "Secret/db-creds", Reason: "secretRef"}, {ChildID: "ConfigMap/web", Reason: "configMapRef"}},
+		"CronJob/backup":    {{ChildID: "Secret/db-creds", Reason: "secretEnv"}},
+		"ConfigMap/unused":  nil,
+		"Deployment/worker": {{ChildID: "ConfigMap/web", Reason: "volume"}},
+	})
+}
+
+func TestImpact(t *testing.T) {
+	impacted := dependency.Impact(impactGraph(), "Secret/db-creds", 0)
+	require.Len(t, impacted, 4)
+
+	assert.Equal(t, "CronJob/backup", impacted[0].ID)
+	assert.Equal(t, 1, impacted[0].Depth)
+	assert.Equal(t, "Deployment/web", impacted[1].ID)
+	assert.Equal(t, 1, impacted[1].Depth)
+	assert.Equal(t, "Service/web", impacted[2].ID)
+	assert.Equal(t, 2, impacted[2].Depth)
+
+	ingress := impacted[3]
+	assert.Equal(t, "Ingress/web", ingress.ID)
+	assert.Equal(t, 3, ingress.Depth)
+	assert.Equal(t, []string{"ingressBackend", "selector", "secretRef"}, ingress.Reasons())
+	require.Len(t, ingress.Chain, 3)
+	assert.Equal(t, "Ingress/web", ingress.Chain[0].ParentID)
+	assert.Equal(t, "Secret/db-creds", ingress.Chain[2].ChildID)
+}
+
+func TestImpactMaxDepth(t *testing.T) {
+	impacted := dependency.Impact(impactGraph(), "Secret/db-creds", 1)
+	var ids []string
+	for _, r := range impacted {
+		ids = append(ids, r.ID)
+	}
+	assert.Equal(t, []string{"CronJob/backup", "Deployment/web"}, ids)
+}
+
+func TestImpactNoDependents(t *testing.T) {
+	assert.Empty(t, dependency.Impact(impactGraph(), "Ingress/web", 0))
+}
+
+// TestImpactCycle verifies a cycle is walked once without looping.
+func TestImpactCycle(t *testing.T) {
+	g := graphFromEdges(map[string][]dependency.Edge{
+		"ConfigMap/a": {{ChildID: "ConfigMap/b", Reason: "ref"}},
+		"ConfigMap/b": {{ChildID: "ConfigMap/a", Reason: "ref"}},
+	})
+	impacted := dependency.Impact(g, "ConfigMap/a", 0)
+	require.Len(t, impacted, 1)
+	assert.Equal(t, "ConfigMap/b", impacted[0].ID)
+}
+
+func TestImpactGraph(t *testing.T) {
+	g := impactGraph()
+	impacted := dependency.Impact(g, "Secret/db-creds", 0)
+	sub := dependency.ImpactGraph(g, "Secret/db-creds", impacted)
+
+	assert.Equal(t, 5, sub.Len())
+	assert.True(t, sub.IsHighlighted("Secret/db-creds"))
+	assert.False(t, sub.IsHighlighted("Deployment/web"))
+	_, ok := sub.Node("ConfigMap/web")
+	assert.False(t, ok, "resources the target does not affect are left out")
+	assert.Len(t, sub.AllEdges(), 4)
+
+	assert.Equal(t, 1, strings.Count(dependency.GenerateDOT(sub), `color="#1F3A93"`))
+	assert.Contains(t, dependency.GenerateMermaid(sub), "classDef highlight")
+}
+
+func TestGraphResolve(t *testing.T) {
+	g := graphFromEdges(map[string][]dependency.Edge{
+		"Deployment/prod/web":    {{ChildID: "Secret/prod/db", Reason: "secretRef"}},
+		"Deployment/staging/web": {{ChildID: "Secret/staging/db", Reason: "secretRef"}},
+		"Deployment/prod/api":    {{ChildID: "Certificate.cert-manager.io/prod/tls", Reason: "custom"}},
+	})
+
+	assert.Equal(t, []string{"Secret/prod/db"}, g.Resolve("Secret/prod/db"))
+	assert.Equal(t, []string{"Deployment/prod/api"}, g.Resolve("Deployment/api"))
+	assert.Equal(t, []string{"Secret/prod/db", "Secret/staging/db"}, g.Resolve("Secret/db"))
+	assert.Equal(t, []string{"Certificate.cert-manager.io/prod/tls"}, g.Resolve("Certificate.cert-manager.io/tls"))
+	assert.Empty(t, g.Resolve("Certificate/tls"), "a custom resource needs its group")
+	assert.Empty(t, g.Resolve("Secret/other"))
+}
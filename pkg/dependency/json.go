@@ -2,69 +2,83 @@ package dependency
 
 import (
 	"encoding/json"
-	"sort"
 
 	log "github.com/sirupsen/logrus"
+
+	"github.com/HMetcalfeW/cartographer/pkg/parser"
 )
 
-// JSONGraph is the top-level structure emitted by GenerateJSON.
+// JSONGraph is the top-level structure emitted by GenerateJSON. Cycles lists
+// the node IDs of each dependency cycle (see Cycles) and is omitted when the
+// graph is acyclic.
 type JSONGraph struct {
-	Nodes []JSONNode `json:"nodes"`
-	Edges []JSONEdge `json:"edges"`
+	Nodes  []JSONNode `json:"nodes"`
+	Edges  []JSONEdge `json:"edges"`
+	Cycles [][]string `json:"cycles,omitempty"`
 }
 
-// JSONNode represents a single Kubernetes resource in the graph.
+// JSONNode represents a single Kubernetes resource in the graph. Group is the
+// display category; the remaining fields describe the resource itself and are
+// omitted when unknown (e.g. for nodes only seen as a reference target).
+// Missing is set for reference targets that are not in the input, Source
+// for resources read from a file or chart, and Hook for tagged Helm hooks.
 type JSONNode struct {
-	ID    string `json:"id"`
-	Group string `json:"group"`
+	ID          string            `json:"id"`
+	Group       string            `json:"group"`
+	APIVersion  string            `json:"apiVersion,omitempty"`
+	Kind        string            `json:"kind,omitempty"`
+	Namespace   string            `json:"namespace,omitempty"`
+	Name        string            `json:"name,omitempty"`
+	Labels      map[string]string `json:"labels,omitempty"`
+	Annotations map[string]string `json:"annotations,omitempty"`
+	Missing     bool              `json:"missing,omitempty"`
+	Source      *parser.Source    `json:"source,omitempty"`
+	Hook        *Hook             `json:"hook,omitempty"`
 }
 
-// JSONEdge represents a directed dependency between two resources.
+// JSONEdge represents a directed dependency between two resources. Field is
+// the path of the field in "from" that produced the edge, when known.
 type JSONEdge struct {
 	From   string `json:"from"`
 	To     string `json:"to"`
 	Reason string `json:"reason"`
+	Field  string `json:"field,omitempty"`
+
+	// Optional is set for references marked optional: true.
+	Optional bool `json:"optional,omitempty"`
 }
 
-// GenerateJSON produces an indented JSON string from the dependency map.
+// GenerateJSON produces an indented JSON string from the dependency graph.
 // The output is a graph object with separate "nodes" and "edges" arrays,
 // suitable for consumption by jq, custom visualizers, or CI pipelines.
-func GenerateJSON(deps map[string][]Edge) string {
-	nodeSet := make(map[string]struct{})
-	var edges []JSONEdge
-
-	// Sort parent keys for deterministic output.
-	parents := make([]string, 0, len(deps))
-	for p := range deps {
-		parents = append(parents, p)
-	}
-	sort.Strings(parents)
-
-	for _, parent := range parents {
-		nodeSet[parent] = struct{}{}
-		for _, edge := range deps[parent] {
-			nodeSet[edge.ChildID] = struct{}{}
-			edges = append(edges, JSONEdge{
-				From:   parent,
-				To:     edge.ChildID,
-				Reason: edge.Reason,
-			})
+func GenerateJSON(g *Graph) string {
+	graphNodes := g.Nodes()
+	nodes := make([]JSONNode, len(graphNodes))
+	for i, n := range graphNodes {
+		nodes[i] = JSONNode{
+			ID:          n.ID,
+			Group:       CategoryForNode(n.ID),
+			APIVersion:  n.APIVersion(),
+			Kind:        n.Kind,
+			Namespace:   n.Namespace,
+			Name:        n.Name,
+			Labels:      n.Labels,
+			Annotations: n.Annotations,
+			Missing:     n.Missing(),
+			Hook:        n.Hook,
+		}
+		if !n.Source.IsZero() {
+			source := n.Source
+			nodes[i].Source = &source
 		}
 	}
 
-	// Build sorted node list.
-	nodeIDs := make([]string, 0, len(nodeSet))
-	for id := range nodeSet {
-		nodeIDs = append(nodeIDs, id)
-	}
-	sort.Strings(nodeIDs)
-
-	nodes := make([]JSONNode, len(nodeIDs))
-	for i, id := range nodeIDs {
-		nodes[i] = JSONNode{ID: id, Group: CategoryForNode(id)}
+	var edges []JSONEdge
+	for _, e := range g.AllEdges() {
+		edges = append(edges, JSONEdge{From: e.ParentID, To: e.ChildID, Reason: e.Reason, Field: e.Field, Optional: e.Optional})
 	}
 
-	graph := JSONGraph{Nodes: nodes, Edges: edges}
+	graph := JSONGraph{Nodes: nodes, Edges: edges, Cycles: Cycles(g)}
 	data, _ := json.MarshalIndent(graph, "", "  ")
 
 	log.WithFields(log.Fields{
@@ -2,9 +2,11 @@ package dependency_test
 
 import (
 	"encoding/json"
+	"strings"
 	"testing"
 
 	"github.com/HMetcalfeW/cartographer/pkg/dependency"
+	"github.com/HMetcalfeW/cartographer/pkg/parser"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
 )
@@ -17,7 +19,7 @@ func TestGenerateJSON(t *testing.T) {
 			{ChildID: "ServiceAccount/my-sa", Reason: "serviceAccountName"},
 		},
 	}
-	jsonStr := dependency.GenerateJSON(deps)
+	jsonStr := dependency.GenerateJSON(graphFromEdges(deps))
 	t.Log(jsonStr)
 	assert.Contains(t, jsonStr, `"secretRef"`)
 	assert.Contains(t, jsonStr, `"serviceAccountName"`)
@@ -26,7 +28,7 @@ func TestGenerateJSON(t *testing.T) {
 
 // TestGenerateJSON_EmptyDeps verifies JSON output for an empty dependency map.
 func TestGenerateJSON_EmptyDeps(t *testing.T) {
-	jsonStr := dependency.GenerateJSON(map[string][]dependency.Edge{})
+	jsonStr := dependency.GenerateJSON(graphFromEdges(map[string][]dependency.Edge{}))
 	assert.Contains(t, jsonStr, `"nodes"`)
 	assert.Contains(t, jsonStr, `"edges"`)
 }
@@ -38,7 +40,7 @@ func TestGenerateJSON_StructureValid(t *testing.T) {
 			{ChildID: "Deployment/web", Reason: "selector"},
 		},
 	}
-	jsonStr := dependency.GenerateJSON(deps)
+	jsonStr := dependency.GenerateJSON(graphFromEdges(deps))
 
 	var graph dependency.JSONGraph
 	err := json.Unmarshal([]byte(jsonStr), &graph)
@@ -55,8 +57,8 @@ func TestGenerateJSON_DeterministicOrder(t *testing.T) {
 		"Service/web":    {{ChildID: "Deployment/web", Reason: "selector"}},
 		"Deployment/web": {{ChildID: "Secret/db-pass", Reason: "secretRef"}},
 	}
-	first := dependency.GenerateJSON(deps)
-	second := dependency.GenerateJSON(deps)
+	first := dependency.GenerateJSON(graphFromEdges(deps))
+	second := dependency.GenerateJSON(graphFromEdges(deps))
 	assert.Equal(t, first, second, "JSON output should be deterministic")
 }
 
@@ -73,7 +75,7 @@ func TestGenerateJSON_GroupField(t *testing.T) {
 			{ChildID: "Role/reader", Reason: "roleRef"},
 		},
 	}
-	jsonStr := dependency.GenerateJSON(deps)
+	jsonStr := dependency.GenerateJSON(graphFromEdges(deps))
 
 	var graph dependency.JSONGraph
 	err := json.Unmarshal([]byte(jsonStr), &graph)
@@ -90,3 +92,65 @@ func TestGenerateJSON_GroupField(t *testing.T) {
 	assert.Equal(t, "rbac", groupByID["RoleBinding/bind"])
 	assert.Equal(t, "rbac", groupByID["Role/reader"])
 }
+
+// TestGenerateJSON_MissingField verifies only dangling-reference targets are
+// flagged with "missing": true.
+func TestGenerateJSON_MissingField(t *testing.T) {
+	g := graphFromEdges(map[string][]dependency.Edge{
+		"Deployment/web": {{ChildID: "ConfigMap/cfg", Reason: "configMapRef"}},
+	})
+	g.AddEdge(dependency.Edge{ParentID: "Deployment/web", ChildID: "Secret/gone", Reason: "secretRef"})
+
+	var graph dependency.JSONGraph
+	require.NoError(t, json.Unmarshal([]byte(dependency.GenerateJSON(g)), &graph))
+
+	missingByID := make(map[string]bool)
+	for _, node := range graph.Nodes {
+		missingByID[node.ID] = node.Missing
+	}
+	assert.True(t, missingByID["Secret/gone"])
+	assert.False(t, missingByID["ConfigMap/cfg"])
+	assert.False(t, missingByID["Deployment/web"])
+	assert.Contains(t, dependency.GenerateJSON(g), `"missing": true`)
+}
+
+// TestGenerateJSON_EdgeField verifies edges carry their source field path
+// and that the property is omitted when unknown.
+func TestGenerateJSON_EdgeField(t *testing.T) {
+	g := graphFromEdges(map[string][]dependency.Edge{
+		"Deployment/web": {
+			{ChildID: "Secret/db", Reason: "secretEnv", Field: "spec.template.spec.containers[0].env[1].valueFrom.secretKeyRef"},
+			{ChildID: "ConfigMap/cfg", Reason: "configMapRef"},
+		},
+	})
+
+	var graph dependency.JSONGraph
+	require.NoError(t, json.Unmarshal([]byte(dependency.GenerateJSON(g)), &graph))
+
+	fields := make(map[string]string)
+	for _, e := range graph.Edges {
+		fields[e.To] = e.Field
+	}
+	assert.Equal(t, "spec.template.spec.containers[0].env[1].valueFrom.secretKeyRef", fields["Secret/db"])
+	assert.Empty(t, fields["ConfigMap/cfg"])
+	assert.Equal(t, 1, strings.Count(dependency.GenerateJSON(g), `"field"`))
+}
+
+// TestGenerateJSON_NodeSource verifies nodes carry their source location and
+// omit it when unknown.
+func TestGenerateJSON_NodeSource(t *testing.T) {
+	g := dependency.NewGraph()
+	web := objectForID("Deployment/web")
+	g.AddObject(web)
+	g.SetOrigins(dependency.Origins{web: {Source: parser.Source{File: "k8s/web.yaml", Line: 12}}})
+	g.AddObject(objectForID("Secret/db"))
+
+	var graph dependency.JSONGraph
+	require.NoError(t, json.Unmarshal([]byte(dependency.GenerateJSON(g)), &graph))
+	require.Len(t, graph.Nodes, 2)
+	assert.Equal(t, "Deployment/web", graph.Nodes[0].ID)
+	require.NotNil(t, graph.Nodes[0].Source)
+	assert.Equal(t, parser.Source{File: "k8s/web.yaml", Line: 12}, *graph.Nodes[0].Source)
+	assert.Nil(t, graph.Nodes[1].Source)
+	assert.Empty(t, graph.Nodes[0].Annotations)
+}
@@ -1,44 +1,83 @@
 package dependency
 
 import (
+	"slices"
+	"sort"
+
 	log "github.com/sirupsen/logrus"
 	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
 )
 
-// LabelIndex maps "key=value" strings to the set of pod/controller objects
-// carrying that label. Built once in BuildDependencies and used by
-// selector-based handlers for O(n) lookups instead of O(n²) scans.
-type LabelIndex map[string][]*unstructured.Unstructured
+// LabelIndex indexes pod/controller objects by namespace and label. Built once
+// in BuildDependencies and used by selector-based handlers for O(n) lookups
+// instead of O(n²) scans. Selectors never match across namespaces. A
+// LabelIndex is read-only after construction and safe for concurrent use.
+type LabelIndex struct {
+	// byLabel maps "namespace|key=value" to the objects carrying that label
+	// in that namespace.
+	byLabel map[string][]*unstructured.Unstructured
+
+	// byNamespace lists the indexed objects of each namespace in input
+	// order, the candidates for selectors without matchLabels.
+	byNamespace map[string][]*unstructured.Unstructured
+
+	// entries caches each indexed object's labels and input position;
+	// GetLabels copies the map on every call, which dominated selector
+	// evaluation on large inputs.
+	entries map[*unstructured.Unstructured]indexEntry
+}
+
+// indexEntry is the cached state of one indexed object.
+type indexEntry struct {
+	labels map[string]string
+	order  int
+}
+
+// labelIndexKey builds the index key for a label within a namespace. Neither
+// namespaces nor label keys may contain "|", so the key is unambiguous.
+func labelIndexKey(namespace, key, value string) string {
+	return namespace + "|" + key + "=" + value
+}
 
 // BuildLabelIndex creates a LabelIndex from a slice of objects, indexing only
-// Pods and controller types (Deployment, DaemonSet, etc.).
-func BuildLabelIndex(objs []*unstructured.Unstructured) LabelIndex {
-	idx := make(LabelIndex)
+// Pods and controller types (Deployment, DaemonSet, etc.), i.e. the kinds
+// with a Pod spec path in reg.
+func BuildLabelIndex(objs []*unstructured.Unstructured, reg *Registry) LabelIndex {
+	idx := LabelIndex{
+		byLabel:     make(map[string][]*unstructured.Unstructured),
+		byNamespace: make(map[string][]*unstructured.Unstructured),
+		entries:     make(map[*unstructured.Unstructured]indexEntry),
+	}
 	indexed := 0
 	for _, obj := range objs {
-		if !IsPodOrController(obj) {
+		if !reg.IsPodOrController(obj) {
 			continue
 		}
 		indexed++
-		for k, v := range obj.GetLabels() {
-			key := k + "=" + v
-			idx[key] = append(idx[key], obj)
+		ns := obj.GetNamespace()
+		labels := obj.GetLabels()
+		idx.entries[obj] = indexEntry{labels: labels, order: indexed}
+		idx.byNamespace[ns] = append(idx.byNamespace[ns], obj)
+		for k, v := range labels {
+			key := labelIndexKey(ns, k, v)
+			idx.byLabel[key] = append(idx.byLabel[key], obj)
 		}
 	}
 
 	log.WithFields(log.Fields{
 		"func":            "BuildLabelIndex",
 		"indexed_objects": indexed,
-		"label_keys":      len(idx),
+		"label_keys":      len(idx.byLabel),
 	}).Debug("Built label index")
 
 	return idx
 }
 
-// Match returns all pod/controller objects whose labels satisfy every key-value
-// pair in the selector. For a single-label selector this is a direct lookup;
-// for multi-label selectors it intersects the per-label sets.
-func (idx LabelIndex) Match(selector map[string]string) []*unstructured.Unstructured {
+// Match returns all pod/controller objects in the namespace whose labels
+// satisfy every key-value pair in the selector, in input order. For a
+// single-label selector this is a direct lookup; for multi-label selectors it
+// filters the smallest per-label set.
+func (idx LabelIndex) Match(namespace string, selector map[string]string) []*unstructured.Unstructured {
 	if len(selector) == 0 {
 		return nil
 	}
@@ -47,8 +86,7 @@ func (idx LabelIndex) Match(selector map[string]string) []*unstructured.Unstruct
 	var smallest []*unstructured.Unstructured
 	first := true
 	for k, v := range selector {
-		key := k + "=" + v
-		candidates := idx[key]
+		candidates := idx.byLabel[labelIndexKey(namespace, k, v)]
 		if len(candidates) == 0 {
 			return nil // no objects have this label — empty intersection
 		}
@@ -66,41 +104,34 @@ func (idx LabelIndex) Match(selector map[string]string) []*unstructured.Unstruct
 	// Multi-label: filter the smallest set to those matching all labels.
 	var result []*unstructured.Unstructured
 	for _, obj := range smallest {
-		if LabelsMatch(selector, obj.GetLabels()) {
+		if LabelsMatch(selector, idx.entries[obj].labels) {
 			result = append(result, obj)
 		}
 	}
 	return result
 }
 
-// MatchSelector returns all pod/controller objects whose labels satisfy both
-// the matchLabels map AND every matchExpressions requirement. If matchLabels
-// is non-empty it narrows candidates via the index first; if only expressions
-// are provided it scans all indexed objects.
-func (idx LabelIndex) MatchSelector(matchLabels map[string]string, exprs []LabelSelectorRequirement) []*unstructured.Unstructured {
+// MatchSelector returns all pod/controller objects in the namespace whose
+// labels satisfy both the matchLabels map AND every matchExpressions
+// requirement, in input order. If matchLabels is non-empty it narrows
+// candidates via the index first; otherwise an "In" expression narrows them
+// to the objects carrying one of its values, and only selectors without
+// either check every indexed object in the namespace.
+func (idx LabelIndex) MatchSelector(namespace string, matchLabels map[string]string, exprs []LabelSelectorRequirement) []*unstructured.Unstructured {
 	if len(matchLabels) == 0 && len(exprs) == 0 {
 		return nil
 	}
 
 	var candidates []*unstructured.Unstructured
-
 	if len(matchLabels) > 0 {
-		candidates = idx.Match(matchLabels)
-		if len(candidates) == 0 {
-			return nil
-		}
+		candidates = idx.Match(namespace, matchLabels)
+	} else if in, ok := idx.matchIn(namespace, exprs); ok {
+		candidates = in
 	} else {
-		// No matchLabels — collect all unique indexed objects as candidates.
-		seen := make(map[string]struct{})
-		for _, objs := range idx {
-			for _, obj := range objs {
-				id := ResourceID(obj)
-				if _, exists := seen[id]; !exists {
-					seen[id] = struct{}{}
-					candidates = append(candidates, obj)
-				}
-			}
-		}
+		candidates = idx.byNamespace[namespace]
+	}
+	if len(candidates) == 0 {
+		return nil
 	}
 
 	if len(exprs) == 0 {
@@ -109,13 +140,39 @@ func (idx LabelIndex) MatchSelector(matchLabels map[string]string, exprs []Label
 
 	var result []*unstructured.Unstructured
 	for _, obj := range candidates {
-		if MatchesExpressions(exprs, obj.GetLabels()) {
+		if MatchesExpressions(exprs, idx.entries[obj].labels) {
 			result = append(result, obj)
 		}
 	}
 	return result
 }
 
+// matchIn returns the objects in the namespace satisfying the "In" expression
+// with the fewest such objects, in input order, or false if there is no "In"
+// expression. An object has one value per key, so the per-value sets are
+// disjoint and only need sorting back into input order.
+func (idx LabelIndex) matchIn(namespace string, exprs []LabelSelectorRequirement) ([]*unstructured.Unstructured, bool) {
+	var best []*unstructured.Unstructured
+	found := false
+	for _, expr := range exprs {
+		if expr.Operator != "In" {
+			continue
+		}
+		var objs []*unstructured.Unstructured
+		for i, v := range expr.Values {
+			if slices.Contains(expr.Values[:i], v) {
+				continue
+			}
+			objs = append(objs, idx.byLabel[labelIndexKey(namespace, expr.Key, v)]...)
+		}
+		if !found || len(objs) < len(best) {
+			best, found = objs, true
+		}
+	}
+	sort.Slice(best, func(i, j int) bool { return idx.entries[best[i]].order < idx.entries[best[j]].order })
+	return best, found
+}
+
 // MatchesExpressions returns true if the given labels satisfy every requirement.
 // An empty expression list is vacuously true. All expressions are ANDed together
 // per the Kubernetes LabelSelector spec.
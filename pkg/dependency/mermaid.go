@@ -2,87 +2,89 @@ package dependency
 
 import (
 	"fmt"
-	"sort"
 	"strings"
 
 	log "github.com/sirupsen/logrus"
 )
 
-// sanitizeMermaidID replaces characters that are invalid in Mermaid node
-// identifiers (/, -, .) with underscores.
+// sanitizeMermaidID turns a node ID into a valid Mermaid node identifier.
+// Letters and digits are kept; every other byte (including "_") is escaped as
+// "_" followed by two hex digits, so distinct IDs such as "Deployment/a-b/c"
+// and "Deployment/a/b-c" never share an identifier. The real ID is always
+// written as the node label.
 func sanitizeMermaidID(id string) string {
-	r := strings.NewReplacer("/", "_", "-", "_", ".", "_")
-	return r.Replace(id)
+	var sb strings.Builder
+	for i := 0; i < len(id); i++ {
+		c := id[i]
+		if ('a' <= c && c <= 'z') || ('A' <= c && c <= 'Z') || ('0' <= c && c <= '9') {
+			sb.WriteByte(c)
+			continue
+		}
+		fmt.Fprintf(&sb, "_%02x", c)
+	}
+	return sb.String()
 }
 
 // GenerateMermaid produces a Mermaid flowchart (left-to-right) with resources
 // grouped into subgraphs by category and color-coded via classDef directives.
-// Only nodes that participate in at least one edge are emitted.
-// Node declarations go inside subgraphs; edges are emitted outside so Mermaid
-// can route them across subgraph boundaries.
-func GenerateMermaid(deps map[string][]Edge) string {
+// Only nodes that participate in at least one edge (or are highlighted) are
+// emitted. Missing (dangling-reference) nodes also get a dashed red "missing"
+// class, Helm hooks a dotted grey "hook" class, highlighted nodes a
+// thick-bordered "highlight" class, and highlighted edges a linkStyle in the
+// same color. When the graph groups by chart (see Graph.GroupByChart), nodes
+// rendered from a Helm chart or subchart are declared inside a subgraph titled
+// with the chart path; edges are emitted outside so Mermaid can route them
+// across subgraph boundaries.
+func GenerateMermaid(g *Graph) string {
 	var sb strings.Builder
 	sb.WriteString("graph LR\n")
 
-	// Collect only nodes that participate in edges.
-	connected := make(map[string]struct{})
-	for parent, edges := range deps {
-		if len(edges) > 0 {
-			connected[parent] = struct{}{}
-		}
-		for _, e := range edges {
-			connected[e.ChildID] = struct{}{}
-		}
-	}
+	// Only nodes that participate in edges, already sorted by ID.
+	connected := g.connectedIDs()
 
 	// Group connected nodes by category.
 	groups := make(map[string][]string)
-	for id := range connected {
+	for _, id := range connected {
 		cat := CategoryForNode(id)
 		groups[cat] = append(groups[cat], id)
 	}
-	for cat := range groups {
-		sort.Strings(groups[cat])
-	}
 
-	// Emit node declarations (no subgraph clusters — color-coding via classDef
-	// provides visual grouping without constraining Mermaid's layout engine).
-	nodeIDs := make([]string, 0, len(connected))
-	for id := range connected {
-		nodeIDs = append(nodeIDs, id)
+	// Emit node declarations (no category subgraphs — color-coding via
+	// classDef provides visual grouping without constraining Mermaid's layout
+	// engine). Chart subgraphs are only drawn when asked for.
+	charts, chartOf := g.chartGroups(connected)
+	for _, node := range connected {
+		if _, ok := chartOf[node]; !ok {
+			sb.WriteString(fmt.Sprintf("    %s[\"%s\"]\n", sanitizeMermaidID(node), node))
+		}
 	}
-	sort.Strings(nodeIDs)
-
-	for _, node := range nodeIDs {
-		sb.WriteString(fmt.Sprintf("    %s[\"%s\"]\n", sanitizeMermaidID(node), node))
+	for _, chart := range charts {
+		sb.WriteString(fmt.Sprintf("    subgraph chart_%s[\"%s\"]\n", sanitizeMermaidID(chart), chart))
+		for _, node := range connected {
+			if chartOf[node] == chart {
+				sb.WriteString(fmt.Sprintf("        %s[\"%s\"]\n", sanitizeMermaidID(node), node))
+			}
+		}
+		sb.WriteString("    end\n")
 	}
 
 	// Sorted edges.
-	parents := make([]string, 0, len(deps))
-	for p := range deps {
-		parents = append(parents, p)
-	}
-	sort.Strings(parents)
-
-	edgeCount := 0
-	for _, parent := range parents {
-		for _, edge := range deps[parent] {
-			parentID := sanitizeMermaidID(parent)
-			childID := sanitizeMermaidID(edge.ChildID)
-			sb.WriteString(fmt.Sprintf("    %s --> |%s| %s\n", parentID, edge.Reason, childID))
-			edgeCount++
-		}
+	edges := g.AllEdges()
+	for _, edge := range edges {
+		parentID := sanitizeMermaidID(edge.ParentID)
+		childID := sanitizeMermaidID(edge.ChildID)
+		sb.WriteString(fmt.Sprintf("    %s --> |%s| %s\n", parentID, edge.Reason, childID))
 	}
 
 	log.WithFields(log.Fields{
 		"func":  "GenerateMermaid",
 		"nodes": len(connected),
-		"edges": edgeCount,
+		"edges": len(edges),
 	}).Debug("Generated Mermaid graph")
 
 	// classDef directives for category colors.
 	activeCats := make(map[string]bool)
-	for id := range connected {
+	for _, id := range connected {
 		activeCats[CategoryForNode(id)] = true
 	}
 	sb.WriteString("\n")
@@ -107,5 +109,50 @@ func GenerateMermaid(deps map[string][]Edge) string {
 		sb.WriteString(fmt.Sprintf("    class %s %s\n", strings.Join(ids, ","), catKey))
 	}
 
+	// Outline missing nodes on top of their category color.
+	var missing []string
+	for _, id := range connected {
+		if n, _ := g.Node(id); n.Missing() {
+			missing = append(missing, sanitizeMermaidID(id))
+		}
+	}
+	if len(missing) > 0 {
+		sb.WriteString(fmt.Sprintf("    classDef missing stroke:%s,stroke-width:2px,stroke-dasharray:5 5\n", missingColor))
+		sb.WriteString(fmt.Sprintf("    class %s missing\n", strings.Join(missing, ",")))
+	}
+
+	var hooks []string
+	for _, id := range connected {
+		if n, _ := g.Node(id); n.Hook != nil {
+			hooks = append(hooks, sanitizeMermaidID(id))
+		}
+	}
+	if len(hooks) > 0 {
+		sb.WriteString(fmt.Sprintf("    classDef hook stroke:%s,stroke-width:2px,stroke-dasharray:2 2\n", hookColor))
+		sb.WriteString(fmt.Sprintf("    class %s hook\n", strings.Join(hooks, ",")))
+	}
+
+	var highlighted []string
+	for _, id := range connected {
+		if g.IsHighlighted(id) {
+			highlighted = append(highlighted, sanitizeMermaidID(id))
+		}
+	}
+	if len(highlighted) > 0 {
+		sb.WriteString(fmt.Sprintf("    classDef highlight stroke:%s,stroke-width:4px,font-weight:bold\n", highlightColor))
+		sb.WriteString(fmt.Sprintf("    class %s highlight\n", strings.Join(highlighted, ",")))
+	}
+
+	// linkStyle addresses edges by their position in the output.
+	var highlightedEdges []string
+	for i, edge := range edges {
+		if g.IsEdgeHighlighted(edge) {
+			highlightedEdges = append(highlightedEdges, fmt.Sprint(i))
+		}
+	}
+	if len(highlightedEdges) > 0 {
+		sb.WriteString(fmt.Sprintf("    linkStyle %s stroke:%s,stroke-width:4px\n", strings.Join(highlightedEdges, ","), highlightColor))
+	}
+
 	return sb.String()
 }
@@ -1,6 +1,7 @@
 package dependency_test
 
 import (
+	"strings"
 	"testing"
 
 	"github.com/HMetcalfeW/cartographer/pkg/dependency"
@@ -15,7 +16,7 @@ func TestGenerateMermaid(t *testing.T) {
 			{ChildID: "ServiceAccount/my-sa", Reason: "serviceAccountName"},
 		},
 	}
-	mermaid := dependency.GenerateMermaid(deps)
+	mermaid := dependency.GenerateMermaid(graphFromEdges(deps))
 	t.Log(mermaid)
 	assert.Contains(t, mermaid, "|secretRef|")
 	assert.Contains(t, mermaid, "|serviceAccountName|")
@@ -25,7 +26,7 @@ func TestGenerateMermaid(t *testing.T) {
 
 // TestGenerateMermaid_EmptyDeps verifies Mermaid output for an empty dependency map.
 func TestGenerateMermaid_EmptyDeps(t *testing.T) {
-	mermaid := dependency.GenerateMermaid(map[string][]dependency.Edge{})
+	mermaid := dependency.GenerateMermaid(graphFromEdges(map[string][]dependency.Edge{}))
 	assert.Contains(t, mermaid, "graph LR")
 	assert.NotContains(t, mermaid, "-->")
 }
@@ -37,27 +38,43 @@ func TestGenerateMermaid_StructureValid(t *testing.T) {
 			{ChildID: "Deployment/web", Reason: "selector"},
 		},
 	}
-	mermaid := dependency.GenerateMermaid(deps)
+	mermaid := dependency.GenerateMermaid(graphFromEdges(deps))
 	assert.True(t, len(mermaid) > 0)
 	assert.Contains(t, mermaid, "graph LR")
 	// Sanitized IDs should not contain slashes
-	assert.Contains(t, mermaid, "Service_web")
-	assert.Contains(t, mermaid, "Deployment_web")
+	assert.Contains(t, mermaid, "Service_2fweb")
+	assert.Contains(t, mermaid, "Deployment_2fweb")
 	// Original labels should appear in quotes
 	assert.Contains(t, mermaid, `"Service/web"`)
 	assert.Contains(t, mermaid, `"Deployment/web"`)
 }
 
-// TestGenerateMermaid_SanitizedIDs verifies that slashes and hyphens are replaced.
+// TestGenerateMermaid_SanitizedIDs verifies that slashes, hyphens and dots are
+// escaped.
 func TestGenerateMermaid_SanitizedIDs(t *testing.T) {
 	deps := map[string][]dependency.Edge{
 		"Deployment/my-app.v2": {
 			{ChildID: "Secret/db-pass", Reason: "secretRef"},
 		},
 	}
-	mermaid := dependency.GenerateMermaid(deps)
-	assert.Contains(t, mermaid, "Deployment_my_app_v2")
-	assert.Contains(t, mermaid, "Secret_db_pass")
+	mermaid := dependency.GenerateMermaid(graphFromEdges(deps))
+	assert.Contains(t, mermaid, "Deployment_2fmy_2dapp_2ev2")
+	assert.Contains(t, mermaid, "Secret_2fdb_2dpass")
+}
+
+// TestGenerateMermaid_DistinctIDs is a regression test: IDs that differ only
+// in where the namespace separator falls must not collapse into one Mermaid
+// node.
+func TestGenerateMermaid_DistinctIDs(t *testing.T) {
+	deps := map[string][]dependency.Edge{
+		"Deployment/a-b/c": {{ChildID: "Secret/a-b/db", Reason: "secretRef"}},
+		"Deployment/a/b-c": {{ChildID: "Secret/a/db", Reason: "secretRef"}},
+	}
+	mermaid := dependency.GenerateMermaid(graphFromEdges(deps))
+	assert.Contains(t, mermaid, `Deployment_2fa_2db_2fc["Deployment/a-b/c"]`)
+	assert.Contains(t, mermaid, `Deployment_2fa_2fb_2dc["Deployment/a/b-c"]`)
+	assert.Contains(t, mermaid, "Deployment_2fa_2db_2fc --> |secretRef| Secret_2fa_2db_2fdb")
+	assert.Contains(t, mermaid, "Deployment_2fa_2fb_2dc --> |secretRef| Secret_2fa_2fdb")
 }
 
 // TestGenerateMermaid_ColorCoded verifies classDef directives and class assignments.
@@ -73,22 +90,22 @@ func TestGenerateMermaid_ColorCoded(t *testing.T) {
 			{ChildID: "Role/reader", Reason: "roleRef"},
 		},
 	}
-	mermaid := dependency.GenerateMermaid(deps)
+	mermaid := dependency.GenerateMermaid(graphFromEdges(deps))
 
 	// No subgraph clusters (color-coded instead)
 	assert.NotContains(t, mermaid, "subgraph")
 
 	// Node declarations
-	assert.Contains(t, mermaid, `Deployment_web["Deployment/web"]`)
-	assert.Contains(t, mermaid, `Service_frontend["Service/frontend"]`)
-	assert.Contains(t, mermaid, `Secret_db_creds["Secret/db-creds"]`)
-	assert.Contains(t, mermaid, `RoleBinding_bind["RoleBinding/bind"]`)
-	assert.Contains(t, mermaid, `Role_reader["Role/reader"]`)
+	assert.Contains(t, mermaid, `Deployment_2fweb["Deployment/web"]`)
+	assert.Contains(t, mermaid, `Service_2ffrontend["Service/frontend"]`)
+	assert.Contains(t, mermaid, `Secret_2fdb_2dcreds["Secret/db-creds"]`)
+	assert.Contains(t, mermaid, `RoleBinding_2fbind["RoleBinding/bind"]`)
+	assert.Contains(t, mermaid, `Role_2freader["Role/reader"]`)
 
 	// Edges
-	assert.Contains(t, mermaid, "Deployment_web --> |secretRef| Secret_db_creds")
-	assert.Contains(t, mermaid, "Service_frontend --> |selector| Deployment_web")
-	assert.Contains(t, mermaid, "RoleBinding_bind --> |roleRef| Role_reader")
+	assert.Contains(t, mermaid, "Deployment_2fweb --> |secretRef| Secret_2fdb_2dcreds")
+	assert.Contains(t, mermaid, "Service_2ffrontend --> |selector| Deployment_2fweb")
+	assert.Contains(t, mermaid, "RoleBinding_2fbind --> |roleRef| Role_2freader")
 
 	// classDef directives with category colors
 	assert.Contains(t, mermaid, "classDef workloads fill:#DAEEF3,stroke:#333")
@@ -97,9 +114,9 @@ func TestGenerateMermaid_ColorCoded(t *testing.T) {
 	assert.Contains(t, mermaid, "classDef rbac fill:#E2D9F3,stroke:#333")
 
 	// class assignments
-	assert.Contains(t, mermaid, "class Deployment_web workloads")
-	assert.Contains(t, mermaid, "class Service_frontend networking")
-	assert.Contains(t, mermaid, "class Secret_db_creds config")
+	assert.Contains(t, mermaid, "class Deployment_2fweb workloads")
+	assert.Contains(t, mermaid, "class Service_2ffrontend networking")
+	assert.Contains(t, mermaid, "class Secret_2fdb_2dcreds config")
 
 	// Categories with no nodes should not have classDef
 	assert.NotContains(t, mermaid, "classDef autoscaling")
@@ -114,9 +131,9 @@ func TestGenerateMermaid_OrphansOmitted(t *testing.T) {
 			{ChildID: "Secret/db-pass", Reason: "secretRef"},
 		},
 	}
-	mermaid := dependency.GenerateMermaid(deps)
-	assert.Contains(t, mermaid, `Deployment_web["Deployment/web"]`)
-	assert.Contains(t, mermaid, `Secret_db_pass["Secret/db-pass"]`)
+	mermaid := dependency.GenerateMermaid(graphFromEdges(deps))
+	assert.Contains(t, mermaid, `Deployment_2fweb["Deployment/web"]`)
+	assert.Contains(t, mermaid, `Secret_2fdb_2dpass["Secret/db-pass"]`)
 	assert.NotContains(t, mermaid, "ConfigMap/standalone")
 }
 
@@ -126,7 +143,53 @@ func TestGenerateMermaid_DeterministicOrder(t *testing.T) {
 		"Service/web":    {{ChildID: "Deployment/web", Reason: "selector"}},
 		"Deployment/web": {{ChildID: "Secret/db-pass", Reason: "secretRef"}},
 	}
-	first := dependency.GenerateMermaid(deps)
-	second := dependency.GenerateMermaid(deps)
+	first := dependency.GenerateMermaid(graphFromEdges(deps))
+	second := dependency.GenerateMermaid(graphFromEdges(deps))
 	assert.Equal(t, first, second, "Mermaid output should be deterministic")
 }
+
+// TestGenerateMermaid_MissingNodes verifies dangling-reference targets get
+// the "missing" class and present resources do not.
+func TestGenerateMermaid_MissingNodes(t *testing.T) {
+	g := graphFromEdges(map[string][]dependency.Edge{
+		"Deployment/web": {{ChildID: "ConfigMap/cfg", Reason: "configMapRef"}},
+	})
+	g.AddEdge(dependency.Edge{ParentID: "Deployment/web", ChildID: "Secret/gone", Reason: "secretRef"})
+
+	mermaid := dependency.GenerateMermaid(g)
+	assert.Contains(t, mermaid, "classDef missing stroke:#D62728,stroke-width:2px,stroke-dasharray:5 5")
+	assert.Contains(t, mermaid, "class Secret_2fgone missing")
+	assert.NotContains(t, mermaid, "ConfigMap_2fcfg missing")
+
+	mermaid = dependency.GenerateMermaid(graphFromEdges(map[string][]dependency.Edge{
+		"Deployment/web": {{ChildID: "ConfigMap/cfg", Reason: "configMapRef"}},
+	}))
+	assert.NotContains(t, mermaid, "missing")
+}
+
+// TestGenerateMermaid_HookNodes verifies Helm hooks get the "hook" class.
+func TestGenerateMermaid_HookNodes(t *testing.T) {
+	g := graphFromEdges(map[string][]dependency.Edge{
+		"Job/migrate": {{ChildID: "Secret/db", Reason: "secretRef"}},
+	})
+	job := objectForID("Job/migrate")
+	g.AddObject(job)
+	g.SetOrigins(dependency.Origins{job: {Hook: &dependency.Hook{Events: []string{"pre-install"}, Weight: 1}}})
+
+	mermaid := dependency.GenerateMermaid(g)
+	assert.Contains(t, mermaid, "classDef hook stroke:#7F7F7F")
+	assert.Contains(t, mermaid, "class Job_2fmigrate hook")
+}
+
+// TestGenerateMermaid_ChartSubgraphs verifies nodes are declared inside a
+// subgraph per chart only when chart grouping is enabled.
+func TestGenerateMermaid_ChartSubgraphs(t *testing.T) {
+	g := chartGraph()
+	assert.NotContains(t, dependency.GenerateMermaid(g), "subgraph")
+
+	g.GroupByChart(true)
+	mermaid := dependency.GenerateMermaid(g)
+	assert.Contains(t, mermaid, "    Secret_2fdb[\"Secret/db\"]\n    subgraph chart_platform[\"platform\"]\n        Deployment_2fweb[\"Deployment/web\"]\n    end\n")
+	assert.Contains(t, mermaid, "    subgraph chart_platform_2fpostgresql[\"platform/postgresql\"]\n        StatefulSet_2fdb[\"StatefulSet/db\"]\n    end\n")
+	assert.Equal(t, 1, strings.Count(mermaid, "Deployment_2fweb[\""))
+}
@@ -6,79 +6,141 @@ import (
 	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
 )
 
-// GetPodSpec attempts to read .spec or .spec.template.spec for known controllers.
-// If successful, it returns (podSpec, found=true, err=nil). Otherwise, found will
-// be false or err will be non-nil, indicating an error or no pod spec.
+// GetPodSpec reads the embedded Pod spec of a pod-bearing object using the
+// path registered for its kind in the default registry (.spec for Pods,
+// .spec.template.spec for controllers, and so on). If successful, it returns
+// (podSpec, found=true, err=nil). Otherwise, found will be false or err will
+// be non-nil, indicating an error or no pod spec.
 func GetPodSpec(obj *unstructured.Unstructured) (map[string]interface{}, bool, error) {
-	switch obj.GetKind() {
-	case "Pod":
-		return unstructured.NestedMap(obj.Object, "spec")
-	case "Deployment", "DaemonSet", "StatefulSet", "ReplicaSet", "Job":
-		return unstructured.NestedMap(obj.Object, "spec", "template", "spec")
-	case "CronJob":
-		return unstructured.NestedMap(obj.Object, "spec", "jobTemplate", "spec", "template", "spec")
-	default:
+	path, ok := defaultRegistry.PodSpecPath(obj.GroupVersionKind().GroupKind())
+	if !ok {
 		return nil, false, fmt.Errorf("kind %s does not have a standard pod template", obj.GetKind())
 	}
+	return unstructured.NestedMap(obj.Object, path...)
+}
+
+// Reference is a resource referenced from a Pod spec, with the path of the
+// referring field relative to the Pod spec, e.g.
+// "containers[1].env[3].valueFrom.secretKeyRef".
+type Reference struct {
+	ID    string
+	Field string
+
+	// Optional is set when the referring field has optional: true, so the Pod
+	// starts even if the referenced resource does not exist.
+	Optional bool
 }
 
 // GatherPodSpecReferences scans a Pod spec (including volumes, env, envFrom,
 // serviceAccountName, and imagePullSecrets) and returns slices of references
-// for secrets, configmaps, PVCs, and service accounts.
+// for secrets, configmaps, PVCs, and service accounts. Pod spec references are
+// always namespace-local, so every ID is qualified with the given namespace.
 func GatherPodSpecReferences(
 	podSpec map[string]interface{},
-) (secretRefs, configMapRefs, pvcRefs, serviceAccounts []string) {
-	gatherVolumeRefs(podSpec, &secretRefs, &configMapRefs, &pvcRefs)
-	gatherServiceAccountRefs(podSpec, &serviceAccounts)
-	gatherImagePullSecretRefs(podSpec, &secretRefs)
-	gatherContainerEnvRefs(podSpec, &secretRefs, &configMapRefs)
+	namespace string,
+) (secretRefs, configMapRefs, pvcRefs, serviceAccounts []Reference) {
+	gatherVolumeRefs(podSpec, namespace, &secretRefs, &configMapRefs, &pvcRefs)
+	gatherServiceAccountRefs(podSpec, namespace, &serviceAccounts)
+	gatherImagePullSecretRefs(podSpec, namespace, &secretRefs)
+	gatherContainerEnvRefs(podSpec, namespace, &secretRefs, &configMapRefs)
 	return
 }
 
-// gatherVolumeRefs extracts secret, configMap, and PVC references from .spec.volumes.
-func gatherVolumeRefs(podSpec map[string]interface{}, secretRefs, configMapRefs, pvcRefs *[]string) {
+// volumeSecretRefs lists the volume types that name a Secret through a
+// LocalObjectReference, with the field holding the reference.
+var volumeSecretRefs = []struct{ volume, field string }{
+	{"cephfs", "secretRef"},
+	{"cinder", "secretRef"},
+	{"csi", "nodePublishSecretRef"},
+	{"flexVolume", "secretRef"},
+	{"iscsi", "secretRef"},
+	{"rbd", "secretRef"},
+	{"scaleIO", "secretRef"},
+	{"storageos", "secretRef"},
+}
+
+// gatherVolumeRefs extracts secret, configMap, and PVC references from
+// .spec.volumes, including projected volume sources and the volume plugins
+// that take their credentials from a Secret.
+func gatherVolumeRefs(podSpec map[string]interface{}, namespace string, secretRefs, configMapRefs, pvcRefs *[]Reference) {
 	volSlice, found, _ := unstructured.NestedSlice(podSpec, "volumes")
 	if !found {
 		return
 	}
-	for _, vol := range volSlice {
+	for i, vol := range volSlice {
 		volMap, ok := vol.(map[string]interface{})
 		if !ok {
 			continue
 		}
+		field := fmt.Sprintf("volumes[%d]", i)
 		if sObj, ok := volMap["secret"].(map[string]interface{}); ok {
 			if sName, ok := sObj["secretName"].(string); ok {
-				*secretRefs = append(*secretRefs, "Secret/"+sName)
+				*secretRefs = append(*secretRefs, Reference{ID: RefID("", "Secret", namespace, sName), Field: field + ".secret", Optional: isOptional(sObj)})
 			}
 		} else if cmObj, ok := volMap["configMap"].(map[string]interface{}); ok {
 			if cmName, ok := cmObj["name"].(string); ok {
-				*configMapRefs = append(*configMapRefs, "ConfigMap/"+cmName)
+				*configMapRefs = append(*configMapRefs, Reference{ID: RefID("", "ConfigMap", namespace, cmName), Field: field + ".configMap", Optional: isOptional(cmObj)})
 			}
 		} else if pvcObj, ok := volMap["persistentVolumeClaim"].(map[string]interface{}); ok {
 			if pvcName, ok := pvcObj["claimName"].(string); ok {
-				*pvcRefs = append(*pvcRefs, "PersistentVolumeClaim/"+pvcName)
+				*pvcRefs = append(*pvcRefs, Reference{ID: RefID("", "PersistentVolumeClaim", namespace, pvcName), Field: field + ".persistentVolumeClaim"})
+			}
+		} else if projected, ok := volMap["projected"].(map[string]interface{}); ok {
+			gatherProjectedRefs(projected, namespace, field+".projected", secretRefs, configMapRefs)
+		} else if azureFile, ok := volMap["azureFile"].(map[string]interface{}); ok {
+			if sName, ok := azureFile["secretName"].(string); ok && sName != "" {
+				*secretRefs = append(*secretRefs, Reference{ID: RefID("", "Secret", namespace, sName), Field: field + ".azureFile"})
+			}
+		} else {
+			for _, v := range volumeSecretRefs {
+				if ref, ok, _ := unstructured.NestedString(volMap, v.volume, v.field, "name"); ok && ref != "" {
+					*secretRefs = append(*secretRefs, Reference{ID: RefID("", "Secret", namespace, ref), Field: field + "." + v.volume + "." + v.field})
+				}
+			}
+		}
+	}
+}
+
+// gatherProjectedRefs extracts the secret and configMap sources of a
+// projected volume. field is the path of the projected volume itself.
+func gatherProjectedRefs(projected map[string]interface{}, namespace, field string, secretRefs, configMapRefs *[]Reference) {
+	sources, _ := projected["sources"].([]interface{})
+	for i, src := range sources {
+		srcMap, ok := src.(map[string]interface{})
+		if !ok {
+			continue
+		}
+		srcField := fmt.Sprintf("%s.sources[%d]", field, i)
+		if sObj, ok := srcMap["secret"].(map[string]interface{}); ok {
+			if name, ok := sObj["name"].(string); ok && name != "" {
+				*secretRefs = append(*secretRefs, Reference{ID: RefID("", "Secret", namespace, name), Field: srcField + ".secret", Optional: isOptional(sObj)})
+			}
+		}
+		if cmObj, ok := srcMap["configMap"].(map[string]interface{}); ok {
+			if name, ok := cmObj["name"].(string); ok && name != "" {
+				*configMapRefs = append(*configMapRefs, Reference{ID: RefID("", "ConfigMap", namespace, name), Field: srcField + ".configMap", Optional: isOptional(cmObj)})
 			}
 		}
 	}
 }
 
 // gatherServiceAccountRefs extracts .spec.serviceAccountName.
-func gatherServiceAccountRefs(podSpec map[string]interface{}, serviceAccounts *[]string) {
+func gatherServiceAccountRefs(podSpec map[string]interface{}, namespace string, serviceAccounts *[]Reference) {
 	if saName, found, _ := unstructured.NestedString(podSpec, "serviceAccountName"); found && saName != "" {
-		*serviceAccounts = append(*serviceAccounts, "ServiceAccount/"+saName)
+		*serviceAccounts = append(*serviceAccounts, Reference{ID: RefID("", "ServiceAccount", namespace, saName), Field: "serviceAccountName"})
 	}
 }
 
 // gatherImagePullSecretRefs extracts secret names from .spec.imagePullSecrets.
-func gatherImagePullSecretRefs(podSpec map[string]interface{}, secretRefs *[]string) {
+func gatherImagePullSecretRefs(podSpec map[string]interface{}, namespace string, secretRefs *[]Reference) {
 	ipsList, found, _ := unstructured.NestedSlice(podSpec, "imagePullSecrets")
 	if !found {
 		return
 	}
-	for _, ips := range ipsList {
+	for i, ips := range ipsList {
 		if ipsMap, ok := ips.(map[string]interface{}); ok {
 			if secretName, ok := ipsMap["name"].(string); ok && secretName != "" {
-				*secretRefs = append(*secretRefs, "Secret/"+secretName)
+				*secretRefs = append(*secretRefs, Reference{ID: RefID("", "Secret", namespace, secretName), Field: fmt.Sprintf("imagePullSecrets[%d]", i)})
 			}
 		}
 	}
@@ -86,30 +148,33 @@ func gatherImagePullSecretRefs(podSpec map[string]interface{}, secretRefs *[]str
 
 // gatherContainerEnvRefs extracts secret/configMap references from env and envFrom
 // across containers, initContainers, and ephemeralContainers.
-func gatherContainerEnvRefs(podSpec map[string]interface{}, secretRefs, configMapRefs *[]string) {
+func gatherContainerEnvRefs(podSpec map[string]interface{}, namespace string, secretRefs, configMapRefs *[]Reference) {
 	for _, cKey := range []string{"containers", "initContainers", "ephemeralContainers"} {
 		cList, found, _ := unstructured.NestedSlice(podSpec, cKey)
 		if !found {
 			continue
 		}
-		for _, cVal := range cList {
+		for i, cVal := range cList {
 			cMap, ok := cVal.(map[string]interface{})
 			if !ok {
 				continue
 			}
+			container := fmt.Sprintf("%s[%d]", cKey, i)
 			if envList, foundEnv, _ := unstructured.NestedSlice(cMap, "env"); foundEnv {
-				for _, envVal := range envList {
+				for j, envVal := range envList {
 					if envMap, ok := envVal.(map[string]interface{}); ok {
 						if valueFrom, ok := envMap["valueFrom"].(map[string]interface{}); ok {
-							ParseEnvValueFrom(valueFrom, secretRefs, configMapRefs)
+							field := fmt.Sprintf("%s.env[%d].valueFrom", container, j)
+							ParseEnvValueFrom(valueFrom, namespace, field, secretRefs, configMapRefs)
 						}
 					}
 				}
 			}
 			if envFromList, foundEF, _ := unstructured.NestedSlice(cMap, "envFrom"); foundEF {
-				for _, envFromVal := range envFromList {
+				for j, envFromVal := range envFromList {
 					if envFromMap, ok := envFromVal.(map[string]interface{}); ok {
-						ParseEnvFrom(envFromMap, secretRefs, configMapRefs)
+						field := fmt.Sprintf("%s.envFrom[%d]", container, j)
+						ParseEnvFrom(envFromMap, namespace, field, secretRefs, configMapRefs)
 					}
 				}
 			}
@@ -117,30 +182,55 @@ func gatherContainerEnvRefs(podSpec map[string]interface{}, secretRefs, configMa
 	}
 }
 
-// ParseEnvValueFrom examines env[].valueFrom for references to secrets/configmaps.
-func ParseEnvValueFrom(valueFrom map[string]interface{}, secretRefs, configMapRefs *[]string) {
+// ParseEnvValueFrom examines env[].valueFrom for references to secrets/configmaps
+// in the given namespace. field is the path of valueFrom itself and prefixes
+// each reference's Field. Key refs with optional: true are marked Optional.
+func ParseEnvValueFrom(valueFrom map[string]interface{}, namespace, field string, secretRefs, configMapRefs *[]Reference) {
 	if sRef, ok := valueFrom["secretKeyRef"].(map[string]interface{}); ok {
 		if name, ok := sRef["name"].(string); ok {
-			*secretRefs = append(*secretRefs, "Secret/"+name)
+			*secretRefs = append(*secretRefs, Reference{ID: RefID("", "Secret", namespace, name), Field: joinField(field, "secretKeyRef"), Optional: isOptional(sRef)})
 		}
 	}
 	if cmRef, ok := valueFrom["configMapKeyRef"].(map[string]interface{}); ok {
 		if name, ok := cmRef["name"].(string); ok {
-			*configMapRefs = append(*configMapRefs, "ConfigMap/"+name)
+			*configMapRefs = append(*configMapRefs, Reference{ID: RefID("", "ConfigMap", namespace, name), Field: joinField(field, "configMapKeyRef"), Optional: isOptional(cmRef)})
 		}
 	}
 }
 
-// ParseEnvFrom examines envFrom[].secretRef or envFrom[].configMapRef for references.
-func ParseEnvFrom(envFrom map[string]interface{}, secretRefs, configMapRefs *[]string) {
+// ParseEnvFrom examines envFrom[].secretRef or envFrom[].configMapRef for
+// references in the given namespace. field is the path of the envFrom entry
+// and prefixes each reference's Field. Refs with optional: true are marked
+// Optional.
+func ParseEnvFrom(envFrom map[string]interface{}, namespace, field string, secretRefs, configMapRefs *[]Reference) {
 	if sRef, ok := envFrom["secretRef"].(map[string]interface{}); ok {
 		if name, ok := sRef["name"].(string); ok {
-			*secretRefs = append(*secretRefs, "Secret/"+name)
+			*secretRefs = append(*secretRefs, Reference{ID: RefID("", "Secret", namespace, name), Field: joinField(field, "secretRef"), Optional: isOptional(sRef)})
 		}
 	}
 	if cmRef, ok := envFrom["configMapRef"].(map[string]interface{}); ok {
 		if name, ok := cmRef["name"].(string); ok {
-			*configMapRefs = append(*configMapRefs, "ConfigMap/"+name)
+			*configMapRefs = append(*configMapRefs, Reference{ID: RefID("", "ConfigMap", namespace, name), Field: joinField(field, "configMapRef"), Optional: isOptional(cmRef)})
 		}
 	}
 }
+
+// isOptional reports whether a secret or configMap reference (a volume
+// source, key ref or envFrom ref) has optional: true.
+func isOptional(ref map[string]interface{}) bool {
+	optional, _ := ref["optional"].(bool)
+	return optional
+}
+
+// joinField appends a field name to a dotted path, either of which may be
+// empty.
+func joinField(path, field string) string {
+	switch {
+	case path == "":
+		return field
+	case field == "":
+		return path
+	default:
+		return path + "." + field
+	}
+}
@@ -43,44 +43,80 @@ func TestGetPodSpec(t *testing.T) {
 
 // TestParseEnvValueFrom checks parsing of env[].valueFrom.
 func TestParseEnvValueFrom(t *testing.T) {
-	var secretRefs, configMapRefs []string
+	var secretRefs, configMapRefs []dependency.Reference
 
 	valFrom := map[string]interface{}{
 		"secretKeyRef": map[string]interface{}{
 			"name": "my-secret",
 		},
 	}
-	dependency.ParseEnvValueFrom(valFrom, &secretRefs, &configMapRefs)
-	assert.Contains(t, secretRefs, "Secret/my-secret")
+	dependency.ParseEnvValueFrom(valFrom, "", "containers[0].env[2].valueFrom", &secretRefs, &configMapRefs)
+	assert.Contains(t, secretRefs, dependency.Reference{ID: "Secret/my-secret", Field: "containers[0].env[2].valueFrom.secretKeyRef"})
 
 	valFrom2 := map[string]interface{}{
 		"configMapKeyRef": map[string]interface{}{
 			"name": "my-cm",
 		},
 	}
-	dependency.ParseEnvValueFrom(valFrom2, &secretRefs, &configMapRefs)
-	assert.Contains(t, configMapRefs, "ConfigMap/my-cm")
+	dependency.ParseEnvValueFrom(valFrom2, "", "", &secretRefs, &configMapRefs)
+	assert.Contains(t, configMapRefs, dependency.Reference{ID: "ConfigMap/my-cm", Field: "configMapKeyRef"})
 }
 
 // TestParseEnvFrom checks parsing of envFrom[].secretRef or configMapRef.
 func TestParseEnvFrom(t *testing.T) {
-	var secretRefs, configMapRefs []string
+	var secretRefs, configMapRefs []dependency.Reference
 
 	envFrom := map[string]interface{}{
 		"secretRef": map[string]interface{}{
 			"name": "another-secret",
 		},
 	}
-	dependency.ParseEnvFrom(envFrom, &secretRefs, &configMapRefs)
-	assert.Contains(t, secretRefs, "Secret/another-secret")
+	dependency.ParseEnvFrom(envFrom, "", "containers[1].envFrom[0]", &secretRefs, &configMapRefs)
+	assert.Contains(t, secretRefs, dependency.Reference{ID: "Secret/another-secret", Field: "containers[1].envFrom[0].secretRef"})
 
 	envFrom2 := map[string]interface{}{
 		"configMapRef": map[string]interface{}{
 			"name": "another-cm",
 		},
 	}
-	dependency.ParseEnvFrom(envFrom2, &secretRefs, &configMapRefs)
-	assert.Contains(t, configMapRefs, "ConfigMap/another-cm")
+	dependency.ParseEnvFrom(envFrom2, "", "", &secretRefs, &configMapRefs)
+	assert.Contains(t, configMapRefs, dependency.Reference{ID: "ConfigMap/another-cm", Field: "configMapRef"})
+}
+
+// TestParseEnv_Optional checks that key refs and envFrom refs with
+// optional: true are marked Optional, and others are not.
+func TestParseEnv_Optional(t *testing.T) {
+	var secretRefs, configMapRefs []dependency.Reference
+	dependency.ParseEnvValueFrom(map[string]interface{}{
+		"secretKeyRef": map[string]interface{}{"name": "token", "key": "t", "optional": true},
+	}, "", "env[0].valueFrom", &secretRefs, &configMapRefs)
+	dependency.ParseEnvValueFrom(map[string]interface{}{
+		"configMapKeyRef": map[string]interface{}{"name": "settings", "key": "k", "optional": false},
+	}, "", "env[1].valueFrom", &secretRefs, &configMapRefs)
+	dependency.ParseEnvFrom(map[string]interface{}{
+		"secretRef": map[string]interface{}{"name": "creds"},
+	}, "", "envFrom[0]", &secretRefs, &configMapRefs)
+	dependency.ParseEnvFrom(map[string]interface{}{
+		"configMapRef": map[string]interface{}{"name": "overrides", "optional": true},
+	}, "", "envFrom[1]", &secretRefs, &configMapRefs)
+
+	assert.Equal(t, []dependency.Reference{
+		{ID: "Secret/token", Field: "env[0].valueFrom.secretKeyRef", Optional: true},
+		{ID: "Secret/creds", Field: "envFrom[0].secretRef"},
+	}, secretRefs)
+	assert.Equal(t, []dependency.Reference{
+		{ID: "ConfigMap/settings", Field: "env[1].valueFrom.configMapKeyRef"},
+		{ID: "ConfigMap/overrides", Field: "envFrom[1].configMapRef", Optional: true},
+	}, configMapRefs)
+}
+
+// refIDs returns the IDs of the references, in order.
+func refIDs(refs []dependency.Reference) []string {
+	var ids []string
+	for _, r := range refs {
+		ids = append(ids, r.ID)
+	}
+	return ids
 }
 
 // TestGatherPodSpecReferences tries a minimal spec to confirm volumes, env, etc. are captured.
@@ -120,18 +156,20 @@ func TestGatherPodSpecReferences(t *testing.T) {
 		},
 	}
 
-	secrets, cms, pvcs, sas := dependency.GatherPodSpecReferences(ps)
-	assert.Contains(t, secrets, "Secret/my-secret")
-	assert.Contains(t, secrets, "Secret/another-secret")
-	assert.Contains(t, cms, "ConfigMap/my-cm")
-	assert.Contains(t, secrets, "Secret/pull-secret")
-	assert.Contains(t, sas, "ServiceAccount/my-sa")
+	secrets, cms, pvcs, sas := dependency.GatherPodSpecReferences(ps, "")
+	assert.Equal(t, []dependency.Reference{
+		{ID: "Secret/my-secret", Field: "volumes[0].secret"},
+		{ID: "Secret/pull-secret", Field: "imagePullSecrets[0]"},
+		{ID: "Secret/another-secret", Field: "containers[0].envFrom[0].secretRef"},
+	}, secrets)
+	assert.Equal(t, []dependency.Reference{{ID: "ConfigMap/my-cm", Field: "volumes[1].configMap"}}, cms)
+	assert.Equal(t, []dependency.Reference{{ID: "ServiceAccount/my-sa", Field: "serviceAccountName"}}, sas)
 	assert.Empty(t, pvcs, "No PVC references here")
 }
 
 // TestGatherPodSpecReferences_EmptySpec ensures an empty pod spec doesn't panic.
 func TestGatherPodSpecReferences_EmptySpec(t *testing.T) {
-	secrets, cms, pvcs, sas := dependency.GatherPodSpecReferences(map[string]interface{}{})
+	secrets, cms, pvcs, sas := dependency.GatherPodSpecReferences(map[string]interface{}{}, "")
 	assert.Empty(t, secrets)
 	assert.Empty(t, cms)
 	assert.Empty(t, pvcs)
@@ -158,7 +196,68 @@ func TestGatherPodSpecReferences_MalformedVolumes(t *testing.T) {
 			},
 		},
 	}
-	secrets, cms, _, _ := dependency.GatherPodSpecReferences(ps)
+	secrets, cms, _, _ := dependency.GatherPodSpecReferences(ps, "")
 	assert.Empty(t, secrets, "malformed secret volume should be skipped")
-	assert.Contains(t, cms, "ConfigMap/valid-cm", "valid configMap should still be found")
+	assert.Contains(t, refIDs(cms), "ConfigMap/valid-cm", "valid configMap should still be found")
+}
+
+// TestGatherPodSpecReferences_VolumeSources verifies that projected volume
+// sources and every volume type naming a Secret are captured.
+func TestGatherPodSpecReferences_VolumeSources(t *testing.T) {
+	secretRef := func(name string) map[string]interface{} {
+		return map[string]interface{}{"secretRef": map[string]interface{}{"name": name}}
+	}
+	tests := []struct {
+		name    string
+		volume  map[string]interface{}
+		secrets []dependency.Reference
+		cms     []dependency.Reference
+	}{
+		{
+			name: "projected",
+			volume: map[string]interface{}{"projected": map[string]interface{}{"sources": []interface{}{
+				map[string]interface{}{"serviceAccountToken": map[string]interface{}{"path": "token"}},
+				map[string]interface{}{"secret": map[string]interface{}{"name": "tls"}},
+				map[string]interface{}{"configMap": map[string]interface{}{"name": "ca"}},
+			}}},
+			secrets: []dependency.Reference{{ID: "Secret/prod/tls", Field: "volumes[0].projected.sources[1].secret"}},
+			cms:     []dependency.Reference{{ID: "ConfigMap/prod/ca", Field: "volumes[0].projected.sources[2].configMap"}},
+		},
+		{
+			name: "csi",
+			volume: map[string]interface{}{"csi": map[string]interface{}{
+				"driver":               "secrets-store.csi.k8s.io",
+				"nodePublishSecretRef": map[string]interface{}{"name": "csi-creds"},
+			}},
+			secrets: []dependency.Reference{{ID: "Secret/prod/csi-creds", Field: "volumes[0].csi.nodePublishSecretRef"}},
+		},
+		{
+			name:    "azureFile",
+			volume:  map[string]interface{}{"azureFile": map[string]interface{}{"secretName": "azure", "shareName": "data"}},
+			secrets: []dependency.Reference{{ID: "Secret/prod/azure", Field: "volumes[0].azureFile"}},
+		},
+	}
+	for _, kind := range []string{"cephfs", "cinder", "flexVolume", "iscsi", "rbd", "scaleIO", "storageos"} {
+		tests = append(tests, struct {
+			name    string
+			volume  map[string]interface{}
+			secrets []dependency.Reference
+			cms     []dependency.Reference
+		}{
+			name:    kind,
+			volume:  map[string]interface{}{kind: secretRef(kind + "-creds")},
+			secrets: []dependency.Reference{{ID: "Secret/prod/" + kind + "-creds", Field: "volumes[0]." + kind + ".secretRef"}},
+		})
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			tc.volume["name"] = "vol"
+			ps := map[string]interface{}{"volumes": []interface{}{tc.volume}}
+			secrets, cms, pvcs, _ := dependency.GatherPodSpecReferences(ps, "prod")
+			assert.Equal(t, tc.secrets, secrets)
+			assert.Equal(t, tc.cms, cms)
+			assert.Empty(t, pvcs)
+		})
+	}
 }
@@ -0,0 +1,187 @@
+package dependency
+
+import (
+	"sync"
+
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+)
+
+// HandlerContext carries the read-only state shared by every handler during a
+// single BuildDependencies run.
+type HandlerContext struct {
+	// Objects is every object being analyzed.
+	Objects []*unstructured.Unstructured
+
+	// Labels indexes Objects by namespace and label for selector lookups.
+	Labels LabelIndex
+
+	// claims indexes the PersistentVolumeClaims in Objects for StatefulSet
+	// volumeClaimTemplates (see buildClaimIndex). It is built on demand
+	// when nil.
+	claims map[string][]claimEntry
+}
+
+// Handler discovers the references held by one object. It returns one Edge
+// per reference; ParentID is normally ResourceID(obj). Handlers must not
+// modify obj or the context, and must be safe to call from several
+// goroutines at once.
+type Handler interface {
+	Handle(obj *unstructured.Unstructured, ctx *HandlerContext) []Edge
+}
+
+// HandlerFunc adapts an ordinary function to the Handler interface.
+type HandlerFunc func(obj *unstructured.Unstructured, ctx *HandlerContext) []Edge
+
+// Handle calls f(obj, ctx).
+func (f HandlerFunc) Handle(obj *unstructured.Unstructured, ctx *HandlerContext) []Edge {
+	return f(obj, ctx)
+}
+
+// Registry maps a GroupKind to the handlers that run for objects of that kind,
+// and to the path of the embedded Pod spec for pod-bearing kinds. It is safe
+// for concurrent use.
+type Registry struct {
+	mu           sync.RWMutex
+	handlers     map[schema.GroupKind][]Handler
+	podSpecPaths map[schema.GroupKind][]string
+}
+
+// NewRegistry returns an empty Registry with no handlers registered.
+func NewRegistry() *Registry {
+	return &Registry{
+		handlers:     make(map[schema.GroupKind][]Handler),
+		podSpecPaths: make(map[schema.GroupKind][]string),
+	}
+}
+
+// Register adds a handler for the given GroupKind. Several handlers may be
+// registered for the same GroupKind; they run in registration order. Built-in
+// kinds may be registered under any of the groups they are served from (or
+// the empty group) and match objects from all of them.
+func (r *Registry) Register(gk schema.GroupKind, h Handler) {
+	gk = canonicalGroupKind(gk)
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.handlers[gk] = append(r.handlers[gk], h)
+}
+
+// RegisterPodSpecPath declares that objects of the given GroupKind embed a
+// Pod spec at path (e.g. "spec", "template", "spec"). The object then gets the
+// standard Secret, ConfigMap, PVC and ServiceAccount edges, and
+// Registry.IsPodOrController reports true for it, so it is also matched by
+// Service, NetworkPolicy and PodDisruptionBudget selectors. Registering again
+// replaces the path.
+func (r *Registry) RegisterPodSpecPath(gk schema.GroupKind, path ...string) {
+	gk = canonicalGroupKind(gk)
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.podSpecPaths[gk] = append([]string(nil), path...)
+}
+
+// Handlers returns the handlers registered for the GroupKind.
+func (r *Registry) Handlers(gk schema.GroupKind) []Handler {
+	gk = canonicalGroupKind(gk)
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	return append([]Handler(nil), r.handlers[gk]...)
+}
+
+// PodSpecPath returns the Pod spec path registered for the GroupKind.
+func (r *Registry) PodSpecPath(gk schema.GroupKind) ([]string, bool) {
+	gk = canonicalGroupKind(gk)
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	path, ok := r.podSpecPaths[gk]
+	return path, ok
+}
+
+// IsPodOrController reports whether the object's kind has a Pod spec path in
+// the registry, i.e. whether it is a Pod or a pod-bearing controller.
+func (r *Registry) IsPodOrController(obj *unstructured.Unstructured) bool {
+	_, ok := r.PodSpecPath(obj.GroupVersionKind().GroupKind())
+	return ok
+}
+
+// Clone returns an independent copy of the registry, so callers can add
+// handlers for one analysis without affecting the original.
+func (r *Registry) Clone() *Registry {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	c := NewRegistry()
+	for gk, hs := range r.handlers {
+		c.handlers[gk] = append([]Handler(nil), hs...)
+	}
+	for gk, path := range r.podSpecPaths {
+		c.podSpecPaths[gk] = path
+	}
+	return c
+}
+
+// defaultRegistry holds the built-in handlers plus anything added through the
+// package-level Register functions. BuildDependencies uses it.
+var defaultRegistry = newBuiltinRegistry()
+
+// DefaultRegistry returns the registry used by BuildDependencies.
+func DefaultRegistry() *Registry {
+	return defaultRegistry
+}
+
+// Register adds a handler for the GroupKind to the default registry. It is
+// typically called from an init function by code embedding cartographer.
+func Register(gk schema.GroupKind, h Handler) {
+	defaultRegistry.Register(gk, h)
+}
+
+// RegisterPodSpecPath declares a Pod spec path for the GroupKind in the
+// default registry.
+func RegisterPodSpecPath(gk schema.GroupKind, path ...string) {
+	defaultRegistry.RegisterPodSpecPath(gk, path...)
+}
+
+// newBuiltinRegistry returns a registry holding cartographer's own handlers.
+func newBuiltinRegistry() *Registry {
+	r := NewRegistry()
+
+	r.Register(schema.GroupKind{Kind: "Service"}, HandlerFunc(handleServiceLabelSelector))
+	r.Register(schema.GroupKind{Group: "networking.k8s.io", Kind: "NetworkPolicy"}, HandlerFunc(handleNetworkPolicy))
+	r.Register(schema.GroupKind{Group: "policy", Kind: "PodDisruptionBudget"}, HandlerFunc(handlePodDisruptionBudget))
+	r.Register(schema.GroupKind{Group: "networking.k8s.io", Kind: "Ingress"}, HandlerFunc(handleIngressReferences))
+	r.Register(schema.GroupKind{Group: "autoscaling", Kind: "HorizontalPodAutoscaler"}, HandlerFunc(handleHPAReferences))
+	r.Register(schema.GroupKind{Group: "rbac.authorization.k8s.io", Kind: "RoleBinding"}, HandlerFunc(handleRoleBinding))
+	r.Register(schema.GroupKind{Group: "rbac.authorization.k8s.io", Kind: "ClusterRoleBinding"}, HandlerFunc(handleRoleBinding))
+	r.Register(schema.GroupKind{Group: "apps", Kind: "StatefulSet"}, HandlerFunc(handleStatefulSetClaims))
+	r.Register(schema.GroupKind{Kind: "ServiceAccount"}, HandlerFunc(handleServiceAccountSecrets))
+
+	r.RegisterPodSpecPath(schema.GroupKind{Kind: "Pod"}, "spec")
+	for _, gk := range []schema.GroupKind{
+		{Group: "apps", Kind: "Deployment"},
+		{Group: "apps", Kind: "DaemonSet"},
+		{Group: "apps", Kind: "StatefulSet"},
+		{Group: "apps", Kind: "ReplicaSet"},
+		{Group: "batch", Kind: "Job"},
+	} {
+		r.RegisterPodSpecPath(gk, "spec", "template", "spec")
+	}
+	r.RegisterPodSpecPath(schema.GroupKind{Group: "batch", Kind: "CronJob"}, "spec", "jobTemplate", "spec", "template", "spec")
+
+	return r
+}
+
+// canonicalGroupKind maps a built-in kind to a single registry key regardless
+// of which of its groups (or the empty group, for manifests without an
+// apiVersion) it was given with. Other GroupKinds are returned unchanged.
+func canonicalGroupKind(gk schema.GroupKind) schema.GroupKind {
+	groups, ok := builtinGroups[gk.Kind]
+	if !ok || !IsBuiltinGroup(gk.Kind, gk.Group) {
+		return gk
+	}
+	// Every built-in kind has exactly one group other than the legacy
+	// "extensions" group; that is its canonical group.
+	for group := range groups {
+		if group != "extensions" {
+			return schema.GroupKind{Group: group, Kind: gk.Kind}
+		}
+	}
+	return gk
+}
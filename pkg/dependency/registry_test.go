@@ -0,0 +1,168 @@
+package dependency_test
+
+import (
+	"testing"
+
+	"github.com/HMetcalfeW/cartographer/pkg/dependency"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+)
+
+// certificateHandler is an in-house style handler linking a cert-manager
+// Certificate to the Secret it writes.
+func certificateHandler(obj *unstructured.Unstructured, _ *dependency.HandlerContext) []dependency.Edge {
+	name, _, _ := unstructured.NestedString(obj.Object, "spec", "secretName")
+	if name == "" {
+		return nil
+	}
+	return []dependency.Edge{{
+		ParentID: dependency.ResourceID(obj),
+		ChildID:  dependency.RefID("", "Secret", obj.GetNamespace(), name),
+		Reason:   "secretName",
+	}}
+}
+
+func newCertificate(namespace, name, secretName string) *unstructured.Unstructured {
+	return &unstructured.Unstructured{
+		Object: map[string]interface{}{
+			"apiVersion": "cert-manager.io/v1",
+			"kind":       "Certificate",
+			"metadata":   map[string]interface{}{"name": name, "namespace": namespace},
+			"spec":       map[string]interface{}{"secretName": secretName},
+		},
+	}
+}
+
+// TestRegistryCustomHandler verifies a handler registered on a cloned registry
+// runs for its GroupKind and does not leak into the default registry.
+func TestRegistryCustomHandler(t *testing.T) {
+	certGK := schema.GroupKind{Group: "cert-manager.io", Kind: "Certificate"}
+	reg := dependency.DefaultRegistry().Clone()
+	reg.Register(certGK, dependency.HandlerFunc(certificateHandler))
+
+	objs := []*unstructured.Unstructured{newCertificate("prod", "web-tls", "web-tls-secret")}
+
+	g := dependency.BuildDependenciesWith(objs, reg)
+	edges := g.Edges("Certificate.cert-manager.io/prod/web-tls")
+	require.Len(t, edges, 1)
+	assert.Equal(t, "Secret/prod/web-tls-secret", edges[0].ChildID)
+	assert.Equal(t, "secretName", edges[0].Reason)
+
+	assert.Empty(t, dependency.DefaultRegistry().Handlers(certGK), "clone must not modify the default registry")
+	assert.Empty(t, dependency.BuildDependencies(objs).Edges("Certificate.cert-manager.io/prod/web-tls"))
+}
+
+// TestRegistryGroupMismatch verifies a handler only runs for its own group.
+func TestRegistryGroupMismatch(t *testing.T) {
+	reg := dependency.NewRegistry()
+	reg.Register(schema.GroupKind{Group: "example.com", Kind: "Certificate"}, dependency.HandlerFunc(certificateHandler))
+
+	g := dependency.BuildDependenciesWith([]*unstructured.Unstructured{newCertificate("prod", "web-tls", "s")}, reg)
+	assert.Empty(t, g.AllEdges())
+}
+
+// TestRegistryCanonicalGroups verifies built-in kinds match regardless of
+// which of their served groups (or none) the manifest uses.
+func TestRegistryCanonicalGroups(t *testing.T) {
+	reg := dependency.DefaultRegistry()
+
+	for _, gk := range []schema.GroupKind{
+		{Group: "apps", Kind: "Deployment"},
+		{Group: "extensions", Kind: "Deployment"},
+		{Kind: "Deployment"},
+	} {
+		path, ok := reg.PodSpecPath(gk)
+		assert.Truef(t, ok, "%v should have a pod spec path", gk)
+		assert.Equal(t, []string{"spec", "template", "spec"}, path)
+	}
+
+	assert.NotEmpty(t, reg.Handlers(schema.GroupKind{Group: "extensions", Kind: "Ingress"}))
+	assert.NotEmpty(t, reg.Handlers(schema.GroupKind{Group: "networking.k8s.io", Kind: "Ingress"}))
+	assert.Empty(t, reg.Handlers(schema.GroupKind{Group: "serving.knative.dev", Kind: "Service"}))
+}
+
+// TestRegistryPodSpecPath verifies a custom pod-bearing kind gets the standard
+// pod spec edges once its Pod spec path is registered.
+func TestRegistryPodSpecPath(t *testing.T) {
+	rolloutGK := schema.GroupKind{Group: "argoproj.io", Kind: "Rollout"}
+	rollout := &unstructured.Unstructured{
+		Object: map[string]interface{}{
+			"apiVersion": "argoproj.io/v1alpha1",
+			"kind":       "Rollout",
+			"metadata":   map[string]interface{}{"name": "web", "namespace": "prod"},
+			"spec": map[string]interface{}{
+				"template": map[string]interface{}{
+					"spec": map[string]interface{}{
+						"serviceAccountName": "web-sa",
+					},
+				},
+			},
+		},
+	}
+
+	g := dependency.BuildDependencies([]*unstructured.Unstructured{rollout})
+	assert.Empty(t, g.Edges("Rollout.argoproj.io/prod/web"))
+
+	reg := dependency.DefaultRegistry().Clone()
+	reg.RegisterPodSpecPath(rolloutGK, "spec", "template", "spec")
+
+	g = dependency.BuildDependenciesWith([]*unstructured.Unstructured{rollout}, reg)
+	edges := g.Edges("Rollout.argoproj.io/prod/web")
+	require.Len(t, edges, 1)
+	assert.Equal(t, "ServiceAccount/prod/web-sa", edges[0].ChildID)
+	assert.Equal(t, "serviceAccountName", edges[0].Reason)
+}
+
+// TestRegistryPodSpecPathSelectors verifies a custom pod-bearing kind
+// registered on a cloned registry is label-indexed with that registry, so
+// Service, NetworkPolicy and PodDisruptionBudget selectors reach it.
+func TestRegistryPodSpecPathSelectors(t *testing.T) {
+	reg := dependency.DefaultRegistry().Clone()
+	reg.RegisterPodSpecPath(schema.GroupKind{Group: "argoproj.io", Kind: "Rollout"}, "spec", "template", "spec")
+
+	selector := map[string]interface{}{"app": "web"}
+	objs := []*unstructured.Unstructured{
+		{Object: map[string]interface{}{
+			"apiVersion": "argoproj.io/v1alpha1",
+			"kind":       "Rollout",
+			"metadata":   map[string]interface{}{"name": "web", "namespace": "prod", "labels": map[string]interface{}{"app": "web"}},
+			"spec": map[string]interface{}{
+				"template": map[string]interface{}{"spec": map[string]interface{}{}},
+			},
+		}},
+		{Object: map[string]interface{}{
+			"apiVersion": "v1",
+			"kind":       "Service",
+			"metadata":   map[string]interface{}{"name": "web", "namespace": "prod"},
+			"spec":       map[string]interface{}{"selector": selector},
+		}},
+		{Object: map[string]interface{}{
+			"apiVersion": "networking.k8s.io/v1",
+			"kind":       "NetworkPolicy",
+			"metadata":   map[string]interface{}{"name": "web", "namespace": "prod"},
+			"spec":       map[string]interface{}{"podSelector": map[string]interface{}{"matchLabels": selector}},
+		}},
+		{Object: map[string]interface{}{
+			"apiVersion": "policy/v1",
+			"kind":       "PodDisruptionBudget",
+			"metadata":   map[string]interface{}{"name": "web", "namespace": "prod"},
+			"spec":       map[string]interface{}{"selector": map[string]interface{}{"matchLabels": selector}},
+		}},
+	}
+
+	g := dependency.BuildDependenciesWith(objs, reg)
+	for parent, reason := range map[string]string{
+		"Service/prod/web":             "selector",
+		"NetworkPolicy/prod/web":       "podSelector",
+		"PodDisruptionBudget/prod/web": "pdbSelector",
+	} {
+		edges := g.Edges(parent)
+		require.Lenf(t, edges, 1, "%s should select the Rollout", parent)
+		assert.Equal(t, "Rollout.argoproj.io/prod/web", edges[0].ChildID)
+		assert.Equal(t, reason, edges[0].Reason)
+	}
+
+	assert.Empty(t, dependency.BuildDependencies(objs).Edges("Service/prod/web"), "the default registry does not know Rollouts")
+}
@@ -0,0 +1,198 @@
+package dependency
+
+import (
+	"fmt"
+	"strings"
+
+	log "github.com/sirupsen/logrus"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+)
+
+// ReferenceRule declares a reference held by a custom resource, so that edges
+// can be emitted for it without writing a Handler. Rules are normally read
+// from the "references" list in .cartographer.yaml.
+//
+// Path is a dot-separated field path such as "spec.issuerRef" or
+// "spec.sources[].sourceRef"; a leading "$." or "." is ignored, and lists
+// met along the way are traversed automatically ("[]" or "[*]" may be written
+// for clarity). The value at the end of the path is either the target's name
+// (a string) or a reference object from which the name, kind, group and
+// namespace are read.
+type ReferenceRule struct {
+	// Group and Kind select the objects the rule applies to. Group may only
+	// be left empty for built-in kinds.
+	Group string `mapstructure:"group"`
+	Kind  string `mapstructure:"kind"`
+
+	// Path locates the reference within the object.
+	Path string `mapstructure:"path"`
+
+	// TargetKind is the kind of the referenced object. TargetKindField names
+	// a field in the reference object holding the kind instead; TargetKind is
+	// then used only when that field is absent.
+	TargetKind      string `mapstructure:"targetKind"`
+	TargetKindField string `mapstructure:"targetKindField"`
+
+	// TargetGroup is the API group of the referenced object. TargetGroupField
+	// names a field in the reference object holding either a group or an
+	// apiVersion instead.
+	TargetGroup      string `mapstructure:"targetGroup"`
+	TargetGroupField string `mapstructure:"targetGroupField"`
+
+	// NameField and NamespaceField name the fields of the reference object
+	// holding the target's name and namespace. They default to "name" and
+	// "namespace". Without a namespace, the referring object's is used.
+	NameField      string `mapstructure:"nameField"`
+	NamespaceField string `mapstructure:"namespaceField"`
+
+	// ClusterScopedKinds lists target kinds that have no namespace (e.g.
+	// ClusterIssuer).
+	ClusterScopedKinds []string `mapstructure:"clusterScopedKinds"`
+
+	// Reason is the edge reason, e.g. "issuerRef".
+	Reason string `mapstructure:"reason"`
+}
+
+// Validate checks that the rule has everything needed to emit edges.
+func (r ReferenceRule) Validate() error {
+	switch {
+	case r.Kind == "":
+		return fmt.Errorf("kind is required")
+	case r.Group == "" && builtinGroups[r.Kind] == nil:
+		// Handlers are looked up by group, so the rule would never match.
+		return fmt.Errorf("group is required for %s, which is not a built-in kind", r.Kind)
+	case len(splitRulePath(r.Path)) == 0:
+		return fmt.Errorf("path is required")
+	case r.TargetKind == "" && r.TargetKindField == "":
+		return fmt.Errorf("one of targetKind or targetKindField is required")
+	case r.Reason == "":
+		return fmt.Errorf("reason is required")
+	}
+	return nil
+}
+
+// RegisterRules validates each rule and registers a handler for it on reg.
+// Nothing is registered if any rule is invalid.
+func RegisterRules(reg *Registry, rules []ReferenceRule) error {
+	for i, rule := range rules {
+		if err := rule.Validate(); err != nil {
+			return fmt.Errorf("reference rule %d (%s): %w", i, rule.Kind, err)
+		}
+	}
+	for _, rule := range rules {
+		reg.Register(schema.GroupKind{Group: rule.Group, Kind: rule.Kind}, ruleHandler(rule))
+	}
+	log.WithFields(log.Fields{
+		"func":  "RegisterRules",
+		"count": len(rules),
+	}).Debug("Registered reference rules")
+	return nil
+}
+
+// ruleHandler returns a Handler emitting an edge for every reference the
+// rule's path resolves to.
+func ruleHandler(rule ReferenceRule) Handler {
+	segments := splitRulePath(rule.Path)
+	nameField := rule.NameField
+	if nameField == "" {
+		nameField = "name"
+	}
+	namespaceField := rule.NamespaceField
+	if namespaceField == "" {
+		namespaceField = "namespace"
+	}
+	clusterScoped := make(map[string]bool, len(rule.ClusterScopedKinds))
+	for _, k := range rule.ClusterScopedKinds {
+		clusterScoped[k] = true
+	}
+
+	return HandlerFunc(func(obj *unstructured.Unstructured, _ *HandlerContext) []Edge {
+		parentID := ResourceID(obj)
+		var edges []Edge
+		for _, m := range resolveRulePath(obj.Object, segments, "") {
+			kind, group, namespace := rule.TargetKind, rule.TargetGroup, obj.GetNamespace()
+			var name string
+
+			switch ref := m.value.(type) {
+			case string:
+				name = ref
+			case map[string]interface{}:
+				name, _ = ref[nameField].(string)
+				if rule.TargetKindField != "" {
+					if k, ok := ref[rule.TargetKindField].(string); ok && k != "" {
+						kind = k
+					}
+				}
+				if rule.TargetGroupField != "" {
+					if g, ok := ref[rule.TargetGroupField].(string); ok && g != "" {
+						group = g
+						if strings.Contains(g, "/") {
+							group = groupOf(g)
+						}
+					}
+				}
+				if ns, ok := ref[namespaceField].(string); ok && ns != "" {
+					namespace = ns
+				}
+			}
+			if name == "" || kind == "" {
+				continue
+			}
+			if clusterScoped[kind] {
+				namespace = ""
+			}
+			edges = append(edges, Edge{
+				ParentID: parentID,
+				ChildID:  RefID(group, kind, namespace, name),
+				Reason:   rule.Reason,
+				Field:    m.field,
+			})
+		}
+		return edges
+	})
+}
+
+// splitRulePath turns "spec.sources[].sourceRef" into its field names.
+func splitRulePath(path string) []string {
+	path = strings.TrimPrefix(path, "$")
+	var segments []string
+	for _, seg := range strings.Split(path, ".") {
+		seg = strings.TrimSuffix(strings.TrimSuffix(seg, "[*]"), "[]")
+		if seg != "" {
+			segments = append(segments, seg)
+		}
+	}
+	return segments
+}
+
+// ruleMatch is a value reached by a rule path, with the concrete field path
+// (list indexes included) that led to it.
+type ruleMatch struct {
+	value interface{}
+	field string
+}
+
+// resolveRulePath returns every value reached by following segments from v,
+// fanning out over any list along the way (including a list at the end).
+// field is the path of v itself.
+func resolveRulePath(v interface{}, segments []string, field string) []ruleMatch {
+	if list, ok := v.([]interface{}); ok {
+		var out []ruleMatch
+		for i, item := range list {
+			out = append(out, resolveRulePath(item, segments, fmt.Sprintf("%s[%d]", field, i))...)
+		}
+		return out
+	}
+	if len(segments) == 0 {
+		if v == nil {
+			return nil
+		}
+		return []ruleMatch{{value: v, field: field}}
+	}
+	m, ok := v.(map[string]interface{})
+	if !ok {
+		return nil
+	}
+	return resolveRulePath(m[segments[0]], segments[1:], joinField(field, segments[0]))
+}
@@ -0,0 +1,172 @@
+package dependency_test
+
+import (
+	"testing"
+
+	"github.com/HMetcalfeW/cartographer/pkg/dependency"
+	"github.com/HMetcalfeW/cartographer/pkg/parser"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+const ruleManifests = `
+apiVersion: cert-manager.io/v1
+kind: Certificate
+metadata:
+  name: web-tls
+  namespace: prod
+spec:
+  secretName: web-tls
+  issuerRef:
+    name: letsencrypt
+    kind: ClusterIssuer
+    group: cert-manager.io
+---
+apiVersion: cert-manager.io/v1
+kind: Certificate
+metadata:
+  name: api-tls
+  namespace: prod
+spec:
+  secretName: api-tls
+  issuerRef:
+    name: internal-ca
+    kind: Issuer
+    group: cert-manager.io
+---
+apiVersion: helm.toolkit.fluxcd.io/v2
+kind: HelmRelease
+metadata:
+  name: podinfo
+  namespace: apps
+spec:
+  chart:
+    spec:
+      chart: podinfo
+      sourceRef:
+        kind: HelmRepository
+        name: podinfo
+        namespace: flux-system
+  valuesFrom:
+    - kind: ConfigMap
+      name: podinfo-values
+    - kind: Secret
+      name: podinfo-secrets
+`
+
+// TestRegisterRules verifies config-style rules produce edges for object
+// references, string references, kind fields and list traversal.
+func TestRegisterRules(t *testing.T) {
+	objs, err := parser.ParseYAML([]byte(ruleManifests))
+	require.NoError(t, err)
+
+	rules := []dependency.ReferenceRule{
+		{
+			Group:              "cert-manager.io",
+			Kind:               "Certificate",
+			Path:               "spec.issuerRef",
+			TargetKindField:    "kind",
+			TargetKind:         "Issuer",
+			TargetGroupField:   "group",
+			ClusterScopedKinds: []string{"ClusterIssuer"},
+			Reason:             "issuerRef",
+		},
+		{
+			Group:      "cert-manager.io",
+			Kind:       "Certificate",
+			Path:       "$.spec.secretName",
+			TargetKind: "Secret",
+			Reason:     "secretName",
+		},
+		{
+			Group:           "helm.toolkit.fluxcd.io",
+			Kind:            "HelmRelease",
+			Path:            "spec.chart.spec.sourceRef",
+			TargetKindField: "kind",
+			TargetGroup:     "source.toolkit.fluxcd.io",
+			Reason:          "sourceRef",
+		},
+		{
+			Group:           "helm.toolkit.fluxcd.io",
+			Kind:            "HelmRelease",
+			Path:            "spec.valuesFrom[]",
+			TargetKindField: "kind",
+			Reason:          "valuesFrom",
+		},
+	}
+
+	reg := dependency.DefaultRegistry().Clone()
+	require.NoError(t, dependency.RegisterRules(reg, rules))
+	g := dependency.BuildDependenciesWith(objs, reg)
+
+	type ref struct{ child, reason string }
+	collect := func(id string) []ref {
+		var out []ref
+		for _, e := range g.Edges(id) {
+			out = append(out, ref{e.ChildID, e.Reason})
+		}
+		return out
+	}
+
+	assert.ElementsMatch(t, []ref{
+		{"ClusterIssuer.cert-manager.io/letsencrypt", "issuerRef"},
+		{"Secret/prod/web-tls", "secretName"},
+	}, collect("Certificate.cert-manager.io/prod/web-tls"))
+
+	assert.ElementsMatch(t, []ref{
+		{"Issuer.cert-manager.io/prod/internal-ca", "issuerRef"},
+		{"Secret/prod/api-tls", "secretName"},
+	}, collect("Certificate.cert-manager.io/prod/api-tls"))
+
+	assert.ElementsMatch(t, []ref{
+		{"HelmRepository.source.toolkit.fluxcd.io/flux-system/podinfo", "sourceRef"},
+		{"ConfigMap/apps/podinfo-values", "valuesFrom"},
+		{"Secret/apps/podinfo-secrets", "valuesFrom"},
+	}, collect("HelmRelease.helm.toolkit.fluxcd.io/apps/podinfo"))
+
+	// Field paths name the list entry each reference came from.
+	fields := make(map[string]string)
+	for _, e := range g.Edges("HelmRelease.helm.toolkit.fluxcd.io/apps/podinfo") {
+		fields[e.ChildID] = e.Field
+	}
+	assert.Equal(t, map[string]string{
+		"HelmRepository.source.toolkit.fluxcd.io/flux-system/podinfo": "spec.chart.spec.sourceRef",
+		"ConfigMap/apps/podinfo-values":                               "spec.valuesFrom[0]",
+		"Secret/apps/podinfo-secrets":                                 "spec.valuesFrom[1]",
+	}, fields)
+
+	// The default registry is unaffected.
+	assert.Empty(t, dependency.BuildDependencies(objs).Edges("Certificate.cert-manager.io/prod/web-tls"))
+}
+
+// TestRegisterRules_Invalid verifies incomplete rules are rejected.
+func TestRegisterRules_Invalid(t *testing.T) {
+	valid := dependency.ReferenceRule{Group: "cert-manager.io", Kind: "Certificate", Path: "spec.secretName", TargetKind: "Secret", Reason: "secretName"}
+
+	tests := []struct {
+		name   string
+		mutate func(r *dependency.ReferenceRule)
+		errMsg string
+	}{
+		{"missing kind", func(r *dependency.ReferenceRule) { r.Kind = "" }, "kind is required"},
+		{"missing group", func(r *dependency.ReferenceRule) { r.Group = "" }, "group is required for Certificate"},
+		{"missing path", func(r *dependency.ReferenceRule) { r.Path = "$." }, "path is required"},
+		{"missing target kind", func(r *dependency.ReferenceRule) { r.TargetKind = "" }, "targetKind or targetKindField"},
+		{"missing reason", func(r *dependency.ReferenceRule) { r.Reason = "" }, "reason is required"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rule := valid
+			tt.mutate(&rule)
+			reg := dependency.NewRegistry()
+			err := dependency.RegisterRules(reg, []dependency.ReferenceRule{valid, rule})
+			require.Error(t, err)
+			assert.Contains(t, err.Error(), tt.errMsg)
+			assert.Contains(t, err.Error(), "reference rule 1")
+		})
+	}
+
+	// Built-in kinds are matched without a group.
+	builtin := dependency.ReferenceRule{Kind: "Deployment", Path: "spec.template.metadata.annotations.config", TargetKind: "ConfigMap", Reason: "config"}
+	require.NoError(t, dependency.RegisterRules(dependency.NewRegistry(), []dependency.ReferenceRule{builtin}))
+}
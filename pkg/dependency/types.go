@@ -1,8 +1,6 @@
 package dependency
 
 import (
-	"fmt"
-
 	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
 )
 
@@ -10,11 +8,26 @@ import (
 // to another resource (the child), along with the reason describing how or why
 // the parent references the child.
 type Edge struct {
-	// ChildID is the unique identifier of the child resource, in the form "Kind/Name".
+	// ParentID is the unique identifier of the referring resource.
+	ParentID string
+
+	// ChildID is the unique identifier of the child resource, in the form
+	// "Kind[.group]/[namespace/]name" (see ResourceKey).
 	ChildID string
 
 	// Reason describes the nature of this dependency, e.g., "ownerRef", "secretRef", "selector".
 	Reason string
+
+	// Field is the path of the field in the parent that produced the edge,
+	// e.g. "spec.template.spec.containers[1].env[3].valueFrom.secretKeyRef".
+	// When the same edge is produced by several fields, their paths are
+	// joined with ", ". Empty if unknown.
+	Field string
+
+	// Optional is set when every field producing the edge marks the
+	// reference optional: true (see Reference), so the parent works without
+	// the child and a missing child is not a dangling reference.
+	Optional bool
 }
 
 // LabelSelectorRequirement represents a single matchExpressions entry from a
@@ -25,20 +38,19 @@ type LabelSelectorRequirement struct {
 	Values   []string
 }
 
-// IsPodOrController returns true if the object is a Pod or a common controller
-// type that embeds a Pod spec (.spec.template.spec or .spec.jobTemplate...).
+// IsPodOrController returns true if the object is a Pod or a controller type
+// that embeds a Pod spec, i.e. its kind has a Pod spec path in the default
+// registry (see RegisterPodSpecPath). Custom resources that merely share a
+// built-in kind name are not matched. Use Registry.IsPodOrController for a
+// registry other than the default one.
 func IsPodOrController(obj *unstructured.Unstructured) bool {
-	switch obj.GetKind() {
-	case "Pod", "Deployment", "DaemonSet", "StatefulSet", "ReplicaSet", "Job", "CronJob":
-		return true
-	default:
-		return false
-	}
+	return defaultRegistry.IsPodOrController(obj)
 }
 
-// ResourceID builds a string "Kind/Name" from the object's kind and metadata.name.
+// ResourceID builds the node ID for an object from its API group, kind,
+// namespace and name, e.g. "Secret/prod/db" or "ClusterRole/admin".
 func ResourceID(obj *unstructured.Unstructured) string {
-	return fmt.Sprintf("%s/%s", obj.GetKind(), obj.GetName())
+	return KeyFor(obj).String()
 }
 
 // LabelsMatch returns true if all key-value pairs in 'selector' are present in 'labels'.
@@ -65,17 +77,3 @@ func MapInterfaceToStringMap(in interface{}) map[string]string {
 	}
 	return out
 }
-
-// deduplicateEdges removes duplicate edges based on ChildID+Reason.
-func deduplicateEdges(edges []Edge) []Edge {
-	seen := make(map[string]struct{}, len(edges))
-	result := make([]Edge, 0, len(edges))
-	for _, e := range edges {
-		key := e.ChildID + "|" + e.Reason
-		if _, exists := seen[key]; !exists {
-			seen[key] = struct{}{}
-			result = append(result, e)
-		}
-	}
-	return result
-}
@@ -5,6 +5,7 @@ import (
 
 	"github.com/HMetcalfeW/cartographer/pkg/dependency"
 	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
 	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
 )
 
@@ -186,20 +187,20 @@ func TestLabelIndexMatchSelector(t *testing.T) {
 		},
 	}
 
-	idx := dependency.BuildLabelIndex([]*unstructured.Unstructured{web, api, worker})
+	idx := dependency.BuildLabelIndex([]*unstructured.Unstructured{web, api, worker}, dependency.DefaultRegistry())
 
 	// matchLabels only — same as Match()
-	results := idx.MatchSelector(map[string]string{"tier": "backend"}, nil)
+	results := idx.MatchSelector("", map[string]string{"tier": "backend"}, nil)
 	assert.Len(t, results, 2)
 
 	// matchExpressions only — In operator
-	results = idx.MatchSelector(nil, []dependency.LabelSelectorRequirement{
+	results = idx.MatchSelector("", nil, []dependency.LabelSelectorRequirement{
 		{Key: "env", Operator: "In", Values: []string{"prod"}},
 	})
 	assert.Len(t, results, 2) // web + api
 
 	// Combined matchLabels + matchExpressions
-	results = idx.MatchSelector(
+	results = idx.MatchSelector("",
 		map[string]string{"tier": "backend"},
 		[]dependency.LabelSelectorRequirement{
 			{Key: "env", Operator: "NotIn", Values: []string{"staging"}},
@@ -209,17 +210,26 @@ func TestLabelIndexMatchSelector(t *testing.T) {
 	assert.Equal(t, "api", results[0].GetName())
 
 	// Both empty — nil
-	results = idx.MatchSelector(nil, nil)
+	results = idx.MatchSelector("", nil, nil)
 	assert.Nil(t, results)
 
 	// matchExpressions with DoesNotExist
-	results = idx.MatchSelector(nil, []dependency.LabelSelectorRequirement{
+	results = idx.MatchSelector("", nil, []dependency.LabelSelectorRequirement{
 		{Key: "deprecated", Operator: "DoesNotExist"},
 	})
 	assert.Len(t, results, 3, "all objects lack the deprecated label")
+
+	// Several In values (one repeated) — each object once, in input order
+	results = idx.MatchSelector("", nil, []dependency.LabelSelectorRequirement{
+		{Key: "app", Operator: "In", Values: []string{"worker", "web", "worker"}},
+		{Key: "tier", Operator: "Exists"},
+	})
+	require.Len(t, results, 2)
+	assert.Equal(t, "web", results[0].GetName())
+	assert.Equal(t, "worker", results[1].GetName())
 }
 
-// TestDeduplicateEdges verifies that duplicate edges are removed from the dependency map.
+// TestDeduplicateEdges verifies that duplicate edges are stored once in the graph.
 func TestDeduplicateEdges(t *testing.T) {
 	// Create a Deployment that references the same secret in two places (volume + env)
 	deployment := &unstructured.Unstructured{
@@ -248,7 +258,7 @@ func TestDeduplicateEdges(t *testing.T) {
 	}
 
 	deps := dependency.BuildDependencies([]*unstructured.Unstructured{deployment})
-	edges := deps["Deployment/dup-deploy"]
+	edges := deps.Edges("Deployment/dup-deploy")
 
 	// Count secretRef edges to shared-secret — should be exactly 1 after dedup
 	count := 0
@@ -0,0 +1,112 @@
+package dependency
+
+import (
+	"fmt"
+	"strings"
+)
+
+// UnusedResource is a config, storage or RBAC object that nothing in the graph
+// references, with an explanation of what was checked.
+type UnusedResource struct {
+	ID        string `json:"id"`
+	Kind      string `json:"kind"`
+	Namespace string `json:"namespace,omitempty"`
+	Name      string `json:"name"`
+	Reason    string `json:"reason"`
+}
+
+// unusedKinds lists the built-in kinds checked by FindUnused, with the
+// references that would have counted as a use.
+var unusedKinds = map[string]string{
+	"ConfigMap":             "no workload mounts it or reads it via env/envFrom, and no other resource references it",
+	"Secret":                "no workload mounts it, reads it via env/envFrom or uses it as an imagePullSecret, no ServiceAccount lists it, no Ingress uses it for TLS, and no other resource references it",
+	"PersistentVolumeClaim": "no workload mounts it as a volume, no StatefulSet volumeClaimTemplate creates it, and no other resource references it",
+	"ServiceAccount":        "no workload runs as it and no RoleBinding or ClusterRoleBinding names it as a subject",
+	"Role":                  "no RoleBinding references it",
+	"ClusterRole":           "no RoleBinding or ClusterRoleBinding references it",
+}
+
+// unusedExempt reports objects that always look unused but are created and
+// consumed by Kubernetes or Helm themselves.
+func unusedExempt(n *Node) bool {
+	switch n.Kind {
+	case "ConfigMap":
+		return n.Name == "kube-root-ca.crt"
+	case "ServiceAccount":
+		return n.Name == "default"
+	case "Secret":
+		secretType, _ := n.Object.Object["type"].(string)
+		return secretType == "kubernetes.io/service-account-token" || secretType == "helm.sh/release.v1"
+	case "ClusterRole":
+		_, aggregated := n.Object.Object["aggregationRule"]
+		return aggregated || strings.HasPrefix(n.Name, "system:")
+	}
+	return false
+}
+
+// UnusedOptions describes how the graph given to FindUnusedWith was read.
+type UnusedOptions struct {
+	// NamespaceScoped marks a graph read from a single namespace of a
+	// cluster. ClusterRoleBindings are not read then, so the reason of every
+	// reported ServiceAccount warns that one may still bind it.
+	NamespaceScoped bool
+}
+
+// namespaceScopedCaveat is appended to ServiceAccount reasons for
+// namespace-scoped graphs.
+const namespaceScopedCaveat = "; ClusterRoleBindings were not read in this namespace-scoped cluster run, so check them before deleting it"
+
+// FindUnused returns the ConfigMaps, Secrets, PersistentVolumeClaims,
+// ServiceAccounts, Roles and ClusterRoles in the input that no other resource
+// references, sorted by ID. Owner references do not count as a use. Objects
+// Kubernetes manages itself (the "default" ServiceAccount, kube-root-ca.crt,
+// service account token and Helm release Secrets, system: and aggregated
+// ClusterRoles) are never reported.
+//
+// Only references from objects in the graph count, so the graph should hold
+// every workload: objects dropped by kind exclusions (bare Pods in
+// particular) can make the resources they use look unused.
+func FindUnused(g *Graph) []UnusedResource {
+	return FindUnusedWith(g, UnusedOptions{})
+}
+
+// FindUnusedWith is FindUnused for a graph read as described by opts.
+func FindUnusedWith(g *Graph, opts UnusedOptions) []UnusedResource {
+	var unused []UnusedResource
+	for _, n := range g.Nodes() {
+		checked, ok := unusedKinds[n.Kind]
+		if !ok || n.Missing() || !IsBuiltinGroup(n.Kind, n.Group) || unusedExempt(n) {
+			continue
+		}
+
+		var owners []string
+		used := false
+		for _, e := range g.InEdges(n.ID) {
+			if e.Reason == "ownerRef" {
+				owners = append(owners, e.ParentID)
+				continue
+			}
+			used = true
+			break
+		}
+		if used {
+			continue
+		}
+
+		reason := "not referenced: " + checked
+		if len(owners) > 0 {
+			reason = fmt.Sprintf("%s (owned by %s, which does not count as a use)", reason, strings.Join(owners, ", "))
+		}
+		if opts.NamespaceScoped && n.Kind == "ServiceAccount" {
+			reason += namespaceScopedCaveat
+		}
+		unused = append(unused, UnusedResource{
+			ID:        n.ID,
+			Kind:      n.Kind,
+			Namespace: n.Namespace,
+			Name:      n.Name,
+			Reason:    reason,
+		})
+	}
+	return unused
+}
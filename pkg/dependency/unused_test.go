@@ -0,0 +1,349 @@
+package dependency_test
+
+import (
+	"testing"
+
+	"github.com/HMetcalfeW/cartographer/pkg/dependency"
+	"github.com/HMetcalfeW/cartographer/pkg/parser"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+const unusedManifests = `
+apiVersion: apps/v1
+kind: Deployment
+metadata:
+  name: web
+  namespace: prod
+spec:
+  template:
+    spec:
+      serviceAccountName: web-sa
+      volumes:
+        - name: data
+          persistentVolumeClaim:
+            claimName: data
+      containers:
+        - name: web
+          image: nginx
+          envFrom:
+            - configMapRef:
+                name: web-config
+---
+apiVersion: v1
+kind: ConfigMap
+metadata:
+  name: web-config
+  namespace: prod
+---
+apiVersion: v1
+kind: ConfigMap
+metadata:
+  name: stale-config
+  namespace: prod
+---
+apiVersion: v1
+kind: ConfigMap
+metadata:
+  name: kube-root-ca.crt
+  namespace: prod
+---
+apiVersion: v1
+kind: ConfigMap
+metadata:
+  name: owned-config
+  namespace: prod
+  ownerReferences:
+    - apiVersion: apps/v1
+      kind: Deployment
+      name: web
+---
+apiVersion: v1
+kind: Secret
+metadata:
+  name: old-token
+  namespace: prod
+---
+apiVersion: v1
+kind: Secret
+metadata:
+  name: sh.helm.release.v1.web.v1
+  namespace: prod
+type: helm.sh/release.v1
+---
+apiVersion: v1
+kind: Secret
+metadata:
+  name: web-tls
+  namespace: prod
+---
+apiVersion: networking.k8s.io/v1
+kind: Ingress
+metadata:
+  name: web
+  namespace: prod
+spec:
+  tls:
+    - secretName: web-tls
+---
+apiVersion: v1
+kind: PersistentVolumeClaim
+metadata:
+  name: data
+  namespace: prod
+---
+apiVersion: v1
+kind: PersistentVolumeClaim
+metadata:
+  name: scratch
+  namespace: prod
+---
+apiVersion: v1
+kind: ServiceAccount
+metadata:
+  name: web-sa
+  namespace: prod
+---
+apiVersion: v1
+kind: ServiceAccount
+metadata:
+  name: default
+  namespace: prod
+---
+apiVersion: v1
+kind: ServiceAccount
+metadata:
+  name: ci-bot
+  namespace: prod
+---
+apiVersion: rbac.authorization.k8s.io/v1
+kind: Role
+metadata:
+  name: reader
+  namespace: prod
+---
+apiVersion: rbac.authorization.k8s.io/v1
+kind: ClusterRole
+metadata:
+  name: system:auth-delegator
+`
+
+// TestFindUnused verifies only unreferenced config, storage and RBAC objects
+// are reported, with managed objects exempt.
+func TestFindUnused(t *testing.T) {
+	objs, err := parser.ParseYAML([]byte(unusedManifests))
+	require.NoError(t, err)
+
+	unused := dependency.FindUnused(dependency.BuildDependencies(objs))
+
+	var ids []string
+	reasons := make(map[string]string)
+	for _, u := range unused {
+		ids = append(ids, u.ID)
+		reasons[u.ID] = u.Reason
+	}
+	assert.Equal(t, []string{
+		"ConfigMap/prod/owned-config",
+		"ConfigMap/prod/stale-config",
+		"PersistentVolumeClaim/prod/scratch",
+		"Role/prod/reader",
+		"Secret/prod/old-token",
+		"ServiceAccount/prod/ci-bot",
+	}, ids)
+
+	assert.Contains(t, reasons["ConfigMap/prod/stale-config"], "no workload mounts it or reads it via env/envFrom")
+	assert.Contains(t, reasons["ConfigMap/prod/owned-config"], "owned by Deployment/prod/web")
+	assert.Contains(t, reasons["Role/prod/reader"], "no RoleBinding references it")
+
+	for _, u := range unused {
+		if u.ID == "Secret/prod/old-token" {
+			assert.Equal(t, "Secret", u.Kind)
+			assert.Equal(t, "prod", u.Namespace)
+			assert.Equal(t, "old-token", u.Name)
+		}
+	}
+}
+
+// TestFindUnused_Empty verifies an empty graph reports nothing.
+func TestFindUnused_Empty(t *testing.T) {
+	assert.Empty(t, dependency.FindUnused(dependency.NewGraph()))
+}
+
+// TestFindUnused_StatefulSetClaims verifies PVCs created from a StatefulSet's
+// volumeClaimTemplates count as used, while a PVC that only shares the
+// prefix does not.
+func TestFindUnused_StatefulSetClaims(t *testing.T) {
+	objs, err := parser.ParseYAML([]byte(`
+apiVersion: apps/v1
+kind: StatefulSet
+metadata:
+  name: db
+  namespace: prod
+spec:
+  volumeClaimTemplates:
+    - metadata:
+        name: data
+    - metadata:
+        name: logs
+---
+apiVersion: v1
+kind: PersistentVolumeClaim
+metadata:
+  name: data-db-0
+  namespace: prod
+---
+apiVersion: v1
+kind: PersistentVolumeClaim
+metadata:
+  name: data-db-12
+  namespace: prod
+---
+apiVersion: v1
+kind: PersistentVolumeClaim
+metadata:
+  name: data-db-backup
+  namespace: prod
+---
+apiVersion: v1
+kind: PersistentVolumeClaim
+metadata:
+  name: data-db-0
+  namespace: staging
+---
+apiVersion: v1
+kind: PersistentVolumeClaim
+metadata:
+  name: logs-db-0
+  namespace: prod
+`))
+	require.NoError(t, err)
+
+	g := dependency.BuildDependencies(objs)
+	var ids []string
+	for _, u := range dependency.FindUnused(g) {
+		ids = append(ids, u.ID)
+	}
+	assert.Equal(t, []string{
+		"PersistentVolumeClaim/prod/data-db-backup",
+		"PersistentVolumeClaim/staging/data-db-0",
+	}, ids)
+
+	// Claims are linked in input order, each to its own template.
+	edges := g.Edges("StatefulSet/prod/db")
+	require.Len(t, edges, 3)
+	assert.Equal(t, "volumeClaimTemplate", edges[0].Reason)
+	assert.Equal(t, "spec.volumeClaimTemplates[0]", edges[0].Field)
+	assert.Equal(t, "PersistentVolumeClaim/prod/logs-db-0", edges[2].ChildID)
+	assert.Equal(t, "spec.volumeClaimTemplates[1]", edges[2].Field)
+}
+
+// TestFindUnused_ServiceAccountSecrets verifies Secrets listed in a
+// ServiceAccount's secrets and imagePullSecrets count as used.
+func TestFindUnused_ServiceAccountSecrets(t *testing.T) {
+	objs, err := parser.ParseYAML([]byte(`
+apiVersion: v1
+kind: ServiceAccount
+metadata:
+  name: default
+  namespace: prod
+secrets:
+  - name: deploy-key
+imagePullSecrets:
+  - name: registry
+---
+apiVersion: v1
+kind: Secret
+metadata:
+  name: deploy-key
+  namespace: prod
+---
+apiVersion: v1
+kind: Secret
+metadata:
+  name: registry
+  namespace: prod
+---
+apiVersion: v1
+kind: Secret
+metadata:
+  name: stale
+  namespace: prod
+`))
+	require.NoError(t, err)
+
+	unused := dependency.FindUnused(dependency.BuildDependencies(objs))
+	require.Len(t, unused, 1)
+	assert.Equal(t, "Secret/prod/stale", unused[0].ID)
+}
+
+// TestFindUnused_VolumeSources verifies ConfigMaps and Secrets used through
+// projected volumes and CSI node publish secrets count as used.
+func TestFindUnused_VolumeSources(t *testing.T) {
+	objs, err := parser.ParseYAML([]byte(`
+apiVersion: apps/v1
+kind: Deployment
+metadata:
+  name: web
+  namespace: prod
+spec:
+  template:
+    spec:
+      volumes:
+        - name: bundle
+          projected:
+            sources:
+              - configMap:
+                  name: ca-bundle
+              - secret:
+                  name: tls
+        - name: vault
+          csi:
+            driver: secrets-store.csi.k8s.io
+            nodePublishSecretRef:
+              name: csi-creds
+      containers:
+        - name: web
+          image: nginx
+---
+apiVersion: v1
+kind: ConfigMap
+metadata:
+  name: ca-bundle
+  namespace: prod
+---
+apiVersion: v1
+kind: Secret
+metadata:
+  name: tls
+  namespace: prod
+---
+apiVersion: v1
+kind: Secret
+metadata:
+  name: csi-creds
+  namespace: prod
+`))
+	require.NoError(t, err)
+
+	assert.Empty(t, dependency.FindUnused(dependency.BuildDependencies(objs)))
+}
+
+// TestFindUnusedWith_NamespaceScoped verifies that ServiceAccounts reported
+// from a namespace-scoped cluster graph carry a caveat about the
+// ClusterRoleBindings that were not read, and other kinds do not.
+func TestFindUnusedWith_NamespaceScoped(t *testing.T) {
+	objs, err := parser.ParseYAML([]byte(unusedManifests))
+	require.NoError(t, err)
+	g := dependency.BuildDependencies(objs)
+
+	for _, u := range dependency.FindUnusedWith(g, dependency.UnusedOptions{NamespaceScoped: true}) {
+		if u.Kind == "ServiceAccount" {
+			assert.Contains(t, u.Reason, "ClusterRoleBindings were not read")
+		} else {
+			assert.NotContains(t, u.Reason, "ClusterRoleBindings were not read")
+		}
+	}
+	for _, u := range dependency.FindUnused(g) {
+		assert.NotContains(t, u.Reason, "ClusterRoleBindings were not read")
+	}
+}
@@ -1,23 +1,75 @@
 package filter
 
 import (
-	"fmt"
 	"strings"
 
 	log "github.com/sirupsen/logrus"
 	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+
+	"github.com/HMetcalfeW/cartographer/pkg/dependency"
 )
 
+// Filter decides whether single objects are excluded, so callers decoding a
+// stream can drop objects as they arrive instead of collecting them first.
+type Filter struct {
+	kinds map[string]bool
+	names map[string]bool
+}
+
+// New returns a Filter excluding objects whose Kind matches any entry in
+// excludeKinds (case-insensitive) or whose metadata.name matches any entry in
+// excludeNames (exact match). A name entry of the form "namespace/name" only
+// matches objects in that namespace.
+func New(excludeKinds, excludeNames []string) *Filter {
+	f := &Filter{
+		kinds: make(map[string]bool, len(excludeKinds)),
+		names: make(map[string]bool, len(excludeNames)),
+	}
+	for _, k := range excludeKinds {
+		f.kinds[strings.ToLower(k)] = true
+	}
+	for _, n := range excludeNames {
+		f.names[n] = true
+	}
+	return f
+}
+
+// Empty reports whether the filter excludes nothing.
+func (f *Filter) Empty() bool {
+	return len(f.kinds) == 0 && len(f.names) == 0
+}
+
+// Excludes reports whether obj should be dropped.
+func (f *Filter) Excludes(obj *unstructured.Unstructured) bool {
+	reason := ""
+	switch {
+	case f.kinds[strings.ToLower(obj.GetKind())]:
+		reason = "kind"
+	case f.names[obj.GetName()] || f.names[obj.GetNamespace()+"/"+obj.GetName()]:
+		reason = "name"
+	default:
+		return false
+	}
+	log.WithFields(log.Fields{
+		"func":   "filter.Apply",
+		"id":     dependency.ResourceID(obj),
+		"reason": reason,
+	}).Debug("Excluded resource")
+	return true
+}
+
 // Apply removes objects whose Kind matches any entry in excludeKinds
 // (case-insensitive) or whose metadata.name matches any entry in
-// excludeNames (exact match). Returns the filtered slice.
+// excludeNames (exact match). A name entry of the form "namespace/name"
+// only matches objects in that namespace. Returns the filtered slice.
 // If both lists are empty, the input is returned unchanged.
 func Apply(
 	objs []*unstructured.Unstructured,
 	excludeKinds []string,
 	excludeNames []string,
 ) []*unstructured.Unstructured {
-	if len(excludeKinds) == 0 && len(excludeNames) == 0 {
+	f := New(excludeKinds, excludeNames)
+	if f.Empty() {
 		return objs
 	}
 
@@ -29,35 +81,11 @@ func Apply(
 	})
 	logger.Debug("Applying exclusion filters")
 
-	kindSet := make(map[string]bool, len(excludeKinds))
-	for _, k := range excludeKinds {
-		kindSet[strings.ToLower(k)] = true
-	}
-
-	nameSet := make(map[string]bool, len(excludeNames))
-	for _, n := range excludeNames {
-		nameSet[n] = true
-	}
-
 	result := make([]*unstructured.Unstructured, 0, len(objs))
 	for _, obj := range objs {
-		if kindSet[strings.ToLower(obj.GetKind())] {
-			log.WithFields(log.Fields{
-				"func":   "filter.Apply",
-				"id":     fmt.Sprintf("%s/%s", obj.GetKind(), obj.GetName()),
-				"reason": "kind",
-			}).Debug("Excluded resource")
-			continue
-		}
-		if nameSet[obj.GetName()] {
-			log.WithFields(log.Fields{
-				"func":   "filter.Apply",
-				"id":     fmt.Sprintf("%s/%s", obj.GetKind(), obj.GetName()),
-				"reason": "name",
-			}).Debug("Excluded resource")
-			continue
+		if !f.Excludes(obj) {
+			result = append(result, obj)
 		}
-		result = append(result, obj)
 	}
 
 	logger.WithFields(log.Fields{
@@ -9,13 +9,17 @@ import (
 )
 
 func makeObj(kind, name string) *unstructured.Unstructured {
+	return makeObjInNamespace(kind, "default", name)
+}
+
+func makeObjInNamespace(kind, namespace, name string) *unstructured.Unstructured {
 	return &unstructured.Unstructured{
 		Object: map[string]interface{}{
 			"apiVersion": "v1",
 			"kind":       kind,
 			"metadata": map[string]interface{}{
 				"name":      name,
-				"namespace": "default",
+				"namespace": namespace,
 			},
 		},
 	}
@@ -73,6 +77,16 @@ func TestApply(t *testing.T) {
 			excludeNames: []string{"nonexistent"},
 			wantCount:    2,
 		},
+		{
+			name: "namespace-qualified name only matches that namespace",
+			objs: []*unstructured.Unstructured{
+				makeObjInNamespace("ConfigMap", "prod", "cfg"),
+				makeObjInNamespace("ConfigMap", "staging", "cfg"),
+			},
+			excludeNames: []string{"prod/cfg"},
+			wantCount:    1,
+			wantKinds:    []string{"ConfigMap"},
+		},
 		{
 			name:         "empty input returns empty",
 			objs:         []*unstructured.Unstructured{},
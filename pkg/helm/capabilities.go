@@ -0,0 +1,65 @@
+package helm
+
+import (
+	"fmt"
+	"slices"
+
+	log "github.com/sirupsen/logrus"
+	"helm.sh/helm/v3/pkg/action"
+	"helm.sh/helm/v3/pkg/chartutil"
+	"k8s.io/client-go/discovery"
+)
+
+// Capabilities returns the .Capabilities to render with: base (Helm's
+// defaults if nil) with its Kubernetes version replaced by kubeVersion, if
+// set, and apiVersions added to its API versions, as `helm template
+// --kube-version --api-versions` does. API versions may be group versions
+// such as "monitoring.coreos.com/v1" or include a kind, such as
+// "monitoring.coreos.com/v1/ServiceMonitor".
+func Capabilities(base *chartutil.Capabilities, kubeVersion string, apiVersions []string) (*chartutil.Capabilities, error) {
+	if base == nil {
+		base = chartutil.DefaultCapabilities
+	}
+	caps := base.Copy()
+	caps.APIVersions = slices.Clone(caps.APIVersions)
+
+	if kubeVersion != "" {
+		kv, err := chartutil.ParseKubeVersion(kubeVersion)
+		if err != nil {
+			return nil, fmt.Errorf("cannot parse Kubernetes version %q: %w", kubeVersion, err)
+		}
+		caps.KubeVersion = *kv
+	}
+	caps.APIVersions = append(caps.APIVersions, apiVersions...)
+	return caps, nil
+}
+
+// DiscoverCapabilities reads the Kubernetes version and served API versions
+// of a cluster, like `helm install` does before rendering. API groups that
+// are registered but unavailable are left out rather than failing discovery.
+func DiscoverCapabilities(client discovery.DiscoveryInterface) (*chartutil.Capabilities, error) {
+	version, err := client.ServerVersion()
+	if err != nil {
+		return nil, fmt.Errorf("failed to get Kubernetes server version: %w", err)
+	}
+	apiVersions, err := action.GetVersionSet(client)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get Kubernetes API versions: %w", err)
+	}
+
+	caps := &chartutil.Capabilities{
+		KubeVersion: chartutil.KubeVersion{
+			Version: version.GitVersion,
+			Major:   version.Major,
+			Minor:   version.Minor,
+		},
+		APIVersions: apiVersions,
+		HelmVersion: chartutil.DefaultCapabilities.HelmVersion,
+	}
+	log.WithFields(log.Fields{
+		"func":        "DiscoverCapabilities",
+		"kubeVersion": caps.KubeVersion.Version,
+		"apiVersions": len(apiVersions),
+	}).Info("Discovered cluster capabilities")
+	return caps, nil
+}
@@ -0,0 +1,70 @@
+package helm_test
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/HMetcalfeW/cartographer/pkg/helm"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"helm.sh/helm/v3/pkg/chartutil"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/version"
+	fakediscovery "k8s.io/client-go/discovery/fake"
+	k8stesting "k8s.io/client-go/testing"
+)
+
+// TestCapabilities verifies that the Kubernetes version replaces the base one,
+// extra API versions are added, and the base is left untouched.
+func TestCapabilities(t *testing.T) {
+	defaults := chartutil.DefaultCapabilities.Copy()
+
+	caps, err := helm.Capabilities(nil, "1.31.2", []string{"monitoring.coreos.com/v1"})
+	require.NoError(t, err)
+	assert.Equal(t, chartutil.KubeVersion{Version: "v1.31.2", Major: "1", Minor: "31"}, caps.KubeVersion)
+	assert.True(t, caps.APIVersions.Has("monitoring.coreos.com/v1"))
+	assert.True(t, caps.APIVersions.Has("apps/v1"), "default API versions are kept")
+	assert.Equal(t, defaults, chartutil.DefaultCapabilities)
+
+	base := &chartutil.Capabilities{
+		KubeVersion: chartutil.KubeVersion{Version: "v1.29.0", Major: "1", Minor: "29"},
+		APIVersions: chartutil.VersionSet{"v1"},
+	}
+	caps, err = helm.Capabilities(base, "", []string{"cert-manager.io/v1"})
+	require.NoError(t, err)
+	assert.Equal(t, "v1.29.0", caps.KubeVersion.Version)
+	assert.Equal(t, chartutil.VersionSet{"v1", "cert-manager.io/v1"}, caps.APIVersions)
+	assert.Equal(t, chartutil.VersionSet{"v1"}, base.APIVersions)
+
+	_, err = helm.Capabilities(nil, "latest", nil)
+	assert.ErrorContains(t, err, `cannot parse Kubernetes version "latest"`)
+}
+
+// TestDiscoverCapabilities verifies that the server version and the group
+// versions and kinds it serves become the capabilities.
+func TestDiscoverCapabilities(t *testing.T) {
+	client := &fakediscovery.FakeDiscovery{
+		Fake: &k8stesting.Fake{Resources: []*metav1.APIResourceList{
+			{GroupVersion: "v1", APIResources: []metav1.APIResource{{Name: "pods", Kind: "Pod"}}},
+			{GroupVersion: "monitoring.coreos.com/v1", APIResources: []metav1.APIResource{{Name: "servicemonitors", Kind: "ServiceMonitor"}}},
+		}},
+		FakedServerVersion: &version.Info{GitVersion: "v1.30.4", Major: "1", Minor: "30"},
+	}
+
+	caps, err := helm.DiscoverCapabilities(client)
+	require.NoError(t, err)
+	assert.Equal(t, chartutil.KubeVersion{Version: "v1.30.4", Major: "1", Minor: "30"}, caps.KubeVersion)
+	assert.ElementsMatch(t, chartutil.VersionSet{
+		"v1", "v1/Pod",
+		"monitoring.coreos.com/v1",
+		"monitoring.coreos.com/v1/ServiceMonitor",
+	}, caps.APIVersions)
+	assert.Equal(t, chartutil.DefaultCapabilities.HelmVersion, caps.HelmVersion)
+
+	client.PrependReactor("get", "resource", func(k8stesting.Action) (bool, runtime.Object, error) {
+		return true, nil, errors.New("connection refused")
+	})
+	_, err = helm.DiscoverCapabilities(client)
+	assert.ErrorContains(t, err, "failed to get Kubernetes API versions")
+}
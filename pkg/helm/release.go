@@ -0,0 +1,89 @@
+package helm
+
+import (
+	"errors"
+	"fmt"
+	"slices"
+	"strings"
+
+	log "github.com/sirupsen/logrus"
+	"helm.sh/helm/v3/pkg/release"
+	"helm.sh/helm/v3/pkg/storage"
+	"helm.sh/helm/v3/pkg/storage/driver"
+	"k8s.io/client-go/kubernetes"
+)
+
+// ReleaseOptions configures how FetchRelease reads an installed release.
+type ReleaseOptions struct {
+	// Hooks selects how the release's hook resources are included; the zero
+	// value means HooksTag.
+	Hooks HookMode
+
+	// SkipTests drops test hooks.
+	SkipTests bool
+}
+
+// FetchRelease reads the latest deployed revision of the named release from
+// Helm's release storage in namespace and returns its manifest, like `helm
+// get manifest`, followed by its hooks as opts asks, and the hooks it kept
+// when opts.Hooks is HooksTag. Each document keeps the
+// "# Source:" comment naming its template. Releases stored by the Secret
+// driver (Helm's default) are looked up first, then those stored by the
+// ConfigMap driver.
+//
+// The manifest is what Helm last applied, so it reflects upgrades and
+// values used at install time rather than what the chart renders today.
+func FetchRelease(client kubernetes.Interface, namespace, name string, opts ReleaseOptions) (string, Hooks, error) {
+	logger := log.WithFields(log.Fields{
+		"func":      "FetchRelease",
+		"release":   name,
+		"namespace": namespace,
+	})
+
+	drivers := []driver.Driver{
+		driver.NewSecrets(client.CoreV1().Secrets(namespace)),
+		driver.NewConfigMaps(client.CoreV1().ConfigMaps(namespace)),
+	}
+	var rel *release.Release
+	for _, d := range drivers {
+		found, err := storage.Init(d).Deployed(name)
+		if err == nil {
+			rel = found
+			logger = logger.WithFields(log.Fields{"driver": d.Name(), "revision": rel.Version})
+			break
+		}
+		if !errors.Is(err, driver.ErrReleaseNotFound) && !errors.Is(err, driver.ErrNoDeployedReleases) {
+			return "", nil, fmt.Errorf("failed to read release %q from %s storage: %w", name, d.Name(), err)
+		}
+	}
+	if rel == nil {
+		return "", nil, fmt.Errorf("no deployed revision of release %q found in namespace %q", name, namespace)
+	}
+
+	var combined strings.Builder
+	combined.WriteString(rel.Manifest)
+	combined.WriteString("\n")
+
+	var tagged Hooks
+	tests := 0
+	for _, h := range rel.Hooks {
+		hook := Hook{Template: h.Path, Kind: h.Kind, Name: h.Name, Weight: h.Weight}
+		for _, e := range h.Events {
+			hook.Events = append(hook.Events, string(e))
+		}
+		if opts.SkipTests && slices.Contains(hook.Events, string(release.HookTest)) {
+			tests++
+			continue
+		}
+		if tagged.add(hook, opts.Hooks) {
+			fmt.Fprintf(&combined, "---\n# Source: %s\n%s\n", h.Path, h.Manifest)
+		}
+	}
+
+	logger.WithFields(log.Fields{
+		"hooks":        len(rel.Hooks),
+		"hookMode":     opts.Hooks,
+		"testsSkipped": tests,
+	}).Info("Read installed Helm release")
+	return combined.String(), tagged, nil
+}
@@ -0,0 +1,136 @@
+package helm_test
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+
+	"github.com/HMetcalfeW/cartographer/pkg/helm"
+	"github.com/HMetcalfeW/cartographer/pkg/parser"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"helm.sh/helm/v3/pkg/release"
+	"helm.sh/helm/v3/pkg/storage/driver"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/client-go/kubernetes/fake"
+)
+
+// storeRelease writes a revision of the web release to d as Helm would.
+func storeRelease(t *testing.T, d driver.Driver, version int, status release.Status, manifest string, hooks ...*release.Hook) {
+	t.Helper()
+	rel := &release.Release{
+		Name:      "web",
+		Namespace: "prod",
+		Version:   version,
+		Info:      &release.Info{Status: status},
+		Manifest:  manifest,
+		Hooks:     hooks,
+	}
+	require.NoError(t, d.Create(fmt.Sprintf("sh.helm.release.v1.web.v%d", version), rel))
+}
+
+const releaseManifest = `---
+# Source: web/templates/deployment.yaml
+apiVersion: apps/v1
+kind: Deployment
+metadata:
+  name: web
+  namespace: prod
+---
+# Source: web/templates/secret.yaml
+apiVersion: v1
+kind: Secret
+metadata:
+  name: db
+  namespace: prod
+`
+
+var testHook = &release.Hook{
+	Name:     "web-test",
+	Kind:     "Job",
+	Path:     "web/templates/tests/test.yaml",
+	Manifest: "apiVersion: batch/v1\nkind: Job\nmetadata:\n  name: web-test\n  namespace: prod\n  annotations:\n    helm.sh/hook: test\n",
+	Events:   []release.HookEvent{release.HookTest},
+}
+
+var migrateHook = &release.Hook{
+	Name:     "migrate",
+	Kind:     "Job",
+	Path:     "web/templates/migrate.yaml",
+	Manifest: "apiVersion: batch/v1\nkind: Job\nmetadata:\n  name: migrate\n  namespace: prod\n  annotations:\n    helm.sh/hook: pre-upgrade\n    helm.sh/hook-weight: \"-1\"\n",
+	Events:   []release.HookEvent{release.HookPreUpgrade},
+	Weight:   -1,
+}
+
+// TestFetchRelease_Secret verifies that the latest deployed revision is read
+// from Secret storage with its template sources and tagged hooks.
+func TestFetchRelease_Secret(t *testing.T) {
+	client := fake.NewClientset()
+	secrets := driver.NewSecrets(client.CoreV1().Secrets("prod"))
+	storeRelease(t, secrets, 1, release.StatusSuperseded, "---\n# Source: web/templates/old.yaml\napiVersion: v1\nkind: ConfigMap\nmetadata:\n  name: old\n")
+	storeRelease(t, secrets, 2, release.StatusDeployed, releaseManifest, migrateHook, testHook)
+	storeRelease(t, secrets, 3, release.StatusFailed, "---\n# Source: web/templates/new.yaml\napiVersion: v1\nkind: ConfigMap\nmetadata:\n  name: new\n")
+
+	manifest, hooks, err := helm.FetchRelease(client, "prod", "web", helm.ReleaseOptions{SkipTests: true})
+	require.NoError(t, err)
+
+	var objs []*unstructured.Unstructured
+	var got []string
+	err = parser.Decode(strings.NewReader(manifest), parser.Options{}, func(obj *unstructured.Unstructured, src parser.Source) error {
+		objs = append(objs, obj)
+		got = append(got, obj.GetKind()+"/"+obj.GetName()+" "+src.Template)
+		return nil
+	})
+	require.NoError(t, err)
+	assert.Equal(t, []string{
+		"Deployment/web web/templates/deployment.yaml",
+		"Secret/db web/templates/secret.yaml",
+		"Job/migrate web/templates/migrate.yaml",
+	}, got)
+	assert.Equal(t, helm.Hooks{{
+		Template: "web/templates/migrate.yaml",
+		Kind:     "Job",
+		Name:     "migrate",
+		Events:   []string{"pre-upgrade"},
+		Weight:   -1,
+	}}, hooks)
+	hook, ok := hooks.Lookup("web/templates/migrate.yaml", objs[2])
+	require.True(t, ok)
+	assert.Equal(t, []string{"pre-upgrade"}, hook.Events)
+	_, ok = hooks.Lookup("web/templates/deployment.yaml", objs[0])
+	assert.False(t, ok)
+}
+
+// TestFetchRelease_ConfigMap verifies that releases stored by the ConfigMap
+// driver are found, and that hooks can be kept untagged or dropped.
+func TestFetchRelease_ConfigMap(t *testing.T) {
+	client := fake.NewClientset()
+	storeRelease(t, driver.NewConfigMaps(client.CoreV1().ConfigMaps("prod")), 1, release.StatusDeployed, releaseManifest, migrateHook, testHook)
+
+	manifest, hooks, err := helm.FetchRelease(client, "prod", "web", helm.ReleaseOptions{Hooks: helm.HooksInclude})
+	require.NoError(t, err)
+	objs, err := parser.ParseYAML([]byte(manifest))
+	require.NoError(t, err)
+	require.Len(t, objs, 4)
+	assert.Empty(t, hooks)
+
+	manifest, hooks, err = helm.FetchRelease(client, "prod", "web", helm.ReleaseOptions{Hooks: helm.HooksExclude})
+	require.NoError(t, err)
+	objs, err = parser.ParseYAML([]byte(manifest))
+	require.NoError(t, err)
+	assert.Len(t, objs, 2)
+	assert.Empty(t, hooks)
+}
+
+// TestFetchRelease_NotFound verifies that a release without a deployed
+// revision, or in another namespace, is reported.
+func TestFetchRelease_NotFound(t *testing.T) {
+	client := fake.NewClientset()
+	storeRelease(t, driver.NewSecrets(client.CoreV1().Secrets("prod")), 1, release.StatusFailed, releaseManifest)
+
+	_, _, err := helm.FetchRelease(client, "prod", "web", helm.ReleaseOptions{})
+	assert.EqualError(t, err, `no deployed revision of release "web" found in namespace "prod"`)
+
+	_, _, err = helm.FetchRelease(client, "staging", "web", helm.ReleaseOptions{})
+	assert.EqualError(t, err, `no deployed revision of release "web" found in namespace "staging"`)
+}
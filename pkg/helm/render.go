@@ -4,6 +4,9 @@ import (
 	"fmt"
 	"os"
 	"path/filepath"
+	"slices"
+	"sort"
+	"strconv"
 	"strings"
 
 	log "github.com/sirupsen/logrus"
@@ -12,17 +15,125 @@ import (
 	"helm.sh/helm/v3/pkg/chart/loader"
 	"helm.sh/helm/v3/pkg/chartutil"
 	"helm.sh/helm/v3/pkg/cli"
+	"helm.sh/helm/v3/pkg/cli/values"
 	"helm.sh/helm/v3/pkg/downloader"
 	"helm.sh/helm/v3/pkg/engine"
 	"helm.sh/helm/v3/pkg/getter"
 	"helm.sh/helm/v3/pkg/registry"
+	"helm.sh/helm/v3/pkg/release"
+	"helm.sh/helm/v3/pkg/releaseutil"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
 	"sigs.k8s.io/yaml"
 )
 
+// RenderOptions configures how RenderChart locates and renders a chart.
+type RenderOptions struct {
+	// ReleaseName and Namespace populate .Release in the templates.
+	ReleaseName string
+	Namespace   string
+
+	// Version is the chart version to pull; empty means the latest.
+	Version string
+
+	// RepoURL is the URL of a chart repository to find the chart in by name,
+	// like `helm template --repo`, without adding it to the Helm repo config.
+	RepoURL string
+
+	// Values holds the values files and --set style overrides, merged with
+	// the same precedence as `helm template`: files in order, then
+	// --set-json, --set, --set-string, --set-file and --set-literal, all on
+	// top of the chart's own values.
+	Values values.Options
+
+	// Hooks selects how resources annotated with helm.sh/hook are rendered;
+	// the zero value means HooksTag.
+	Hooks HookMode
+
+	// SkipTests drops test hooks, like `helm template --skip-tests`.
+	SkipTests bool
+
+	// IncludeCRDs renders the CRDs in the crds/ directories of the chart and
+	// its subcharts ahead of the templates, like `helm template --include-crds`.
+	IncludeCRDs bool
+
+	// Capabilities populates .Capabilities in the templates; nil means Helm's
+	// library defaults. When set, its Kubernetes version must also satisfy
+	// the chart's kubeVersion constraint, as on install. See Capabilities and
+	// DiscoverCapabilities.
+	Capabilities *chartutil.Capabilities
+}
+
+// HookMode selects how Helm hook resources are rendered.
+type HookMode string
+
+const (
+	// HooksTag keeps hooks and reports each one's events and weight
+	// alongside the manifest (see Hooks), so they can become hook nodes.
+	HooksTag HookMode = "tag"
+
+	// HooksInclude keeps hooks as ordinary resources, as `helm template` does.
+	HooksInclude HookMode = "include"
+
+	// HooksExclude drops hooks, like `helm template --no-hooks`.
+	HooksExclude HookMode = "exclude"
+)
+
+// ParseHookMode validates a --hooks flag value.
+func ParseHookMode(s string) (HookMode, error) {
+	switch m := HookMode(s); m {
+	case HooksTag, HooksInclude, HooksExclude:
+		return m, nil
+	}
+	return "", fmt.Errorf("unsupported hook mode %q; use tag, include or exclude", s)
+}
+
+// Hook is a Helm hook resource kept in a rendered manifest with HooksTag.
+type Hook struct {
+	// Template is the path in the "# Source:" comment above its document.
+	Template string
+
+	// Kind and Name identify the resource within its template.
+	Kind string
+	Name string
+
+	// Events are the hook events it runs on, e.g. "pre-install" or "test".
+	Events []string
+
+	// Weight orders hooks of the same event; lower weights run first.
+	Weight int
+}
+
+// Hooks lists the tagged hooks of a rendered manifest, which is otherwise
+// left exactly as Helm rendered it.
+type Hooks []Hook
+
+// Lookup returns the hook decoded from template as obj, if obj is one.
+func (hs Hooks) Lookup(template string, obj *unstructured.Unstructured) (Hook, bool) {
+	for _, h := range hs {
+		if h.Template == template && h.Kind == obj.GetKind() && h.Name == obj.GetName() {
+			return h, true
+		}
+	}
+	return Hook{}, false
+}
+
+// add records h as mode asks and reports whether its manifest is kept.
+func (hs *Hooks) add(h Hook, mode HookMode) bool {
+	switch mode {
+	case HooksExclude:
+		return false
+	case HooksInclude:
+		return true
+	}
+	*hs = append(*hs, h)
+	return true
+}
+
 // RenderChart pulls (or locates) a Helm chart, updates its dependencies if needed,
 // merges user-provided values, and renders the chart templates.
-// It returns a combined multi-document YAML string (only .yaml/.yml files).
-func RenderChart(chartRef, valuesFile, releaseName, version, namespace string) (string, error) {
+// It returns a combined multi-document YAML string (only .yaml/.yml files),
+// and the hooks in it when opts.Hooks is HooksTag.
+func RenderChart(chartRef string, opts RenderOptions) (string, Hooks, error) {
 	logger := log.WithFields(log.Fields{
 		"func":     "RenderChart",
 		"chartRef": chartRef,
@@ -30,37 +141,59 @@ func RenderChart(chartRef, valuesFile, releaseName, version, namespace string) (
 	logger.Info("Starting Helm chart render")
 
 	settings := cli.New()
-	if namespace != "" {
-		settings.SetNamespace(namespace)
+	if opts.Namespace != "" {
+		settings.SetNamespace(opts.Namespace)
 	}
 
 	// Resolve chartRef to a local path.
-	resolvedPath, err := resolveChartPath(chartRef, version, settings)
+	resolvedPath, err := resolveChartPath(chartRef, opts.Version, opts.RepoURL, settings)
 	if err != nil {
-		return "", err
+		return "", nil, err
 	}
 
 	// Load the chart from the resolved path.
 	ch, err := loader.Load(resolvedPath)
 	if err != nil {
-		return "", fmt.Errorf("failed to load chart: %w", err)
+		return "", nil, fmt.Errorf("failed to load chart: %w", err)
 	}
 
 	// Update chart dependencies if needed.
 	ch, err = updateDependencies(ch, resolvedPath, settings)
 	if err != nil {
-		return "", err
+		return "", nil, err
 	}
 
 	// Merge user values and render templates.
-	return renderTemplates(ch, valuesFile, releaseName, namespace)
+	userValues, err := opts.Values.MergeValues(getter.All(settings))
+	if err != nil {
+		return "", nil, fmt.Errorf("failed to merge values: %w", err)
+	}
+	return renderTemplates(ch, userValues, opts)
 }
 
 // resolveChartPath determines the local filesystem path for a chart reference.
-// It handles local paths, repo aliases, and OCI registries.
-func resolveChartPath(chartRef, version string, settings *cli.EnvSettings) (string, error) {
-	// Local directory or archive.
+// It handles local paths and file:// archives, OCI registries, chart names in
+// the repository at repoURL, chart archive URLs (e.g.
+// https://example.com/charts/app-1.0.0.tgz) and repo aliases. Charts fetched
+// over HTTP are downloaded with Helm's getters into its repository cache.
+func resolveChartPath(chartRef, version, repoURL string, settings *cli.EnvSettings) (string, error) {
+	// file:// archive or directory, as in Chart.yaml dependencies.
+	if path, ok := strings.CutPrefix(chartRef, "file://"); ok {
+		if !pathExists(path) {
+			return "", fmt.Errorf("failed to locate chart: %q not found", path)
+		}
+		return filepath.Abs(path)
+	}
+
+	// Local directory or archive, which Helm prefers over a repository.
 	if pathExists(chartRef) {
+		if repoURL != "" {
+			log.WithFields(log.Fields{
+				"func":  "resolveChartPath",
+				"chart": chartRef,
+				"repo":  repoURL,
+			}).Warn("Local chart found; repository URL ignored")
+		}
 		return filepath.Abs(chartRef)
 	}
 
@@ -69,9 +202,11 @@ func resolveChartPath(chartRef, version string, settings *cli.EnvSettings) (stri
 		return pullOCIChart(chartRef, version, settings)
 	}
 
-	// Local Helm repo alias (e.g. "bitnami/postgresql").
+	// Chart in a repository given by URL, chart archive URL, or local Helm
+	// repo alias (e.g. "bitnami/postgresql").
 	var cpo action.ChartPathOptions
 	cpo.Version = version
+	cpo.RepoURL = repoURL
 	resolved, err := cpo.LocateChart(chartRef, settings)
 	if err != nil {
 		return "", fmt.Errorf("failed to locate chart: %w", err)
@@ -157,30 +292,26 @@ func updateDependencies(ch *chart.Chart, chartPath string, settings *cli.EnvSett
 	return reloaded, nil
 }
 
-// renderTemplates merges values and renders chart templates, returning combined YAML.
-func renderTemplates(ch *chart.Chart, valuesFile, releaseName, namespace string) (string, error) {
-	userValues := map[string]interface{}{}
-	if valuesFile != "" {
-		data, err := os.ReadFile(valuesFile)
-		if err != nil {
-			return "", fmt.Errorf("failed to read values file: %w", err)
-		}
-		if err := yaml.Unmarshal(data, &userValues); err != nil {
-			return "", fmt.Errorf("failed to unmarshal values file: %w", err)
-		}
+// renderTemplates coalesces the user values with the chart's and renders
+// chart templates, returning combined YAML with hooks, tests and CRDs handled
+// as opts asks.
+func renderTemplates(ch *chart.Chart, userValues map[string]interface{}, opts RenderOptions) (string, Hooks, error) {
+	if caps := opts.Capabilities; caps != nil && ch.Metadata.KubeVersion != "" &&
+		!chartutil.IsCompatibleRange(ch.Metadata.KubeVersion, caps.KubeVersion.String()) {
+		return "", nil, fmt.Errorf("chart requires kubeVersion %s, which is incompatible with Kubernetes %s", ch.Metadata.KubeVersion, caps.KubeVersion.String())
 	}
 
 	coalesced, err := chartutil.CoalesceValues(ch, userValues)
 	if err != nil {
-		return "", fmt.Errorf("failed to coalesce values: %w", err)
+		return "", nil, fmt.Errorf("failed to coalesce values: %w", err)
 	}
 
 	renderVals, err := chartutil.ToRenderValues(ch, coalesced, chartutil.ReleaseOptions{
-		Name:      releaseName,
-		Namespace: namespace,
-	}, nil)
+		Name:      opts.ReleaseName,
+		Namespace: opts.Namespace,
+	}, opts.Capabilities)
 	if err != nil {
-		return "", fmt.Errorf("failed to prepare render values: %w", err)
+		return "", nil, fmt.Errorf("failed to prepare render values: %w", err)
 	}
 
 	// Filter out non-manifest templates (e.g. NOTES.txt).
@@ -195,18 +326,99 @@ func renderTemplates(ch *chart.Chart, valuesFile, releaseName, namespace string)
 
 	renderedFiles, err := engine.Render(ch, renderVals)
 	if err != nil {
-		return "", fmt.Errorf("failed to render chart templates: %w", err)
+		return "", nil, fmt.Errorf("failed to render chart templates: %w", err)
 	}
 
-	var combined strings.Builder
-	for fname, content := range renderedFiles {
+	// Templates are written in name order, each document preceded by a
+	// "# Source:" comment naming its template as `helm template` does.
+	names := make([]string, 0, len(renderedFiles))
+	for fname := range renderedFiles {
 		if strings.HasSuffix(fname, ".yaml") || strings.HasSuffix(fname, ".yml") {
-			combined.WriteString(content)
-			combined.WriteString("\n---\n")
+			names = append(names, fname)
+		}
+	}
+	sort.Strings(names)
+
+	var combined strings.Builder
+	if opts.IncludeCRDs {
+		for _, crd := range ch.CRDObjects() {
+			fmt.Fprintf(&combined, "---\n# Source: %s\n%s\n", crd.Filename, crd.File.Data)
+		}
+	}
+
+	var tagged Hooks
+	hooks, tests := 0, 0
+	for _, fname := range names {
+		docs := releaseutil.SplitManifests(renderedFiles[fname])
+		keys := make([]string, 0, len(docs))
+		for k := range docs {
+			keys = append(keys, k)
+		}
+		sort.Sort(releaseutil.BySplitManifestsOrder(keys))
+		for _, k := range keys {
+			doc := docs[k]
+			if strings.TrimSpace(doc) == "" {
+				continue
+			}
+			if hook, isHook := hookOf(doc); isHook {
+				hooks++
+				if opts.SkipTests && slices.Contains(hook.Events, string(release.HookTest)) {
+					tests++
+					continue
+				}
+				hook.Template = fname
+				if !tagged.add(hook, opts.Hooks) {
+					continue
+				}
+			}
+			fmt.Fprintf(&combined, "---\n# Source: %s\n%s\n", fname, doc)
 		}
 	}
 
-	return combined.String(), nil
+	log.WithFields(log.Fields{
+		"func":         "renderTemplates",
+		"hooks":        hooks,
+		"hookMode":     opts.Hooks,
+		"testsSkipped": tests,
+		"crds":         opts.IncludeCRDs,
+	}).Debug("Rendered chart templates")
+
+	return combined.String(), tagged, nil
+}
+
+// hookHead is the part of a manifest that identifies a Helm hook.
+type hookHead struct {
+	Kind     string `json:"kind"`
+	Metadata struct {
+		Name        string            `json:"name"`
+		Annotations map[string]string `json:"annotations"`
+	} `json:"metadata"`
+}
+
+// hookOf reports whether the manifest is a Helm hook, returning its kind,
+// name, events and weight. Helm 2's "test-success" event is reported as
+// "test".
+func hookOf(manifest string) (Hook, bool) {
+	var head hookHead
+	if err := yaml.Unmarshal([]byte(manifest), &head); err != nil {
+		return Hook{}, false
+	}
+	annotation, ok := head.Metadata.Annotations[release.HookAnnotation]
+	if !ok {
+		return Hook{}, false
+	}
+	h := Hook{Kind: head.Kind, Name: head.Metadata.Name}
+	for _, e := range strings.Split(annotation, ",") {
+		e = strings.TrimSpace(e)
+		if e == "test-success" {
+			e = string(release.HookTest)
+		}
+		if e != "" && !slices.Contains(h.Events, e) {
+			h.Events = append(h.Events, e)
+		}
+	}
+	h.Weight, _ = strconv.Atoi(strings.TrimSpace(head.Metadata.Annotations[release.HookWeightAnnotation]))
+	return h, true
 }
 
 func initActionConfig(settings *cli.EnvSettings) (*action.Configuration, error) {
@@ -1,13 +1,23 @@
 package helm_test
 
 import (
+	"net/http"
+	"net/http/httptest"
 	"os"
 	"path/filepath"
+	"strconv"
+	"strings"
 	"testing"
 
 	"github.com/HMetcalfeW/cartographer/pkg/helm"
+	"github.com/HMetcalfeW/cartographer/pkg/parser"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
+	"helm.sh/helm/v3/pkg/chart/loader"
+	"helm.sh/helm/v3/pkg/chartutil"
+	"helm.sh/helm/v3/pkg/cli/values"
+	"helm.sh/helm/v3/pkg/repo"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
 )
 
 // TestRenderChart_WithValues simulates a local Helm chart directory with a values file.
@@ -61,13 +71,11 @@ spec:
 	require.NoError(t, err, "failed to close values file")
 
 	// Call RenderChart with the local chart directory.
-	rendered, rErr := helm.RenderChart(
-		chartDir,          // chart path (local directory)
-		valuesFile.Name(), // values file
-		"test-release",    // release name
-		"",                // version empty
-		"default",         // namespace
-	)
+	rendered, _, rErr := helm.RenderChart(chartDir, helm.RenderOptions{
+		ReleaseName: "test-release",
+		Namespace:   "default",
+		Values:      values.Options{ValueFiles: []string{valuesFile.Name()}},
+	})
 	require.NoError(t, rErr, "RenderChart returned an error")
 	t.Logf("Rendered output:\n%s", rendered)
 	assert.Contains(t, rendered, "my-deployment", "rendered output should contain the name from values")
@@ -116,7 +124,7 @@ func TestRenderChart_Remote(t *testing.T) {
 
 	for _, tc := range tests {
 		t.Run(tc.name, func(t *testing.T) {
-			rendered, err := helm.RenderChart(tc.chartRef, "", "test-remote", tc.version, "default")
+			rendered, _, err := helm.RenderChart(tc.chartRef, helm.RenderOptions{ReleaseName: "test-remote", Namespace: "default", Version: tc.version})
 			if tc.expectError != "" {
 				require.Error(t, err)
 				assert.Contains(t, err.Error(), tc.expectError)
@@ -137,9 +145,14 @@ func TestRenderChart_BadValuesFile(t *testing.T) {
 	require.NoError(t, os.Mkdir(filepath.Join(chartDir, "templates"), 0755))
 	require.NoError(t, os.WriteFile(filepath.Join(chartDir, "templates", "cm.yaml"), []byte("apiVersion: v1\nkind: ConfigMap\nmetadata:\n  name: test\n"), 0644))
 
-	_, err = helm.RenderChart(chartDir, "/tmp/nonexistent-values-file.yaml", "test", "", "default")
+	_, _, err = helm.RenderChart(chartDir, helm.RenderOptions{
+		ReleaseName: "test",
+		Namespace:   "default",
+		Values:      values.Options{ValueFiles: []string{"/tmp/nonexistent-values-file.yaml"}},
+	})
 	require.Error(t, err)
-	assert.Contains(t, err.Error(), "failed to read values file")
+	assert.Contains(t, err.Error(), "failed to merge values")
+	assert.Contains(t, err.Error(), "nonexistent-values-file.yaml")
 }
 
 func TestRenderChart_NoNamespace(t *testing.T) {
@@ -151,7 +164,7 @@ func TestRenderChart_NoNamespace(t *testing.T) {
 	require.NoError(t, os.Mkdir(filepath.Join(chartDir, "templates"), 0755))
 	require.NoError(t, os.WriteFile(filepath.Join(chartDir, "templates", "cm.yaml"), []byte("apiVersion: v1\nkind: ConfigMap\nmetadata:\n  name: test\n"), 0644))
 
-	rendered, err := helm.RenderChart(chartDir, "", "test", "", "")
+	rendered, _, err := helm.RenderChart(chartDir, helm.RenderOptions{ReleaseName: "test"})
 	require.NoError(t, err)
 	assert.Contains(t, rendered, "ConfigMap")
 }
@@ -166,7 +179,7 @@ func TestRenderChart_NotesFilteredOut(t *testing.T) {
 	require.NoError(t, os.WriteFile(filepath.Join(chartDir, "templates", "cm.yaml"), []byte("apiVersion: v1\nkind: ConfigMap\nmetadata:\n  name: test\n"), 0644))
 	require.NoError(t, os.WriteFile(filepath.Join(chartDir, "templates", "NOTES.txt"), []byte("Thank you for installing!"), 0644))
 
-	rendered, err := helm.RenderChart(chartDir, "", "test", "", "default")
+	rendered, _, err := helm.RenderChart(chartDir, helm.RenderOptions{ReleaseName: "test", Namespace: "default"})
 	require.NoError(t, err)
 	assert.Contains(t, rendered, "ConfigMap")
 	assert.NotContains(t, rendered, "Thank you for installing")
@@ -178,7 +191,309 @@ func TestRenderChart_InvalidChartDir(t *testing.T) {
 	require.NoError(t, err)
 	defer func() { _ = os.RemoveAll(chartDir) }()
 
-	_, err = helm.RenderChart(chartDir, "", "test", "", "default")
+	_, _, err = helm.RenderChart(chartDir, helm.RenderOptions{ReleaseName: "test", Namespace: "default"})
 	require.Error(t, err)
 	assert.Contains(t, err.Error(), "failed to load chart")
 }
+
+// TestRenderChart_SourceComments verifies that templates are written in name
+// order with a "# Source:" comment before every document, so parsed objects
+// are attributed to their template.
+func TestRenderChart_SourceComments(t *testing.T) {
+	chartDir := t.TempDir()
+	require.NoError(t, os.WriteFile(filepath.Join(chartDir, "Chart.yaml"), []byte("apiVersion: v2\nname: testchart\nversion: 0.1.0\n"), 0644))
+	require.NoError(t, os.Mkdir(filepath.Join(chartDir, "templates"), 0755))
+	require.NoError(t, os.WriteFile(filepath.Join(chartDir, "templates", "b.yaml"), []byte("apiVersion: v1\nkind: ConfigMap\nmetadata:\n  name: b1\n---\napiVersion: v1\nkind: ConfigMap\nmetadata:\n  name: b2\n"), 0644))
+	require.NoError(t, os.WriteFile(filepath.Join(chartDir, "templates", "a.yaml"), []byte("apiVersion: v1\nkind: Secret\nmetadata:\n  name: a\n"), 0644))
+
+	rendered, _, err := helm.RenderChart(chartDir, helm.RenderOptions{ReleaseName: "test", Namespace: "default"})
+	require.NoError(t, err)
+
+	var got []string
+	err = parser.Decode(strings.NewReader(rendered), parser.Options{}, func(obj *unstructured.Unstructured, src parser.Source) error {
+		got = append(got, obj.GetName()+" "+src.Template)
+		return nil
+	})
+	require.NoError(t, err)
+	assert.Equal(t, []string{
+		"a testchart/templates/a.yaml",
+		"b1 testchart/templates/b.yaml",
+		"b2 testchart/templates/b.yaml",
+	}, got)
+}
+
+// TestRenderChart_Capabilities verifies that templates see the given
+// Kubernetes and API versions, and that the chart's kubeVersion constraint is
+// checked against them when they are given.
+func TestRenderChart_Capabilities(t *testing.T) {
+	chartDir := t.TempDir()
+	require.NoError(t, os.WriteFile(filepath.Join(chartDir, "Chart.yaml"), []byte("apiVersion: v2\nname: testchart\nversion: 0.1.0\nkubeVersion: \">=1.28.0-0\"\n"), 0644))
+	require.NoError(t, os.Mkdir(filepath.Join(chartDir, "templates"), 0755))
+	require.NoError(t, os.WriteFile(filepath.Join(chartDir, "templates", "capabilities.yaml"), []byte(`apiVersion: v1
+kind: ConfigMap
+metadata:
+  name: versions
+data:
+  kube: {{ .Capabilities.KubeVersion.Version }}
+{{- if .Capabilities.APIVersions.Has "monitoring.coreos.com/v1/ServiceMonitor" }}
+---
+apiVersion: monitoring.coreos.com/v1
+kind: ServiceMonitor
+metadata:
+  name: web
+{{- end }}
+`), 0644))
+
+	old, err := helm.Capabilities(nil, "1.27", nil)
+	require.NoError(t, err)
+	_, _, err = helm.RenderChart(chartDir, helm.RenderOptions{ReleaseName: "test", Namespace: "default", Capabilities: old})
+	assert.ErrorContains(t, err, "chart requires kubeVersion >=1.28.0-0, which is incompatible with Kubernetes v1.27")
+
+	caps, err := helm.Capabilities(nil, "v1.31.0", []string{"monitoring.coreos.com/v1/ServiceMonitor"})
+	require.NoError(t, err)
+	rendered, _, err := helm.RenderChart(chartDir, helm.RenderOptions{ReleaseName: "test", Namespace: "default", Capabilities: caps})
+	require.NoError(t, err)
+
+	objs, err := parser.ParseYAML([]byte(rendered))
+	require.NoError(t, err)
+	require.Len(t, objs, 2)
+	kube, _, _ := unstructured.NestedString(objs[0].Object, "data", "kube")
+	assert.Equal(t, "v1.31.0", kube)
+	assert.Equal(t, "ServiceMonitor", objs[1].GetKind())
+}
+
+// TestRenderChart_ValuesPrecedence verifies that values are merged like
+// `helm template`: chart defaults, then values files in order, then the
+// --set family, with --set-string, --set-file and --set-json keeping their
+// types.
+func TestRenderChart_ValuesPrecedence(t *testing.T) {
+	chartDir := t.TempDir()
+	require.NoError(t, os.WriteFile(filepath.Join(chartDir, "Chart.yaml"), []byte("apiVersion: v2\nname: testchart\nversion: 0.1.0\n"), 0644))
+	require.NoError(t, os.WriteFile(filepath.Join(chartDir, "values.yaml"), []byte("name: default\nenv: dev\nreplicas: 1\ntag: latest\n"), 0644))
+	require.NoError(t, os.Mkdir(filepath.Join(chartDir, "templates"), 0755))
+	require.NoError(t, os.WriteFile(filepath.Join(chartDir, "templates", "cm.yaml"), []byte(`apiVersion: v1
+kind: ConfigMap
+metadata:
+  name: {{ .Values.name }}
+data:
+  env: {{ .Values.env | quote }}
+  replicas: {{ .Values.replicas | quote }}
+  tag: {{ .Values.tag | quote }}
+  tagType: {{ kindOf .Values.tag | quote }}
+  config: {{ .Values.config | quote }}
+  limits: {{ .Values.resources.limits.cpu | quote }}
+`), 0644))
+
+	dir := t.TempDir()
+	base := filepath.Join(dir, "base.yaml")
+	prod := filepath.Join(dir, "prod.yaml")
+	conf := filepath.Join(dir, "app.conf")
+	require.NoError(t, os.WriteFile(base, []byte("name: base\nenv: staging\nreplicas: 2\n"), 0644))
+	require.NoError(t, os.WriteFile(prod, []byte("env: prod\n"), 0644))
+	require.NoError(t, os.WriteFile(conf, []byte("log=debug"), 0644))
+
+	rendered, _, err := helm.RenderChart(chartDir, helm.RenderOptions{
+		ReleaseName: "test",
+		Namespace:   "default",
+		Values: values.Options{
+			ValueFiles:   []string{base, prod},
+			Values:       []string{"replicas=3", "tag=1.10"},
+			StringValues: []string{"tag=1.10"},
+			FileValues:   []string{"config=" + conf},
+			JSONValues:   []string{`resources={"limits":{"cpu":"500m"}}`},
+		},
+	})
+	require.NoError(t, err)
+
+	objs, err := parser.ParseYAML([]byte(rendered))
+	require.NoError(t, err)
+	require.Len(t, objs, 1)
+	assert.Equal(t, "base", objs[0].GetName(), "first values file overrides the chart")
+	data, _, _ := unstructured.NestedStringMap(objs[0].Object, "data")
+	assert.Equal(t, map[string]string{
+		"env":      "prod",
+		"replicas": "3",
+		"tag":      "1.10",
+		"tagType":  "string",
+		"config":   "log=debug",
+		"limits":   "500m",
+	}, data)
+}
+
+// writeHookChart creates a chart with a Deployment, a pre-install Job hook,
+// a Helm 2 style test Pod and a CRD, and returns its directory.
+func writeHookChart(t *testing.T) string {
+	t.Helper()
+	chartDir := t.TempDir()
+	require.NoError(t, os.WriteFile(filepath.Join(chartDir, "Chart.yaml"), []byte("apiVersion: v2\nname: hooks\nversion: 0.1.0\n"), 0644))
+	require.NoError(t, os.MkdirAll(filepath.Join(chartDir, "templates", "tests"), 0755))
+	require.NoError(t, os.Mkdir(filepath.Join(chartDir, "crds"), 0755))
+	require.NoError(t, os.WriteFile(filepath.Join(chartDir, "templates", "deploy.yaml"), []byte(`apiVersion: apps/v1
+kind: Deployment
+metadata:
+  name: web
+`), 0644))
+	require.NoError(t, os.WriteFile(filepath.Join(chartDir, "templates", "migrate.yaml"), []byte(`apiVersion: batch/v1
+kind: Job
+metadata:
+  name: migrate
+  annotations:
+    helm.sh/hook: pre-install,pre-upgrade
+    helm.sh/hook-weight: "-5"
+`), 0644))
+	require.NoError(t, os.WriteFile(filepath.Join(chartDir, "templates", "tests", "test-connection.yaml"), []byte(`apiVersion: v1
+kind: Pod
+metadata:
+  name: test-connection
+  annotations:
+    helm.sh/hook: test-success
+`), 0644))
+	require.NoError(t, os.WriteFile(filepath.Join(chartDir, "crds", "widgets.yaml"), []byte(`apiVersion: apiextensions.k8s.io/v1
+kind: CustomResourceDefinition
+metadata:
+  name: widgets.example.com
+`), 0644))
+	return chartDir
+}
+
+func TestRenderChart_Hooks(t *testing.T) {
+	chartDir := writeHookChart(t)
+
+	tests := []struct {
+		name   string
+		opts   helm.RenderOptions
+		names  []string
+		tagged map[string]string
+	}{
+		{
+			name:   "tag by default",
+			opts:   helm.RenderOptions{},
+			names:  []string{"web", "migrate", "test-connection"},
+			tagged: map[string]string{"migrate": "pre-install,pre-upgrade/-5", "test-connection": "test/0"},
+		},
+		{
+			name:  "include",
+			opts:  helm.RenderOptions{Hooks: helm.HooksInclude},
+			names: []string{"web", "migrate", "test-connection"},
+		},
+		{
+			name:  "exclude",
+			opts:  helm.RenderOptions{Hooks: helm.HooksExclude},
+			names: []string{"web"},
+		},
+		{
+			name:   "skip tests",
+			opts:   helm.RenderOptions{SkipTests: true},
+			names:  []string{"web", "migrate"},
+			tagged: map[string]string{"migrate": "pre-install,pre-upgrade/-5"},
+		},
+		{
+			name:   "include CRDs",
+			opts:   helm.RenderOptions{Hooks: helm.HooksInclude, SkipTests: true, IncludeCRDs: true},
+			names:  []string{"widgets.example.com", "web", "migrate"},
+			tagged: map[string]string{},
+		},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			tc.opts.ReleaseName = "test"
+			rendered, hooks, err := helm.RenderChart(chartDir, tc.opts)
+			require.NoError(t, err)
+
+			var names []string
+			tagged := make(map[string]string)
+			err = parser.Decode(strings.NewReader(rendered), parser.Options{}, func(obj *unstructured.Unstructured, src parser.Source) error {
+				names = append(names, obj.GetName())
+				if h, ok := hooks.Lookup(src.Template, obj); ok {
+					tagged[obj.GetName()] = strings.Join(h.Events, ",") + "/" + strconv.Itoa(h.Weight)
+				}
+				return nil
+			})
+			require.NoError(t, err)
+			assert.Equal(t, tc.names, names)
+			assert.Len(t, hooks, len(tc.tagged))
+			assert.NotContains(t, rendered, "cartographer/", "hooks are reported alongside, not annotated")
+			if tc.tagged == nil {
+				tc.tagged = map[string]string{}
+			}
+			assert.Equal(t, tc.tagged, tagged)
+		})
+	}
+}
+
+func TestParseHookMode(t *testing.T) {
+	mode, err := helm.ParseHookMode("exclude")
+	require.NoError(t, err)
+	assert.Equal(t, helm.HooksExclude, mode)
+
+	_, err = helm.ParseHookMode("drop")
+	assert.ErrorContains(t, err, `unsupported hook mode "drop"`)
+}
+
+// serveChartRepo packages a one-template chart named app at version 0.1.0
+// into a chart repository served over HTTP, returning the server URL and the
+// path of the packaged archive. Helm's config and cache go to a temporary
+// directory so no repositories need to be added.
+func serveChartRepo(t *testing.T) (string, string) {
+	t.Helper()
+	home := t.TempDir()
+	t.Setenv("HELM_CONFIG_HOME", filepath.Join(home, "config"))
+	t.Setenv("HELM_CACHE_HOME", filepath.Join(home, "cache"))
+	t.Setenv("HELM_DATA_HOME", filepath.Join(home, "data"))
+
+	chartDir := t.TempDir()
+	require.NoError(t, os.WriteFile(filepath.Join(chartDir, "Chart.yaml"), []byte("apiVersion: v2\nname: app\nversion: 0.1.0\n"), 0644))
+	require.NoError(t, os.Mkdir(filepath.Join(chartDir, "templates"), 0755))
+	require.NoError(t, os.WriteFile(filepath.Join(chartDir, "templates", "cm.yaml"), []byte("apiVersion: v1\nkind: ConfigMap\nmetadata:\n  name: {{ .Release.Name }}-config\n"), 0644))
+	ch, err := loader.LoadDir(chartDir)
+	require.NoError(t, err)
+
+	repoDir := t.TempDir()
+	archive, err := chartutil.Save(ch, repoDir)
+	require.NoError(t, err)
+
+	srv := httptest.NewServer(http.FileServer(http.Dir(repoDir)))
+	t.Cleanup(srv.Close)
+
+	index, err := repo.IndexDirectory(repoDir, srv.URL)
+	require.NoError(t, err)
+	require.NoError(t, index.WriteFile(filepath.Join(repoDir, "index.yaml"), 0644))
+	return srv.URL, archive
+}
+
+// TestRenderChart_RepoURLAndArchives verifies that charts are found by name
+// in a repository given by URL, fetched from a chart archive URL, or read
+// from a file:// archive, all without a configured Helm repository.
+func TestRenderChart_RepoURLAndArchives(t *testing.T) {
+	repoURL, archive := serveChartRepo(t)
+
+	tests := []struct {
+		name     string
+		chartRef string
+		opts     helm.RenderOptions
+	}{
+		{name: "Repo", chartRef: "app", opts: helm.RenderOptions{RepoURL: repoURL}},
+		{name: "RepoVersion", chartRef: "app", opts: helm.RenderOptions{RepoURL: repoURL, Version: "0.1.0"}},
+		{name: "ArchiveURL", chartRef: repoURL + "/app-0.1.0.tgz"},
+		{name: "FileURL", chartRef: "file://" + archive},
+	}
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			tc.opts.ReleaseName, tc.opts.Namespace = "web", "default"
+			rendered, _, err := helm.RenderChart(tc.chartRef, tc.opts)
+			require.NoError(t, err)
+			assert.Contains(t, rendered, "# Source: app/templates/cm.yaml")
+			assert.Contains(t, rendered, "name: web-config")
+		})
+	}
+
+	_, _, err := helm.RenderChart("missing", helm.RenderOptions{RepoURL: repoURL})
+	assert.ErrorContains(t, err, `chart "missing" not found in `+repoURL)
+
+	_, _, err = helm.RenderChart("app", helm.RenderOptions{RepoURL: repoURL, Version: "9.9.9"})
+	assert.ErrorContains(t, err, `chart "app" version "9.9.9" not found`)
+
+	_, _, err = helm.RenderChart("file://"+filepath.Join(t.TempDir(), "app-0.1.0.tgz"), helm.RenderOptions{})
+	assert.ErrorContains(t, err, "failed to locate chart")
+}
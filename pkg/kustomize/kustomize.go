@@ -0,0 +1,82 @@
+// Package kustomize builds kustomizations in-process with the kustomize API,
+// the same library `kubectl kustomize` uses, so no kustomize binary is needed.
+package kustomize
+
+import (
+	"fmt"
+	"os"
+
+	log "github.com/sirupsen/logrus"
+	"sigs.k8s.io/kustomize/api/krusty"
+	"sigs.k8s.io/kustomize/api/types"
+	"sigs.k8s.io/kustomize/kyaml/filesys"
+)
+
+// Options configures Build.
+type Options struct {
+	// LoadRestrictions limits which files a kustomization may load. The zero
+	// value means types.LoadRestrictionsRootOnly, as in `kubectl kustomize`:
+	// files must be in or under the kustomization's directory, though bases
+	// and components may still be directories elsewhere.
+	// types.LoadRestrictionsNone also allows files anywhere on disk.
+	LoadRestrictions types.LoadRestrictions
+}
+
+// ParseLoadRestrictor validates a --load-restrictor flag value, which takes
+// the same values as `kubectl kustomize --load-restrictor`.
+func ParseLoadRestrictor(s string) (types.LoadRestrictions, error) {
+	switch s {
+	case types.LoadRestrictionsRootOnly.String():
+		return types.LoadRestrictionsRootOnly, nil
+	case types.LoadRestrictionsNone.String():
+		return types.LoadRestrictionsNone, nil
+	}
+	return types.LoadRestrictionsUnknown, fmt.Errorf("unsupported load restrictor %q; use %s or %s",
+		s, types.LoadRestrictionsRootOnly, types.LoadRestrictionsNone)
+}
+
+// Build runs the kustomization in dir and returns the resulting resources as
+// a multi-document YAML string, in the order kustomize emits them.
+//
+// Overlays, components and generators behave as in `kubectl kustomize`:
+// generated ConfigMaps and Secrets get their name-hash suffix and references
+// to them are rewritten. Nothing is fetched over the network: a kustomization
+// that references a remote base, component or file by URL fails before the
+// build with an error naming it, so vendor a copy of the base next to the
+// kustomization and reference it by relative path instead. Exec and container
+// plugins, and helmCharts, are disabled.
+func Build(dir string, opts Options) (string, error) {
+	logger := log.WithFields(log.Fields{
+		"func": "kustomize.Build",
+		"dir":  dir,
+	})
+
+	info, err := os.Stat(dir)
+	if err != nil {
+		return "", fmt.Errorf("failed to read kustomization: %w", err)
+	}
+	if !info.IsDir() {
+		return "", fmt.Errorf("kustomization path %q is not a directory", dir)
+	}
+	if err := checkRemote(dir, make(map[string]bool)); err != nil {
+		return "", fmt.Errorf("failed to build kustomization %q: %w", dir, err)
+	}
+
+	kopts := krusty.MakeDefaultOptions()
+	kopts.LoadRestrictions = types.LoadRestrictionsRootOnly
+	if opts.LoadRestrictions != types.LoadRestrictionsUnknown {
+		kopts.LoadRestrictions = opts.LoadRestrictions
+	}
+	resources, err := krusty.MakeKustomizer(kopts).Run(filesys.MakeFsOnDisk(), dir)
+	if err != nil {
+		return "", fmt.Errorf("failed to build kustomization %q: %w", dir, err)
+	}
+
+	out, err := resources.AsYaml()
+	if err != nil {
+		return "", fmt.Errorf("failed to encode kustomization output: %w", err)
+	}
+
+	logger.WithField("count", resources.Size()).Info("Built kustomization")
+	return string(out), nil
+}
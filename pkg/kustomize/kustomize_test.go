@@ -0,0 +1,207 @@
+package kustomize_test
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/HMetcalfeW/cartographer/pkg/kustomize"
+	"github.com/HMetcalfeW/cartographer/pkg/parser"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"sigs.k8s.io/kustomize/api/types"
+)
+
+// writeFiles creates files, keyed by slash-separated path relative to a new
+// temporary directory, and returns the directory.
+func writeFiles(t *testing.T, files map[string]string) string {
+	t.Helper()
+	dir := t.TempDir()
+	for name, content := range files {
+		path := filepath.Join(dir, filepath.FromSlash(name))
+		require.NoError(t, os.MkdirAll(filepath.Dir(path), 0o755))
+		require.NoError(t, os.WriteFile(path, []byte(content), 0o644))
+	}
+	return dir
+}
+
+// appFiles is a base with a configMapGenerator, a component adding a
+// generated Secret, and a prod overlay using both.
+var appFiles = map[string]string{
+	"base/kustomization.yaml": `resources:
+  - deployment.yaml
+configMapGenerator:
+  - name: web-config
+    literals:
+      - LOG_LEVEL=info
+`,
+	"base/deployment.yaml": `apiVersion: apps/v1
+kind: Deployment
+metadata:
+  name: web
+spec:
+  template:
+    spec:
+      containers:
+        - name: web
+          image: nginx
+          envFrom:
+            - configMapRef:
+                name: web-config
+            - secretRef:
+                name: web-secrets
+`,
+	"components/secrets/kustomization.yaml": `apiVersion: kustomize.config.k8s.io/v1alpha1
+kind: Component
+secretGenerator:
+  - name: web-secrets
+    literals:
+      - PASSWORD=hunter2
+`,
+	"overlays/prod/kustomization.yaml": `namespace: prod
+namePrefix: prod-
+resources:
+  - ../../base
+components:
+  - ../../components/secrets
+`,
+}
+
+func TestBuild_OverlayWithComponentAndGenerators(t *testing.T) {
+	dir := writeFiles(t, appFiles)
+
+	out, err := kustomize.Build(filepath.Join(dir, "overlays", "prod"), kustomize.Options{})
+	require.NoError(t, err)
+
+	objs, err := parser.ParseYAML([]byte(out))
+	require.NoError(t, err)
+	byKind := make(map[string]*unstructured.Unstructured)
+	for _, obj := range objs {
+		byKind[obj.GetKind()] = obj
+	}
+	require.Len(t, byKind, 3)
+
+	cm, secret, deploy := byKind["ConfigMap"], byKind["Secret"], byKind["Deployment"]
+	require.NotNil(t, cm)
+	require.NotNil(t, secret)
+	require.NotNil(t, deploy)
+	assert.True(t, strings.HasPrefix(cm.GetName(), "prod-web-config-"), "name-hash suffix: %s", cm.GetName())
+	assert.True(t, strings.HasPrefix(secret.GetName(), "prod-web-secrets-"), "name-hash suffix: %s", secret.GetName())
+	assert.Equal(t, "prod-web", deploy.GetName())
+	assert.Equal(t, "prod", deploy.GetNamespace())
+
+	// References in the Deployment are rewritten to the generated names.
+	containers, _, _ := unstructured.NestedSlice(deploy.Object, "spec", "template", "spec", "containers")
+	require.Len(t, containers, 1)
+	envFrom, _, _ := unstructured.NestedSlice(containers[0].(map[string]interface{}), "envFrom")
+	require.Len(t, envFrom, 2)
+	cmRef, _, _ := unstructured.NestedString(envFrom[0].(map[string]interface{}), "configMapRef", "name")
+	secretRef, _, _ := unstructured.NestedString(envFrom[1].(map[string]interface{}), "secretRef", "name")
+	assert.Equal(t, cm.GetName(), cmRef)
+	assert.Equal(t, secret.GetName(), secretRef)
+}
+
+func TestBuild_Errors(t *testing.T) {
+	dir := writeFiles(t, map[string]string{
+		"broken/kustomization.yaml": "resources:\n  - missing.yaml\n",
+		"file.yaml":                 "kind: ConfigMap\n",
+	})
+
+	_, err := kustomize.Build(filepath.Join(dir, "nonexistent"), kustomize.Options{})
+	assert.ErrorContains(t, err, "failed to read kustomization")
+
+	_, err = kustomize.Build(filepath.Join(dir, "file.yaml"), kustomize.Options{})
+	assert.ErrorContains(t, err, "is not a directory")
+
+	_, err = kustomize.Build(filepath.Join(dir, "broken"), kustomize.Options{})
+	assert.ErrorContains(t, err, "failed to build kustomization")
+}
+
+func TestBuild_LoadRestrictions(t *testing.T) {
+	// The overlay loads a patch file from outside its own directory; bases
+	// and components elsewhere are allowed either way.
+	files := map[string]string{
+		"shared/replicas.yaml": `apiVersion: apps/v1
+kind: Deployment
+metadata:
+  name: web
+spec:
+  replicas: 3
+`,
+		"overlays/prod/kustomization.yaml": `resources:
+  - ../../base
+patches:
+  - path: ../../shared/replicas.yaml
+`,
+	}
+	for name, content := range appFiles {
+		if strings.HasPrefix(name, "base/") {
+			files[name] = content
+		}
+	}
+	overlay := filepath.Join(writeFiles(t, files), "overlays", "prod")
+
+	_, err := kustomize.Build(overlay, kustomize.Options{})
+	assert.ErrorContains(t, err, "is not in or below")
+
+	_, err = kustomize.Build(overlay, kustomize.Options{LoadRestrictions: types.LoadRestrictionsRootOnly})
+	assert.ErrorContains(t, err, "is not in or below")
+
+	out, err := kustomize.Build(overlay, kustomize.Options{LoadRestrictions: types.LoadRestrictionsNone})
+	require.NoError(t, err)
+	assert.Contains(t, out, "replicas: 3")
+}
+
+func TestBuild_RemoteReferences(t *testing.T) {
+	remotes := map[string]string{
+		"https base":       "resources:\n  - https://github.com/org/repo//deploy?ref=v1.0.0\n",
+		"github base":      "resources:\n  - github.com/org/repo/deploy?ref=v1.0.0\n",
+		"scp base":         "resources:\n  - git@gitlab.com:org/repo.git//deploy\n",
+		"forced git":       "resources:\n  - git::https://gitlab.com/org/repo.git\n",
+		"remote file":      "resources:\n  - http://example.com/deployment.yaml\n",
+		"remote patch":     "resources:\n  - cm.yaml\npatches:\n  - path: https://example.com/patch.yaml\n",
+		"remote bases":     "bases:\n  - ssh://git@example.com/org/repo.git\n",
+		"remote component": "components:\n  - https://github.com/org/repo//components/tls\n",
+		"generator file":   "configMapGenerator:\n  - name: cfg\n    files:\n      - app.conf=https://example.com/app.conf\n",
+	}
+	for name, kustomization := range remotes {
+		t.Run(name, func(t *testing.T) {
+			dir := writeFiles(t, map[string]string{"kustomization.yaml": kustomization})
+			_, err := kustomize.Build(dir, kustomize.Options{LoadRestrictions: types.LoadRestrictionsNone})
+			require.Error(t, err)
+			assert.Contains(t, err.Error(), "references remote")
+			assert.Contains(t, err.Error(), "vendor a copy")
+		})
+	}
+
+	// A remote base in a local base is found too.
+	dir := writeFiles(t, map[string]string{
+		"base/kustomization.yaml":          "resources:\n  - github.com/org/repo/deploy\n",
+		"overlays/prod/kustomization.yaml": "resources:\n  - ../../base\n",
+	})
+	_, err := kustomize.Build(filepath.Join(dir, "overlays", "prod"), kustomize.Options{})
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), filepath.Join(dir, "base", "kustomization.yaml"))
+	assert.Contains(t, err.Error(), `references remote "github.com/org/repo/deploy"`)
+
+	// A file:// URL names a local repository, which is not fetched remotely.
+	dir = writeFiles(t, map[string]string{"kustomization.yaml": "resources:\n  - file:///nonexistent/repo//deploy\n"})
+	_, err = kustomize.Build(dir, kustomize.Options{LoadRestrictions: types.LoadRestrictionsNone})
+	require.Error(t, err)
+	assert.NotContains(t, err.Error(), "references remote")
+}
+
+func TestParseLoadRestrictor(t *testing.T) {
+	r, err := kustomize.ParseLoadRestrictor("LoadRestrictionsRootOnly")
+	require.NoError(t, err)
+	assert.Equal(t, types.LoadRestrictionsRootOnly, r)
+
+	r, err = kustomize.ParseLoadRestrictor("LoadRestrictionsNone")
+	require.NoError(t, err)
+	assert.Equal(t, types.LoadRestrictionsNone, r)
+
+	_, err = kustomize.ParseLoadRestrictor("none")
+	assert.ErrorContains(t, err, `unsupported load restrictor "none"`)
+}
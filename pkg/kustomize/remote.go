@@ -0,0 +1,139 @@
+package kustomize
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+
+	"sigs.k8s.io/kustomize/api/konfig"
+	"sigs.k8s.io/kustomize/api/types"
+)
+
+// gitUsername matches the user@ of an SCP-style git URL such as
+// git@gitlab.com:org/repo.git, which kustomize clones like any other URL.
+var gitUsername = regexp.MustCompile(`^[a-zA-Z][a-zA-Z0-9-]*@`)
+
+// isRemote reports whether the kustomize loader would fetch ref over the
+// network: a URL with a network scheme it clones or downloads, an SCP-style
+// git URL, or a github.com path. The rules follow kustomize's own repo URL
+// parsing; file:// URLs name local repositories and are allowed.
+func isRemote(ref string) bool {
+	lower := strings.ToLower(strings.TrimSpace(ref))
+	lower = strings.TrimPrefix(lower, "git::")
+	for _, scheme := range []string{"ssh://", "https://", "http://"} {
+		if strings.HasPrefix(lower, scheme) {
+			return true
+		}
+	}
+	return gitUsername.MatchString(lower) ||
+		strings.HasPrefix(lower, "github.com/") ||
+		strings.HasPrefix(lower, "github.com:")
+}
+
+// checkRemote walks the kustomization in dir and every local kustomization it
+// includes, and returns an error naming the first remote reference found.
+// Kustomizations that cannot be read are skipped; the build reports them.
+func checkRemote(dir string, seen map[string]bool) error {
+	abs, err := filepath.Abs(dir)
+	if err != nil || seen[abs] {
+		return nil
+	}
+	seen[abs] = true
+
+	k, file, ok := readKustomization(abs)
+	if !ok {
+		return nil
+	}
+	for _, ref := range references(k) {
+		if isRemote(ref) {
+			return fmt.Errorf("%s references remote %q; remote bases are not fetched, vendor a copy and reference it by relative path", file, ref)
+		}
+	}
+
+	// Only directory entries can hold further kustomizations.
+	var dirs []string
+	dirs = append(dirs, k.Resources...)
+	dirs = append(dirs, k.Components...)
+	dirs = append(dirs, k.Generators...)
+	dirs = append(dirs, k.Transformers...)
+	dirs = append(dirs, k.Validators...)
+	for _, ref := range dirs {
+		path := ref
+		if !filepath.IsAbs(path) {
+			path = filepath.Join(abs, path)
+		}
+		if info, err := os.Stat(path); err != nil || !info.IsDir() {
+			continue
+		}
+		if err := checkRemote(path, seen); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// readKustomization reads the kustomization file in dir, trying the names
+// kustomize recognizes, and returns it with its path.
+func readKustomization(dir string) (*types.Kustomization, string, bool) {
+	for _, name := range konfig.RecognizedKustomizationFileNames() {
+		file := filepath.Join(dir, name)
+		data, err := os.ReadFile(file)
+		if err != nil {
+			continue
+		}
+		k := &types.Kustomization{}
+		if err := k.Unmarshal(data); err != nil {
+			return nil, "", false
+		}
+		k.FixKustomization()
+		return k, file, true
+	}
+	return nil, "", false
+}
+
+// references returns every path in k that kustomize loads, directories and
+// files alike.
+func references(k *types.Kustomization) []string {
+	var refs []string
+	refs = append(refs, k.Resources...)
+	refs = append(refs, k.Components...)
+	refs = append(refs, k.Crds...)
+	refs = append(refs, k.Configurations...)
+	refs = append(refs, k.Generators...)
+	refs = append(refs, k.Transformers...)
+	refs = append(refs, k.Validators...)
+	for _, p := range k.Patches {
+		refs = append(refs, p.Path)
+	}
+	for _, p := range k.PatchesJson6902 {
+		refs = append(refs, p.Path)
+	}
+	for _, p := range k.PatchesStrategicMerge {
+		// An entry is either a path or an inline patch.
+		if !strings.Contains(string(p), "\n") {
+			refs = append(refs, string(p))
+		}
+	}
+	if path, ok := k.OpenAPI["path"]; ok {
+		refs = append(refs, path)
+	}
+	sources := func(kv types.KvPairSources) {
+		refs = append(refs, kv.EnvSources...)
+		for _, f := range kv.FileSources {
+			// Files are given as [key=]path.
+			if _, path, ok := strings.Cut(f, "="); ok && isRemote(path) {
+				f = path
+			}
+			refs = append(refs, f)
+		}
+	}
+	for _, g := range k.ConfigMapGenerator {
+		sources(g.KvPairSources)
+	}
+	for _, g := range k.SecretGenerator {
+		sources(g.KvPairSources)
+	}
+	return refs
+}
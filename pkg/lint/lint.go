@@ -0,0 +1,153 @@
+// Package lint checks a dependency graph for common manifest problems such as
+// dangling references and selectors that match nothing.
+package lint
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	log "github.com/sirupsen/logrus"
+
+	"github.com/HMetcalfeW/cartographer/pkg/dependency"
+)
+
+// Severity ranks how serious a finding is. SeverityOff disables a rule.
+type Severity int
+
+const (
+	SeverityOff Severity = iota
+	SeverityInfo
+	SeverityWarning
+	SeverityError
+)
+
+// String returns the lower-case name of the severity.
+func (s Severity) String() string {
+	switch s {
+	case SeverityOff:
+		return "off"
+	case SeverityInfo:
+		return "info"
+	case SeverityWarning:
+		return "warning"
+	case SeverityError:
+		return "error"
+	default:
+		return fmt.Sprintf("severity(%d)", int(s))
+	}
+}
+
+// MarshalText renders the severity by name, e.g. in JSON output.
+func (s Severity) MarshalText() ([]byte, error) {
+	return []byte(s.String()), nil
+}
+
+// ParseSeverity parses a severity name (case-insensitive). "warn" and "none"
+// are accepted as aliases for "warning" and "off".
+func ParseSeverity(name string) (Severity, error) {
+	switch strings.ToLower(strings.TrimSpace(name)) {
+	case "off", "none":
+		return SeverityOff, nil
+	case "info":
+		return SeverityInfo, nil
+	case "warning", "warn":
+		return SeverityWarning, nil
+	case "error":
+		return SeverityError, nil
+	default:
+		return SeverityOff, fmt.Errorf("unknown severity %q (want off, info, warning or error)", name)
+	}
+}
+
+// Finding is a single problem reported by a rule.
+//
+// Source is the "file:line" the resource was read from, when known; Run
+// fills it in from the graph so rules need not.
+type Finding struct {
+	Rule     string   `json:"rule"`
+	Severity Severity `json:"severity"`
+	Resource string   `json:"resource"`
+	Source   string   `json:"source,omitempty"`
+	Message  string   `json:"message"`
+}
+
+// Rule is a named check over the dependency graph.
+type Rule struct {
+	// Name identifies the rule in config and output, e.g. "dangling-reference".
+	Name string
+
+	// Description is a one-line summary of what the rule reports.
+	Description string
+
+	// DefaultSeverity applies unless the config overrides it.
+	DefaultSeverity Severity
+
+	// Check returns the rule's findings. Severity is filled in by Run.
+	Check func(g *dependency.Graph) []Finding
+}
+
+// Run evaluates every rule against the graph. severities overrides a rule's
+// default severity by name; rules set to SeverityOff are skipped. Unknown
+// names in severities are an error. Findings are sorted by resource, then
+// rule, then message.
+func Run(g *dependency.Graph, rules []Rule, severities map[string]Severity) ([]Finding, error) {
+	known := make(map[string]bool, len(rules))
+	for _, r := range rules {
+		known[r.Name] = true
+	}
+	for name := range severities {
+		if !known[name] {
+			return nil, fmt.Errorf("unknown lint rule %q", name)
+		}
+	}
+
+	var findings []Finding
+	for _, r := range rules {
+		sev := r.DefaultSeverity
+		if override, ok := severities[r.Name]; ok {
+			sev = override
+		}
+		if sev == SeverityOff {
+			continue
+		}
+		for _, f := range r.Check(g) {
+			f.Rule = r.Name
+			f.Severity = sev
+			if n, ok := g.Node(f.Resource); ok && f.Source == "" {
+				f.Source = n.Source.String()
+			}
+			findings = append(findings, f)
+		}
+	}
+
+	sort.SliceStable(findings, func(i, j int) bool {
+		a, b := findings[i], findings[j]
+		if a.Resource != b.Resource {
+			return a.Resource < b.Resource
+		}
+		if a.Rule != b.Rule {
+			return a.Rule < b.Rule
+		}
+		return a.Message < b.Message
+	})
+
+	log.WithFields(log.Fields{
+		"func":     "lint.Run",
+		"rules":    len(rules),
+		"findings": len(findings),
+	}).Debug("Lint complete")
+
+	return findings, nil
+}
+
+// CountAtLeast returns how many findings are at or above the threshold.
+func CountAtLeast(findings []Finding, threshold Severity) int {
+	n := 0
+	for _, f := range findings {
+		if f.Severity >= threshold {
+			n++
+		}
+	}
+	return n
+}
@@ -0,0 +1,330 @@
+package lint_test
+
+import (
+	"testing"
+
+	"github.com/HMetcalfeW/cartographer/pkg/dependency"
+	"github.com/HMetcalfeW/cartographer/pkg/lint"
+	"github.com/HMetcalfeW/cartographer/pkg/parser"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// brokenManifests exercises every built-in rule once, alongside resources
+// that should not be reported.
+const brokenManifests = `
+apiVersion: apps/v1
+kind: Deployment
+metadata:
+  name: web
+  labels:
+    app: web
+spec:
+  template:
+    spec:
+      serviceAccountName: web-sa
+      containers:
+        - name: web
+          image: nginx
+          envFrom:
+            - secretRef:
+                name: web-secrets
+---
+apiVersion: v1
+kind: ServiceAccount
+metadata:
+  name: web-sa
+---
+apiVersion: v1
+kind: Service
+metadata:
+  name: web
+spec:
+  selector:
+    app: web
+---
+apiVersion: v1
+kind: Service
+metadata:
+  name: typo
+spec:
+  selector:
+    app: wbe
+---
+apiVersion: v1
+kind: Service
+metadata:
+  name: external
+spec:
+  type: ExternalName
+  externalName: example.com
+---
+apiVersion: policy/v1
+kind: PodDisruptionBudget
+metadata:
+  name: api-pdb
+spec:
+  selector:
+    matchLabels:
+      app: api
+---
+apiVersion: networking.k8s.io/v1
+kind: NetworkPolicy
+metadata:
+  name: api-only
+spec:
+  podSelector:
+    matchLabels:
+      app: api
+---
+apiVersion: networking.k8s.io/v1
+kind: NetworkPolicy
+metadata:
+  name: all-pods
+spec:
+  podSelector: {}
+---
+apiVersion: autoscaling/v2
+kind: HorizontalPodAutoscaler
+metadata:
+  name: api-hpa
+spec:
+  scaleTargetRef:
+    apiVersion: apps/v1
+    kind: Deployment
+    name: api
+---
+apiVersion: rbac.authorization.k8s.io/v1
+kind: RoleBinding
+metadata:
+  name: reader
+roleRef:
+  apiGroup: rbac.authorization.k8s.io
+  kind: Role
+  name: reader
+subjects:
+  - kind: ServiceAccount
+    name: web-sa
+---
+apiVersion: rbac.authorization.k8s.io/v1
+kind: ClusterRoleBinding
+metadata:
+  name: web-view
+roleRef:
+  apiGroup: rbac.authorization.k8s.io
+  kind: ClusterRole
+  name: view
+subjects:
+  - kind: ServiceAccount
+    name: web-sa
+`
+
+func buildGraph(t *testing.T, manifests string) *dependency.Graph {
+	t.Helper()
+	objs, err := parser.ParseYAML([]byte(manifests))
+	require.NoError(t, err)
+	return dependency.BuildDependencies(objs)
+}
+
+// TestRunDefaultRules verifies each built-in rule reports exactly the
+// expected resources.
+func TestRunDefaultRules(t *testing.T) {
+	findings, err := lint.Run(buildGraph(t, brokenManifests), lint.DefaultRules(), nil)
+	require.NoError(t, err)
+
+	type key struct{ rule, resource string }
+	got := make(map[key]lint.Severity)
+	for _, f := range findings {
+		got[key{f.Rule, f.Resource}] = f.Severity
+	}
+
+	assert.Equal(t, map[key]lint.Severity{
+		{"dangling-reference", "Deployment/web"}:                      lint.SeverityError,
+		{"service-selector-no-match", "Service/typo"}:                 lint.SeverityWarning,
+		{"pdb-selector-no-match", "PodDisruptionBudget/api-pdb"}:      lint.SeverityWarning,
+		{"networkpolicy-selector-no-match", "NetworkPolicy/api-only"}: lint.SeverityWarning,
+		{"hpa-target-missing", "HorizontalPodAutoscaler/api-hpa"}:     lint.SeverityError,
+		{"rolebinding-role-missing", "RoleBinding/reader"}:            lint.SeverityError,
+	}, got)
+
+	// Findings are sorted by resource.
+	for i := 1; i < len(findings); i++ {
+		assert.LessOrEqual(t, findings[i-1].Resource, findings[i].Resource)
+	}
+}
+
+// TestRunSeverityOverrides verifies config overrides change or disable rules
+// and that unknown rule names are rejected.
+func TestRunSeverityOverrides(t *testing.T) {
+	g := buildGraph(t, brokenManifests)
+
+	findings, err := lint.Run(g, lint.DefaultRules(), map[string]lint.Severity{
+		"dangling-reference":        lint.SeverityOff,
+		"service-selector-no-match": lint.SeverityError,
+	})
+	require.NoError(t, err)
+	for _, f := range findings {
+		assert.NotEqual(t, "dangling-reference", f.Rule)
+		if f.Rule == "service-selector-no-match" {
+			assert.Equal(t, lint.SeverityError, f.Severity)
+		}
+	}
+	assert.Equal(t, 3, lint.CountAtLeast(findings, lint.SeverityError))
+	assert.Equal(t, 5, lint.CountAtLeast(findings, lint.SeverityWarning))
+
+	_, err = lint.Run(g, lint.DefaultRules(), map[string]lint.Severity{"no-such-rule": lint.SeverityError})
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "no-such-rule")
+}
+
+// TestRunCleanManifests verifies a healthy set of manifests has no findings.
+func TestRunCleanManifests(t *testing.T) {
+	findings, err := lint.Run(buildGraph(t, `
+apiVersion: v1
+kind: Secret
+metadata:
+  name: db
+---
+apiVersion: apps/v1
+kind: Deployment
+metadata:
+  name: api
+  labels:
+    app: api
+spec:
+  template:
+    spec:
+      containers:
+        - name: api
+          image: api
+          env:
+            - name: PASS
+              valueFrom:
+                secretKeyRef:
+                  name: db
+                  key: pass
+---
+apiVersion: v1
+kind: Service
+metadata:
+  name: api
+spec:
+  selector:
+    app: api
+`), lint.DefaultRules(), nil)
+	require.NoError(t, err)
+	assert.Empty(t, findings)
+}
+
+// TestRunOptionalReferences verifies that optional env, envFrom and volume
+// references to absent Secrets and ConfigMaps are not dangling, while a
+// required reference to the same Secret still is.
+func TestRunOptionalReferences(t *testing.T) {
+	manifests := `
+apiVersion: apps/v1
+kind: Deployment
+metadata:
+  name: api
+spec:
+  template:
+    spec:
+      volumes:
+        - name: extra
+          configMap:
+            name: extra-config
+            optional: true
+      containers:
+        - name: api
+          image: api
+          env:
+            - name: TOKEN
+              valueFrom:
+                secretKeyRef:
+                  name: token
+                  key: token
+                  optional: true
+          envFrom:
+            - configMapRef:
+                name: overrides
+                optional: true
+`
+	findings, err := lint.Run(buildGraph(t, manifests), lint.DefaultRules(), nil)
+	require.NoError(t, err)
+	assert.Empty(t, findings)
+
+	findings, err = lint.Run(buildGraph(t, manifests+`
+---
+apiVersion: batch/v1
+kind: Job
+metadata:
+  name: rotate
+spec:
+  template:
+    spec:
+      containers:
+        - name: rotate
+          image: rotate
+          envFrom:
+            - secretRef:
+                name: token
+`), lint.DefaultRules(), nil)
+	require.NoError(t, err)
+	require.Len(t, findings, 1)
+	assert.Equal(t, "dangling-reference", findings[0].Rule)
+	assert.Equal(t, "Job/rotate", findings[0].Resource)
+}
+
+func TestParseSeverity(t *testing.T) {
+	tests := []struct {
+		in       string
+		expected lint.Severity
+	}{
+		{"error", lint.SeverityError},
+		{"Warning", lint.SeverityWarning},
+		{"warn", lint.SeverityWarning},
+		{"info", lint.SeverityInfo},
+		{"off", lint.SeverityOff},
+		{"none", lint.SeverityOff},
+	}
+	for _, tt := range tests {
+		sev, err := lint.ParseSeverity(tt.in)
+		require.NoError(t, err, tt.in)
+		assert.Equal(t, tt.expected, sev, tt.in)
+	}
+
+	_, err := lint.ParseSeverity("fatal")
+	assert.Error(t, err)
+}
+
+// TestRunDependencyCycle verifies an ownership loop is reported once, against
+// the first resource of the cycle.
+func TestRunDependencyCycle(t *testing.T) {
+	findings, err := lint.Run(buildGraph(t, `
+apiVersion: v1
+kind: ConfigMap
+metadata:
+  name: a
+  ownerReferences:
+    - apiVersion: v1
+      kind: ConfigMap
+      name: b
+      uid: "2"
+---
+apiVersion: v1
+kind: ConfigMap
+metadata:
+  name: b
+  ownerReferences:
+    - apiVersion: v1
+      kind: ConfigMap
+      name: a
+      uid: "1"
+`), lint.DefaultRules(), nil)
+	require.NoError(t, err)
+
+	require.Len(t, findings, 1)
+	assert.Equal(t, "dependency-cycle", findings[0].Rule)
+	assert.Equal(t, lint.SeverityWarning, findings[0].Severity)
+	assert.Equal(t, "ConfigMap/a", findings[0].Resource)
+	assert.Equal(t, "is part of a dependency cycle of 2 resource(s): ConfigMap/a -[ownerRef]-> ConfigMap/b, ConfigMap/b -[ownerRef]-> ConfigMap/a", findings[0].Message)
+}
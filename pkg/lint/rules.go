@@ -0,0 +1,234 @@
+package lint
+
+import (
+	"fmt"
+	"strings"
+
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+
+	"github.com/HMetcalfeW/cartographer/pkg/dependency"
+)
+
+// defaultClusterRoles are the user-facing ClusterRoles every cluster
+// bootstraps; bindings to them are not reported as missing. ClusterRoles
+// prefixed with "system:" are exempt as well.
+var defaultClusterRoles = map[string]bool{
+	"cluster-admin": true,
+	"admin":         true,
+	"edit":          true,
+	"view":          true,
+}
+
+// specificReasons are reference reasons reported by a dedicated rule rather
+// than by dangling-reference. Owner references are left out entirely: owners
+// are routinely managed outside the analyzed input.
+var specificReasons = map[string]bool{
+	"ownerRef":       true,
+	"scaleTargetRef": true,
+	"roleRef":        true,
+}
+
+// DefaultRules returns the built-in lint rules.
+func DefaultRules() []Rule {
+	return []Rule{
+		{
+			Name:            "dangling-reference",
+			Description:     "A resource references another resource that is not in the input",
+			DefaultSeverity: SeverityError,
+			Check:           checkDanglingReferences,
+		},
+		{
+			Name:            "service-selector-no-match",
+			Description:     "A Service selector matches no workload",
+			DefaultSeverity: SeverityWarning,
+			Check:           selectorCheck("Service", "selector", "spec", "selector"),
+		},
+		{
+			Name:            "pdb-selector-no-match",
+			Description:     "A PodDisruptionBudget selector matches no workload",
+			DefaultSeverity: SeverityWarning,
+			Check:           selectorCheck("PodDisruptionBudget", "pdbSelector", "spec", "selector"),
+		},
+		{
+			Name:            "networkpolicy-selector-no-match",
+			Description:     "A NetworkPolicy podSelector matches no workload",
+			DefaultSeverity: SeverityWarning,
+			Check:           selectorCheck("NetworkPolicy", "podSelector", "spec", "podSelector"),
+		},
+		{
+			Name:            "hpa-target-missing",
+			Description:     "A HorizontalPodAutoscaler targets a workload that is not in the input",
+			DefaultSeverity: SeverityError,
+			Check:           missingTargetCheck("HorizontalPodAutoscaler", "scaleTargetRef", nil),
+		},
+		{
+			Name:            "rolebinding-role-missing",
+			Description:     "A RoleBinding or ClusterRoleBinding references a Role or ClusterRole that is not in the input",
+			DefaultSeverity: SeverityError,
+			Check:           missingTargetCheck("RoleBinding", "roleRef", isDefaultClusterRole),
+		},
+		{
+			Name:            "unreadable-reference",
+			Description:     "A resource references another resource that the cluster refused to return",
+			DefaultSeverity: SeverityWarning,
+			Check:           checkUnreadableReferences,
+		},
+		{
+			Name:            "dependency-cycle",
+			Description:     "Resources reference each other in a cycle",
+			DefaultSeverity: SeverityWarning,
+			Check:           checkCycles,
+		},
+	}
+}
+
+// checkCycles reports each dependency cycle once, against its first resource,
+// listing the edges that form it.
+func checkCycles(g *dependency.Graph) []Finding {
+	cycles := dependency.Cycles(g)
+	edges := dependency.CycleEdges(g, cycles)
+
+	var findings []Finding
+	for _, scc := range cycles {
+		members := make(map[string]bool, len(scc))
+		for _, id := range scc {
+			members[id] = true
+		}
+		var links []string
+		for _, e := range edges {
+			if members[e.ParentID] {
+				links = append(links, fmt.Sprintf("%s -[%s]-> %s", e.ParentID, e.Reason, e.ChildID))
+			}
+		}
+		findings = append(findings, Finding{
+			Resource: scc[0],
+			Message:  fmt.Sprintf("is part of a dependency cycle of %d resource(s): %s", len(scc), strings.Join(links, ", ")),
+		})
+	}
+	return findings
+}
+
+// checkDanglingReferences reports every edge whose target has no backing
+// object, except those covered by a more specific rule, optional references,
+// whose target may legitimately be absent, and unreadable targets, which
+// checkUnreadableReferences reports.
+func checkDanglingReferences(g *dependency.Graph) []Finding {
+	var findings []Finding
+	for _, e := range g.AllEdges() {
+		if specificReasons[e.Reason] || e.Optional {
+			continue
+		}
+		if child, ok := g.Node(e.ChildID); ok && child.Missing() && !child.Unreadable {
+			findings = append(findings, Finding{
+				Resource: e.ParentID,
+				Message:  fmt.Sprintf("references %s (%s), which is not in the input", e.ChildID, e.Reason),
+			})
+		}
+	}
+	return findings
+}
+
+// checkUnreadableReferences reports every edge whose target the cluster
+// refused to return. The target may exist, so this is only a warning.
+func checkUnreadableReferences(g *dependency.Graph) []Finding {
+	var findings []Finding
+	for _, e := range g.AllEdges() {
+		if child, ok := g.Node(e.ChildID); ok && child.Unreadable {
+			findings = append(findings, Finding{
+				Resource: e.ParentID,
+				Message:  fmt.Sprintf("references %s (%s), which the cluster did not allow to be read", e.ChildID, e.Reason),
+			})
+		}
+	}
+	return findings
+}
+
+// selectorCheck reports objects of the given built-in kind whose selector at
+// path is non-empty but produced no edge with the given reason.
+func selectorCheck(kind, reason string, path ...string) func(g *dependency.Graph) []Finding {
+	return func(g *dependency.Graph) []Finding {
+		var findings []Finding
+		for _, n := range g.Nodes() {
+			if !isBuiltinKind(n, kind) || n.Missing() {
+				continue
+			}
+			selector, found, _ := unstructured.NestedMap(n.Object.Object, path...)
+			if !found || selectorEmpty(selector) || hasEdge(g, n.ID, reason) {
+				continue
+			}
+			findings = append(findings, Finding{
+				Resource: n.ID,
+				Message:  fmt.Sprintf("%s matches no workload", strings.Join(path, ".")),
+			})
+		}
+		return findings
+	}
+}
+
+// missingTargetCheck reports objects of the given built-in kind (and its
+// Cluster-prefixed counterpart) whose edge with the given reason points at a
+// missing node. Unreadable targets, and those for which exempt returns true,
+// are skipped.
+func missingTargetCheck(kind, reason string, exempt func(id string) bool) func(g *dependency.Graph) []Finding {
+	return func(g *dependency.Graph) []Finding {
+		var findings []Finding
+		for _, n := range g.Nodes() {
+			if n.Missing() || !(isBuiltinKind(n, kind) || isBuiltinKind(n, "Cluster"+kind)) {
+				continue
+			}
+			for _, e := range g.Edges(n.ID) {
+				if e.Reason != reason {
+					continue
+				}
+				child, ok := g.Node(e.ChildID)
+				if !ok || !child.Missing() || child.Unreadable || (exempt != nil && exempt(e.ChildID)) {
+					continue
+				}
+				findings = append(findings, Finding{
+					Resource: n.ID,
+					Message:  fmt.Sprintf("%s %s is not in the input", reason, e.ChildID),
+				})
+			}
+		}
+		return findings
+	}
+}
+
+// selectorEmpty reports whether a selector selects nothing explicitly. A
+// LabelSelector (matchLabels/matchExpressions) is empty when both parts are;
+// a plain label map (Service .spec.selector) is empty when it has no entries.
+// Empty selectors are not analyzed, so they are never reported.
+func selectorEmpty(selector map[string]interface{}) bool {
+	matchLabels, hasLabels := selector["matchLabels"].(map[string]interface{})
+	matchExprs, hasExprs := selector["matchExpressions"].([]interface{})
+	if hasLabels || hasExprs {
+		return len(matchLabels) == 0 && len(matchExprs) == 0
+	}
+	return len(selector) == 0
+}
+
+// isDefaultClusterRole reports whether id names a ClusterRole that every
+// cluster provides.
+func isDefaultClusterRole(id string) bool {
+	key := dependency.ParseResourceID(id)
+	if key.Kind != "ClusterRole" {
+		return false
+	}
+	return defaultClusterRoles[key.Name] || strings.HasPrefix(key.Name, "system:")
+}
+
+// isBuiltinKind reports whether the node is the built-in kind (and not a
+// custom resource sharing its name).
+func isBuiltinKind(n *dependency.Node, kind string) bool {
+	return n.Kind == kind && dependency.IsBuiltinGroup(n.Kind, n.Group)
+}
+
+// hasEdge reports whether the node has an outgoing edge with the reason.
+func hasEdge(g *dependency.Graph, id, reason string) bool {
+	for _, e := range g.Edges(id) {
+		if e.Reason == reason {
+			return true
+		}
+	}
+	return false
+}
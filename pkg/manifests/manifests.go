@@ -0,0 +1,153 @@
+// Package manifests expands the paths given to --input into the list of
+// manifest files to read: files are read as given, directories are walked
+// recursively, globs are expanded, and "-" stands for standard input.
+package manifests
+
+import (
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strings"
+
+	log "github.com/sirupsen/logrus"
+)
+
+// Stdin is the path that stands for standard input.
+const Stdin = "-"
+
+// DefaultInclude lists the file name patterns read from directories when no
+// include patterns are given.
+var DefaultInclude = []string{"*.yaml", "*.yml", "*.json"}
+
+// Find expands paths into manifest files in a deterministic order. Each path
+// may be:
+//
+//   - "-" for standard input, at most once
+//   - a file, which is read whatever its name
+//   - a directory, walked recursively in lexical order for files matching
+//     include (DefaultInclude if empty) and not matching exclude
+//   - a glob such as "k8s/*/deploy.yaml", whose matches are treated as above
+//
+// Patterns without a slash match a file or directory's base name; patterns
+// with one match its slash-separated path relative to the walked directory.
+// An excluded directory is skipped entirely. Paths that do not exist and are
+// not globs are returned unchanged so opening them reports the error; a glob
+// matching nothing is an error. Files reached more than once are listed once.
+func Find(paths, include, exclude []string) ([]string, error) {
+	if len(include) == 0 {
+		include = DefaultInclude
+	}
+	for _, p := range append(append([]string(nil), include...), exclude...) {
+		if _, err := filepath.Match(p, ""); err != nil {
+			return nil, fmt.Errorf("invalid pattern %q: %w", p, err)
+		}
+	}
+
+	var files []string
+	seen := make(map[string]bool)
+	add := func(path string) {
+		if !seen[path] {
+			seen[path] = true
+			files = append(files, path)
+		}
+	}
+
+	for _, path := range paths {
+		if path == Stdin {
+			if seen[Stdin] {
+				return nil, fmt.Errorf("standard input (%q) can only be given once", Stdin)
+			}
+			add(Stdin)
+			continue
+		}
+
+		matches := []string{path}
+		if _, err := os.Stat(path); err != nil && hasMeta(path) {
+			matches, err = filepath.Glob(path)
+			if err != nil {
+				return nil, fmt.Errorf("invalid glob %q: %w", path, err)
+			}
+			if len(matches) == 0 {
+				return nil, fmt.Errorf("no files match %q", path)
+			}
+		}
+
+		for _, m := range matches {
+			info, err := os.Stat(m)
+			if err != nil || !info.IsDir() {
+				add(filepath.Clean(m))
+				continue
+			}
+			found, err := walk(m, include, exclude)
+			if err != nil {
+				return nil, err
+			}
+			for _, f := range found {
+				add(f)
+			}
+		}
+	}
+
+	log.WithFields(log.Fields{
+		"func":  "manifests.Find",
+		"paths": len(paths),
+		"files": len(files),
+	}).Debug("Expanded input paths")
+
+	return files, nil
+}
+
+// walk returns the files under dir that match include and not exclude.
+func walk(dir string, include, exclude []string) ([]string, error) {
+	var files []string
+	err := filepath.WalkDir(dir, func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return fmt.Errorf("failed to read directory: %w", err)
+		}
+		if path == dir {
+			return nil
+		}
+		rel, err := filepath.Rel(dir, path)
+		if err != nil {
+			return err
+		}
+		rel = filepath.ToSlash(rel)
+
+		if matchAny(exclude, rel) {
+			if d.IsDir() {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+		if !d.IsDir() && matchAny(include, rel) {
+			files = append(files, filepath.Clean(path))
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return files, nil
+}
+
+// matchAny reports whether rel, a slash-separated relative path, matches any
+// of the patterns. Patterns without a slash are matched against the base name.
+func matchAny(patterns []string, rel string) bool {
+	base := rel[strings.LastIndex(rel, "/")+1:]
+	for _, p := range patterns {
+		name := base
+		if strings.Contains(p, "/") {
+			name = rel
+		}
+		if ok, _ := filepath.Match(p, name); ok {
+			return true
+		}
+	}
+	return false
+}
+
+// hasMeta reports whether path contains glob metacharacters.
+func hasMeta(path string) bool {
+	return strings.ContainsAny(path, `*?[`)
+}
@@ -0,0 +1,98 @@
+package manifests_test
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/HMetcalfeW/cartographer/pkg/manifests"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// writeTree creates the given files, relative to a new temporary directory,
+// and returns the directory.
+func writeTree(t *testing.T, files ...string) string {
+	t.Helper()
+	dir := t.TempDir()
+	for _, f := range files {
+		path := filepath.Join(dir, filepath.FromSlash(f))
+		require.NoError(t, os.MkdirAll(filepath.Dir(path), 0o755))
+		require.NoError(t, os.WriteFile(path, []byte("kind: ConfigMap\n"), 0o644))
+	}
+	return dir
+}
+
+func TestFind_Directory(t *testing.T) {
+	dir := writeTree(t,
+		"base/deploy.yaml",
+		"base/svc.yml",
+		"base/README.md",
+		"overlays/prod/patch.json",
+		"vendor/lib.yaml",
+		"tests/fixture.yaml",
+	)
+
+	files, err := manifests.Find([]string{dir}, nil, []string{"vendor", "tests/*"})
+	require.NoError(t, err)
+	assert.Equal(t, []string{
+		filepath.Join(dir, "base", "deploy.yaml"),
+		filepath.Join(dir, "base", "svc.yml"),
+		filepath.Join(dir, "overlays", "prod", "patch.json"),
+	}, files)
+}
+
+func TestFind_Include(t *testing.T) {
+	dir := writeTree(t, "a/deploy.yaml", "a/svc.yaml", "b/deploy.yaml")
+
+	files, err := manifests.Find([]string{dir}, []string{"deploy.*"}, nil)
+	require.NoError(t, err)
+	assert.Equal(t, []string{
+		filepath.Join(dir, "a", "deploy.yaml"),
+		filepath.Join(dir, "b", "deploy.yaml"),
+	}, files)
+
+	files, err = manifests.Find([]string{dir}, []string{"b/*"}, nil)
+	require.NoError(t, err)
+	assert.Equal(t, []string{filepath.Join(dir, "b", "deploy.yaml")}, files)
+}
+
+// TestFind_FilesGlobsAndStdin verifies that explicit files are kept in the
+// given order whatever their name, globs are expanded, stdin passes through
+// and files reached twice are listed once.
+func TestFind_FilesGlobsAndStdin(t *testing.T) {
+	dir := writeTree(t, "one.yaml", "two.yaml", "notes.txt")
+
+	files, err := manifests.Find([]string{
+		filepath.Join(dir, "notes.txt"),
+		manifests.Stdin,
+		filepath.Join(dir, "*.yaml"),
+		filepath.Join(dir, "one.yaml"),
+	}, nil, nil)
+	require.NoError(t, err)
+	assert.Equal(t, []string{
+		filepath.Join(dir, "notes.txt"),
+		manifests.Stdin,
+		filepath.Join(dir, "one.yaml"),
+		filepath.Join(dir, "two.yaml"),
+	}, files)
+}
+
+func TestFind_Errors(t *testing.T) {
+	dir := writeTree(t, "one.yaml")
+
+	_, err := manifests.Find([]string{filepath.Join(dir, "*.json")}, nil, nil)
+	assert.ErrorContains(t, err, "no files match")
+
+	_, err = manifests.Find([]string{"-", "-"}, nil, nil)
+	assert.ErrorContains(t, err, "can only be given once")
+
+	_, err = manifests.Find([]string{dir}, []string{"[bad"}, nil)
+	assert.ErrorContains(t, err, `invalid pattern "[bad"`)
+
+	// Missing files are returned as given so opening them reports the error.
+	missing := filepath.Join(dir, "missing.yaml")
+	files, err := manifests.Find([]string{missing}, nil, nil)
+	require.NoError(t, err)
+	assert.Equal(t, []string{missing}, files)
+}
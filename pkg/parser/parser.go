@@ -1,42 +1,387 @@
 package parser
 
 import (
+	"bufio"
 	"bytes"
+	"errors"
 	"fmt"
 	"io"
 	"os"
+	"strings"
 
 	log "github.com/sirupsen/logrus"
 	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
-	"k8s.io/apimachinery/pkg/util/yaml"
+	"k8s.io/apimachinery/pkg/runtime"
 )
 
 const (
 	BUFFER_BYTES = 4096
 )
 
-// ParseYAML parses raw YAML bytes (potentially multi-document) and returns
-// a slice of unstructured Kubernetes objects. Empty documents are skipped.
-func ParseYAML(data []byte) ([]*unstructured.Unstructured, error) {
-	decoder := yaml.NewYAMLOrJSONDecoder(io.NopCloser(bytes.NewReader(data)), BUFFER_BYTES)
+// Options controls how a Decoder post-processes each object.
+type Options struct {
+	// StripFields removes .status and .metadata.managedFields from every
+	// object as it is decoded. Neither affects the dependency graph, and on
+	// `kubectl get -o yaml` dumps they make up much of each object.
+	StripFields bool
 
-	var objs []*unstructured.Unstructured
+	// Strict makes the Decoder fail on the first malformed document or
+	// object missing kind, apiVersion or metadata.name. By default such
+	// documents are skipped with a warning and decoding continues.
+	Strict bool
+
+	// Source names the input in diagnostics, e.g. a file path or chart.
+	Source string
+}
+
+// DocumentError describes a document that could not be decoded, or an object
+// in it that lacks kind, apiVersion or metadata.name.
+type DocumentError struct {
+	// Source is Options.Source; empty if none was given.
+	Source string
+
+	// Document is the 1-based index of the document in the stream. Only
+	// documents with content (not just comments) are counted.
+	Document int
+
+	// Line is the 1-based line of the problem in the stream, or of the start
+	// of the document when the exact line is unknown.
+	Line int
+
+	Err error
+}
+
+// Error formats the error as "source: document N, line L: err".
+func (e *DocumentError) Error() string {
+	msg := fmt.Sprintf("document %d, line %d: %v", e.Document, e.Line, e.Err)
+	if e.Source != "" {
+		return e.Source + ": " + msg
+	}
+	return msg
+}
+
+// Unwrap returns the underlying error.
+func (e *DocumentError) Unwrap() error {
+	return e.Err
+}
+
+// Decoder reads Kubernetes objects one at a time from a stream of YAML or
+// JSON documents, so a large input never has to be held in memory in full.
+// Empty documents are skipped, and List objects (e.g. the output of
+// `kubectl get -o yaml`) are expanded into their items. The items of a List
+// are decoded one at a time as well, so a single huge List document is no
+// more of a problem than many small documents.
+type Decoder struct {
+	docs     *documentReader
+	opts     Options
+	objs     objectStream
+	doc      document
+	warnings []*DocumentError
+}
+
+// NewDecoder returns a Decoder reading from r.
+func NewDecoder(r io.Reader, opts Options) *Decoder {
+	return &Decoder{
+		docs: &documentReader{r: bufio.NewReaderSize(r, BUFFER_BYTES)},
+		opts: opts,
+	}
+}
+
+// Next returns the next object in the stream and where it was read from. It
+// returns io.EOF once the stream is exhausted. In strict mode a malformed
+// document or incomplete object is returned as a *DocumentError; otherwise it
+// is logged, recorded in Warnings and skipped.
+func (d *Decoder) Next() (*unstructured.Unstructured, Source, error) {
 	for {
-		var obj map[string]interface{}
-		if err := decoder.Decode(&obj); err != nil {
-			break
+		if d.objs == nil {
+			doc, err := d.docs.next()
+			if err != nil {
+				return nil, Source{}, err
+			}
+			d.doc = doc
+			d.objs = newObjectStream(d.docs, doc)
 		}
-		if len(obj) == 0 {
+
+		next, err := d.objs.next()
+		if errors.Is(err, io.EOF) {
+			d.objs = nil
 			continue
 		}
-		u := &unstructured.Unstructured{Object: obj}
-		objs = append(objs, u)
+		if err != nil {
+			return nil, Source{}, err
+		}
+		if next.err == nil {
+			next.err, next.line = validate(next.obj), d.doc.line
+		}
+		if next.err != nil {
+			if err := d.reject(next.line, next.err); err != nil {
+				return nil, Source{}, err
+			}
+			continue
+		}
+		if d.opts.StripFields {
+			StripFields(next.obj)
+		}
+		return next.obj, d.source(), nil
 	}
+}
 
-	return objs, nil
+// Warnings returns the documents and objects skipped so far in lenient mode.
+func (d *Decoder) Warnings() []*DocumentError {
+	return d.warnings
+}
+
+// source returns the current document's location. Documents from a rendered
+// Helm chart also carry their template and chart.
+func (d *Decoder) source() Source {
+	src := Source{File: d.opts.Source, Document: d.doc.index, Line: d.doc.line}
+	if d.doc.template != "" {
+		src.Template = d.doc.template
+		src.Chart = ChartOf(d.doc.template)
+	}
+	return src
+}
+
+// reject handles a problem at the given line of the current document: in
+// strict mode it returns it, otherwise it logs and records it.
+func (d *Decoder) reject(line int, err error) error {
+	docErr := &DocumentError{Source: d.opts.Source, Document: d.doc.index, Line: line, Err: err}
+	if d.opts.Strict {
+		return docErr
+	}
+	d.warnings = append(d.warnings, docErr)
+	log.WithFields(log.Fields{
+		"func":     "parser.Decoder",
+		"source":   d.opts.Source,
+		"document": docErr.Document,
+		"line":     docErr.Line,
+	}).WithError(err).Warn("Skipping invalid document")
+	return nil
+}
+
+// validate reports an object lacking the fields needed to identify it.
+func validate(obj *unstructured.Unstructured) error {
+	var missing []string
+	if obj.GetKind() == "" {
+		missing = append(missing, "kind")
+	}
+	if obj.GetAPIVersion() == "" {
+		missing = append(missing, "apiVersion")
+	}
+	if obj.GetName() == "" {
+		missing = append(missing, "metadata.name")
+	}
+	if len(missing) == 0 {
+		return nil
+	}
+	if kind := obj.GetKind(); kind != "" {
+		return fmt.Errorf("%s is missing %s", kind, strings.Join(missing, ", "))
+	}
+	return fmt.Errorf("object is missing %s", strings.Join(missing, ", "))
+}
+
+// expandList returns the items of a List object (recursively, since an item
+// may itself be a List), or the object itself if it is not a List. Items of
+// typed lists such as a PodList may omit their apiVersion and kind; they are
+// filled in from the list.
+func expandList(obj *unstructured.Unstructured) []*unstructured.Unstructured {
+	kind := obj.GetKind()
+	if !strings.HasSuffix(kind, "List") || !obj.IsList() {
+		return []*unstructured.Unstructured{obj}
+	}
+
+	itemKind := strings.TrimSuffix(kind, "List")
+	var objs []*unstructured.Unstructured
+	_ = obj.EachListItem(func(o runtime.Object) error {
+		item := o.(*unstructured.Unstructured)
+		if item.GetKind() == "" && itemKind != "" {
+			item.SetKind(itemKind)
+		}
+		if item.GetAPIVersion() == "" {
+			item.SetAPIVersion(obj.GetAPIVersion())
+		}
+		objs = append(objs, expandList(item)...)
+		return nil
+	})
+	return objs
+}
+
+// StripFields removes .status and .metadata.managedFields from obj.
+func StripFields(obj *unstructured.Unstructured) {
+	unstructured.RemoveNestedField(obj.Object, "status")
+	unstructured.RemoveNestedField(obj.Object, "metadata", "managedFields")
+}
+
+// document is one "---"-separated document of a stream. Its content is not
+// held here: it is read line by line from the documentReader.
+type document struct {
+	// index is the 1-based position among documents with content.
+	index int
+
+	// line is the stream line of the document's first line with content.
+	line int
+
+	// template is the path from a Helm "# Source:" comment preceding the
+	// content, if any.
+	template string
+}
+
+// documentReader splits a stream into documents on "---" separator lines,
+// using the same separator rules as the Kubernetes YAML reader, while
+// counting lines so diagnostics can point into the stream. Documents are
+// read one line at a time, so none is ever held in memory whole.
+type documentReader struct {
+	r     *bufio.Reader
+	lines int
+	count int
+	done  bool
+
+	// open is set while lines of the current document remain to be read.
+	open bool
+
+	// unread holds lines of the current document that were read and then
+	// given back, to be returned by line before any others.
+	unread [][]byte
+}
+
+// next skips whatever is left of the current document and returns the next
+// document with content, or io.EOF. Its first line with content is the first
+// one returned by line.
+func (dr *documentReader) next() (document, error) {
+	for dr.open || len(dr.unread) > 0 {
+		if _, _, err := dr.line(); err != nil {
+			return document{}, err
+		}
+	}
+	for !dr.done {
+		var doc document
+		dr.open = true
+		for {
+			line, ok, err := dr.line()
+			if err != nil {
+				return document{}, err
+			}
+			if !ok {
+				break
+			}
+			if hasContent(line) {
+				dr.unread = append(dr.unread, line)
+				dr.count++
+				doc.index, doc.line = dr.count, dr.lines
+				return doc, nil
+			}
+			if template, ok := helmSource(line); ok {
+				doc.template = template
+			}
+		}
+	}
+	return document{}, io.EOF
+}
+
+// line returns the next line of the current document, or false once the
+// document's separator or the end of the stream is reached. Given-back lines
+// are not counted again.
+func (dr *documentReader) line() ([]byte, bool, error) {
+	if len(dr.unread) > 0 {
+		line := dr.unread[0]
+		dr.unread = dr.unread[1:]
+		return line, true, nil
+	}
+	if !dr.open || dr.done {
+		dr.open = false
+		return nil, false, nil
+	}
+	line, err := dr.r.ReadBytes('\n')
+	if errors.Is(err, io.EOF) {
+		dr.done = true
+	} else if err != nil {
+		return nil, false, err
+	}
+	if len(line) == 0 {
+		dr.open = false
+		return nil, false, nil
+	}
+	dr.lines++
+	if isSeparator(line) {
+		dr.open = false
+		return nil, false, nil
+	}
+	return line, true, nil
+}
+
+// giveBack returns lines read from the current document to the front of it,
+// in order.
+func (dr *documentReader) giveBack(lines [][]byte) {
+	dr.unread = append(lines, dr.unread...)
+}
+
+// isSeparator reports a "---" line, optionally followed by a comment.
+func isSeparator(line []byte) bool {
+	rest, ok := bytes.CutPrefix(line, []byte("---"))
+	if !ok {
+		return false
+	}
+	rest = bytes.TrimSpace(rest)
+	return len(rest) == 0 || rest[0] == '#'
+}
+
+// helmSource extracts the template path from a "# Source: <path>" comment,
+// which Helm writes above every rendered document.
+func helmSource(line []byte) (string, bool) {
+	rest, ok := bytes.CutPrefix(bytes.TrimSpace(line), []byte("# Source:"))
+	if !ok {
+		return "", false
+	}
+	path := string(bytes.TrimSpace(rest))
+	return path, path != ""
+}
+
+// hasContent reports a line that is neither blank nor a comment.
+func hasContent(line []byte) bool {
+	line = bytes.TrimSpace(line)
+	return len(line) > 0 && line[0] != '#'
+}
+
+// Decode reads every object from r, calling fn for each in stream order with
+// where it was read from. It returns the first error from the stream
+// (including, in strict mode, a *DocumentError) or from fn.
+func Decode(r io.Reader, opts Options, fn func(*unstructured.Unstructured, Source) error) error {
+	d := NewDecoder(r, opts)
+	for {
+		obj, src, err := d.Next()
+		if errors.Is(err, io.EOF) {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		if err := fn(obj, src); err != nil {
+			return err
+		}
+	}
+}
+
+// ParseYAML parses raw YAML bytes (potentially multi-document) and returns
+// a slice of unstructured Kubernetes objects. Empty documents are skipped,
+// List objects are expanded into their items, and invalid documents are
+// skipped with a warning.
+func ParseYAML(data []byte) ([]*unstructured.Unstructured, error) {
+	return ParseReader(bytes.NewReader(data), Options{})
+}
+
+// ParseReader is ParseYAML reading from a stream, with the given Options.
+// Where each object was read from is dropped; use Decode to keep it.
+func ParseReader(r io.Reader, opts Options) ([]*unstructured.Unstructured, error) {
+	var objs []*unstructured.Unstructured
+	err := Decode(r, opts, func(obj *unstructured.Unstructured, _ Source) error {
+		objs = append(objs, obj)
+		return nil
+	})
+	return objs, err
 }
 
 // ParseYAMLFile reads a YAML file and returns a slice of unstructured objects.
+// The file is decoded as it is read rather than loaded into memory first.
 func ParseYAMLFile(path string) ([]*unstructured.Unstructured, error) {
 	if path == "" {
 		return nil, fmt.Errorf("file path must not be empty")
@@ -48,12 +393,13 @@ func ParseYAMLFile(path string) ([]*unstructured.Unstructured, error) {
 	})
 	logger.Info("Parsing yaml input")
 
-	data, err := os.ReadFile(path)
+	f, err := os.Open(path)
 	if err != nil {
 		return nil, err
 	}
+	defer func() { _ = f.Close() }()
 
-	objs, err := ParseYAML(data)
+	objs, err := ParseReader(f, Options{Source: path})
 	if err != nil {
 		return nil, fmt.Errorf("failed to parse YAML from %s: %w", path, err)
 	}
@@ -1,12 +1,19 @@
 package parser_test
 
 import (
+	"bufio"
+	"errors"
+	"fmt"
+	"io"
 	"os"
+	"runtime"
+	"strings"
 	"testing"
 
 	"github.com/HMetcalfeW/cartographer/pkg/parser"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
 )
 
 func TestParseYAMLFile(t *testing.T) {
@@ -153,3 +160,439 @@ metadata:
 	require.Len(t, objs, 1, "should skip empty documents and parse only the ConfigMap")
 	assert.Equal(t, "ConfigMap", objs[0].GetKind())
 }
+
+func TestDecoder_Next(t *testing.T) {
+	d := parser.NewDecoder(strings.NewReader(`
+apiVersion: v1
+kind: ConfigMap
+metadata:
+  name: first
+---
+---
+{"apiVersion": "v1", "kind": "Secret", "metadata": {"name": "second"}}
+`), parser.Options{})
+
+	obj, src, err := d.Next()
+	require.NoError(t, err)
+	assert.Equal(t, "first", obj.GetName())
+	assert.Equal(t, parser.Source{Document: 1, Line: 2}, src)
+	assert.Empty(t, obj.GetAnnotations(), "the location is not written onto the object")
+
+	obj, src, err = d.Next()
+	require.NoError(t, err)
+	assert.Equal(t, "Secret", obj.GetKind())
+	assert.Equal(t, "second", obj.GetName())
+	assert.Equal(t, parser.Source{Document: 2, Line: 8}, src)
+
+	_, _, err = d.Next()
+	assert.ErrorIs(t, err, io.EOF)
+}
+
+// TestParseYAML_List verifies that List objects, as printed by
+// `kubectl get -o yaml`, are expanded into their items, including nested and
+// typed lists whose items omit apiVersion and kind.
+func TestParseYAML_List(t *testing.T) {
+	objs, err := parser.ParseYAML([]byte(`
+apiVersion: v1
+kind: List
+items:
+  - apiVersion: apps/v1
+    kind: Deployment
+    metadata:
+      name: web
+  - apiVersion: v1
+    kind: PodList
+    items:
+      - metadata:
+          name: web-abc
+---
+apiVersion: v1
+kind: SecretList
+items:
+  - metadata:
+      name: db
+---
+apiVersion: v1
+kind: ConfigMap
+metadata:
+  name: items-but-not-a-list
+data:
+  items: "a,b"
+`))
+	require.NoError(t, err)
+
+	var got []string
+	for _, obj := range objs {
+		got = append(got, obj.GetAPIVersion()+" "+obj.GetKind()+"/"+obj.GetName())
+	}
+	assert.Equal(t, []string{
+		"apps/v1 Deployment/web",
+		"v1 Pod/web-abc",
+		"v1 Secret/db",
+		"v1 ConfigMap/items-but-not-a-list",
+	}, got)
+}
+
+// TestParseReader_StreamedList verifies the List layouts decoded item by
+// item: `kubectl get -o yaml` and `-o json` output, whose kind follows the
+// items, and a typed list whose items wait for the kind after them.
+func TestParseReader_StreamedList(t *testing.T) {
+	objs, err := parser.ParseReader(strings.NewReader(`apiVersion: v1
+items:
+- apiVersion: v1
+  kind: ConfigMap
+  metadata:
+    name: settings
+  data:
+    script: |
+      #!/bin/sh
+
+      echo items:
+- apiVersion: v1
+  kind: Secret
+  metadata:
+    name: creds
+kind: List
+metadata:
+  resourceVersion: ""
+---
+{
+  "apiVersion": "v1",
+  "items": [
+    {"apiVersion": "v1", "kind": "Service", "metadata": {"name": "web"}},
+    {"apiVersion": "v1", "kind": "List", "items": [{"kind": "Pod", "metadata": {"name": "web-abc"}}]}
+  ],
+  "kind": "List"
+}
+---
+items:
+  - metadata:
+      name: cache
+kind: ServiceAccountList
+apiVersion: v1
+`), parser.Options{Strict: true})
+	require.NoError(t, err)
+
+	var got []string
+	for _, obj := range objs {
+		got = append(got, obj.GetAPIVersion()+" "+obj.GetKind()+"/"+obj.GetName())
+	}
+	assert.Equal(t, []string{
+		"v1 ConfigMap/settings",
+		"v1 Secret/creds",
+		"v1 Service/web",
+		"v1 Pod/web-abc",
+		"v1 ServiceAccount/cache",
+	}, got)
+	script, _, _ := unstructured.NestedString(objs[0].Object, "data", "script")
+	assert.Equal(t, "#!/bin/sh\n\necho items:\n", script)
+}
+
+// TestParseReader_ItemsNotAList verifies that top-level items in an object
+// that is not a List are decoded as a plain field, or reported when the kind
+// only follows them.
+func TestParseReader_ItemsNotAList(t *testing.T) {
+	objs, err := parser.ParseReader(strings.NewReader(`apiVersion: example.com/v1
+kind: Menu
+metadata:
+  name: lunch
+items:
+- soup
+- bread
+`), parser.Options{Strict: true})
+	require.NoError(t, err)
+	require.Len(t, objs, 1)
+	items, _, _ := unstructured.NestedStringSlice(objs[0].Object, "items")
+	assert.Equal(t, []string{"soup", "bread"}, items)
+
+	_, err = parser.ParseReader(strings.NewReader(`apiVersion: example.com/v1
+items:
+- apiVersion: v1
+  kind: ConfigMap
+  metadata:
+    name: settings
+kind: Menu
+`), parser.Options{Strict: true})
+	assert.EqualError(t, err, `document 1, line 1: document has top-level items but its kind "Menu" is not a List`)
+}
+
+// TestDecoder_ListItemErrorLine verifies that a malformed List item is
+// reported at its own line and that the items around it are still decoded.
+func TestDecoder_ListItemErrorLine(t *testing.T) {
+	d := parser.NewDecoder(strings.NewReader(`apiVersion: v1
+kind: List
+items:
+- apiVersion: v1
+  kind: ConfigMap
+  metadata:
+    name: first
+- apiVersion: v1
+  kind: Secret
+  metadata:
+    name: [unterminated
+- apiVersion: v1
+  kind: ConfigMap
+  metadata:
+    name: last
+`), parser.Options{})
+
+	var names []string
+	for {
+		obj, _, err := d.Next()
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		require.NoError(t, err)
+		names = append(names, obj.GetName())
+	}
+	assert.Equal(t, []string{"first", "last"}, names)
+	require.Len(t, d.Warnings(), 1)
+	assert.Equal(t, 11, d.Warnings()[0].Line)
+}
+
+// TestDecoder_LargeListBoundedMemory verifies that a List is decoded one item
+// at a time: while a List of about 2MB is decoded, the live heap stays far
+// below its size.
+func TestDecoder_LargeListBoundedMemory(t *testing.T) {
+	if testing.Short() {
+		t.Skip("decodes a large List")
+	}
+	const items = 2000
+	for _, format := range []string{"yaml", "json"} {
+		t.Run(format, func(t *testing.T) {
+			r, w := io.Pipe()
+			go func() { _ = w.CloseWithError(writeLargeList(w, format, items)) }()
+
+			var stats runtime.MemStats
+			runtime.GC()
+			runtime.ReadMemStats(&stats)
+			base := stats.HeapAlloc
+
+			var decoded int
+			var peak uint64
+			err := parser.Decode(r, parser.Options{}, func(*unstructured.Unstructured, parser.Source) error {
+				decoded++
+				if decoded%250 == 0 {
+					runtime.GC()
+					runtime.ReadMemStats(&stats)
+					if stats.HeapAlloc > base && stats.HeapAlloc-base > peak {
+						peak = stats.HeapAlloc - base
+					}
+				}
+				return nil
+			})
+			require.NoError(t, err)
+			assert.Equal(t, items, decoded)
+			assert.Less(t, peak, uint64(512<<10), "live heap grew by %d bytes while decoding the List", peak)
+		})
+	}
+}
+
+// writeLargeList writes a List of n ConfigMaps of about 1KB each to w.
+func writeLargeList(w io.Writer, format string, n int) error {
+	padding := strings.Repeat("x", 1000)
+	bw := bufio.NewWriter(w)
+	if format == "json" {
+		fmt.Fprint(bw, "{\n  \"apiVersion\": \"v1\",\n  \"items\": [\n")
+		for i := 0; i < n; i++ {
+			if i > 0 {
+				fmt.Fprint(bw, ",\n")
+			}
+			fmt.Fprintf(bw, `    {"apiVersion": "v1", "kind": "ConfigMap", "metadata": {"name": "cm-%d"}, "data": {"padding": %q}}`, i, padding)
+		}
+		fmt.Fprint(bw, "\n  ],\n  \"kind\": \"List\"\n}\n")
+	} else {
+		fmt.Fprint(bw, "apiVersion: v1\nitems:\n")
+		for i := 0; i < n; i++ {
+			fmt.Fprintf(bw, "- apiVersion: v1\n  kind: ConfigMap\n  metadata:\n    name: cm-%d\n  data:\n    padding: %s\n", i, padding)
+		}
+		fmt.Fprint(bw, "kind: List\nmetadata:\n  resourceVersion: \"\"\n")
+	}
+	return bw.Flush()
+}
+
+func TestParseReader_StripFields(t *testing.T) {
+	manifest := `
+apiVersion: apps/v1
+kind: Deployment
+metadata:
+  name: web
+  managedFields:
+    - manager: kubectl
+      operation: Apply
+spec:
+  replicas: 2
+status:
+  readyReplicas: 2
+`
+	objs, err := parser.ParseReader(strings.NewReader(manifest), parser.Options{StripFields: true})
+	require.NoError(t, err)
+	require.Len(t, objs, 1)
+	assert.NotContains(t, objs[0].Object, "status")
+	assert.Nil(t, objs[0].GetManagedFields())
+	replicas, found, _ := unstructured.NestedFieldNoCopy(objs[0].Object, "spec", "replicas")
+	assert.True(t, found, "other fields are kept")
+	assert.EqualValues(t, 2, replicas)
+
+	objs, err = parser.ParseReader(strings.NewReader(manifest), parser.Options{})
+	require.NoError(t, err)
+	assert.Contains(t, objs[0].Object, "status", "fields are kept unless stripping is requested")
+}
+
+func TestDecode_CallbackError(t *testing.T) {
+	stop := errors.New("stop")
+	seen := 0
+	err := parser.Decode(strings.NewReader(brokenYAML), parser.Options{}, func(*unstructured.Unstructured, parser.Source) error {
+		seen++
+		return stop
+	})
+	assert.ErrorIs(t, err, stop)
+	assert.Equal(t, 1, seen)
+}
+
+// brokenYAML has a valid ConfigMap, a Secret with a syntax error on line 10,
+// a Service without a name and a valid Deployment.
+const brokenYAML = `# leading comment
+apiVersion: v1
+kind: ConfigMap
+metadata:
+  name: first
+---
+apiVersion: v1
+kind: Secret
+metadata:
+  name: [unterminated
+---
+apiVersion: v1
+kind: Service
+metadata:
+  labels:
+    app: web
+---
+apiVersion: apps/v1
+kind: Deployment
+metadata:
+  name: last
+`
+
+func TestDecoder_Lenient(t *testing.T) {
+	d := parser.NewDecoder(strings.NewReader(brokenYAML), parser.Options{Source: "bundle.yaml"})
+
+	var names []string
+	for {
+		obj, _, err := d.Next()
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		require.NoError(t, err)
+		names = append(names, obj.GetName())
+	}
+	assert.Equal(t, []string{"first", "last"}, names, "invalid documents are skipped, later ones still decoded")
+
+	warnings := d.Warnings()
+	require.Len(t, warnings, 2)
+	assert.Equal(t, 2, warnings[0].Document)
+	assert.Equal(t, 10, warnings[0].Line)
+	assert.Equal(t, "bundle.yaml", warnings[0].Source)
+	assert.Equal(t, 3, warnings[1].Document)
+	assert.Equal(t, 12, warnings[1].Line)
+	assert.EqualError(t, warnings[1], "bundle.yaml: document 3, line 12: Service is missing metadata.name")
+}
+
+func TestDecoder_Strict(t *testing.T) {
+	objs, err := parser.ParseReader(strings.NewReader(brokenYAML), parser.Options{Strict: true, Source: "bundle.yaml"})
+	require.Error(t, err)
+	assert.Len(t, objs, 1, "objects before the bad document are returned")
+
+	var docErr *parser.DocumentError
+	require.ErrorAs(t, err, &docErr)
+	assert.Equal(t, 2, docErr.Document)
+	assert.Equal(t, 10, docErr.Line)
+	assert.Contains(t, err.Error(), "bundle.yaml: document 2, line 10: ")
+}
+
+func TestDecoder_StrictMissingFields(t *testing.T) {
+	_, err := parser.ParseReader(strings.NewReader(`
+apiVersion: v1
+kind: List
+items:
+  - metadata:
+      name: no-kind
+`), parser.Options{Strict: true})
+	require.Error(t, err)
+	assert.EqualError(t, err, "document 1, line 2: object is missing kind")
+}
+
+func TestDecoder_JSONSyntaxErrorLine(t *testing.T) {
+	_, err := parser.ParseReader(strings.NewReader(`apiVersion: v1
+kind: ConfigMap
+metadata:
+  name: ok
+---
+{
+  "apiVersion": "v1",
+  "kind": "Secret"
+  "metadata": {"name": "bad"}
+}
+`), parser.Options{Strict: true})
+	var docErr *parser.DocumentError
+	require.ErrorAs(t, err, &docErr)
+	assert.Equal(t, 2, docErr.Document)
+	assert.Equal(t, 9, docErr.Line)
+}
+
+// TestDecoder_Source verifies that every object comes with the file, document
+// and line it was decoded from, and that rendered Helm documents keep that
+// location and also carry their template and chart.
+func TestDecoder_Source(t *testing.T) {
+	var sources []parser.Source
+	err := parser.Decode(strings.NewReader(`apiVersion: v1
+kind: ConfigMap
+metadata:
+  name: first
+---
+# A comment before the content.
+
+apiVersion: v1
+kind: Secret
+metadata:
+  name: second
+---
+# Source: web/templates/service.yaml
+apiVersion: v1
+kind: Service
+metadata:
+  name: web
+`), parser.Options{Source: "k8s/app.yaml"}, func(_ *unstructured.Unstructured, src parser.Source) error {
+		sources = append(sources, src)
+		return nil
+	})
+	require.NoError(t, err)
+	require.Len(t, sources, 3)
+	assert.Equal(t, parser.Source{File: "k8s/app.yaml", Document: 1, Line: 1}, sources[0])
+	assert.Equal(t, parser.Source{File: "k8s/app.yaml", Document: 2, Line: 8}, sources[1])
+	assert.Equal(t, parser.Source{File: "k8s/app.yaml", Document: 3, Line: 14, Template: "web/templates/service.yaml", Chart: "web"}, sources[2])
+
+	assert.Equal(t, "k8s/app.yaml:8", sources[1].String())
+	assert.Equal(t, "k8s/app.yaml:14 (web/templates/service.yaml)", sources[2].String())
+	assert.Equal(t, "web/templates/service.yaml", parser.Source{Template: "web/templates/service.yaml"}.String())
+	assert.Equal(t, "document 2, line 8", parser.Source{Document: 2, Line: 8}.String())
+}
+
+// TestChartOf verifies that chart paths follow the charts/ directories of
+// subcharts and that other paths have no chart.
+func TestChartOf(t *testing.T) {
+	tests := map[string]string{
+		"web/templates/service.yaml":                                "web",
+		"web/templates/nested/deploy.yaml":                          "web",
+		"web/crds/widgets.yaml":                                     "web",
+		"platform/charts/postgresql/templates/statefulset.yaml":     "platform/postgresql",
+		"platform/charts/db/charts/metrics/templates/exporter.yaml": "platform/db/metrics",
+		"k8s/app.yaml":          "",
+		"templates/deploy.yaml": "",
+		"web/files/config.yaml": "",
+	}
+	for path, want := range tests {
+		assert.Equal(t, want, parser.ChartOf(path), path)
+	}
+}
@@ -0,0 +1,82 @@
+package parser
+
+import (
+	"fmt"
+	"strings"
+)
+
+// Source is the location an object was decoded from.
+type Source struct {
+	// File is the input the object was read from, as given in
+	// Options.Source: a file path, "stdin", or a label such as "chart web".
+	File string `json:"file,omitempty"`
+
+	// Document is the 1-based index of the document in the input stream.
+	Document int `json:"document,omitempty"`
+
+	// Line is the 1-based line in the input the document's content starts on.
+	Line int `json:"line,omitempty"`
+
+	// Template is the chart template named by the "# Source:" comment Helm
+	// writes above each rendered document, when the document has one.
+	Template string `json:"template,omitempty"`
+
+	// Chart is the chart Template belongs to, as the path of chart names from
+	// the top-level chart down, e.g. "platform/postgresql" for a template of
+	// the postgresql subchart of platform.
+	Chart string `json:"chart,omitempty"`
+}
+
+// IsZero reports whether no location is known.
+func (s Source) IsZero() bool {
+	return s == Source{}
+}
+
+// String renders the location as "file:line", falling back to the file alone
+// or "document N" when parts are unknown, followed by the template in
+// parentheses when there is one, e.g.
+// "rendered.yaml:12 (web/templates/deployment.yaml)".
+func (s Source) String() string {
+	var loc string
+	switch {
+	case s.File != "" && s.Line > 0:
+		loc = fmt.Sprintf("%s:%d", s.File, s.Line)
+	case s.File != "":
+		loc = s.File
+	case s.Line > 0:
+		loc = fmt.Sprintf("document %d, line %d", s.Document, s.Line)
+	case s.Document > 0:
+		loc = fmt.Sprintf("document %d", s.Document)
+	}
+	switch {
+	case s.Template == "":
+		return loc
+	case loc == "":
+		return s.Template
+	}
+	return fmt.Sprintf("%s (%s)", loc, s.Template)
+}
+
+// ChartOf returns the chart path of a rendered Helm template path, following
+// the charts/ directories of subcharts: "platform/templates/a.yaml" gives
+// "platform" and "platform/charts/postgresql/templates/b.yaml" gives
+// "platform/postgresql". It returns "" for paths that are not inside a
+// chart's templates/ or crds/ directory.
+func ChartOf(template string) string {
+	parts := strings.Split(template, "/")
+	chart := []string{parts[0]}
+	for i := 1; i < len(parts)-1; i += 2 {
+		switch parts[i] {
+		case "templates", "crds":
+			if chart[0] == "" {
+				return ""
+			}
+			return strings.Join(chart, "/")
+		case "charts":
+			chart = append(chart, parts[i+1])
+		default:
+			return ""
+		}
+	}
+	return ""
+}
@@ -0,0 +1,570 @@
+package parser
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/util/yaml"
+)
+
+// decoded is one object decoded from a document, or the problem that
+// prevented decoding it.
+type decoded struct {
+	obj *unstructured.Unstructured
+
+	// line is the stream line err points at; unset when err is nil.
+	line int
+	err  error
+}
+
+// objectStream yields the objects of one document. next returns io.EOF once
+// the document is exhausted, and any other error only for a failure to read
+// the underlying stream; problems with the document itself are returned in
+// decoded.err.
+type objectStream interface {
+	next() (decoded, error)
+}
+
+// newObjectStream returns the stream of objects in doc, whose lines are read
+// from dr. A document starting with "{" or "[" is decoded as JSON, anything
+// else as YAML.
+func newObjectStream(dr *documentReader, doc document) objectStream {
+	if first := bytes.TrimSpace(dr.unread[0]); first[0] == '{' || first[0] == '[' {
+		return newJSONStream(dr, doc)
+	}
+	return newYAMLStream(dr, doc)
+}
+
+// listItems tracks a List whose items are being decoded one at a time. Items
+// of typed lists such as a PodList may omit their apiVersion and kind; they
+// are filled in from the List. An item that needs a field the List has not
+// declared yet (fields may follow the items) is held, along with all items
+// after it to keep their order, until the List's fields are all read.
+type listItems struct {
+	apiVersion string
+	kind       string
+	held       []*unstructured.Unstructured
+}
+
+// mayBeList reports whether an object with the given top-level fields can be
+// a List: one whose kind is unknown so far counts.
+func mayBeList(fields map[string]interface{}) bool {
+	kind, _ := fields["kind"].(string)
+	return kind == "" || strings.HasSuffix(kind, "List")
+}
+
+// setFields records the List's apiVersion and kind from its top-level fields.
+func (l *listItems) setFields(fields map[string]interface{}) {
+	if apiVersion, ok := fields["apiVersion"].(string); ok {
+		l.apiVersion = apiVersion
+	}
+	if kind, ok := fields["kind"].(string); ok {
+		l.kind = kind
+	}
+}
+
+// add returns the objects a decoded item expands to, or nothing if the item
+// is held until finish.
+func (l *listItems) add(item *unstructured.Unstructured) []*unstructured.Unstructured {
+	if len(l.held) > 0 ||
+		(item.GetKind() == "" && l.kind == "") ||
+		(item.GetAPIVersion() == "" && l.apiVersion == "") {
+		l.held = append(l.held, item)
+		return nil
+	}
+	return l.fill(item)
+}
+
+// finish returns the held items once the List's fields are all read, and an
+// error if the document turned out not to be a List after all.
+func (l *listItems) finish() ([]*unstructured.Unstructured, error) {
+	var objs []*unstructured.Unstructured
+	for _, item := range l.held {
+		objs = append(objs, l.fill(item)...)
+	}
+	l.held = nil
+	if !strings.HasSuffix(l.kind, "List") {
+		return objs, fmt.Errorf("document has top-level items but its kind %q is not a List", l.kind)
+	}
+	return objs, nil
+}
+
+// fill sets the item's apiVersion and kind from the List where missing and
+// expands the item if it is itself a List.
+func (l *listItems) fill(item *unstructured.Unstructured) []*unstructured.Unstructured {
+	if itemKind := strings.TrimSuffix(l.kind, "List"); item.GetKind() == "" && itemKind != "" {
+		item.SetKind(itemKind)
+	}
+	if item.GetAPIVersion() == "" && l.apiVersion != "" {
+		item.SetAPIVersion(l.apiVersion)
+	}
+	return expandList(item)
+}
+
+// yamlStream decodes a YAML document. A document is decoded whole unless it
+// has a top-level "items:" block sequence and a kind that is a List or not
+// given before the items: then each item is decoded on its own as soon as its
+// lines have been read, and only the List's other fields are kept together.
+type yamlStream struct {
+	dr   *documentReader
+	doc  document
+	line int
+
+	state yamlState
+
+	// head holds the document's lines before "items:", or all of them if it
+	// has none; tail holds the lines after the items.
+	head     bytes.Buffer
+	tail     bytes.Buffer
+	tailLine int
+
+	// itemsKey is the "items:" line, put back into head if items turns out
+	// not to be a block sequence.
+	itemsKey []byte
+
+	// indent is the column of the items' "-", or -1 until it is known.
+	indent   int
+	item     bytes.Buffer
+	itemLine int
+
+	list *listItems
+	out  []decoded
+	done bool
+}
+
+// yamlState is the part of the document a yamlStream is reading.
+type yamlState int
+
+const (
+	yamlHead yamlState = iota
+	yamlItems
+	yamlTail
+)
+
+func newYAMLStream(dr *documentReader, doc document) *yamlStream {
+	return &yamlStream{dr: dr, doc: doc, line: doc.line - 1}
+}
+
+func (s *yamlStream) next() (decoded, error) {
+	for {
+		if len(s.out) > 0 {
+			next := s.out[0]
+			s.out = s.out[1:]
+			return next, nil
+		}
+		if s.done {
+			return decoded{}, io.EOF
+		}
+		line, ok, err := s.dr.line()
+		if err != nil {
+			return decoded{}, err
+		}
+		if !ok {
+			s.finish()
+			s.done = true
+			continue
+		}
+		s.line++
+		s.add(line)
+	}
+}
+
+// add takes the next line of the document.
+func (s *yamlStream) add(line []byte) {
+	switch s.state {
+	case yamlHead:
+		if !isItemsKey(line) {
+			s.head.Write(line)
+			return
+		}
+		fields, err := decodeYAML(s.head.Bytes())
+		if err != nil || !mayBeList(fields) {
+			s.head.Write(line)
+			return
+		}
+		s.list = &listItems{}
+		s.list.setFields(fields)
+		s.state, s.itemsKey, s.indent = yamlItems, line, -1
+
+	case yamlItems:
+		if !hasContent(line) {
+			if s.item.Len() > 0 {
+				s.item.Write(line)
+			}
+			return
+		}
+		indent := len(line) - len(bytes.TrimLeft(line, " "))
+		entry := isSequenceEntry(line[indent:])
+		if s.indent < 0 {
+			if !entry {
+				s.notList()
+				s.head.Write(line)
+				return
+			}
+			s.indent = indent
+		}
+		switch {
+		case indent == s.indent && entry:
+			s.flushItem()
+			s.itemLine = s.line
+			// Blanking the "-" leaves the item a mapping at its own indent.
+			line[indent] = ' '
+			s.item.Write(line)
+		case indent > s.indent:
+			s.item.Write(line)
+		default:
+			s.flushItem()
+			s.state, s.tailLine = yamlTail, s.line
+			s.tail.Write(line)
+		}
+
+	case yamlTail:
+		s.tail.Write(line)
+	}
+}
+
+// notList puts the "items:" line back into head, to decode the document whole.
+func (s *yamlStream) notList() {
+	s.head.Write(s.itemsKey)
+	s.state, s.list = yamlHead, nil
+}
+
+// flushItem decodes the item read so far, if any.
+func (s *yamlStream) flushItem() {
+	if s.item.Len() == 0 {
+		return
+	}
+	defer s.item.Reset()
+	fields, err := decodeYAML(s.item.Bytes())
+	if err != nil {
+		s.out = append(s.out, decoded{line: errorLine(s.itemLine, err), err: err})
+		return
+	}
+	if len(fields) > 0 {
+		s.emit(s.list.add(&unstructured.Unstructured{Object: fields}))
+	}
+}
+
+// finish decodes what is left once the whole document has been read.
+func (s *yamlStream) finish() {
+	if s.state == yamlItems && s.indent < 0 {
+		s.notList()
+	}
+	if s.list == nil {
+		fields, err := decodeYAML(s.head.Bytes())
+		if err != nil {
+			s.out = append(s.out, decoded{line: errorLine(s.doc.line, err), err: err})
+			return
+		}
+		if len(fields) > 0 {
+			s.emit(expandList(&unstructured.Unstructured{Object: fields}))
+		}
+		return
+	}
+
+	s.flushItem()
+	fields, err := decodeYAML(s.tail.Bytes())
+	if err != nil {
+		s.out = append(s.out, decoded{line: errorLine(s.tailLine, err), err: err})
+	}
+	s.list.setFields(fields)
+	objs, err := s.list.finish()
+	if err != nil {
+		s.out = append(s.out, decoded{line: s.doc.line, err: err})
+	}
+	s.emit(objs)
+}
+
+func (s *yamlStream) emit(objs []*unstructured.Unstructured) {
+	for _, obj := range objs {
+		s.out = append(s.out, decoded{obj: obj})
+	}
+}
+
+// decodeYAML decodes a single YAML object; it returns nil for empty input.
+func decodeYAML(data []byte) (map[string]interface{}, error) {
+	var fields map[string]interface{}
+	err := yaml.NewYAMLOrJSONDecoder(bytes.NewReader(data), BUFFER_BYTES).Decode(&fields)
+	if errors.Is(err, io.EOF) {
+		return nil, nil
+	}
+	return fields, err
+}
+
+// isItemsKey reports a top-level "items:" key with its value on the lines
+// that follow.
+func isItemsKey(line []byte) bool {
+	rest, ok := bytes.CutPrefix(line, []byte("items:"))
+	if !ok {
+		return false
+	}
+	rest = bytes.TrimSpace(rest)
+	return len(rest) == 0 || rest[0] == '#'
+}
+
+// isSequenceEntry reports text starting with a block sequence entry's "-".
+func isSequenceEntry(text []byte) bool {
+	rest, ok := bytes.CutPrefix(text, []byte("-"))
+	return ok && (len(rest) == 0 || rest[0] == ' ' || rest[0] == '\t' || rest[0] == '\r' || rest[0] == '\n')
+}
+
+// yamlErrorLine extracts the line relative to the decoded text from YAML
+// syntax errors such as "yaml: line 3: mapping values are not allowed in
+// this context".
+var yamlErrorLine = regexp.MustCompile(`line (\d+)`)
+
+// errorLine maps a YAML decode error in text starting at the given stream
+// line to the stream line it points at, or to start if it carries no line.
+func errorLine(start int, err error) int {
+	if m := yamlErrorLine.FindStringSubmatch(err.Error()); m != nil {
+		if n, convErr := strconv.Atoi(m[1]); convErr == nil && n > 0 {
+			return start + n - 1
+		}
+	}
+	return start
+}
+
+// jsonStream decodes a JSON document, which may hold several objects, token
+// by token: the elements of a top-level "items" array are decoded one at a
+// time unless the object's kind, given before them, is not a List.
+type jsonStream struct {
+	dr  *documentReader
+	doc document
+	in  *jsonInput
+	dec *json.Decoder
+
+	// fields holds the top-level fields of the object being read.
+	fields   map[string]interface{}
+	inObject bool
+	inItems  bool
+
+	// yaml takes over if the document turns out not to be JSON.
+	yaml *yamlStream
+
+	list *listItems
+	out  []decoded
+	done bool
+}
+
+func newJSONStream(dr *documentReader, doc document) *jsonStream {
+	in := &jsonInput{dr: dr, line: doc.line - 1, record: true}
+	return &jsonStream{dr: dr, doc: doc, in: in, dec: json.NewDecoder(in)}
+}
+
+func (s *jsonStream) next() (decoded, error) {
+	for {
+		if s.yaml != nil {
+			return s.yaml.next()
+		}
+		if len(s.out) > 0 {
+			next := s.out[0]
+			s.out = s.out[1:]
+			return next, nil
+		}
+		if s.done {
+			return decoded{}, io.EOF
+		}
+		err := s.step()
+		if err == nil {
+			continue
+		}
+		if s.in.err != nil {
+			return decoded{}, s.in.err
+		}
+		if s.in.record {
+			// Like the Kubernetes YAML-or-JSON decoder, fall back to YAML
+			// for a document that only looked like JSON, e.g. a flow mapping.
+			s.dr.giveBack(s.in.recorded)
+			s.yaml = newYAMLStream(s.dr, s.doc)
+			continue
+		}
+		s.done = true
+		s.out = append(s.out, decoded{line: s.in.line, err: err})
+	}
+}
+
+// step consumes the next token or value of the document.
+func (s *jsonStream) step() error {
+	switch {
+	case s.inItems:
+		if !s.dec.More() {
+			s.inItems = false
+			_, err := s.dec.Token()
+			return err
+		}
+		var item interface{}
+		if err := s.dec.Decode(&item); err != nil {
+			return err
+		}
+		fields, ok := item.(map[string]interface{})
+		if !ok {
+			s.out = append(s.out, decoded{line: s.in.line, err: fmt.Errorf("List item is not an object")})
+			return nil
+		}
+		if len(fields) > 0 {
+			s.emit(s.list.add(&unstructured.Unstructured{Object: fields}))
+		}
+		return nil
+
+	case s.inObject:
+		if !s.dec.More() {
+			s.inObject = false
+			if _, err := s.dec.Token(); err != nil {
+				return err
+			}
+			s.finishObject()
+			return nil
+		}
+		tok, err := s.dec.Token()
+		if err != nil {
+			return err
+		}
+		s.in.stopRecording()
+		key := tok.(string)
+		var value interface{}
+		if key == "items" && mayBeList(s.fields) {
+			tok, err := s.dec.Token()
+			if err != nil {
+				return err
+			}
+			if tok == json.Delim('[') {
+				s.list = &listItems{}
+				s.list.setFields(s.fields)
+				s.inItems = true
+				return nil
+			}
+			value, err = decodeFrom(s.dec, tok)
+			if err != nil {
+				return err
+			}
+		} else if err := s.dec.Decode(&value); err != nil {
+			return err
+		}
+		s.fields[key] = value
+		if s.list != nil {
+			s.list.setFields(s.fields)
+		}
+		return nil
+
+	default:
+		tok, err := s.dec.Token()
+		if errors.Is(err, io.EOF) {
+			s.done = true
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		if tok != json.Delim('{') {
+			return fmt.Errorf("expected a JSON object, found %v", tok)
+		}
+		s.inObject, s.fields, s.list = true, map[string]interface{}{}, nil
+		return nil
+	}
+}
+
+// finishObject emits a top-level object once its closing brace is read.
+func (s *jsonStream) finishObject() {
+	if s.list == nil {
+		if len(s.fields) > 0 {
+			s.emit(expandList(&unstructured.Unstructured{Object: s.fields}))
+		}
+		return
+	}
+	objs, err := s.list.finish()
+	if err != nil {
+		s.out = append(s.out, decoded{line: s.doc.line, err: err})
+	}
+	s.emit(objs)
+}
+
+func (s *jsonStream) emit(objs []*unstructured.Unstructured) {
+	for _, obj := range objs {
+		s.out = append(s.out, decoded{obj: obj})
+	}
+}
+
+// decodeFrom decodes the rest of a value whose first token has been read.
+func decodeFrom(dec *json.Decoder, tok json.Token) (interface{}, error) {
+	switch tok {
+	case json.Delim('{'):
+		fields := map[string]interface{}{}
+		for dec.More() {
+			key, err := dec.Token()
+			if err != nil {
+				return nil, err
+			}
+			var value interface{}
+			if err := dec.Decode(&value); err != nil {
+				return nil, err
+			}
+			fields[key.(string)] = value
+		}
+		_, err := dec.Token()
+		return fields, err
+	case json.Delim('['):
+		values := []interface{}{}
+		for dec.More() {
+			var value interface{}
+			if err := dec.Decode(&value); err != nil {
+				return nil, err
+			}
+			values = append(values, value)
+		}
+		_, err := dec.Token()
+		return values, err
+	}
+	return tok, nil
+}
+
+// jsonInput feeds a document's lines to a json.Decoder one line per Read, so
+// that when decoding fails the last line read is the one at fault. Until the
+// first key has been read it records the lines, to give them back should the
+// document not be JSON after all.
+type jsonInput struct {
+	dr      *documentReader
+	pending []byte
+
+	// line is the stream line of the last line read.
+	line int
+
+	// err is the first error reading the underlying stream.
+	err error
+
+	record   bool
+	recorded [][]byte
+}
+
+func (in *jsonInput) Read(p []byte) (int, error) {
+	for len(in.pending) == 0 {
+		line, ok, err := in.dr.line()
+		if err != nil {
+			in.err = err
+			return 0, err
+		}
+		if !ok {
+			return 0, io.EOF
+		}
+		in.line++
+		if in.record {
+			in.recorded = append(in.recorded, line)
+		}
+		in.pending = line
+	}
+	n := copy(p, in.pending)
+	in.pending = in.pending[n:]
+	return n, nil
+}
+
+// stopRecording drops the recorded lines once the document is known to be
+// JSON.
+func (in *jsonInput) stopRecording() {
+	in.record, in.recorded = false, nil
+}